@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -24,6 +25,7 @@ type ArrangerAgent struct {
 	useMCP        bool // If true, Pro arranger with MCP tools; if false, Basic arranger
 	mcpURL        string
 	mcpLabel      string
+	maxRepairs    int // Times rejected DSL is sent back to the model (0 = no repair)
 }
 
 // NewBasicArrangerAgent creates a basic arranger agent (functional, no MCP)
@@ -54,6 +56,7 @@ func newArrangerAgent(cfg *config.Config, useMCP bool, mcpURL, mcpLabel string)
 		useMCP:        useMCP,
 		mcpURL:        mcpURL,
 		mcpLabel:      mcpLabel,
+		maxRepairs:    cfg.DSLRepairAttempts,
 	}
 
 	agentType := "Basic"
@@ -77,10 +80,12 @@ func newArrangerAgent(cfg *config.Config, useMCP bool, mcpURL, mcpLabel string)
 }
 
 type ArrangerResult struct {
-	Actions  []map[string]any `json:"actions"` // Parsed DSL actions
-	Usage    any              `json:"usage"`
-	MCPUsed  bool             `json:"mcpUsed,omitempty"`
-	MCPCalls int              `json:"mcpCalls,omitempty"`
+	Actions  []map[string]any    `json:"actions"` // Parsed DSL actions
+	Usage    any                 `json:"usage"`
+	MCPUsed  bool                `json:"mcpUsed,omitempty"`
+	MCPCalls int                 `json:"mcpCalls,omitempty"`
+	DSL      string              `json:"dsl,omitempty"`     // Accepted DSL code
+	Repairs  []llm.RepairAttempt `json:"repairs,omitempty"` // Rejected DSL sent back for repair, oldest first
 }
 
 // GenerateActions generates musical content using chord symbols
@@ -108,6 +113,150 @@ func (a *ArrangerAgent) GenerateActions(
 		"mcp_enabled":     a.useMCP,
 	})
 
+	request := a.buildRequest(question)
+
+	log.Printf("🔧 Using DSL mode (CFG grammar) - Arranger DSL")
+
+	// Call provider
+	log.Printf("🚀 ARRANGER PROVIDER REQUEST: %s", a.provider.Name())
+
+	// Generate and parse, sending rejected DSL back to the model when repair is enabled
+	repair, err := llm.GenerateWithRepair(ctx, a.provider, request, a.maxRepairs, a.parseActionsFromResponse)
+	if a.maxRepairs > 0 && repair != nil && len(repair.History) > 0 {
+		a.metrics.RecordDSLRepair(ctx, "arranger", repair.Repairs, err == nil)
+	}
+	if err != nil {
+		transaction.SetTag("success", "false")
+		sentry.CaptureException(err)
+		if errors.Is(err, llm.ErrProviderFailed) {
+			transaction.SetTag("error_type", "provider_error")
+			return nil, err
+		}
+		transaction.SetTag("error_type", "parse_error")
+		return nil, fmt.Errorf("failed to parse actions: %w", err)
+	}
+	actions := repair.Actions
+	resp := repair.Response
+
+	result := &ArrangerResult{
+		Actions:  actions,
+		Usage:    resp.Usage,
+		MCPUsed:  resp.MCPUsed,
+		MCPCalls: resp.MCPCalls,
+		DSL:      repair.DSL,
+		Repairs:  repair.History,
+	}
+
+	// Mark transaction as successful
+	transaction.SetTag("success", "true")
+	transaction.SetTag("actions_count", fmt.Sprintf("%d", len(actions)))
+
+	// Record metrics
+	duration := time.Since(startTime)
+	a.metrics.RecordGenerationDuration(ctx, duration, true)
+
+	// Record token usage if available
+	if result.Usage != nil {
+		if usage, ok := result.Usage.(responses.ResponseUsage); ok {
+			reasoningTokens := int(usage.OutputTokensDetails.ReasoningTokens)
+			a.metrics.RecordTokenUsage(ctx, "gpt-5.1",
+				int(usage.TotalTokens),
+				int(usage.InputTokens),
+				int(usage.OutputTokens),
+				reasoningTokens)
+		}
+	}
+
+	log.Printf("✅ ARRANGER REQUEST COMPLETE: actions=%d, duration=%v", len(actions), duration)
+
+	return result, nil
+}
+
+// StreamActionCallback is called for each arranger action as soon as it has
+// been parsed from the stream
+type StreamActionCallback func(action map[string]any) error
+
+// GenerateActionsStream generates musical content like GenerateActions, but
+// passes each arranger call to callback as soon as the model has written it.
+// From the first chord() on, actions arrive when the stream ends, since a
+// later arpeggio of the same chord replaces it. Providers without streaming fall back to
+// GenerateActions, with callback called for each action afterwards.
+// Streamed output is not repaired: a rejected statement ends the stream, and
+// actions already passed to callback stand.
+func (a *ArrangerAgent) GenerateActionsStream(
+	ctx context.Context, question string, callback StreamActionCallback,
+) (*ArrangerResult, error) {
+	provider, ok := a.provider.(llm.StreamingProvider)
+	if !ok {
+		log.Printf("⚠️  Provider %s does not support streaming, using GenerateActions", a.provider.Name())
+		result, err := a.GenerateActions(ctx, question)
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range result.Actions {
+			if err := callback(action); err != nil {
+				return nil, fmt.Errorf("stream callback: %w", err)
+			}
+		}
+		return result, nil
+	}
+
+	startTime := time.Now()
+	log.Printf("🎵 ARRANGER STREAMING REQUEST STARTED: question=%s", question)
+
+	// Start Sentry transaction
+	transaction := sentry.StartTransaction(ctx, "arranger.generate_actions_stream")
+	defer transaction.Finish()
+
+	transaction.SetTag("model", "gpt-5.1")
+	transaction.SetTag("streaming", "true")
+
+	parser, err := NewArrangerDSLParser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DSL parser: %w", err)
+	}
+	stream := parser.Stream(ctx, callback)
+
+	log.Printf("🚀 ARRANGER PROVIDER STREAM REQUEST: %s", a.provider.Name())
+	resp, err := llm.StreamDSL(ctx, provider, a.buildRequest(question), stream.Write)
+	var actions []map[string]any
+	if err == nil {
+		actions, err = stream.Close()
+	}
+	if err != nil {
+		transaction.SetTag("success", "false")
+		sentry.CaptureException(err)
+		if errors.Is(err, llm.ErrProviderFailed) {
+			transaction.SetTag("error_type", "provider_error")
+			return nil, err
+		}
+		transaction.SetTag("error_type", "parse_error")
+		return nil, fmt.Errorf("failed to parse actions: %w", err)
+	}
+
+	result := &ArrangerResult{
+		Actions: actions,
+		DSL:     stream.splitter.Source(),
+	}
+	if resp != nil {
+		result.Usage = resp.Usage
+		result.MCPUsed = resp.MCPUsed
+		result.MCPCalls = resp.MCPCalls
+	}
+
+	transaction.SetTag("success", "true")
+	transaction.SetTag("actions_count", fmt.Sprintf("%d", len(actions)))
+
+	duration := time.Since(startTime)
+	a.metrics.RecordGenerationDuration(ctx, duration, true)
+
+	log.Printf("✅ ARRANGER STREAMING REQUEST COMPLETE: actions=%d, duration=%v", len(actions), duration)
+
+	return result, nil
+}
+
+// buildRequest builds the provider request with the arranger CFG grammar
+func (a *ArrangerAgent) buildRequest(question string) *llm.GenerationRequest {
 	// Build input messages
 	inputArray := a.buildInputMessages(question)
 
@@ -152,58 +301,7 @@ func (a *ArrangerAgent) GenerateActions(
 		}
 	}
 
-	log.Printf("🔧 Using DSL mode (CFG grammar) - Arranger DSL")
-
-	// Call provider
-	log.Printf("🚀 ARRANGER PROVIDER REQUEST: %s", a.provider.Name())
-
-	resp, err := a.provider.Generate(ctx, request)
-	if err != nil {
-		transaction.SetTag("success", "false")
-		transaction.SetTag("error_type", "provider_error")
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("provider request failed: %w", err)
-	}
-
-	// Parse actions from DSL response
-	actions, err := a.parseActionsFromResponse(resp)
-	if err != nil {
-		transaction.SetTag("success", "false")
-		transaction.SetTag("error_type", "parse_error")
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("failed to parse actions: %w", err)
-	}
-
-	result := &ArrangerResult{
-		Actions:  actions,
-		Usage:    resp.Usage,
-		MCPUsed:  resp.MCPUsed,
-		MCPCalls: resp.MCPCalls,
-	}
-
-	// Mark transaction as successful
-	transaction.SetTag("success", "true")
-	transaction.SetTag("actions_count", fmt.Sprintf("%d", len(actions)))
-
-	// Record metrics
-	duration := time.Since(startTime)
-	a.metrics.RecordGenerationDuration(ctx, duration, true)
-
-	// Record token usage if available
-	if result.Usage != nil {
-		if usage, ok := result.Usage.(responses.ResponseUsage); ok {
-			reasoningTokens := int(usage.OutputTokensDetails.ReasoningTokens)
-			a.metrics.RecordTokenUsage(ctx, "gpt-5.1",
-				int(usage.TotalTokens),
-				int(usage.InputTokens),
-				int(usage.OutputTokens),
-				reasoningTokens)
-		}
-	}
-
-	log.Printf("✅ ARRANGER REQUEST COMPLETE: actions=%d, duration=%v", len(actions), duration)
-
-	return result, nil
+	return request
 }
 
 // buildInputMessages constructs the input array for the LLM
@@ -231,7 +329,7 @@ func (a *ArrangerAgent) parseActionsFromResponse(resp *llm.GenerationResponse) (
 	// Parse DSL using Grammar School engine
 	parser, err := NewArrangerDSLParser()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create DSL parser: %w", err)
+		return nil, llm.Permanent(fmt.Errorf("failed to create DSL parser: %w", err))
 	}
 
 	actions, err := parser.ParseDSL(resp.RawOutput)
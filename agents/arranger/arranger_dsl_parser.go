@@ -8,6 +8,8 @@ import (
 
 	"github.com/Conceptual-Machines/grammar-school-go/gs"
 	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
 )
 
 // ArrangerDSLParser parses Arranger DSL code with chord symbols.
@@ -17,6 +19,8 @@ type ArrangerDSLParser struct {
 	arrangerDSL *ArrangerDSL
 	actions     []map[string]any
 	rawDSL      string // Store raw DSL for manual parsing (Grammar School has array issues)
+	checker     *diagnostics.Checker
+	diags       []diagnostics.Diagnostic // Diagnostics of the last script
 }
 
 // ArrangerDSL implements the DSL methods for musical composition.
@@ -46,30 +50,50 @@ func NewArrangerDSLParser() (*ArrangerDSLParser, error) {
 	}
 
 	parser.engine = engine
+	parser.checker = diagnostics.NewChecker(grammar, parser.arrangerDSL)
 
 	return parser, nil
 }
 
+// Diagnostics returns the warnings found in the last script passed to ParseDSL.
+// When ParseDSL fails, they are carried by the returned *diagnostics.Error.
+func (p *ArrangerDSLParser) Diagnostics() []diagnostics.Diagnostic {
+	return p.diags
+}
+
 // ParseDSL parses DSL code and returns arranger actions.
 func (p *ArrangerDSLParser) ParseDSL(dslCode string) ([]map[string]any, error) {
 	if dslCode == "" {
 		return nil, fmt.Errorf("empty DSL code")
 	}
 
-	// Store raw DSL for manual parsing (Grammar School has issues with arrays)
-	p.rawDSL = dslCode
-
 	// Reset actions for new parse
 	p.actions = make([]map[string]any, 0)
 
-	// Execute DSL code using Grammar School Engine
+	// Syntax errors and unknown methods stop the script before anything runs
+	p.diags = p.checker.Check(dslCode)
+	if first, ok := diagnostics.First(p.diags); ok {
+		return nil, diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %s", first))
+	}
+	for _, diag := range p.diags {
+		log.Printf("⚠️  Arranger DSL %s", diag)
+	}
+
+	// Execute each statement separately so a failure can be traced to its source
 	ctx := context.Background()
-	if err := p.engine.Execute(ctx, dslCode); err != nil {
-		return nil, fmt.Errorf("failed to execute DSL: %w", err)
+	for _, statement := range dslscan.Split(dslCode, false) {
+		// Progression reads its chords from the raw statement
+		p.rawDSL = dslCode[statement.Start:statement.End]
+		if err := p.engine.Execute(ctx, p.rawDSL); err != nil {
+			p.diags = append(p.diags, p.checker.Explain(dslCode, statement.Start, statement.End, err))
+			return nil, diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %w", err))
+		}
 	}
 
 	if len(p.actions) == 0 {
-		return nil, fmt.Errorf("no actions found in DSL code")
+		err := fmt.Errorf("no actions found in DSL code")
+		p.diags = append(p.diags, p.checker.Explain(dslCode, 0, len(dslCode), err))
+		return nil, diagnostics.NewError(dslCode, p.diags, err)
 	}
 
 	// Post-process: Filter out redundant chord actions when arpeggio exists
@@ -118,6 +142,126 @@ func (p *ArrangerDSLParser) filterRedundantChords(actions []map[string]any) []ma
 	return filtered
 }
 
+// ArrangerDSLStream executes Arranger DSL while its text is still arriving,
+// e.g. from a streaming LLM response. Each call runs as soon as its ";"
+// arrives and its action is passed to the callback.
+//
+// From the first chord() on, actions are held back until Close, because a
+// later arpeggio() of the same chord replaces it (see filterRedundantChords)
+// and callers rely on the order of actions to place them in time.
+type ArrangerDSLStream struct {
+	parser   *ArrangerDSLParser
+	ctx      context.Context
+	splitter dslscan.Splitter
+	callback func(action map[string]any) error
+	emitted  int  // Actions passed to the callback
+	holding  bool // A chord has been parsed; later actions wait for Close
+	err      error
+}
+
+// Stream starts an incremental parse of a new script. If callback returns an
+// error the stream stops and Write returns it.
+func (p *ArrangerDSLParser) Stream(ctx context.Context, callback func(action map[string]any) error) *ArrangerDSLStream {
+	p.actions = make([]map[string]any, 0)
+	p.diags = nil
+	return &ArrangerDSLStream{parser: p, ctx: ctx, callback: callback}
+}
+
+// Write appends a chunk of DSL text and executes every statement it
+// completes. After an error, Write and Close return that error.
+func (s *ArrangerDSLStream) Write(chunk string) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, statement := range s.splitter.Write(chunk) {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Close executes the last statement, emits the held back actions without the
+// chords replaced by an arpeggio, and returns every action of the script.
+func (s *ArrangerDSLStream) Close() ([]map[string]any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, statement := range s.splitter.Close() {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return nil, err
+		}
+	}
+
+	p := s.parser
+	dslCode := s.splitter.Source()
+	if len(p.actions) == 0 {
+		err := fmt.Errorf("no actions found in DSL code")
+		p.diags = append(p.diags, p.checker.Explain(dslCode, 0, len(dslCode), err))
+		s.err = diagnostics.NewError(dslCode, p.diags, err)
+		return nil, s.err
+	}
+
+	// Only chords are filtered, so the actions already emitted keep their place
+	p.actions = p.filterRedundantChords(p.actions)
+	if s.callback != nil {
+		for _, action := range p.actions[s.emitted:] {
+			if err := s.callback(action); err != nil {
+				s.err = fmt.Errorf("stream callback: %w", err)
+				return nil, s.err
+			}
+		}
+	}
+	s.emitted = len(p.actions)
+
+	log.Printf("✅ Arranger DSL Parser: Streamed %d actions from DSL", len(p.actions))
+	return p.actions, nil
+}
+
+// run checks and executes one statement, then passes its actions to the
+// callback unless a chord is being held back. Only the statement is checked,
+// with diagnostic spans pointing into the whole script.
+func (s *ArrangerDSLStream) run(statement dslscan.Statement) error {
+	p := s.parser
+	dslCode := s.splitter.Source()[:statement.End]
+
+	for _, diag := range p.checker.CheckRange(dslCode, statement.Start, statement.End) {
+		p.diags = append(p.diags, diag)
+		if diag.Severity == diagnostics.SeverityError {
+			return diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %s", diag))
+		}
+		log.Printf("⚠️  Arranger DSL %s", diag)
+	}
+
+	// Progression reads its chords from the raw statement
+	p.rawDSL = dslCode[statement.Start:]
+
+	first := len(p.actions)
+	if err := p.engine.Execute(s.ctx, p.rawDSL); err != nil {
+		p.diags = append(p.diags, p.checker.Explain(dslCode, statement.Start, statement.End, err))
+		return diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %w", err))
+	}
+
+	if s.callback == nil {
+		return nil
+	}
+	for _, action := range p.actions[first:] {
+		if action["type"] == "chord" {
+			s.holding = true
+		}
+		if s.holding {
+			return nil
+		}
+		if err := s.callback(action); err != nil {
+			return fmt.Errorf("stream callback: %w", err)
+		}
+		s.emitted++
+	}
+	return nil
+}
+
 // ========== Side-effect methods (ArrangerDSL) ==========
 
 // Arpeggio handles arpeggio() calls.
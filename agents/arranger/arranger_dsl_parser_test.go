@@ -1,7 +1,11 @@
 package services
 
 import (
+	"context"
+	"slices"
 	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
 )
 
 func TestArrangerDSLParser_Arpeggio(t *testing.T) {
@@ -219,6 +223,28 @@ func TestArrangerDSLParser_Progression(t *testing.T) {
 	}
 }
 
+func TestArrangerDSLParser_TwoProgressions(t *testing.T) {
+	parser, err := NewArrangerDSLParser()
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+
+	actions, err := parser.ParseDSL(`progression(chords=[C, Am, F, G], length=16); progression(chords=[Dm, G, C], length=12)`)
+	if err != nil {
+		t.Fatalf("ParseDSL failed: %v", err)
+	}
+	if len(actions) != 2 {
+		t.Fatalf("Expected 2 actions, got %d", len(actions))
+	}
+
+	for i, expected := range [][]string{{"C", "Am", "F", "G"}, {"Dm", "G", "C"}} {
+		chords, _ := actions[i]["chords"].([]string)
+		if !slices.Equal(chords, expected) {
+			t.Errorf("Progression %d: expected chords %v, got %v", i+1, expected, chords)
+		}
+	}
+}
+
 func TestArrangerDSLParser_NoteDuration(t *testing.T) {
 	parser, err := NewArrangerDSLParser()
 	if err != nil {
@@ -335,3 +361,102 @@ func TestArrangerDSLParser_Note(t *testing.T) {
 		})
 	}
 }
+
+func TestArrangerDSLParser_Diagnostics(t *testing.T) {
+	parser, err := NewArrangerDSLParser()
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+
+	// Unknown parameters are warnings; arpeggio still finds the symbol
+	if _, err := parser.ParseDSL(`arpeggio(symbl=Em, length=2)`); err != nil {
+		t.Fatalf("ParseDSL failed: %v", err)
+	}
+	warnings := parser.Diagnostics()
+	if len(warnings) != 1 || warnings[0].Kind != diagnostics.KindUnknownParameter || warnings[0].Parameter != "symbl" {
+		t.Fatalf("Expected unknown parameter 'symbl', got %+v", warnings)
+	}
+	if len(warnings[0].Suggestions) == 0 || warnings[0].Suggestions[0] != "symbol" {
+		t.Errorf("Expected suggestion 'symbol', got %v", warnings[0].Suggestions)
+	}
+
+	// Execution errors point at the failing statement
+	_, err = parser.ParseDSL(`chord(symbol=C, length=4); note(duration=4)`)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+	diagErr, ok := diagnostics.AsError(err)
+	if !ok {
+		t.Fatalf("Expected *diagnostics.Error, got %T", err)
+	}
+	if len(diagErr.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %d: %s", len(diagErr.Diagnostics), diagErr.Format())
+	}
+	failure := diagErr.Diagnostics[0]
+	if failure.Method != "note" || failure.Message != "note: missing pitch" || failure.Span.Start.Column != 28 {
+		t.Errorf("Expected missing pitch at note (column 28), got %+v", failure)
+	}
+}
+
+func TestArrangerDSLParser_Stream(t *testing.T) {
+	collect := func(t *testing.T, chunks ...string) ([]string, []int, []map[string]any) {
+		t.Helper()
+		parser, err := NewArrangerDSLParser()
+		if err != nil {
+			t.Fatalf("Failed to create parser: %v", err)
+		}
+
+		var types []string
+		var emittedAt []int
+		stream := parser.Stream(context.Background(), func(action map[string]any) error {
+			types = append(types, action["type"].(string))
+			return nil
+		})
+		for _, chunk := range chunks {
+			if err := stream.Write(chunk); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			emittedAt = append(emittedAt, len(types))
+		}
+		actions, err := stream.Close()
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return types, emittedAt, actions
+	}
+
+	t.Run("calls are emitted as they complete", func(t *testing.T) {
+		types, emittedAt, actions := collect(t,
+			`note(pitch="E1", duration=4); progression(chords=[C, Am`,
+			`, F, G], length=16); arpeggio(symbol=Em, `,
+			`note_duration=0.25, length=4)`,
+		)
+		if want := []int{1, 2, 2}; !slices.Equal(emittedAt, want) {
+			t.Errorf("Expected actions emitted after each chunk %v, got %v", want, emittedAt)
+		}
+		if want := []string{"note", "progression", "arpeggio"}; !slices.Equal(types, want) {
+			t.Errorf("Expected %v, got %v", want, types)
+		}
+		chords, _ := actions[1]["chords"].([]string)
+		if !slices.Equal(chords, []string{"C", "Am", "F", "G"}) {
+			t.Errorf("Expected progression chords from its own statement, got %v", chords)
+		}
+	})
+
+	t.Run("chords wait for the end of the stream", func(t *testing.T) {
+		types, emittedAt, actions := collect(t,
+			`note(pitch="C2", duration=4); chord(symbol=C, length=4); `,
+			`note(pitch="G2", duration=4); arpeggio(symbol=C, length=4); chord(symbol=F, length=4)`,
+		)
+		if want := []int{1, 1}; !slices.Equal(emittedAt, want) {
+			t.Errorf("Expected actions emitted after each chunk %v, got %v", want, emittedAt)
+		}
+		// The C chord is replaced by the C arpeggio
+		if want := []string{"note", "note", "arpeggio", "chord"}; !slices.Equal(types, want) {
+			t.Errorf("Expected %v, got %v", want, types)
+		}
+		if len(actions) != len(types) {
+			t.Errorf("Expected %d actions, got %d", len(types), len(actions))
+		}
+	})
+}
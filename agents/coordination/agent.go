@@ -0,0 +1,209 @@
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+)
+
+// Agent is an agent the Orchestrator can route requests to. The built-in DAW,
+// arranger and drummer agents and agents supplied by the host application
+// implement it the same way and are added with Orchestrator.Register.
+type Agent interface {
+	// Descriptor describes the agent to the router and the merge step
+	Descriptor() AgentDescriptor
+
+	// Run handles a request. When emit is non-nil, partial output is passed to
+	// it as soon as it is available; the returned output holds all of it.
+	Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error)
+}
+
+// AgentEmitFunc receives partial agent output while an agent is running
+type AgentEmitFunc func(output *AgentOutput) error
+
+// AgentRequest is the input of an orchestrated agent run
+type AgentRequest struct {
+	Question string         `json:"question"`
+	State    map[string]any `json:"state,omitempty"`  // Current REAPER state, with tracks created by earlier steps
+	Target   *StepTarget    `json:"target,omitempty"` // Track and time range the output goes to, if planned
+}
+
+// AgentOutput is what an agent contributes to the orchestrated result
+type AgentOutput struct {
+	Actions []map[string]any   `json:"actions,omitempty"` // DAW actions, merged as-is
+	Notes   []models.NoteEvent `json:"notes,omitempty"`   // MIDI notes, merged into add_midi on the target track
+	Usage   any                `json:"usage,omitempty"`
+}
+
+// AgentDescriptor describes an agent to the router and the merge step
+type AgentDescriptor struct {
+	Name        string           `json:"name"`        // Unique identifier, also the router's JSON field
+	Description string           `json:"description"` // What the agent does, for the router prompt
+	Examples    []RoutingExample `json:"examples,omitempty"`
+	Keywords    []string         `json:"keywords,omitempty"` // Words that route a request here without asking the LLM
+
+	// KeywordPatterns route a request here when they match its text, for what
+	// keywords can't express (note names like "C4")
+	KeywordPatterns []*regexp.Regexp `json:"-"`
+
+	AlwaysRun  bool `json:"alwaysRun,omitempty"`  // Runs for every request without asking the router
+	Required   bool `json:"required,omitempty"`   // Failure fails the whole request; creates the tracks and clips notes go to
+	NeedsTrack bool `json:"needsTrack,omitempty"` // Output goes on a track, so a track-creating agent must run too
+}
+
+// RoutingExample is a request the router should (or should not) send to an agent
+type RoutingExample struct {
+	Request string `json:"request"`
+	Needed  bool   `json:"needed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// agentNamePattern matches valid agent names, which are also JSON fields
+var agentNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AgentRegistry holds the agents an Orchestrator can route to. Agents run and
+// their output is merged in registration order.
+type AgentRegistry struct {
+	agents   []Agent
+	byName   map[string]Agent
+	keywords *keywordIndex
+}
+
+// NewAgentRegistry creates an empty agent registry
+func NewAgentRegistry() *AgentRegistry {
+	return &AgentRegistry{byName: make(map[string]Agent), keywords: newKeywordIndex(nil)}
+}
+
+// Register adds an agent. Names must be lowercase identifiers and unique.
+func (r *AgentRegistry) Register(agent Agent) error {
+	descriptor := agent.Descriptor()
+	if !agentNamePattern.MatchString(descriptor.Name) {
+		return fmt.Errorf("invalid agent name %q: use lowercase letters, digits and underscores", descriptor.Name)
+	}
+	if _, exists := r.byName[descriptor.Name]; exists {
+		return fmt.Errorf("agent %q is already registered", descriptor.Name)
+	}
+	if strings.TrimSpace(descriptor.Description) == "" {
+		return fmt.Errorf("agent %q needs a description for the router", descriptor.Name)
+	}
+
+	r.agents = append(r.agents, agent)
+	r.byName[descriptor.Name] = agent
+	r.keywords = newKeywordIndex(r.descriptors())
+	return nil
+}
+
+// Get returns the agent with the given name
+func (r *AgentRegistry) Get(name string) (Agent, bool) {
+	agent, ok := r.byName[name]
+	return agent, ok
+}
+
+// Agents returns the registered agents in registration order
+func (r *AgentRegistry) Agents() []Agent {
+	return append([]Agent(nil), r.agents...)
+}
+
+// Names returns the registered agent names, sorted
+func (r *AgentRegistry) Names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// descriptors returns the descriptors of all agents, in registration order
+func (r *AgentRegistry) descriptors() []AgentDescriptor {
+	descriptors := make([]AgentDescriptor, len(r.agents))
+	for i, agent := range r.agents {
+		descriptors[i] = agent.Descriptor()
+	}
+	return descriptors
+}
+
+// routable returns the descriptors of the agents the router decides on
+func (r *AgentRegistry) routable() []AgentDescriptor {
+	var descriptors []AgentDescriptor
+	for _, agent := range r.agents {
+		if descriptor := agent.Descriptor(); !descriptor.AlwaysRun {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+	return descriptors
+}
+
+// RouterPrompt builds the classification prompt for a request from the
+// registered agent descriptors.
+func (r *AgentRegistry) RouterPrompt(question string) string {
+	var b strings.Builder
+	b.WriteString("You are a router for a music production AI system. Classify requests to determine which specialized agents are needed.\n\n")
+	fmt.Fprintf(&b, "THE SYSTEM HAS %d AGENTS:\n", len(r.agents))
+
+	for i, agent := range r.agents {
+		descriptor := agent.Descriptor()
+		label := strings.ToUpper(strings.ReplaceAll(descriptor.Name, "_", " ")) + " AGENT"
+		if descriptor.AlwaysRun {
+			label += " (always runs)"
+		}
+		fmt.Fprintf(&b, "%d. %s: %s\n", i+1, label, descriptor.Description)
+	}
+
+	routable := r.routable()
+	names := make([]string, len(routable))
+	for i, descriptor := range routable {
+		names[i] = strings.ToUpper(descriptor.Name)
+	}
+	fmt.Fprintf(&b, "\nYOUR TASK: Decide which of %s are needed.\n", strings.Join(names, ", "))
+
+	examples := false
+	for _, descriptor := range routable {
+		for _, example := range descriptor.Examples {
+			if !examples {
+				b.WriteString("\nEXAMPLES:\n")
+				examples = true
+			}
+			needed := "needed"
+			if !example.Needed {
+				needed = "not needed"
+			}
+			fmt.Fprintf(&b, "- %q → %s %s", example.Request, descriptor.Name, needed)
+			if example.Reason != "" {
+				fmt.Fprintf(&b, " (%s)", example.Reason)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\nREQUEST: %q\n\nReturn JSON: {", question)
+	for i, descriptor := range routable {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q: bool", descriptor.Name)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// routerSchema builds the JSON schema of the router's answer: one boolean per
+// routable agent.
+func (r *AgentRegistry) routerSchema() map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, descriptor := range r.routable() {
+		properties[descriptor.Name] = map[string]any{"type": "boolean"}
+		required = append(required, descriptor.Name)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+		"required":             required,
+	}
+}
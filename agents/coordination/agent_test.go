@@ -0,0 +1,279 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAgent returns a fixed output, or a fixed error
+type stubAgent struct {
+	descriptor    AgentDescriptor
+	output        *AgentOutput
+	err           error
+	failures      int                                               // If set, only the first runs fail with err
+	emitFirst     bool                                              // Emit the output before failing, too
+	gate          chan struct{}                                     // If set, runs once it is closed or its context is done
+	ignoreContext bool                                              // Wait for the gate even once the context is done
+	retried       chan struct{}                                     // If set, closed when the second run starts
+	respond       func(request *AgentRequest) (*AgentOutput, error) // If set, answers each request instead
+
+	mu      sync.Mutex
+	runs    int
+	ran     bool
+	request *AgentRequest
+}
+
+func (s *stubAgent) Descriptor() AgentDescriptor { return s.descriptor }
+
+func (s *stubAgent) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	if s.gate != nil {
+		if s.ignoreContext {
+			<-s.gate
+		} else {
+			select {
+			case <-s.gate:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	s.mu.Lock()
+	s.runs++
+	s.ran = true
+	s.request = request
+	failing := s.err != nil && (s.failures == 0 || s.runs <= s.failures)
+	if s.runs == 2 && s.retried != nil {
+		close(s.retried)
+	}
+	s.mu.Unlock()
+
+	output, err := s.output, error(nil)
+	if failing {
+		err = s.err
+	}
+	if s.respond != nil {
+		output, err = s.respond(request)
+	}
+	if emit != nil && (err == nil || s.emitFirst) {
+		if err := emit(output); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// newDAWAgent creates a track with a clip
+func newDAWAgent() *stubAgent {
+	return &stubAgent{
+		descriptor: AgentDescriptor{Name: "daw", Description: "DAW", AlwaysRun: true, Required: true},
+		output: &AgentOutput{Actions: []map[string]any{
+			{"action": "create_track", "index": 0},
+			{"action": "create_clip_at_bar", "track": 0, "bar": 1},
+		}},
+	}
+}
+
+// byTask answers each task with its own output, so several steps can run an agent at once
+func byTask(outputs map[string]*AgentOutput) func(request *AgentRequest) (*AgentOutput, error) {
+	return func(request *AgentRequest) (*AgentOutput, error) {
+		output, ok := outputs[request.Question]
+		if !ok {
+			return nil, fmt.Errorf("unexpected task %q", request.Question)
+		}
+		return output, nil
+	}
+}
+
+// testOrchestrator runs the agents, with the LLM router answering router
+func testOrchestrator(t *testing.T, router string, agents ...Agent) *Orchestrator {
+	t.Helper()
+	orchestrator, err := NewOrchestratorWithAgents(&routerProvider{answer: router}, agents...)
+	require.NoError(t, err)
+	return orchestrator
+}
+
+// routerProvider answers the router with a fixed JSON object and records the request
+type routerProvider struct {
+	answer  string
+	request *llm.GenerationRequest
+}
+
+func (r *routerProvider) Name() string { return "router" }
+
+func (r *routerProvider) Generate(ctx context.Context, request *llm.GenerationRequest) (*llm.GenerationResponse, error) {
+	r.request = request
+	return &llm.GenerationResponse{RawOutput: r.answer}, nil
+}
+
+func TestAgentRegistry_Register(t *testing.T) {
+	registry := NewAgentRegistry()
+	require.NoError(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "mix_analysis", Description: "Analyzes the mix"}}))
+	require.NoError(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "jsfx", Description: "Writes JSFX effects"}}))
+
+	assert.ErrorContains(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "jsfx", Description: "Again"}}), "already registered")
+	assert.ErrorContains(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "Mix Analysis", Description: "Bad name"}}), "invalid agent name")
+	assert.ErrorContains(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "plugin"}}), "needs a description")
+
+	assert.Equal(t, []string{"jsfx", "mix_analysis"}, registry.Names())
+	agents := registry.Agents()
+	require.Len(t, agents, 2)
+	assert.Equal(t, "mix_analysis", agents[0].Descriptor().Name, "agents keep registration order")
+
+	_, ok := registry.Get("plugin")
+	assert.False(t, ok)
+}
+
+func TestAgentRegistry_RouterPrompt(t *testing.T) {
+	registry := NewAgentRegistry()
+	require.NoError(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "daw", Description: "Handles REAPER operations", AlwaysRun: true}}))
+	require.NoError(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{
+		Name:        "jsfx",
+		Description: "Writes JSFX effects",
+		Examples:    []RoutingExample{{Request: "write a bitcrusher", Needed: true, Reason: "custom effect"}},
+	}}))
+
+	prompt := registry.RouterPrompt("make a distortion plugin")
+	assert.Contains(t, prompt, "THE SYSTEM HAS 2 AGENTS")
+	assert.Contains(t, prompt, "1. DAW AGENT (always runs): Handles REAPER operations")
+	assert.Contains(t, prompt, "2. JSFX AGENT: Writes JSFX effects")
+	assert.Contains(t, prompt, `- "write a bitcrusher" → jsfx needed (custom effect)`)
+	assert.Contains(t, prompt, `REQUEST: "make a distortion plugin"`)
+	assert.Contains(t, prompt, `Return JSON: {"jsfx": bool}`)
+
+	schema := registry.routerSchema()
+	assert.Equal(t, []string{"jsfx"}, schema["required"])
+	assert.Contains(t, schema["properties"], "jsfx")
+	assert.NotContains(t, schema["properties"], "daw")
+}
+
+func TestOrchestrator_Route(t *testing.T) {
+	provider := &routerProvider{answer: `{"arranger": false, "jsfx": true}`}
+	orchestrator, err := NewOrchestratorWithAgents(provider,
+		&stubAgent{descriptor: AgentDescriptor{Name: "daw", Description: "Handles REAPER operations", AlwaysRun: true, Required: true}},
+		&stubAgent{descriptor: AgentDescriptor{Name: "arranger", Description: "Writes melodies"}},
+		&stubAgent{descriptor: AgentDescriptor{Name: "jsfx", Description: "Writes JSFX effects"}},
+	)
+	require.NoError(t, err)
+
+	decision, err := orchestrator.Route(context.Background(), "write a bitcrusher")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"daw", "jsfx"}, decision.Agents)
+	assert.True(t, decision.Needs("jsfx"))
+	assert.False(t, decision.Needs("arranger"))
+
+	schema, err := json.Marshal(provider.request.OutputSchema.Schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {"arranger": {"type": "boolean"}, "jsfx": {"type": "boolean"}},
+		"required": ["arranger", "jsfx"]
+	}`, string(schema))
+
+	needsDAW, needsArranger, needsDrummer, err := orchestrator.DetectAgentsNeeded(context.Background(), "write a bitcrusher")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, []bool{needsDAW, needsArranger, needsDrummer})
+}
+
+func TestOrchestrator_GenerateActions_CustomAgents(t *testing.T) {
+	notes := []models.NoteEvent{{MidiNoteNumber: 60, Velocity: 100, StartBeats: 0, DurationBeats: 1}}
+	newOrchestrator := func(t *testing.T, dawErr, melodyErr error) (*Orchestrator, *stubAgent) {
+		t.Helper()
+		mix := &stubAgent{descriptor: AgentDescriptor{Name: "mix_analysis", Description: "Analyzes the mix"}}
+		orchestrator, err := NewOrchestratorWithAgents(
+			&routerProvider{answer: `{"melody": true, "mix_analysis": false}`},
+			&stubAgent{
+				descriptor: AgentDescriptor{Name: "daw", Description: "Handles REAPER operations", AlwaysRun: true, Required: true},
+				output: &AgentOutput{Actions: []map[string]any{
+					{"action": "create_track", "index": 2},
+					{"action": "create_clip_at_bar", "track": 2, "bar": 1},
+					{"action": "play"},
+				}},
+				err: dawErr,
+			},
+			&stubAgent{
+				descriptor: AgentDescriptor{Name: "melody", Description: "Writes melodies", NeedsTrack: true},
+				output:     &AgentOutput{Notes: notes},
+				err:        melodyErr,
+			},
+			mix,
+		)
+		require.NoError(t, err)
+		return orchestrator, mix
+	}
+
+	t.Run("notes follow their track", func(t *testing.T) {
+		orchestrator, mix := newOrchestrator(t, nil, nil)
+		result, err := orchestrator.GenerateActions(context.Background(), "add a melody", nil)
+		require.NoError(t, err)
+		assert.False(t, mix.ran, "unrouted agents do not run")
+
+		require.Len(t, result.Actions, 4)
+		midi := result.Actions[2]
+		assert.Equal(t, "add_midi", midi["action"])
+		assert.Equal(t, 2, midi["track"])
+		assert.Equal(t, noteMaps(notes), midi["notes"])
+		assert.Equal(t, "play", result.Actions[3]["action"])
+	})
+
+	t.Run("optional agent failure is dropped", func(t *testing.T) {
+		orchestrator, _ := newOrchestrator(t, nil, errors.New("melody failed"))
+		result, err := orchestrator.GenerateActions(context.Background(), "add a melody", nil)
+		require.NoError(t, err)
+		assert.Len(t, result.Actions, 3)
+	})
+
+	t.Run("required agent failure fails the request", func(t *testing.T) {
+		orchestrator, _ := newOrchestrator(t, errors.New("out of scope"), nil)
+		_, err := orchestrator.GenerateActions(context.Background(), "add a melody", nil)
+		assert.ErrorContains(t, err, "daw agent failed: out of scope")
+	})
+}
+
+func TestPlaceNotes(t *testing.T) {
+	notes := []models.NoteEvent{{MidiNoteNumber: 60, Velocity: 100, DurationBeats: 1}}
+	note := noteMaps(notes)[0]
+	actions := func() []map[string]any {
+		return []map[string]any{
+			{"action": "create_clip_at_bar", "track": 0, "bar": 1},
+			{"action": "add_midi", "track": 0, "notes": []any{map[string]any{"pitch": 48}}},
+			{"action": "create_clip_at_bar", "track": 1, "bar": 1},
+			{"action": "add_midi", "track": 1, "notes": []map[string]any{{"pitch": 36}}},
+			{"action": "add_midi", "track": 1},
+		}
+	}
+
+	// Only the track's last add_midi gets the notes
+	placed := placeNotes(actions(), notes, 0, true)
+	assert.Equal(t, []any{map[string]any{"pitch": 48}, note}, placed[1]["notes"])
+	assert.Equal(t, []map[string]any{{"pitch": 36}}, placed[3]["notes"])
+	assert.NotContains(t, placed[4], "notes")
+
+	placed = placeNotes(actions(), notes, 1, true)
+	assert.Equal(t, []map[string]any{note}, placed[4]["notes"])
+	assert.Equal(t, []map[string]any{{"pitch": 36}}, placed[3]["notes"])
+
+	// Without a track, the last add_midi
+	placed = placeNotes(actions(), notes, -1, false)
+	assert.Equal(t, []map[string]any{note}, placed[4]["notes"])
+	assert.Len(t, placed[1]["notes"], 1)
+
+	// A track without add_midi gets a new one after its clip
+	placed = placeNotes(append(actions(), map[string]any{"action": "create_clip_at_bar", "track": 2, "bar": 1}), notes, 2, true)
+	require.Len(t, placed, 7)
+	assert.Equal(t, map[string]any{"action": "add_midi", "track": 2, "notes": []map[string]any{note}}, placed[6])
+	assert.Len(t, placed[1]["notes"], 1)
+	assert.Len(t, placed[3]["notes"], 1)
+}
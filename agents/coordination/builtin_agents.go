@@ -0,0 +1,427 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+
+	arranger "github.com/Conceptual-Machines/magda-agents-go/agents/arranger"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/drummer"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/jsfx"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/mix"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+)
+
+// Names of the built-in agents
+const (
+	DAWAgentName      = "daw"
+	ArrangerAgentName = "arranger"
+	DrummerAgentName  = "drummer"
+	JSFXAgentName     = "jsfx"
+	MixAgentName      = "mix"
+)
+
+// Models the orchestrator runs the drummer and JSFX agents with
+const (
+	drummerModel = "gpt-5.1"
+	jsfxModel    = "gpt-5.2"
+)
+
+// MixAnalysisStateKey is the state field the host puts the DSP analysis of
+// the mix in, as a mix.AnalysisRequest, for the mix agent
+const MixAnalysisStateKey = "mix_analysis"
+
+// ArrangerAgent interface for the arranger agent
+// Uses the actual arranger agent's ArrangerResult type
+type ArrangerAgent interface {
+	GenerateActions(ctx context.Context, question string) (*arranger.ArrangerResult, error)
+	GenerateActionsStream(ctx context.Context, question string, callback arranger.StreamActionCallback) (*arranger.ArrangerResult, error)
+}
+
+// dawAgentAdapter runs the DAW agent as an orchestrated agent
+type dawAgentAdapter struct {
+	agent *daw.DawAgent
+}
+
+// WrapDawAgent adapts a DAW agent to the Agent interface. It always runs and
+// gates the request: when it fails, the whole request fails.
+func WrapDawAgent(agent *daw.DawAgent) Agent {
+	return &dawAgentAdapter{agent: agent}
+}
+
+func (a *dawAgentAdapter) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		Name:        DAWAgentName,
+		Description: "Handles REAPER operations - tracks, clips, FX, volume, pan, mute, solo, routing. Does NOT generate musical content.",
+		Keywords:    BuiltinKeywords(DAWAgentName),
+		AlwaysRun:   true,
+		Required:    true,
+	}
+}
+
+func (a *dawAgentAdapter) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	var result *daw.DawResult
+	var err error
+	if emit == nil {
+		result, err = a.agent.GenerateActions(ctx, request.Question, request.State)
+	} else {
+		result, err = a.agent.GenerateActionsStream(ctx, request.Question, request.State, func(action map[string]any) error {
+			return emit(&AgentOutput{Actions: []map[string]any{action}})
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AgentOutput{Actions: result.Actions, Usage: result.Usage}, nil
+}
+
+// Clarify asks which track a request means when it acts on a track without
+// saying which, and none is selected
+func (a *dawAgentAdapter) Clarify(request *AgentRequest) *models.Clarification {
+	return daw.Clarify(request.Question, request.State)
+}
+
+// arrangerPatterns route note names ("E1", "C#4") and chord sequences
+// ("C Am F G") to the arranger
+var arrangerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b[a-g][#b]?-?[0-8]\b`),
+	regexp.MustCompile(`\b[A-G][#b]?(?:maj7|maj|min|m7|m|dim|aug|sus2|sus4|7)?(?:[ ,-]+[A-G][#b]?(?:maj7|maj|min|m7|m|dim|aug|sus2|sus4|7)?)+\b`),
+}
+
+// arrangerAgentAdapter runs the arranger agent as an orchestrated agent,
+// turning its chord and arpeggio actions into notes
+type arrangerAgentAdapter struct {
+	agent ArrangerAgent
+}
+
+// WrapArrangerAgent adapts an arranger agent to the Agent interface. Its
+// actions are converted to notes placed one after the other.
+func WrapArrangerAgent(agent ArrangerAgent) Agent {
+	return &arrangerAgentAdapter{agent: agent}
+}
+
+func (a *arrangerAgentAdapter) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		Name:        ArrangerAgentName,
+		Description: "Generates melodic/harmonic MIDI content - chords, arpeggios, melodies, basslines, chord progressions. Creates actual notes with pitches.",
+		Examples: []RoutingExample{
+			{Request: "create a track called Drums", Needed: false, Reason: "just naming a track, no content"},
+			{Request: "add a chord progression in C major", Needed: true, Reason: "harmonic content"},
+			{Request: "create an arpeggio", Needed: true, Reason: "melodic content"},
+			{Request: "add sustained E1", Needed: true, Reason: "single note = melodic content"},
+			{Request: "add note C4", Needed: true, Reason: "single note = melodic content"},
+			{Request: "bass note at bar 2", Needed: true, Reason: "single note = melodic content"},
+			{Request: "add a breakbeat pattern", Needed: false, Reason: "drums, not melody"},
+		},
+		Keywords:        BuiltinKeywords(ArrangerAgentName),
+		KeywordPatterns: arrangerPatterns,
+		NeedsTrack:      true,
+	}
+}
+
+func (a *arrangerAgentAdapter) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	output := &AgentOutput{}
+	currentBeat := 0.0
+	convert := func(action map[string]any) []models.NoteEvent {
+		notes, err := arranger.ConvertArrangerActionToNoteEvents(action, currentBeat)
+		currentBeat = advanceBeat(action, currentBeat)
+		if err != nil {
+			log.Printf("⚠️ Failed to convert arranger action to NoteEvents: %v", err)
+			return nil
+		}
+		return notes
+	}
+
+	if emit == nil {
+		result, err := a.agent.GenerateActions(ctx, stepQuestion(request))
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range result.Actions {
+			output.Notes = append(output.Notes, convert(action)...)
+		}
+		output.Usage = result.Usage
+		return output, nil
+	}
+
+	result, err := a.agent.GenerateActionsStream(ctx, stepQuestion(request), func(action map[string]any) error {
+		notes := convert(action)
+		if len(notes) == 0 {
+			return nil
+		}
+		output.Notes = append(output.Notes, notes...)
+		return emit(&AgentOutput{Notes: notes})
+	})
+	if err != nil {
+		return nil, err
+	}
+	output.Usage = result.Usage
+	return output, nil
+}
+
+// stepQuestion returns the request's question with the length of its target
+// range, if it has one. Agents write from beat 0; the orchestrator moves their
+// output to the target bar.
+func stepQuestion(request *AgentRequest) string {
+	if request.Target == nil || request.Target.LengthBars <= 0 {
+		return request.Question
+	}
+	return fmt.Sprintf("%s (%d bars)", request.Question, request.Target.LengthBars)
+}
+
+// advanceBeat returns the beat after an arranger action, taking its length
+// and repeat count into account
+func advanceBeat(action map[string]any, currentBeat float64) float64 {
+	length, ok := getFloat(action, "length")
+	if !ok {
+		return currentBeat
+	}
+	if repeat, ok := getInt(action, "repeat"); ok && repeat > 0 {
+		return currentBeat + length*float64(repeat)
+	}
+	return currentBeat + length
+}
+
+// drummerAgentAdapter runs the drummer agent as an orchestrated agent
+type drummerAgentAdapter struct {
+	agent   *drummer.DrummerAgent
+	options drummer.DrumPatternOptions
+}
+
+// WrapDrummerAgent adapts a drummer agent to the Agent interface. Drum
+// patterns are converted to General MIDI notes.
+func WrapDrummerAgent(agent *drummer.DrummerAgent) Agent {
+	return WrapDrummerAgentWithOptions(agent, drummer.DefaultDrumPatternOptions())
+}
+
+// WrapDrummerAgentWithOptions adapts a drummer agent to the Agent interface,
+// converting drum patterns to notes with the given drum map and velocities
+func WrapDrummerAgentWithOptions(agent *drummer.DrummerAgent, options drummer.DrumPatternOptions) Agent {
+	return &drummerAgentAdapter{agent: agent, options: options}
+}
+
+func (a *drummerAgentAdapter) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		Name:        DrummerAgentName,
+		Description: "Generates drum/percussion patterns - kick, snare, hi-hat, toms, cymbals. Creates rhythmic patterns on a grid.",
+		Examples: []RoutingExample{
+			{Request: "add reverb to the bass", Needed: false, Reason: "FX operation"},
+			{Request: "add a breakbeat pattern", Needed: true, Reason: "generating drums"},
+			{Request: "create a funk groove with ghost notes", Needed: true, Reason: "drum pattern"},
+			{Request: "create a hip hop beat with kicks and snares", Needed: true, Reason: "drum pattern"},
+			{Request: "mute track 2", Needed: false, Reason: "track control"},
+		},
+		Keywords:   BuiltinKeywords(DrummerAgentName),
+		NeedsTrack: true,
+	}
+}
+
+func (a *drummerAgentAdapter) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	inputArray := []map[string]any{
+		{
+			"role":    "user",
+			"content": stepQuestion(request),
+		},
+	}
+
+	output := &AgentOutput{}
+	convert := func(action map[string]any) *AgentOutput {
+		notes, err := drummer.ConvertDrumPatternToNoteEvents(action, a.options)
+		if err != nil {
+			// Leave the pattern for the REAPER side to interpret
+			log.Printf("⚠️ Failed to convert drum pattern to NoteEvents: %v", err)
+			return &AgentOutput{Actions: []map[string]any{action}}
+		}
+		return &AgentOutput{Notes: notes}
+	}
+
+	var result *drummer.DrummerResult
+	var err error
+	if emit == nil {
+		result, err = a.agent.Generate(ctx, drummerModel, inputArray)
+		if err == nil {
+			for _, action := range result.Actions {
+				converted := convert(action)
+				output.Actions = append(output.Actions, converted.Actions...)
+				output.Notes = append(output.Notes, converted.Notes...)
+			}
+		}
+	} else {
+		result, err = a.agent.GenerateStream(ctx, drummerModel, inputArray, func(action map[string]any) error {
+			converted := convert(action)
+			output.Actions = append(output.Actions, converted.Actions...)
+			output.Notes = append(output.Notes, converted.Notes...)
+			return emit(converted)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	output.Usage = result.Usage
+	return output, nil
+}
+
+// jsfxPatterns route requests to build an effect ("make me a tape saturation
+// effect") to the JSFX agent; adding an existing plugin is the DAW agent's job
+var jsfxPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:build|make|write|design|code|program)\s+(?:me\s+|us\s+)?(?:a|an|my own|our own)\s+(?:[\w-]+\s+){0,3}?(?:effect|plugin|fx)\b`),
+}
+
+// jsfxAgentAdapter runs the JSFX agent as an orchestrated agent, installing
+// the effect it writes and adding it to a track
+type jsfxAgentAdapter struct {
+	agent *jsfx.JSFXAgent
+}
+
+// WrapJSFXAgent adapts a JSFX agent to the Agent interface. The generated
+// effect becomes an install_jsfx action and an add_track_fx action, on the
+// track the request names or the step's target.
+func WrapJSFXAgent(agent *jsfx.JSFXAgent) Agent {
+	return &jsfxAgentAdapter{agent: agent}
+}
+
+func (a *jsfxAgentAdapter) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		Name:        JSFXAgentName,
+		Description: "Writes custom JSFX audio effects (EEL2 code) - saturation, distortion, filters, dynamics, utilities - and puts them on a track. Only for effects that have to be built, not for adding existing plugins.",
+		Examples: []RoutingExample{
+			{Request: "build me a tape saturation effect and put it on the vocal", Needed: true, Reason: "custom effect"},
+			{Request: "write a JSFX bitcrusher", Needed: true, Reason: "custom effect"},
+			{Request: "add ReaComp to the bass", Needed: false, Reason: "existing plugin"},
+			{Request: "add reverb to the drums", Needed: false, Reason: "existing plugin"},
+		},
+		Keywords:        BuiltinKeywords(JSFXAgentName),
+		KeywordPatterns: jsfxPatterns,
+		NeedsTrack:      true,
+	}
+}
+
+func (a *jsfxAgentAdapter) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	inputArray := []map[string]any{
+		{
+			"role":    "user",
+			"content": request.Question,
+		},
+	}
+	result, err := a.agent.Generate(ctx, jsfxModel, inputArray)
+	if err != nil {
+		return nil, err
+	}
+
+	// A planned target is placed by the orchestrator; otherwise use the
+	// existing track the request names, if any
+	track := -1
+	if request.Target == nil {
+		if index, ok := destinationTrack(request.Question, request.State); ok {
+			track = index
+		}
+	}
+	actions, err := jsfx.InstallActions(result, track)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &AgentOutput{Actions: actions, Usage: result.Usage}
+	if emit != nil {
+		if err := emit(output); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// mixAgentAdapter runs the mix analysis agent as an orchestrated agent,
+// applying its recommendations
+type mixAgentAdapter struct {
+	agent *mix.MixAnalysisAgent
+}
+
+// WrapMixAgent adapts a mix analysis agent to the Agent interface. It
+// analyzes the DSP data the host puts in the state under MixAnalysisStateKey,
+// and its recommendations become DAW actions.
+func WrapMixAgent(agent *mix.MixAnalysisAgent) Agent {
+	return &mixAgentAdapter{agent: agent}
+}
+
+func (a *mixAgentAdapter) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		Name:        MixAgentName,
+		Description: "Analyzes how tracks or the mix sound from DSP analysis data (spectrum, loudness, dynamics, stereo) and fixes the problems it finds - EQ cuts, compression, volume and pan.",
+		Examples: []RoutingExample{
+			{Request: "analyse my mix and fix the muddy bass", Needed: true, Reason: "mix analysis"},
+			{Request: "why do my vocals sound harsh?", Needed: true, Reason: "diagnosing the sound"},
+			{Request: "set the bass volume to -6 dB", Needed: false, Reason: "explicit track control"},
+			{Request: "add a chord progression", Needed: false, Reason: "musical content"},
+		},
+		Keywords: BuiltinKeywords(MixAgentName),
+	}
+}
+
+func (a *mixAgentAdapter) Run(ctx context.Context, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	analysis, err := mixAnalysisRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.agent.Analyze(ctx, analysis)
+	if err != nil {
+		return nil, err
+	}
+
+	// In track mode, recommendations without a track are for the analyzed one
+	track := -1
+	if analysis.Mode == mix.ModeTrack && analysis.Context != nil {
+		track = analysis.Context.TrackIndex
+	}
+	output := &AgentOutput{Actions: result.DAWActions(track)}
+	log.Printf("🎛️ Mix agent: %d of %d recommendations applied", len(output.Actions), len(result.Recommendations))
+	if emit != nil && len(output.Actions) > 0 {
+		if err := emit(output); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// mixAnalysisRequest reads the analysis request from the state, with the
+// user's request and a mode that fits the data
+func mixAnalysisRequest(request *AgentRequest) (*mix.AnalysisRequest, error) {
+	state := request.State
+	if nested, ok := state["state"].(map[string]any); ok && state[MixAnalysisStateKey] == nil {
+		state = nested
+	}
+
+	var analysis mix.AnalysisRequest
+	switch value := state[MixAnalysisStateKey].(type) {
+	case nil:
+		return nil, fmt.Errorf("no mix analysis in the state: analyze the tracks and send it as %q", MixAnalysisStateKey)
+	case *mix.AnalysisRequest:
+		analysis = *value
+	case mix.AnalysisRequest:
+		analysis = value
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mix analysis: %w", err)
+		}
+		if err := json.Unmarshal(data, &analysis); err != nil {
+			return nil, fmt.Errorf("invalid mix analysis: %w", err)
+		}
+	}
+	if analysis.AnalysisData == nil {
+		return nil, fmt.Errorf("mix analysis has no analysis_data")
+	}
+
+	if analysis.UserRequest == "" {
+		analysis.UserRequest = request.Question
+	}
+	if analysis.Mode == "" {
+		analysis.Mode = mix.ModeTrack
+		if len(analysis.AnalysisData.Tracks) > 0 {
+			analysis.Mode = mix.ModeMultiTrack
+		}
+	}
+	return &analysis, nil
+}
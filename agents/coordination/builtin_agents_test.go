@@ -0,0 +1,111 @@
+package coordination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/agents/jsfx"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/mix"
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tapeSaturation = `desc:Tape Saturation
+slider1:drive=0.5<0,1,0.01>Drive
+@sample
+spl0 = tanh(spl0 * (1 + drive * 4));
+spl1 = tanh(spl1 * (1 + drive * 4));`
+
+// effectsOrchestrator runs a DAW agent that does nothing and the JSFX and mix
+// agents with scripted models
+func effectsOrchestrator(t *testing.T) *Orchestrator {
+	t.Helper()
+	cfg := &config.Config{}
+	orchestrator, err := NewOrchestratorWithAgents(
+		&routerProvider{answer: `{}`},
+		&stubAgent{descriptor: AgentDescriptor{Name: DAWAgentName, Description: "DAW", AlwaysRun: true, Required: true}, output: &AgentOutput{}},
+		WrapJSFXAgent(jsfx.NewJSFXAgentWithProvider(cfg, &scriptedProvider{output: tapeSaturation})),
+		WrapMixAgent(mix.NewMixAnalysisAgentWithProvider(cfg, &scriptedProvider{output: `{
+			"analysis": {"summary": "Low-mid buildup", "issues": [], "strengths": []},
+			"recommendations": [
+				{"id": "rec_1", "priority": "high", "description": "Cut 250 Hz", "explanation": "Mud", "action_type": "add_fx",
+					"fx_name": "ReaEQ", "fx_index": -1, "track": -1, "parameter": "", "value": 0, "value_string": ""},
+				{"id": "rec_2", "priority": "low", "description": "Lower the bass", "explanation": "Balance", "action_type": "set_volume",
+					"fx_name": "", "fx_index": -1, "track": -1, "parameter": "", "value": -2, "value_string": ""}
+			],
+			"relationship_issues": []
+		}`})),
+	)
+	require.NoError(t, err)
+	orchestrator.SetPlanning(false)
+	return orchestrator
+}
+
+func effectsState() map[string]any {
+	return map[string]any{
+		"tracks": []any{
+			map[string]any{"index": 0, "name": "Vocal"},
+			map[string]any{"index": 1, "name": "Bass"},
+		},
+		MixAnalysisStateKey: map[string]any{
+			"analysis_data": map[string]any{"frequency_spectrum": map[string]any{"fft_size": 4096}},
+			"context":       map[string]any{"track_index": 1, "track_name": "Bass"},
+		},
+	}
+}
+
+func TestOrchestrator_JSFXAgent(t *testing.T) {
+	want := []map[string]any{
+		{
+			"action":      "install_jsfx",
+			"path":        "magda/tape_saturation.jsfx",
+			"name":        "Tape Saturation",
+			"description": "Generated JSFX effect: Tape Saturation",
+			"code":        tapeSaturation,
+		},
+		{"action": "add_track_fx", "track": 0, "fxname": "JS: magda/tape_saturation.jsfx"},
+	}
+	question := "build me a tape saturation effect and put it on the vocal"
+
+	result, err := effectsOrchestrator(t).GenerateActions(context.Background(), question, effectsState())
+	require.NoError(t, err)
+	assert.Equal(t, []string{DAWAgentName, JSFXAgentName}, result.Routing.Agents)
+	assert.Equal(t, want, result.Actions)
+
+	result, err = effectsOrchestrator(t).GenerateActionsStream(context.Background(), question, effectsState(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, result.Actions)
+}
+
+func TestOrchestrator_MixAgent(t *testing.T) {
+	result, err := effectsOrchestrator(t).GenerateActions(context.Background(), "analyse my mix and fix the muddy bass", effectsState())
+	require.NoError(t, err)
+	assert.Equal(t, []string{DAWAgentName, MixAgentName}, result.Routing.Agents)
+	assert.Equal(t, []map[string]any{
+		{"action": "add_track_fx", "track": 1, "fxname": "ReaEQ", "description": "Cut 250 Hz"},
+		{"action": "set_track", "track": 1, "volume_db": float64(-2), "description": "Lower the bass"},
+	}, result.Actions)
+
+	// Without the DSP analysis there is nothing to analyze
+	result, err = effectsOrchestrator(t).GenerateActions(context.Background(), "analyse my mix and fix the muddy bass", nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actions)
+	assert.Equal(t, AgentFailed, result.Agents[1].Status)
+	assert.Contains(t, result.Agents[1].Error, `no mix analysis in the state`)
+}
+
+func TestMixAnalysisRequest(t *testing.T) {
+	request, err := mixAnalysisRequest(&AgentRequest{
+		Question: "make the kick and bass sit better",
+		State: map[string]any{"state": map[string]any{MixAnalysisStateKey: &mix.AnalysisRequest{
+			AnalysisData: &mix.DSPAnalysisData{Tracks: []mix.TrackAnalysis{{TrackIndex: 0, TrackName: "Kick"}}},
+		}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, mix.ModeMultiTrack, request.Mode)
+	assert.Equal(t, "make the kick and bass sit better", request.UserRequest)
+
+	_, err = mixAnalysisRequest(&AgentRequest{State: map[string]any{MixAnalysisStateKey: map[string]any{"mode": "master"}}})
+	assert.ErrorContains(t, err, "mix analysis has no analysis_data")
+}
@@ -0,0 +1,83 @@
+package coordination
+
+import (
+	"context"
+	"log"
+
+	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+)
+
+// Clarifier is implemented by agents that can ask the user which of several
+// readings of a request they mean, instead of guessing
+type Clarifier interface {
+	// Clarify returns a clarification if the request is too ambiguous to act
+	// on, or nil
+	Clarify(request *AgentRequest) *models.Clarification
+}
+
+// SetClarification turns the clarifying-question mode on or off. When on, a
+// selected agent that finds the request ambiguous stops it before any agent
+// runs, and the result has a Clarification instead of actions; Resume runs
+// the request with the user's answer.
+func (o *Orchestrator) SetClarification(enabled bool) {
+	o.clarify = enabled
+}
+
+// clarification asks the selected agents that are Clarifiers, in order, and
+// returns the first clarification, if clarify is set
+func (o *Orchestrator) clarification(
+	question string, state map[string]any, agents []Agent, clarify bool, logPrefix string,
+) *models.Clarification {
+	if !clarify {
+		return nil
+	}
+	for _, agent := range agents {
+		clarifier, ok := agent.(Clarifier)
+		if !ok {
+			continue
+		}
+		clarification := clarifier.Clarify(&AgentRequest{Question: question, State: state})
+		if clarification == nil {
+			continue
+		}
+		if clarification.Agent == "" {
+			clarification.Agent = agent.Descriptor().Name
+		}
+		log.Printf("❓ %s%s agent asks: %s (%d options)", logPrefix, clarification.Agent, clarification.Question, len(clarification.Options))
+		return clarification
+	}
+	return nil
+}
+
+// Resume runs the request a clarification was asked for with the chosen
+// option: a track option selects that track in the state, and other options
+// are added to the request. The resumed request is not questioned again.
+func (o *Orchestrator) Resume(
+	ctx context.Context,
+	clarification *models.Clarification,
+	choice string,
+	state map[string]any,
+) (*OrchestratorResult, error) {
+	question, state, err := daw.ResolveClarification(clarification, choice, state)
+	if err != nil {
+		return nil, err
+	}
+	return o.generateActions(ctx, question, state, false)
+}
+
+// ResumeStream is Resume with the actions streamed like
+// GenerateActionsStreamWithOptions
+func (o *Orchestrator) ResumeStream(
+	ctx context.Context,
+	clarification *models.Clarification,
+	choice string,
+	state map[string]any,
+	options StreamOptions,
+) (*OrchestratorResult, error) {
+	question, state, err := daw.ResolveClarification(clarification, choice, state)
+	if err != nil {
+		return nil, err
+	}
+	return o.generateActionsStream(ctx, question, state, options, false)
+}
@@ -0,0 +1,101 @@
+package coordination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clarifyingAgent asks a fixed question
+type clarifyingAgent struct {
+	stubAgent
+	clarification *models.Clarification
+}
+
+func (c *clarifyingAgent) Clarify(request *AgentRequest) *models.Clarification {
+	return c.clarification
+}
+
+func TestOrchestrator_Clarification(t *testing.T) {
+	state := map[string]any{"tracks": []any{
+		map[string]any{"index": 0, "name": "Drums"},
+		map[string]any{"index": 1, "name": "Bass"},
+	}}
+	orchestrator, err := NewOrchestratorWithAgents(
+		&routerProvider{answer: `{}`},
+		WrapDawAgent(daw.NewDawAgentWithProvider(&config.Config{}, &scriptedProvider{output: `track(selected=true).set_track(volume_db=3)`})),
+	)
+	require.NoError(t, err)
+
+	// Off by default: the DAW agent guesses, and finds no selected track
+	_, err = orchestrator.GenerateActions(context.Background(), "make it louder", state)
+	assert.ErrorContains(t, err, "no selected track found in state")
+
+	orchestrator.SetClarification(true)
+	result, err := orchestrator.GenerateActions(context.Background(), "make it louder", state)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actions)
+	assert.Nil(t, result.Agents, "no agent ran")
+	require.NotNil(t, result.Clarification)
+	assert.Equal(t, DAWAgentName, result.Clarification.Agent)
+	assert.Equal(t, []string{"Drums", "Bass"}, []string{result.Clarification.Options[0].Label, result.Clarification.Options[1].Label})
+
+	streamed, err := orchestrator.GenerateActionsStream(context.Background(), "make it louder", state, func(map[string]any) error {
+		t.Fatal("no actions are streamed")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, result.Clarification, streamed.Clarification)
+
+	want := []map[string]any{{"action": "set_track", "track": 1, "volume_db": 3.0}}
+	resumed, err := orchestrator.Resume(context.Background(), result.Clarification, "2", state)
+	require.NoError(t, err)
+	assert.Nil(t, resumed.Clarification)
+	assert.Equal(t, want, resumed.Actions)
+
+	var actions []map[string]any
+	resumed, err = orchestrator.ResumeStream(context.Background(), result.Clarification, "2", state, StreamOptions{
+		OnAction: func(action map[string]any) error {
+			actions = append(actions, action)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, actions)
+	assert.Equal(t, want, resumed.Actions)
+
+	_, err = orchestrator.Resume(context.Background(), result.Clarification, "drums", state)
+	assert.ErrorContains(t, err, `unknown option "drums"`)
+}
+
+func TestOrchestrator_Clarification_CustomAgent(t *testing.T) {
+	dawAgent := &stubAgent{descriptor: AgentDescriptor{Name: "daw", Description: "DAW", AlwaysRun: true, Required: true}, output: &AgentOutput{}}
+	reverb := &clarifyingAgent{
+		stubAgent: stubAgent{descriptor: AgentDescriptor{Name: "reverb", Description: "Designs reverbs", Keywords: []string{"reverb"}}},
+		clarification: &models.Clarification{
+			Request:  "add a reverb",
+			Question: "What kind of space?",
+			Options:  []models.ClarificationOption{{ID: "room", Label: "Room"}, {ID: "hall", Label: "Hall"}},
+		},
+	}
+	orchestrator, err := NewOrchestratorWithAgents(&routerProvider{answer: `{}`}, dawAgent, reverb)
+	require.NoError(t, err)
+	orchestrator.SetClarification(true)
+
+	result, err := orchestrator.GenerateActions(context.Background(), "add a reverb", nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Clarification)
+	assert.Equal(t, "reverb", result.Clarification.Agent)
+	assert.False(t, dawAgent.ran)
+
+	// The answer goes to the agents, which are not asked again
+	result, err = orchestrator.Resume(context.Background(), result.Clarification, "hall", nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.Clarification)
+	assert.Equal(t, "add a reverb (Hall)", reverb.request.Question)
+}
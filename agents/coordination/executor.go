@@ -0,0 +1,404 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stepResult is the outcome of a plan step
+type stepResult struct {
+	step     *PlanStep
+	agent    Agent
+	output   *AgentOutput
+	err      error
+	skipped  bool // A dependency failed, so the step did not run
+	canceled bool // Stopped because a required agent failed
+	attempts int
+	duration time.Duration
+}
+
+// executionHooks let the caller follow the steps as they run. All are optional.
+type executionHooks struct {
+	emit     func(step *PlanStep, output *AgentOutput) error // Streams step output; nil runs steps without streaming
+	restart  func(step *PlanStep) bool                       // Drops a failed step's output before a retry; false forbids the retry
+	finished func(result *stepResult)                        // Called as each step ends
+}
+
+// execute runs the plan's steps, each as soon as the steps it depends on have
+// finished. A step whose dependency failed is skipped, and a failed step is
+// run again if the failure policy allows. Each attempt gets its agent's
+// timeout, and when a required agent fails the other steps are canceled.
+// Steps of required agents, which create tracks, run one after another in
+// plan order even when they don't depend on each other. Each step sees the
+// tracks created by the steps it waited for in its state, so track indexes
+// don't collide. Results are in plan order.
+func (o *Orchestrator) execute(
+	ctx context.Context,
+	plan *ExecutionPlan,
+	state map[string]any,
+	hooks executionHooks,
+	logPrefix string,
+) []*stepResult {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]*stepResult, len(plan.Steps))
+	done := map[string]chan struct{}{}
+	byID := map[string]*stepResult{}
+	for i, step := range plan.Steps {
+		agent, _ := o.agents.Get(step.Agent)
+		results[i] = &stepResult{step: step, agent: agent}
+		done[step.ID] = make(chan struct{})
+		byID[step.ID] = results[i]
+	}
+
+	after := serialSteps(plan, o.agents)
+
+	var wg sync.WaitGroup
+	for _, result := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[result.step.ID])
+			if hooks.finished != nil {
+				defer hooks.finished(result)
+			}
+
+			if previous, ok := after[result.step.ID]; ok {
+				// Only the order matters; a failed required step fails the request anyway
+				<-done[previous]
+			}
+			for _, dep := range result.step.DependsOn {
+				<-done[dep]
+				if byID[dep].err != nil {
+					result.err = fmt.Errorf("skipped: step %q failed", dep)
+					result.skipped = true
+					log.Printf("⏭️ %sStep %s skipped: dependency %s failed", logPrefix, result.step.ID, dep)
+					return
+				}
+			}
+
+			if runCtx.Err() != nil && ctx.Err() == nil {
+				result.err = fmt.Errorf("canceled: %w", context.Cause(runCtx))
+				result.canceled = true
+				log.Printf("🛑 %sStep %s canceled before it started: %v", logPrefix, result.step.ID, context.Cause(runCtx))
+				return
+			}
+
+			request := &AgentRequest{
+				Question: result.step.Task,
+				State:    stateWithTracks(state, createdTracks(plan, result.step, byID, after)),
+				Target:   result.step.Target,
+			}
+			var stepEmit AgentEmitFunc
+			if hooks.emit != nil {
+				stepEmit = func(output *AgentOutput) error { return hooks.emit(result.step, output) }
+			}
+
+			start := time.Now()
+			for {
+				result.attempts++
+				result.output, result.err = o.runAttempt(runCtx, result, request, stepEmit)
+				if result.err == nil || runCtx.Err() != nil || !o.retryable(result) ||
+					(hooks.restart != nil && !hooks.restart(result.step)) {
+					break
+				}
+				log.Printf("🔁 %sStep %s (%s agent) failed, retrying: %v", logPrefix, result.step.ID, result.step.Agent, result.err)
+			}
+			result.duration = time.Since(start)
+			if result.err != nil && runCtx.Err() != nil && ctx.Err() == nil {
+				result.err = fmt.Errorf("canceled: %w", context.Cause(runCtx))
+				result.canceled = true
+				log.Printf("🛑 %sStep %s (%s agent) canceled after %v", logPrefix, result.step.ID, result.step.Agent, result.duration)
+				return
+			}
+			if result.err != nil {
+				log.Printf("⚠️ %sStep %s (%s agent) failed in %v: %v", logPrefix, result.step.ID, result.step.Agent, result.duration, result.err)
+				if result.agent.Descriptor().Required {
+					// The request fails anyway, so stop the other steps
+					cancel(fmt.Errorf("%s agent failed", result.step.Agent))
+				}
+				return
+			}
+			log.Printf("⏱️ %sStep %s (%s agent) completed in %v", logPrefix, result.step.ID, result.step.Agent, result.duration)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runAttempt runs a step's agent once, within the agent's timeout. It returns
+// as soon as the context is done, even if the agent does not, and drops output
+// the agent emits after that.
+func (o *Orchestrator) runAttempt(ctx context.Context, result *stepResult, request *AgentRequest, emit AgentEmitFunc) (*AgentOutput, error) {
+	var attemptCtx context.Context
+	var cancel context.CancelFunc
+	timeout := o.agentTimeout(result.step.Agent)
+	if timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		attemptCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var mu sync.Mutex
+	abandoned := false
+	if emit != nil {
+		inner := emit
+		emit = func(output *AgentOutput) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if abandoned {
+				return attemptCtx.Err()
+			}
+			return inner(output)
+		}
+	}
+
+	type outcome struct {
+		output *AgentOutput
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, err := result.agent.Run(attemptCtx, request, emit)
+		done <- outcome{output, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-attemptCtx.Done():
+		mu.Lock()
+		abandoned = true
+		mu.Unlock()
+		select {
+		case out = <-done: // Finished just in time
+		default:
+			out.err = attemptCtx.Err()
+		}
+	}
+	if out.err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, fmt.Errorf("%s agent timed out after %v: %w", result.step.Agent, timeout, out.err)
+	}
+	return out.output, out.err
+}
+
+// serialSteps returns, for each step of a required agent, the required
+// agent's step before it in plan order. Those steps create tracks, so they
+// run one after another to see each other's tracks.
+func serialSteps(plan *ExecutionPlan, agents *AgentRegistry) map[string]string {
+	ordered, err := plan.Order()
+	if err != nil {
+		ordered = plan.Steps
+	}
+	after := map[string]string{}
+	previous := ""
+	for _, step := range ordered {
+		if agent, ok := agents.Get(step.Agent); !ok || !agent.Descriptor().Required {
+			continue
+		}
+		if previous != "" {
+			after[step.ID] = previous
+		}
+		previous = step.ID
+	}
+	return after
+}
+
+// createdTracks returns the create_track actions of the steps a step waits
+// for, directly or not: its dependencies and the serial step before it
+func createdTracks(plan *ExecutionPlan, step *PlanStep, results map[string]*stepResult, after map[string]string) []map[string]any {
+	var tracks []map[string]any
+	seen := map[string]bool{}
+	var visit func(ids []string)
+	waitsFor := func(step *PlanStep) []string {
+		ids := step.DependsOn
+		if previous, ok := after[step.ID]; ok {
+			ids = append([]string{previous}, ids...)
+		}
+		return ids
+	}
+	visit = func(ids []string) {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			dep, _ := plan.Step(id)
+			visit(waitsFor(dep))
+			if output := results[id].output; output != nil {
+				for _, action := range output.Actions {
+					if action["action"] == "create_track" {
+						tracks = append(tracks, action)
+					}
+				}
+			}
+		}
+	}
+	visit(waitsFor(step))
+	return tracks
+}
+
+// stateWithTracks returns a copy of the REAPER state with the given created
+// tracks added to its track list
+func stateWithTracks(state map[string]any, created []map[string]any) map[string]any {
+	if len(created) == 0 {
+		return state
+	}
+
+	copyMap := func(m map[string]any) map[string]any {
+		out := make(map[string]any, len(m)+1)
+		for k, v := range m {
+			out[k] = v
+		}
+		return out
+	}
+	out := copyMap(state)
+	inner := out
+	if nested, ok := out["state"].(map[string]any); ok {
+		inner = copyMap(nested)
+		out["state"] = inner
+	}
+
+	tracks := append([]any(nil), stateTrackList(state)...)
+	for _, action := range created {
+		track := map[string]any{"index": action["index"]}
+		if name, ok := action["name"]; ok {
+			track["name"] = name
+		}
+		tracks = append(tracks, track)
+	}
+	inner["tracks"] = tracks
+	return out
+}
+
+// stateTrackList returns the tracks of a REAPER state, which may be wrapped
+// in a "state" key
+func stateTrackList(state map[string]any) []any {
+	if state == nil {
+		return nil
+	}
+	if nested, ok := state["state"].(map[string]any); ok {
+		state = nested
+	}
+	switch tracks := state["tracks"].(type) {
+	case []any:
+		return tracks
+	case []map[string]any:
+		// Handle typed slice (e.g., from JSON unmarshaling)
+		list := make([]any, len(tracks))
+		for i, track := range tracks {
+			list[i] = track
+		}
+		return list
+	}
+	return nil
+}
+
+// trackResolver finds the track index a step's output goes to, from the
+// tracks in the project and the actions seen so far
+type trackResolver struct {
+	tracks    []namedTrack // In the order they were seen
+	clips     map[int]bool // Tracks a clip was created on
+	lastClip  int          // Track of the last created clip
+	lastTrack int          // Last track an action touched
+}
+
+// namedTrack is a track name and its stemmed words
+type namedTrack struct {
+	name  string
+	words []string
+	index int
+}
+
+func newTrackResolver(state map[string]any) *trackResolver {
+	r := &trackResolver{clips: map[int]bool{}, lastClip: -1, lastTrack: -1}
+	for _, track := range stateTrackList(state) {
+		trackMap, ok := track.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := trackMap["name"].(string)
+		if index, ok := getInt(trackMap, "index"); ok && name != "" {
+			r.addTrack(name, index)
+		}
+	}
+	return r
+}
+
+func (r *trackResolver) addTrack(name string, index int) {
+	r.tracks = append(r.tracks, namedTrack{name: name, words: tokenize(name), index: index})
+}
+
+// observe records the tracks and clips an action creates or touches
+func (r *trackResolver) observe(action map[string]any) {
+	if action["action"] == "create_track" {
+		if index, ok := action["index"].(int); ok {
+			if name, ok := action["name"].(string); ok && name != "" {
+				r.addTrack(name, index)
+			}
+			r.lastTrack = index
+		}
+		return
+	}
+	if isClipAction(action) {
+		if track, ok := action["track"].(int); ok {
+			r.clips[track] = true
+			r.lastClip = track
+		}
+	}
+	if track, ok := action["track"].(int); ok {
+		r.lastTrack = track
+	}
+}
+
+// resolve returns the track a target names, or for no target the track of the
+// last clip (or the last track touched)
+func (r *trackResolver) resolve(target *StepTarget) (int, bool) {
+	if target != nil && target.Track != "" {
+		return r.track(target.Track)
+	}
+	if r.lastClip >= 0 {
+		return r.lastClip, true
+	}
+	return r.lastTrack, r.lastTrack >= 0
+}
+
+// track returns the index of the named track, the newest if several match.
+// Names match case-insensitively, or else by their words up to plurals, one
+// name's words containing the other's: "drums" finds "Drum Kit".
+func (r *trackResolver) track(name string) (int, bool) {
+	for i := len(r.tracks) - 1; i >= 0; i-- {
+		if strings.EqualFold(r.tracks[i].name, name) {
+			return r.tracks[i].index, true
+		}
+	}
+	words := tokenize(name)
+	if len(words) == 0 {
+		return 0, false
+	}
+	for i := len(r.tracks) - 1; i >= 0; i-- {
+		track := r.tracks[i]
+		if len(track.words) > 0 && (containsWords(track.words, words) || containsWords(words, track.words)) {
+			return track.index, true
+		}
+	}
+	return 0, false
+}
+
+// containsWords reports whether all of want are in words
+func containsWords(words, want []string) bool {
+	for _, word := range want {
+		if !slices.Contains(words, word) {
+			return false
+		}
+	}
+	return true
+}
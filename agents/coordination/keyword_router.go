@@ -0,0 +1,328 @@
+package coordination
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Routing methods reported in RoutingDecision
+const (
+	RoutingMethodKeywords = "keywords"
+	RoutingMethodLLM      = "llm"
+)
+
+// DefaultRoutingConfidence is the keyword router confidence below which the
+// orchestrator asks the LLM router instead
+const DefaultRoutingConfidence = 0.7
+
+// Keyword scoring. An agent is needed at keywordNeeded, clearly not needed
+// below keywordAmbiguous, and the keyword router is unsure in between.
+const (
+	keywordNeeded    = 1.0
+	keywordAmbiguous = 0.5
+	sharedWeight     = 0.4 // Keyword that belongs to more than one agent
+	shortWeight      = 0.2 // Two letter keyword, e.g. "vi"
+)
+
+//go:embed expanded_keywords.json
+var expandedKeywordsJSON []byte
+
+// builtinKeywords holds the keyword sets of the built-in agents, keyed by
+// agent name. Generated by scripts/expand_keywords.py.
+var builtinKeywords map[string][]string
+
+func init() {
+	if err := json.Unmarshal(expandedKeywordsJSON, &builtinKeywords); err != nil {
+		panic(fmt.Sprintf("invalid expanded_keywords.json: %v", err))
+	}
+}
+
+// BuiltinKeywords returns the keyword set shipped for a built-in agent
+func BuiltinKeywords(name string) []string {
+	return append([]string(nil), builtinKeywords[name]...)
+}
+
+// nameMarkers are words followed by a track or clip name, which is not a
+// request for content: "the track called arpeggio"
+var nameMarkers = map[string]bool{"called": true, "named": true, "titled": true, "labeled": true, "labelled": true}
+
+// nameNouns are words a name comes before: "the drums track"
+var nameNouns = map[string]bool{"track": true, "bus": true, "folder": true, "channel": true}
+
+// trackControlWords are mix and track-control words. In a request with one,
+// a word right after a determiner names an existing track ("mute the drums",
+// "add reverb to the hi-hat") rather than asking for content.
+var trackControlWords = map[string]bool{
+	"mute": true, "unmute": true, "solo": true, "unsolo": true, "pan": true, "volume": true, "gain": true,
+	"louder": true, "quieter": true, "turn": true, "reverb": true, "delay": true, "compress": true,
+	"compressor": true, "eq": true, "fx": true, "effect": true, "plugin": true, "send": true, "route": true,
+	"rename": true, "delete": true, "remove": true, "arm": true, "select": true, "color": true, "colour": true,
+}
+
+// determiners come before a track reference: "the drums"
+var determiners = map[string]bool{"the": true, "my": true, "our": true, "your": true, "this": true, "that": true, "these": true, "those": true}
+
+// quotedPattern matches quoted names
+var quotedPattern = regexp.MustCompile(`"[^"]*"|“[^”]*”|'[^']*'`)
+
+// keywordEntry is a keyword split into stemmed tokens
+type keywordEntry struct {
+	tokens []string
+	weight float64
+	agents []string
+}
+
+// keywordIndex matches requests against the keyword sets of the registered agents
+type keywordIndex struct {
+	byFirst  map[string][]*keywordEntry // Keyed by first token, longest first
+	cjk      []*keywordEntry            // Matched as substrings, since CJK text has no spaces
+	patterns map[string][]*regexp.Regexp
+	judged   map[string]bool // Agents with keywords or patterns
+}
+
+// newKeywordIndex builds the index for the given descriptors
+func newKeywordIndex(descriptors []AgentDescriptor) *keywordIndex {
+	index := &keywordIndex{
+		byFirst:  map[string][]*keywordEntry{},
+		patterns: map[string][]*regexp.Regexp{},
+		judged:   map[string]bool{},
+	}
+
+	entries := map[string]*keywordEntry{}
+	var order []string
+	for _, descriptor := range descriptors {
+		for _, keyword := range descriptor.Keywords {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword == "" {
+				continue
+			}
+			entry, ok := entries[keyword]
+			if !ok {
+				entry = &keywordEntry{tokens: tokenize(keyword), weight: 1}
+				entries[keyword] = entry
+				order = append(order, keyword)
+			}
+			if len(entry.agents) == 0 || entry.agents[len(entry.agents)-1] != descriptor.Name {
+				entry.agents = append(entry.agents, descriptor.Name)
+			}
+			index.judged[descriptor.Name] = true
+		}
+		if len(descriptor.KeywordPatterns) > 0 {
+			index.patterns[descriptor.Name] = descriptor.KeywordPatterns
+			index.judged[descriptor.Name] = true
+		}
+	}
+
+	for _, keyword := range order {
+		entry := entries[keyword]
+		if len(entry.agents) > 1 {
+			entry.weight = sharedWeight
+		}
+		if len(keyword) == 1 {
+			// Note names like "a" are mostly articles; KeywordPatterns catch notes
+			continue
+		}
+		if len(keyword) == 2 && isASCII(keyword) {
+			entry.weight = min(entry.weight, shortWeight)
+		}
+		if hasCJK(keyword) {
+			entry.tokens = []string{keyword}
+			index.cjk = append(index.cjk, entry)
+			continue
+		}
+		if len(entry.tokens) == 0 {
+			continue
+		}
+		index.byFirst[entry.tokens[0]] = append(index.byFirst[entry.tokens[0]], entry)
+	}
+	for _, list := range index.byFirst {
+		sort.SliceStable(list, func(i, j int) bool { return len(list[i].tokens) > len(list[j].tokens) })
+	}
+	return index
+}
+
+// score returns the keyword score of each agent for a request and the number
+// of keywords matched
+func (index *keywordIndex) score(question string) (map[string]float64, int) {
+	scores := map[string]float64{}
+	matches := 0
+	control := false // The request has a track-control word
+	add := func(entry *keywordEntry, name bool) {
+		matches++
+		if name {
+			// Still a sign of a music production request, but not for content;
+			// with a control word, clearly one about the track
+			if control {
+				matches++
+			}
+			return
+		}
+		for _, agent := range entry.agents {
+			scores[agent] += entry.weight
+		}
+	}
+
+	// Names and tracks referred to are not requests for content, so leave them out
+	text := strings.ToLower(quotedPattern.ReplaceAllString(question, " | "))
+	tokens := tokenize(text)
+	skip := make([]bool, len(tokens))
+	control = slices.ContainsFunc(tokens, func(token string) bool { return trackControlWords[token] })
+	for i, token := range tokens {
+		if control && determiners[token] && i+1 < len(tokens) {
+			skip[i+1] = true
+		}
+		if nameMarkers[token] && i+1 < len(tokens) {
+			skip[i+1] = true
+		}
+		if (token == "rename" || token == "renamed") && i+1 < len(tokens) {
+			for j := i + 1; j < len(tokens); j++ {
+				if tokens[j] == "to" && j+1 < len(tokens) {
+					skip[j+1] = true
+					break
+				}
+			}
+		}
+		if nameNouns[token] && i > 0 {
+			skip[i-1] = true
+		}
+	}
+
+	// Longest keyword first, and a matched token is not matched again
+	for i := 0; i < len(tokens); {
+		matched := 0
+		for _, entry := range index.byFirst[tokens[i]] {
+			if hasTokens(tokens[i:], entry.tokens) {
+				add(entry, slices.Contains(skip[i:i+len(entry.tokens)], true))
+				matched = len(entry.tokens)
+				break
+			}
+		}
+		i += max(matched, 1)
+	}
+
+	for _, entry := range index.cjk {
+		if strings.Contains(text, entry.tokens[0]) {
+			add(entry, false)
+		}
+	}
+
+	for agent, patterns := range index.patterns {
+		for _, pattern := range patterns {
+			if pattern.MatchString(question) {
+				matches++
+				scores[agent] += keywordNeeded
+			}
+		}
+	}
+	return scores, matches
+}
+
+// hasTokens reports whether tokens starts with want
+func hasTokens(tokens []string, want []string) bool {
+	return len(tokens) >= len(want) && slices.Equal(tokens[:len(want)], want)
+}
+
+// routeByKeywords decides which routable agents a request needs from keyword
+// scores alone, with a confidence in [0, 1]. Agents without keywords cannot be
+// judged this way, so registering one keeps the confidence at 0.
+func (r *AgentRegistry) routeByKeywords(question string) *RoutingDecision {
+	scores, matches := r.keywords.score(question)
+	// Clearly a music production request: several keywords, or content clearly asked for
+	inDomain := matches >= 2
+	for _, descriptor := range r.routable() {
+		inDomain = inDomain || scores[descriptor.Name] >= keywordNeeded
+	}
+
+	decision := &RoutingDecision{
+		Agents:     []string{},
+		Method:     RoutingMethodKeywords,
+		Confidence: 1,
+		Scores:     map[string]float64{},
+		Reasons:    map[string]string{},
+	}
+
+	for _, agent := range r.agents {
+		descriptor := agent.Descriptor()
+		if descriptor.AlwaysRun {
+			decision.Agents = append(decision.Agents, descriptor.Name)
+			decision.Reasons[descriptor.Name] = reasonAlwaysRuns
+			continue
+		}
+
+		score := scores[descriptor.Name]
+		decision.Scores[descriptor.Name] = score
+		var confidence float64
+		switch {
+		case !r.keywords.judged[descriptor.Name]:
+			confidence = 0
+		case score >= keywordNeeded:
+			decision.Agents = append(decision.Agents, descriptor.Name)
+			decision.Reasons[descriptor.Name] = fmt.Sprintf("keyword score %.1f", score)
+			confidence = min(1, 0.6+0.2*score)
+		case score >= keywordAmbiguous:
+			confidence = 0.4
+		case score > 0:
+			confidence = 0.75
+		case inDomain:
+			// Nothing for this agent in a music production request
+			confidence = 0.9
+		default:
+			// Too little to go on: out of scope, or worded in a way we don't know
+			confidence = 0.3
+		}
+		decision.Confidence = min(decision.Confidence, confidence)
+	}
+	return decision
+}
+
+// tokenize lowercases text, splits it into words and stems them
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '#'
+	})
+	tokens := make([]string, len(fields))
+	for i, field := range fields {
+		tokens[i] = stem(field)
+	}
+	return tokens
+}
+
+// stem strips plural endings so "chords" matches "chord" and "hats" matches
+// "hat". Other languages' plurals mostly end in s as well.
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && (strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes") || strings.HasSuffix(word, "xes")):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// hasCJK reports whether s contains Chinese or Japanese characters
+func hasCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package coordination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func builtinRegistry(t *testing.T) *AgentRegistry {
+	t.Helper()
+	cfg := &config.Config{}
+	orchestrator := NewOrchestrator(cfg)
+	return orchestrator.Agents()
+}
+
+func TestKeywordRouting(t *testing.T) {
+	registry := builtinRegistry(t)
+
+	tests := []struct {
+		question string
+		agents   []string
+	}{
+		// DAW only
+		{"mute track 2", []string{"daw"}},
+		{"add reverb to the bass", []string{"daw"}},
+		{"pan the synth track to the left", []string{"daw"}},
+		{"solo track 3 and mute everything else", []string{"daw"}},
+		{"create a new track called Drums", []string{"daw"}},
+		{"rename the drums track to percussion", []string{"daw"}},
+		{"mute the drums", []string{"daw"}},
+		{"add reverb to the drums", []string{"daw"}},
+		{"pan the drums left", []string{"daw"}},
+		{"solo the bass and the drums", []string{"daw"}},
+		{"turn the hi-hat down", []string{"daw"}},
+		{`add a track named "Arpeggio" with reverb`, []string{"daw"}},
+		// Arranger
+		{"add a chord progression in C major", []string{"daw", "arranger"}},
+		{"create an arpeggio", []string{"daw", "arranger"}},
+		{"add sustained E1", []string{"daw", "arranger"}},
+		{"piano playing C Am F G", []string{"daw", "arranger"}},
+		{"añade una progresión de acordes", []string{"daw", "arranger"}},
+		// Drummer
+		{"make a four on the floor kick pattern", []string{"daw", "drummer"}},
+		{"create a hip hop beat with kicks and snares", []string{"daw", "drummer"}},
+		{"add some trap hi-hat rolls", []string{"daw", "drummer"}},
+		{"ドラムのビートを作って", []string{"daw", "drummer"}},
+		// Both
+		{"keys with a bassline and drums", []string{"daw", "arranger", "drummer"}},
+		{"add a snare roll and mute the kick", []string{"daw", "drummer"}},
+		// JSFX
+		{"build me a tape saturation effect and put it on the vocal", []string{"daw", "jsfx"}},
+		{"write a JSFX bitcrusher", []string{"daw", "jsfx"}},
+		// Mix
+		{"analyse my mix and fix the muddy bass", []string{"daw", "mix"}},
+		{"why do my vocals sound harsh?", []string{"daw", "mix"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.question, func(t *testing.T) {
+			decision := registry.routeByKeywords(tt.question)
+			assert.Equal(t, tt.agents, decision.Agents, "scores: %v", decision.Scores)
+			assert.GreaterOrEqual(t, decision.Confidence, DefaultRoutingConfidence, "scores: %v", decision.Scores)
+			assert.Equal(t, RoutingMethodKeywords, decision.Method)
+			assert.Len(t, decision.Reasons, len(tt.agents))
+			assert.Equal(t, "always runs", decision.Reasons[DAWAgentName])
+		})
+	}
+
+	decision := registry.routeByKeywords("add a breakbeat with ghost snares")
+	assert.Equal(t, "keyword score 2.0", decision.Reasons[DrummerAgentName], "scores: %v", decision.Scores)
+}
+
+func TestKeywordRouting_LowConfidence(t *testing.T) {
+	registry := builtinRegistry(t)
+
+	for _, question := range []string{
+		"bake me a cake",
+		"how do I make pasta carbonara",
+		"make it sound better",
+		"create harmonic content",
+	} {
+		t.Run(question, func(t *testing.T) {
+			decision := registry.routeByKeywords(question)
+			assert.Less(t, decision.Confidence, DefaultRoutingConfidence, "scores: %v", decision.Scores)
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"chords":     "chord",
+		"hats":       "hat",
+		"melodies":   "melody",
+		"bass":       "bass",
+		"acordes":    "acorde",
+		"this":       "this",
+		"snares":     "snare",
+		"crunches":   "crunch",
+		"percussion": "percussion",
+	} {
+		assert.Equal(t, want, stem(word), word)
+	}
+}
+
+func TestOrchestrator_Route_FallsBackToLLM(t *testing.T) {
+	provider := &routerProvider{answer: `{"arranger": true, "drummer": false}`}
+	registry := builtinRegistry(t)
+	orchestrator, err := NewOrchestratorWithAgents(provider, registry.Agents()...)
+	require.NoError(t, err)
+
+	decision, err := orchestrator.Route(context.Background(), "add a chord progression in C major")
+	require.NoError(t, err)
+	assert.Equal(t, RoutingMethodKeywords, decision.Method)
+	assert.Nil(t, provider.request, "confident keyword routing skips the LLM")
+
+	decision, err = orchestrator.Route(context.Background(), "create harmonic content")
+	require.NoError(t, err)
+	assert.Equal(t, RoutingMethodLLM, decision.Method)
+	assert.Equal(t, []string{"daw", "arranger"}, decision.Agents)
+	assert.Less(t, decision.Confidence, DefaultRoutingConfidence)
+	require.NotNil(t, provider.request)
+
+	provider.request = nil
+	orchestrator.SetRoutingConfidence(0)
+	decision, err = orchestrator.Route(context.Background(), "create harmonic content")
+	require.NoError(t, err)
+	assert.Equal(t, RoutingMethodKeywords, decision.Method)
+	assert.Equal(t, []string{"daw"}, decision.Agents)
+	assert.Nil(t, provider.request)
+}
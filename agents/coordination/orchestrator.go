@@ -5,35 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"sync"
 	"time"
 
 	arranger "github.com/Conceptual-Machines/magda-agents-go/agents/arranger"
 	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
 	"github.com/Conceptual-Machines/magda-agents-go/agents/drummer"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/jsfx"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/mix"
 	"github.com/Conceptual-Machines/magda-agents-go/config"
 	"github.com/Conceptual-Machines/magda-agents-go/llm"
 	"github.com/Conceptual-Machines/magda-agents-go/models"
 )
 
-// Orchestrator coordinates multiple agents (DAW + Arranger + Drummer) running in parallel
+// Orchestrator coordinates the registered agents (DAW, Arranger, Drummer, JSFX,
+// Mix and any custom agents), running the steps of a planned request in
+// parallel where their dependencies allow
 type Orchestrator struct {
-	dawAgent      *daw.DawAgent
-	arrangerAgent ArrangerAgent // Will be set when we integrate
-	drummerAgent  *drummer.DrummerAgent
-	llmProvider   llm.Provider
-}
-
-// ArrangerAgent interface for the arranger agent
-// Uses the actual arranger agent's ArrangerResult type
-type ArrangerAgent interface {
-	GenerateActions(ctx context.Context, question string) (*arranger.ArrangerResult, error)
-}
-
-// ArrangerResult represents the output from the arranger agent (internal format)
-type ArrangerResult struct {
-	Actions []map[string]any `json:"actions"` // Parsed DSL actions
-	Usage   any              `json:"usage"`
+	agents            *AgentRegistry
+	llmProvider       llm.Provider
+	routingConfidence float64       // Keyword router confidence needed to skip the LLM router
+	planning          bool          // Split multi-agent requests into planned steps
+	failurePolicy     FailurePolicy // What a failed agent that is not required does to the request
+	agentTimeouts     map[string]time.Duration
+	defaultTimeout    time.Duration // For agents without their own timeout; 0 = none
+	clarify           bool          // Ask a clarifying question instead of guessing on ambiguous requests
+	polishSummary     bool          // Rewrite the plan summary with the LLM
 }
 
 // MusicalChoice represents a musical composition choice
@@ -54,139 +52,282 @@ type NoteEvent struct {
 type OrchestratorResult struct {
 	Actions []map[string]any `json:"actions"`
 	Usage   any              `json:"usage"`
+	Routing *RoutingDecision `json:"routing,omitempty"` // Which agents ran and why
+	Plan    *ExecutionPlan   `json:"plan,omitempty"`    // The steps the agents ran
+	Agents  []*AgentStatus   `json:"agents,omitempty"`  // Outcome of each step, in plan order
+	Summary *PlanSummary     `json:"summary,omitempty"` // What the actions do, for the user
+
+	// Clarification is set, and no agent has run, when the clarifying-question
+	// mode is on and the request is ambiguous
+	Clarification *models.Clarification `json:"clarification,omitempty"`
 }
 
-// NewOrchestrator creates a new orchestrator instance
+// NewOrchestrator creates a new orchestrator instance with the built-in agents
 func NewOrchestrator(cfg *config.Config) *Orchestrator {
-	dawAgent := daw.NewDawAgent(cfg)
-	llmProvider := llm.NewOpenAIProvider(cfg.OpenAIAPIKey)
-
-	// Initialize arranger agent (basic, no MCP for now)
-	arrangerAgent := arranger.NewBasicArrangerAgent(cfg)
+	o, err := NewOrchestratorWithAgents(
+		llm.NewOpenAIProvider(cfg.OpenAIAPIKey),
+		WrapDawAgent(daw.NewDawAgent(cfg)),
+		// Basic arranger agent, no MCP for now
+		WrapArrangerAgent(arranger.NewBasicArrangerAgent(cfg)),
+		WrapDrummerAgentWithOptions(drummer.NewDrummerAgent(cfg), drumPatternOptions(cfg)),
+		WrapJSFXAgent(jsfx.NewJSFXAgent(cfg)),
+		WrapMixAgent(mix.NewMixAnalysisAgent(cfg)),
+	)
+	if err != nil {
+		// The built-in descriptors are fixed, so this is a programming error
+		panic(err)
+	}
+	return o
+}
 
-	// Initialize drummer agent
-	drummerAgent := drummer.NewDrummerAgent(cfg)
+// drumPatternOptions returns the drum pattern options for the configured drum
+// map, falling back to General MIDI for an unknown one
+func drumPatternOptions(cfg *config.Config) drummer.DrumPatternOptions {
+	options := drummer.DefaultDrumPatternOptions()
+	drumMap, err := drummer.DrumMapPreset(cfg.DrumMap)
+	if err != nil {
+		log.Printf("⚠️ %v, using %s", err, drummer.DefaultDrumMap)
+		return options
+	}
+	options.DrumMap = drumMap
+	return options
+}
 
+// NewOrchestratorWithAgents creates an orchestrator that routes with the given
+// provider to the given agents, in order
+func NewOrchestratorWithAgents(provider llm.Provider, agents ...Agent) (*Orchestrator, error) {
 	o := &Orchestrator{
-		dawAgent:      dawAgent,
-		arrangerAgent: arrangerAgent,
-		drummerAgent:  drummerAgent,
-		llmProvider:   llmProvider,
+		agents:            NewAgentRegistry(),
+		llmProvider:       provider,
+		routingConfidence: DefaultRoutingConfidence,
+		failurePolicy:     FailurePolicyWarn,
+		agentTimeouts:     map[string]time.Duration{},
 	}
+	for _, agent := range agents {
+		if err := o.Register(agent); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
 
-	return o
+// Register adds an agent the orchestrator can route requests to
+func (o *Orchestrator) Register(agent Agent) error {
+	return o.agents.Register(agent)
 }
 
-// GenerateActions coordinates parallel agent execution and merges results
-func (o *Orchestrator) GenerateActions(ctx context.Context, question string, state map[string]any) (*OrchestratorResult, error) {
-	// Step 1: Detect which agents are needed
+// Agents returns the orchestrator's agent registry
+func (o *Orchestrator) Agents() *AgentRegistry {
+	return o.agents
+}
+
+// SetRoutingConfidence sets the keyword router confidence (0-1) below which
+// the LLM router decides. 0 never asks the LLM; above 1 always does.
+func (o *Orchestrator) SetRoutingConfidence(confidence float64) {
+	o.routingConfidence = confidence
+}
+
+// SetAgentTimeout bounds each run of the named agent, so a slow agent cannot
+// hold up the response. 0 removes the agent's own timeout.
+func (o *Orchestrator) SetAgentTimeout(name string, timeout time.Duration) {
+	if timeout <= 0 {
+		delete(o.agentTimeouts, name)
+		return
+	}
+	o.agentTimeouts[name] = timeout
+}
+
+// SetDefaultAgentTimeout bounds each run of agents without their own timeout.
+// 0, the default, leaves them bounded only by the caller's context.
+func (o *Orchestrator) SetDefaultAgentTimeout(timeout time.Duration) {
+	o.defaultTimeout = timeout
+}
+
+// agentTimeout returns the timeout of the named agent, or 0 for none
+func (o *Orchestrator) agentTimeout(name string) time.Duration {
+	if timeout, ok := o.agentTimeouts[name]; ok {
+		return timeout
+	}
+	return o.defaultTimeout
+}
+
+// RoutingDecision lists the agents a request is routed to
+type RoutingDecision struct {
+	Agents     []string           `json:"agents"`            // Agent names, in registration order
+	Method     string             `json:"method"`            // RoutingMethodKeywords or RoutingMethodLLM
+	Confidence float64            `json:"confidence"`        // Keyword router confidence, 0-1
+	Scores     map[string]float64 `json:"scores,omitempty"`  // Keyword score per routable agent
+	Reasons    map[string]string  `json:"reasons,omitempty"` // Why each selected agent runs
+}
+
+// Routing reasons that are not keyword scores
+const (
+	reasonAlwaysRuns  = "always runs"
+	reasonLLM         = "selected by the LLM router"
+	reasonAutoEnabled = "auto-enabled: a musical agent needs a track and none exist"
+)
+
+// Needs reports whether the named agent was selected
+func (d *RoutingDecision) Needs(name string) bool {
+	for _, agent := range d.Agents {
+		if agent == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Route decides which agents a request needs. Requests the keyword router is
+// confident about are routed offline; the rest are classified by the LLM.
+// Agents that always run are selected without asking.
+func (o *Orchestrator) Route(ctx context.Context, question string) (*RoutingDecision, error) {
+	decision := o.agents.routeByKeywords(question)
+	if decision.Confidence >= o.routingConfidence || len(o.agents.routable()) == 0 {
+		return decision, nil
+	}
+
+	log.Printf("🔀 Keyword routing confidence %.2f below %.2f, asking LLM router", decision.Confidence, o.routingConfidence)
+	selected, err := o.classify(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("LLM classification failed: %w", err)
+	}
+
+	decision.Method = RoutingMethodLLM
+	decision.Agents = []string{}
+	decision.Reasons = map[string]string{}
+	for _, agent := range o.agents.Agents() {
+		descriptor := agent.Descriptor()
+		switch {
+		case descriptor.AlwaysRun:
+			decision.Reasons[descriptor.Name] = reasonAlwaysRuns
+		case selected[descriptor.Name]:
+			decision.Reasons[descriptor.Name] = reasonLLM
+		default:
+			continue
+		}
+		decision.Agents = append(decision.Agents, descriptor.Name)
+	}
+	return decision, nil
+}
+
+// DetectAgentsNeeded uses the router to detect which musical agents are needed
+// DAW agent is ALWAYS used (handles all REAPER operations: tracks, clips, FX, etc.)
+// Arranger and Drummer are optional based on musical content requested
+func (o *Orchestrator) DetectAgentsNeeded(ctx context.Context, question string) (needsDAW, needsArranger, needsDrummer bool, err error) {
+	decision, err := o.Route(ctx, question)
+	if err != nil {
+		return false, false, false, err
+	}
+	return decision.Needs(DAWAgentName), decision.Needs(ArrangerAgentName), decision.Needs(DrummerAgentName), nil
+}
+
+// classify uses a small LLM to decide which routable agents are needed.
+// Returns no agents if the request is out of scope.
+func (o *Orchestrator) classify(ctx context.Context, question string) (map[string]bool, error) {
+	// Use a small, fast model for classification
+	request := &llm.GenerationRequest{
+		Model:         "gpt-4.1-mini", // Fast and cheap for classification
+		InputArray:    []map[string]any{{"role": "user", "content": o.agents.RouterPrompt(question)}},
+		ReasoningMode: "none",
+		OutputSchema: &llm.OutputSchema{
+			Name:        "AgentClassification",
+			Description: "Classification of which specialized agents are needed",
+			Schema:      o.agents.routerSchema(),
+		},
+	}
+
+	resp, err := o.llmProvider.Generate(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse response from RawOutput (JSON Schema returns structured JSON)
+	result := map[string]bool{}
+	if resp.RawOutput != "" {
+		if parseErr := json.Unmarshal([]byte(resp.RawOutput), &result); parseErr != nil {
+			log.Printf("⚠️ Failed to parse LLM classification JSON: %v, raw: %s", parseErr, resp.RawOutput)
+			return nil, fmt.Errorf("failed to parse LLM classification: %w", parseErr)
+		}
+	}
+	return result, nil
+}
+
+// selectAgents routes a request and returns the agents to run, in registration order
+func (o *Orchestrator) selectAgents(
+	ctx context.Context, question string, state map[string]any, logPrefix string,
+) ([]Agent, *RoutingDecision, error) {
 	detectionStart := time.Now()
-	needsDAW, needsArranger, needsDrummer, err := o.DetectAgentsNeeded(ctx, question)
+	decision, err := o.Route(ctx, question)
 	detectionDuration := time.Since(detectionStart)
 	if err != nil {
-		log.Printf("⏱️ Agent detection failed in %v", detectionDuration)
-		// DetectAgentsNeeded already handles LLM validation when no keywords are found
-		// If it returns an error, the request is out of scope
-		return nil, err
+		log.Printf("⏱️ %sAgent detection failed in %v", logPrefix, detectionDuration)
+		// The router returns an error when the request is out of scope
+		return nil, nil, err
 	}
 
-	log.Printf("🔍 Agent detection: DAW=%v, Arranger=%v, Drummer=%v (took %v)", needsDAW, needsArranger, needsDrummer, detectionDuration)
+	log.Printf("🔍 %sAgent detection: %v by %s, confidence %.2f (took %v)",
+		logPrefix, decision.Agents, decision.Method, decision.Confidence, detectionDuration)
 
-	// Step 1.5: Auto-enable DAW if arranger or drummer is needed but no tracks exist
+	// Auto-enable required agents if a musical agent needs a track but none exist
 	// This ensures track creation happens before musical content is added
-	if (needsArranger || needsDrummer) && !needsDAW {
-		trackCount := getTrackCount(state)
-		if trackCount == 0 {
-			log.Printf("🔧 Auto-enabling DAW agent: Musical agent needs a track but none exist")
-			needsDAW = true
-		}
-	}
-
-	// Step 2: Launch only needed agents in parallel
-	var wg sync.WaitGroup
-	var dawResult *daw.DawResult
-	var arrangerResult *ArrangerResult
-	var drummerResult *drummer.DrummerResult
-	var dawErr error
-	var dawDuration, arrangerDuration, drummerDuration time.Duration
-
-	if needsDAW {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			result, err := o.dawAgent.GenerateActions(ctx, question, state)
-			dawDuration = time.Since(start)
-			if err != nil {
-				dawErr = fmt.Errorf("daw agent: %w", err)
-				log.Printf("⏱️ DAW agent failed in %v", dawDuration)
-				return
-			}
-			log.Printf("⏱️ DAW agent completed in %v", dawDuration)
-			dawResult = result
-		}()
-	}
-
-	if needsArranger && o.arrangerAgent != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			// Call arranger agent with question
-			result, err := o.arrangerAgent.GenerateActions(ctx, question)
-			arrangerDuration = time.Since(start)
-			if err != nil {
-				log.Printf("⚠️ Arranger agent failed in %v: %v", arrangerDuration, err)
-				return
-			}
-			log.Printf("⏱️ Arranger agent completed in %v", arrangerDuration)
-			// Use arranger result directly
-			arrangerResult = &ArrangerResult{
-				Actions: result.Actions,
-				Usage:   result.Usage,
-			}
-		}()
-	}
-
-	if needsDrummer && o.drummerAgent != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			// Build input array from question
-			inputArray := []map[string]any{
-				{
-					"role":    "user",
-					"content": question,
-				},
-			}
-			result, err := o.drummerAgent.Generate(ctx, "gpt-5.1", inputArray)
-			drummerDuration = time.Since(start)
-			if err != nil {
-				log.Printf("⚠️ Drummer agent failed in %v: %v", drummerDuration, err)
-				return
-			}
-			log.Printf("⏱️ Drummer agent completed in %v", drummerDuration)
-			drummerResult = result
-		}()
+	needsTrack := false
+	for _, name := range decision.Agents {
+		if agent, ok := o.agents.Get(name); ok && agent.Descriptor().NeedsTrack {
+			needsTrack = true
+		}
 	}
+	noTracks := needsTrack && getTrackCount(state) == 0
+
+	var agents []Agent
+	for _, agent := range o.agents.Agents() {
+		descriptor := agent.Descriptor()
+		switch {
+		case decision.Needs(descriptor.Name):
+			agents = append(agents, agent)
+		case noTracks && descriptor.Required:
+			log.Printf("🔧 %sAuto-enabling %s agent: Musical agent needs a track but none exist", logPrefix, descriptor.Name)
+			decision.Reasons[descriptor.Name] = reasonAutoEnabled
+			agents = append(agents, agent)
+		}
+	}
+	return agents, decision, nil
+}
 
-	// Wait for all active agents to complete
-	wg.Wait()
+// GenerateActions plans the request, runs the planned agent steps and merges results
+func (o *Orchestrator) GenerateActions(ctx context.Context, question string, state map[string]any) (*OrchestratorResult, error) {
+	return o.generateActions(ctx, question, state, o.clarify)
+}
 
-	// Log timing summary
-	log.Printf("⏱️ Agent timing summary: DAW=%v, Arranger=%v, Drummer=%v", dawDuration, arrangerDuration, drummerDuration)
+// generateActions is GenerateActions, asking clarifying questions if clarify is set
+func (o *Orchestrator) generateActions(ctx context.Context, question string, state map[string]any, clarify bool) (*OrchestratorResult, error) {
+	// Step 1: Detect which agents are needed
+	agents, decision, err := o.selectAgents(ctx, question, state, "")
+	if err != nil {
+		return nil, err
+	}
+	if clarification := o.clarification(question, state, agents, clarify, ""); clarification != nil {
+		return &OrchestratorResult{Routing: decision, Clarification: clarification}, nil
+	}
 
-	// Step 3: Handle errors
-	// DAW is the gatekeeper - if it fails, fail the entire request
-	// This prevents garbage results from Arranger/Drummer being returned for out-of-scope requests
-	if dawErr != nil {
-		return nil, fmt.Errorf("DAW agent failed: %w", dawErr)
+	// Step 2: Plan the steps and their targets
+	plan := o.plan(ctx, question, state, agents)
+	logPlan(plan, "")
+
+	// Step 3: Run the steps, independent ones in parallel
+	results := o.execute(ctx, plan, state, executionHooks{}, "")
+
+	// Step 4: Handle errors
+	if err := o.failure(results); err != nil {
+		return nil, err
 	}
-	// For non-DAW agents, partial failures are OK (their results just won't be included)
 
-	// Step 4: Merge results
-	return o.mergeResults(dawResult, arrangerResult, drummerResult)
+	// Step 5: Merge results
+	result := o.mergeResults(results, state)
+	result.Routing = decision
+	result.Plan = plan
+	result.Agents = statuses(results, decision)
+	result.Summary = o.summarize(ctx, question, result.Actions, state, "")
+	return result, nil
 }
 
 // StreamActionCallback is called for each action found during streaming
@@ -194,527 +335,592 @@ type StreamActionCallback func(action map[string]any) error
 
 // GenerateActionsStream coordinates agents and emits actions progressively via callback.
 // This allows the UI to execute actions (create track, create clip) as they arrive,
-// masking latency. MIDI notes are buffered until the clip is created, then emitted.
+// masking latency. All steps stream: actions are emitted as they are parsed. The
+// output of agents that need a track is held until it can be placed: output with a
+// target track until that track exists, and other output until a clip exists and
+// the required agents have finished. Notes are emitted as their own add_midi batch.
 func (o *Orchestrator) GenerateActionsStream(
 	ctx context.Context,
 	question string,
 	state map[string]any,
 	callback StreamActionCallback,
+) (*OrchestratorResult, error) {
+	return o.GenerateActionsStreamWithStatus(ctx, question, state, callback, nil)
+}
+
+// GenerateActionsStreamWithStatus streams actions like GenerateActionsStream,
+// and passes the status of each step to status as the step finishes
+func (o *Orchestrator) GenerateActionsStreamWithStatus(
+	ctx context.Context,
+	question string,
+	state map[string]any,
+	callback StreamActionCallback,
+	status StreamStatusCallback,
+) (*OrchestratorResult, error) {
+	return o.GenerateActionsStreamWithOptions(ctx, question, state, StreamOptions{OnAction: callback, OnStatus: status})
+}
+
+// StreamOptions configures GenerateActionsStreamWithOptions. All callbacks are optional.
+type StreamOptions struct {
+	OnAction StreamActionCallback // Each action, as soon as it can be placed
+	OnStatus StreamStatusCallback // Each step's status, as the step finishes
+
+	// FirstUsefulResult returns as soon as the required agents (the DAW agent)
+	// have finished, or the first step without any, with the actions sent so far.
+	// The other steps keep running, and their output is delivered through
+	// OnFollowUp instead of OnAction. Canceling the caller's context stops them
+	// only until the first result is returned; after that they run to the end,
+	// within their agents' timeouts, so the caller may cancel it on return.
+	FirstUsefulResult bool
+	OnFollowUp        StreamFollowUpCallback
+
+	// OnSummary gets the plan summary of all the actions, once they have all
+	// been sent. It is the last callback of a stream.
+	OnSummary StreamSummaryCallback
+}
+
+// FollowUpEvent delivers output that arrived after a first useful result was
+// returned. The last event has Done set, the outcome of every step, the plan
+// summary of all the actions and, if the failure policy fails the request
+// because of a late step, the error.
+type FollowUpEvent struct {
+	Actions []map[string]any `json:"actions,omitempty"` // Late actions, placed on their tracks
+	Agents  []*AgentStatus   `json:"agents,omitempty"`
+	Summary *PlanSummary     `json:"summary,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Done    bool             `json:"done"`
+}
+
+// StreamFollowUpCallback is called with each follow-up event
+type StreamFollowUpCallback func(event *FollowUpEvent)
+
+// StreamSummaryCallback is called with the plan summary at the end of a stream
+type StreamSummaryCallback func(summary *PlanSummary)
+
+// GenerateActionsStreamWithOptions streams actions like GenerateActionsStream,
+// with step statuses and, optionally, an early return once the actions the
+// user needs first are out
+func (o *Orchestrator) GenerateActionsStreamWithOptions(
+	ctx context.Context,
+	question string,
+	state map[string]any,
+	options StreamOptions,
+) (*OrchestratorResult, error) {
+	return o.generateActionsStream(ctx, question, state, options, o.clarify)
+}
+
+// generateActionsStream is GenerateActionsStreamWithOptions, asking clarifying
+// questions if clarify is set
+func (o *Orchestrator) generateActionsStream(
+	ctx context.Context,
+	question string,
+	state map[string]any,
+	options StreamOptions,
+	clarify bool,
 ) (*OrchestratorResult, error) {
 	// Step 1: Detect which agents are needed
-	detectionStart := time.Now()
-	needsDAW, needsArranger, needsDrummer, err := o.DetectAgentsNeeded(ctx, question)
-	detectionDuration := time.Since(detectionStart)
+	agents, decision, err := o.selectAgents(ctx, question, state, "[Stream] ")
 	if err != nil {
-		log.Printf("⏱️ [Stream] Agent detection failed in %v", detectionDuration)
-		// DetectAgentsNeeded already handles LLM validation when no keywords are found
-		// If it returns an error, the request is out of scope
 		return nil, err
 	}
-
-	log.Printf("🔍 [Stream] Agent detection: DAW=%v, Arranger=%v, Drummer=%v (took %v)", needsDAW, needsArranger, needsDrummer, detectionDuration)
-
-	// Step 1.5: Auto-enable DAW if arranger or drummer is needed but no tracks exist
-	if (needsArranger || needsDrummer) && !needsDAW {
-		trackCount := getTrackCount(state)
-		if trackCount == 0 {
-			log.Printf("🔧 [Stream] Auto-enabling DAW agent: Musical agent needs a track but none exist")
-			needsDAW = true
-		}
+	if clarification := o.clarification(question, state, agents, clarify, "[Stream] "); clarification != nil {
+		return &OrchestratorResult{Routing: decision, Clarification: clarification}, nil
 	}
 
+	// Step 2: Plan the steps and their targets
+	plan := o.plan(ctx, question, state, agents)
+	logPlan(plan, "[Stream] ")
+
 	// Track state for dependency resolution
 	var (
-		mu               sync.Mutex
-		pendingNotes     []models.NoteEvent
-		clipCreated      bool
-		targetTrackIdx   int = 0
-		allActions       []map[string]any
-		dawComplete      bool
-		arrangerComplete bool
-		drummerComplete  bool
+		mu           sync.Mutex
+		resolver     = newTrackResolver(state)
+		beatsPerBar  = stateBeatsPerBar(state)
+		held         = map[string]*heldOutput{}
+		delivered    = map[string]bool{} // Steps some output was sent for
+		allActions   []map[string]any
+		requiredLeft int
+		inFlight     sync.WaitGroup // Deliveries to OnAction under way
+		returned     bool           // A first useful result was returned; the rest is follow-up
+		done         = map[string]*AgentStatus{}
+		useful       = make(chan struct{}) // Closed when a first useful result is ready
+		usefulOnce   sync.Once
+		requiredErr  bool
 	)
-
-	// Helper to emit action via callback and track it
-	emitAction := func(action map[string]any) error {
-		mu.Lock()
-		allActions = append(allActions, action)
-		mu.Unlock()
-		if callback != nil {
-			return callback(action)
+	for _, step := range plan.Steps {
+		if agent, _ := o.agents.Get(step.Agent); agent.Descriptor().Required {
+			requiredLeft++
 		}
-		return nil
 	}
+	hasRequired := requiredLeft > 0
 
-	// Helper to check if we can emit add_midi (needs clip and notes, and all agents done)
-	tryEmitMidi := func() error {
-		mu.Lock()
-		defer mu.Unlock()
-
-		if clipCreated && len(pendingNotes) > 0 && dawComplete && arrangerComplete && drummerComplete {
-			// Convert NoteEvents to map format
-			notesArray := make([]map[string]any, len(pendingNotes))
-			for i, note := range pendingNotes {
-				notesArray[i] = map[string]any{
-					"pitch":    note.MidiNoteNumber,
-					"velocity": note.Velocity,
-					"start":    note.StartBeats,
-					"length":   note.DurationBeats,
+	// Helper to send actions via callback, tracking them (mu must be held)
+	send := func(actions []map[string]any) func() error {
+		for _, action := range actions {
+			resolver.observe(action)
+		}
+		allActions = append(allActions, actions...)
+		followUp := returned
+		if !followUp {
+			inFlight.Add(1)
+		}
+		return func() error {
+			if followUp {
+				if len(actions) > 0 && options.OnFollowUp != nil {
+					options.OnFollowUp(&FollowUpEvent{Actions: actions})
 				}
+				return nil
 			}
-
-			midiAction := map[string]any{
-				"action": "add_midi",
-				"track":  targetTrackIdx,
-				"notes":  notesArray,
+			defer inFlight.Done()
+			if options.OnAction == nil {
+				return nil
 			}
-
-			log.Printf("🎵 [Stream] Emitting add_midi with %d notes to track %d", len(pendingNotes), targetTrackIdx)
-			allActions = append(allActions, midiAction)
-			pendingNotes = nil // Clear buffer
-
-			if callback != nil {
-				// Unlock before callback to avoid deadlock
-				mu.Unlock()
-				err := callback(midiAction)
-				mu.Lock()
-				return err
+			for _, action := range actions {
+				if err := options.OnAction(action); err != nil {
+					return err
+				}
 			}
+			return nil
 		}
-		return nil
 	}
 
-	// Step 2: Launch agents
-	var wg sync.WaitGroup
-	var dawErr error
-
-	if needsDAW {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			defer func() {
-				mu.Lock()
-				dawComplete = true
-				mu.Unlock()
-				log.Printf("⏱️ [Stream] DAW agent completed in %v", time.Since(start))
-				_ = tryEmitMidi()
-			}()
-
-			// Use streaming DAW agent
-			dawCallback := func(action map[string]any) error {
-				actionType, _ := action["action"].(string)
-				log.Printf("🎬 [Stream] DAW action: %s", actionType)
-
-				// Track clip creation for dependency resolution
-				if actionType == "create_clip_at_bar" || actionType == "new_clip" {
-					mu.Lock()
-					clipCreated = true
-					if trackIdx, ok := action["track"].(int); ok {
-						targetTrackIdx = trackIdx
-					}
-					mu.Unlock()
-					log.Printf("📋 [Stream] Clip created on track %d", targetTrackIdx)
-				}
-
-				// Track the track index from create_track
-				if actionType == "create_track" {
-					if idx, ok := action["index"].(int); ok {
-						mu.Lock()
-						targetTrackIdx = idx
-						mu.Unlock()
-					}
+	// Helper to emit held output whose track is ready
+	flush := func(final bool) error {
+		mu.Lock()
+		var ready []map[string]any
+		for _, step := range plan.Steps {
+			h := held[step.ID]
+			if h == nil || h.empty() {
+				continue
+			}
+			track, ok := resolver.resolve(step.Target)
+			switch {
+			case final:
+			case step.Target != nil:
+				if !ok || (!resolver.clips[track] && requiredLeft > 0) {
+					continue
 				}
-
-				// Emit immediately (create_track, create_clip, etc.)
-				return emitAction(action)
+			case resolver.lastClip < 0 || requiredLeft > 0:
+				continue
 			}
-
-			_, err := o.dawAgent.GenerateActionsStream(ctx, question, state, dawCallback)
-			if err != nil {
-				dawErr = fmt.Errorf("daw agent stream: %w", err)
-				log.Printf("❌ [Stream] DAW agent error: %v", err)
+			if len(h.notes) > 0 {
+				log.Printf("🎵 [Stream] Emitting add_midi with %d notes to track %d", len(h.notes), track)
 			}
-		}()
-	} else {
-		mu.Lock()
-		dawComplete = true
+			delivered[step.ID] = true
+			ready = append(ready, h.take(track, ok)...)
+		}
+		deliver := send(ready)
 		mu.Unlock()
+		// Deliver outside the lock to avoid deadlock
+		return deliver()
 	}
 
-	if needsArranger && o.arrangerAgent != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			defer func() {
-				mu.Lock()
-				arrangerComplete = true
-				mu.Unlock()
-				log.Printf("⏱️ [Stream] Arranger agent completed in %v", time.Since(start))
-				_ = tryEmitMidi()
-			}()
+	// Emit actions immediately (create_track, create_clip, etc.) and hold the
+	// output that waits for its track
+	emit := func(step *PlanStep, output *AgentOutput) error {
+		agent, _ := o.agents.Get(step.Agent)
+		placed := agent.Descriptor().NeedsTrack
+		if placed {
+			output = offsetOutput(output, step.Target, beatsPerBar)
+		}
 
-			result, err := o.arrangerAgent.GenerateActions(ctx, question)
-			if err != nil {
-				log.Printf("⚠️ [Stream] Arranger agent error: %v", err)
-				return
+		mu.Lock()
+		h := held[step.ID]
+		if h == nil {
+			h = &heldOutput{}
+			held[step.ID] = h
+		}
+		var deliver func() error
+		if placed {
+			h.actions = append(h.actions, output.Actions...)
+		} else {
+			for _, action := range output.Actions {
+				log.Printf("🎬 [Stream] %s action: %v", step.ID, action["action"])
 			}
+			delivered[step.ID] = delivered[step.ID] || len(output.Actions) > 0
+			deliver = send(output.Actions)
+		}
+		h.notes = append(h.notes, output.Notes...)
+		if len(output.Notes) > 0 {
+			log.Printf("📦 [Stream] Buffered %d %s notes", len(output.Notes), step.ID)
+		}
+		mu.Unlock()
 
-			// Convert arranger actions to NoteEvents and buffer them
-			currentBeat := 0.0
-			for _, action := range result.Actions {
-				noteEvents, err := arranger.ConvertArrangerActionToNoteEvents(action, currentBeat)
-				if err != nil {
-					log.Printf("⚠️ [Stream] Failed to convert arranger action: %v", err)
-					continue
-				}
-
-				mu.Lock()
-				pendingNotes = append(pendingNotes, noteEvents...)
-				mu.Unlock()
-
-				log.Printf("📦 [Stream] Buffered %d notes (total: %d)", len(noteEvents), len(pendingNotes))
-
-				// Update beat position
-				if length, ok := getFloat(action, "length"); ok {
-					if repeat, ok := getInt(action, "repeat"); ok && repeat > 0 {
-						currentBeat += length * float64(repeat)
-					} else {
-						currentBeat += length
-					}
-				}
+		if deliver != nil {
+			if err := deliver(); err != nil {
+				return err
 			}
-		}()
-	} else {
+		}
+		return flush(false)
+	}
+
+	// A failed step can be retried only if none of its output was sent
+	restart := func(step *PlanStep) bool {
 		mu.Lock()
-		arrangerComplete = true
-		mu.Unlock()
+		defer mu.Unlock()
+		if delivered[step.ID] {
+			log.Printf("⚠️ [Stream] Not retrying step %s: its output was already sent", step.ID)
+			return false
+		}
+		delete(held, step.ID)
+		return true
 	}
 
-	if needsDrummer && o.drummerAgent != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			start := time.Now()
-			defer func() {
-				mu.Lock()
-				drummerComplete = true
-				mu.Unlock()
-				log.Printf("⏱️ [Stream] Drummer agent completed in %v", time.Since(start))
-				_ = tryEmitMidi()
-			}()
+	finished := func(result *stepResult) {
+		status := result.status(decision)
+		required := result.agent.Descriptor().Required
+		mu.Lock()
+		if required {
+			requiredLeft--
+			requiredErr = requiredErr || result.err != nil
+		}
+		if result.err != nil {
+			// Nothing more of a failed step's output is sent
+			delete(held, result.step.ID)
+		}
+		done[result.step.ID] = status
+		ready := requiredLeft == 0 && (hasRequired || len(done) > 0)
+		mu.Unlock()
 
-			// Build input array from question
-			inputArray := []map[string]any{
-				{
-					"role":    "user",
-					"content": question,
-				},
-			}
-			result, err := o.drummerAgent.Generate(ctx, "gpt-5.1", inputArray)
-			if err != nil {
-				log.Printf("⚠️ [Stream] Drummer agent error: %v", err)
-				return
-			}
+		_ = flush(false)
+		if options.OnStatus != nil {
+			options.OnStatus(status)
+		}
+		if ready {
+			usefulOnce.Do(func() { close(useful) })
+		}
+	}
 
-			// Emit drummer actions directly (they're already in action format)
-			for _, action := range result.Actions {
-				log.Printf("🥁 [Stream] Emitting drummer action: %v", action["type"])
-				if emitErr := emitAction(action); emitErr != nil {
-					log.Printf("⚠️ [Stream] Failed to emit drummer action: %v", emitErr)
-				}
-			}
-		}()
-	} else {
+	// Step 3: Run the steps, independent ones in parallel. The steps outlive
+	// the caller's context once a first useful result is returned.
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	stopCancel := context.AfterFunc(ctx, cancelRun)
+	var results []*stepResult
+	executed := make(chan struct{})
+	go func() {
+		defer close(executed)
+		results = o.execute(runCtx, plan, state, executionHooks{emit: emit, restart: restart, finished: finished}, "[Stream] ")
+	}()
+
+	if options.FirstUsefulResult {
+		select {
+		case <-useful:
+		case <-executed:
+		}
 		mu.Lock()
-		drummerComplete = true
+		early := !requiredErr
 		mu.Unlock()
+		select {
+		case <-executed:
+			early = false
+		default:
+		}
+		if early {
+			// Later output goes to OnFollowUp; wait for what is already on its way
+			mu.Lock()
+			returned = true
+			mu.Unlock()
+			inFlight.Wait()
+
+			mu.Lock()
+			result := &OrchestratorResult{
+				Actions: append([]map[string]any(nil), allActions...),
+				Routing: decision,
+				Plan:    plan,
+				Agents:  pendingStatuses(plan, done, decision),
+			}
+			mu.Unlock()
+
+			stopCancel()
+			go func() {
+				defer cancelRun()
+				<-executed
+				_ = flush(true)
+				log.Printf("✅ [Stream] Follow-up complete: %d total actions emitted", len(allActions))
+				event := &FollowUpEvent{Agents: statuses(results, decision), Done: true}
+				if err := o.failure(results); err != nil {
+					log.Printf("❌ [Stream] Follow-up failed: %v", err)
+					event.Error = err.Error()
+				}
+				event.Summary = o.summarize(runCtx, question, allActions, state, "[Stream] ")
+				if options.OnSummary != nil {
+					options.OnSummary(event.Summary)
+				}
+				if options.OnFollowUp != nil {
+					options.OnFollowUp(event)
+				}
+			}()
+			log.Printf("⚡ [Stream] First useful result: %d actions, %d steps still running", len(result.Actions), len(plan.Steps)-len(done))
+			return result, nil
+		}
 	}
+	<-executed
+	stopCancel()
+	cancelRun()
 
-	// Wait for all agents
-	wg.Wait()
-
-	// Final check - emit any remaining MIDI
-	_ = tryEmitMidi()
+	// Final check - emit any remaining held output
+	_ = flush(true)
 
-	// DAW is the gatekeeper - if it fails, fail the entire request
-	// This prevents garbage results from Arranger/Drummer being returned for out-of-scope requests
-	if dawErr != nil {
-		return nil, fmt.Errorf("DAW agent failed: %w", dawErr)
+	if err := o.failure(results); err != nil {
+		return nil, err
 	}
 
-	// For non-DAW agents, partial failures are OK (their results just won't be included)
-
 	// Return all collected actions
 	mu.Lock()
 	result := &OrchestratorResult{
 		Actions: allActions,
+		Routing: decision,
+		Plan:    plan,
+		Agents:  statuses(results, decision),
 	}
 	mu.Unlock()
 
 	log.Printf("✅ [Stream] Complete: %d total actions emitted", len(result.Actions))
+	result.Summary = o.summarize(ctx, question, result.Actions, state, "[Stream] ")
+	if options.OnSummary != nil {
+		options.OnSummary(result.Summary)
+	}
 	return result, nil
 }
 
-// DetectAgentsNeeded uses LLM to detect which musical agents are needed
-// DAW agent is ALWAYS used (handles all REAPER operations: tracks, clips, FX, etc.)
-// Arranger and Drummer are optional based on musical content requested
-func (o *Orchestrator) DetectAgentsNeeded(ctx context.Context, question string) (needsDAW, needsArranger, needsDrummer bool, err error) {
-	// Use LLM to classify if Arranger or Drummer are needed
-	_, needsArranger, needsDrummer, llmErr := o.detectAgentsNeededLLM(ctx, question)
-	if llmErr != nil {
-		return false, false, false, fmt.Errorf("LLM classification failed: %w", llmErr)
-	}
-
-	// DAW is always needed - it handles all REAPER operations
-	needsDAW = true
-
-	return needsDAW, needsArranger, needsDrummer, nil
+// heldOutput is step output waiting for its track
+type heldOutput struct {
+	actions []map[string]any
+	notes   []models.NoteEvent
 }
 
-// detectAgentsNeededLLM uses LLM to classify which musical agents are needed
-// DAW agent is always used (handled by caller), this only classifies Arranger and Drummer
-// Returns needsArranger=false, needsDrummer=false if request is out of scope
-func (o *Orchestrator) detectAgentsNeededLLM(ctx context.Context, question string) (needsDAW, needsArranger, needsDrummer bool, err error) {
-	prompt := fmt.Sprintf(`You are a router for a music production AI system. Classify requests to determine which specialized agents are needed.
-
-THE SYSTEM HAS 3 AGENTS:
-1. DAW AGENT (always runs): Handles REAPER operations - tracks, clips, FX, volume, pan, mute, solo, routing. Does NOT generate musical content.
-2. ARRANGER AGENT: Generates melodic/harmonic MIDI content - chords, arpeggios, melodies, basslines, chord progressions. Creates actual notes with pitches.
-3. DRUMMER AGENT: Generates drum/percussion patterns - kick, snare, hi-hat, toms, cymbals. Creates rhythmic patterns on a grid.
-
-YOUR TASK: Decide if ARRANGER and/or DRUMMER are needed (DAW always runs).
-
-EXAMPLES:
-- "create a track called Drums" → {"needsArranger": false, "needsDrummer": false} (just naming a track, no content)
-- "add reverb to the bass" → {"needsArranger": false, "needsDrummer": false} (FX operation)
-- "mute track 2" → {"needsArranger": false, "needsDrummer": false} (track control)
-- "add a breakbeat pattern" → {"needsArranger": false, "needsDrummer": true} (generating drums)
-- "create a funk groove with ghost notes" → {"needsArranger": false, "needsDrummer": true} (drum pattern)
-- "add a chord progression in C major" → {"needsArranger": true, "needsDrummer": false} (harmonic content)
-- "create an arpeggio" → {"needsArranger": true, "needsDrummer": false} (melodic content)
-- "add sustained E1" → {"needsArranger": true, "needsDrummer": false} (single note = melodic content)
-- "add note C4" → {"needsArranger": true, "needsDrummer": false} (single note = melodic content)
-- "bass note at bar 2" → {"needsArranger": true, "needsDrummer": false} (single note = melodic content)
-- "create a hip hop beat with kicks and snares" → {"needsArranger": false, "needsDrummer": true} (drum pattern)
-
-REQUEST: "%s"
-
-Return JSON: {"needsArranger": bool, "needsDrummer": bool}`, question)
-
-	// Use a small, fast model for classification
-	request := &llm.GenerationRequest{
-		Model:         "gpt-4.1-mini", // Fast and cheap for classification
-		InputArray:    []map[string]any{{"role": "user", "content": prompt}},
-		ReasoningMode: "none",
-		OutputSchema: &llm.OutputSchema{
-			Name:        "MusicalAgentClassification",
-			Description: "Classification of which musical agents (Arranger/Drummer) are needed",
-			Schema: map[string]any{
-				"type":                 "object",
-				"additionalProperties": false,
-				"properties": map[string]any{
-					"needsArranger": map[string]any{
-						"type": "boolean",
-					},
-					"needsDrummer": map[string]any{
-						"type": "boolean",
-					},
-				},
-				"required": []string{"needsArranger", "needsDrummer"},
-			},
-		},
-	}
-
-	resp, llmErr := o.llmProvider.Generate(ctx, request)
-	if llmErr != nil {
-		return false, false, false, fmt.Errorf("LLM classification failed: %w", llmErr)
-	}
+func (h *heldOutput) empty() bool {
+	return len(h.actions) == 0 && len(h.notes) == 0
+}
 
-	// Parse response from RawOutput (JSON Schema returns structured JSON)
-	result := struct {
-		NeedsArranger bool `json:"needsArranger"`
-		NeedsDrummer  bool `json:"needsDrummer"`
-	}{
-		NeedsArranger: false,
-		NeedsDrummer:  false,
+// unplacedActions are actions of track agents that don't go on a track
+var unplacedActions = map[string]bool{"install_jsfx": true}
+
+// take returns the held output placed on the track, with the notes as an
+// add_midi action, and clears it
+func (h *heldOutput) take(track int, ok bool) []map[string]any {
+	actions := h.actions
+	if ok {
+		for _, action := range actions {
+			name, _ := action["action"].(string)
+			if _, has := action["track"]; !has && !unplacedActions[name] {
+				action["track"] = track
+			}
+		}
 	}
-
-	// Try to parse from RawOutput if available
-	if resp.RawOutput != "" {
-		if parseErr := json.Unmarshal([]byte(resp.RawOutput), &result); parseErr != nil {
-			log.Printf("⚠️ Failed to parse LLM classification JSON: %v, raw: %s", parseErr, resp.RawOutput)
-			return false, false, false, fmt.Errorf("failed to parse LLM classification: %w", parseErr)
+	if len(h.notes) > 0 {
+		midiAction := map[string]any{
+			"action": "add_midi",
+			"notes":  noteMaps(h.notes),
+		}
+		if ok {
+			midiAction["track"] = track
 		}
+		actions = append(actions, midiAction)
 	}
+	h.actions, h.notes = nil, nil
+	return actions
+}
 
-	// DAW is always true (handled by caller), return Arranger and Drummer classification
-	return true, result.NeedsArranger, result.NeedsDrummer, nil
+// logPlan logs the steps of a plan
+func logPlan(plan *ExecutionPlan, logPrefix string) {
+	for _, step := range plan.Steps {
+		log.Printf("🗺️ %sPlan step %s: %s agent, target=%q, depends on %v", logPrefix, step.ID, step.Agent, step.Target.String(), step.DependsOn)
+	}
 }
 
-// mergeResults combines DAW, Arranger, and Drummer results
-func (o *Orchestrator) mergeResults(dawResult *daw.DawResult, arrangerResult *ArrangerResult, drummerResult *drummer.DrummerResult) (*OrchestratorResult, error) {
+// mergeResults combines step outputs in plan order. The output of agents that
+// need a track is placed on the target track, or without a target on the last
+// clip's track, as are other agents' notes: notes are injected into the track's
+// add_midi action, or a new add_midi after its clip. Output targeting a later
+// bar is moved there.
+func (o *Orchestrator) mergeResults(results []*stepResult, state map[string]any) *OrchestratorResult {
 	result := &OrchestratorResult{
 		Actions: []map[string]any{},
 	}
+	resolver := newTrackResolver(state)
+	beatsPerBar := stateBeatsPerBar(state)
 
-	// If we only have arranger results (no DAW), convert arranger actions to NoteEvents
-	// and create a simple DAW action structure
-	if arrangerResult != nil && len(arrangerResult.Actions) > 0 && (dawResult == nil || len(dawResult.Actions) == 0) {
-		// Convert arranger actions to NoteEvents
-		allNoteEvents := []models.NoteEvent{}
-		currentBeat := 0.0
-
-		for _, action := range arrangerResult.Actions {
-			noteEvents, err := arranger.ConvertArrangerActionToNoteEvents(action, currentBeat)
-			if err != nil {
-				log.Printf("⚠️ Failed to convert arranger action to NoteEvents: %v", err)
-				continue
-			}
-
-			allNoteEvents = append(allNoteEvents, noteEvents...)
-
-			// Update currentBeat for next action (sum of lengths)
-			if length, ok := getFloat(action, "length"); ok {
-				if repeat, ok := getInt(action, "repeat"); ok {
-					currentBeat += length * float64(repeat)
-				} else {
-					currentBeat += length
-				}
+	var placed []*stepResult
+	for _, r := range results {
+		if r.output == nil {
+			continue
+		}
+		descriptor := r.agent.Descriptor()
+		if result.Usage == nil && descriptor.Required {
+			result.Usage = r.output.Usage // TODO: merge usage from all agents
+		}
+		if descriptor.NeedsTrack {
+			placed = append(placed, r)
+			continue
+		}
+		if len(r.output.Actions) > 0 {
+			log.Printf("🔄 Adding %d %s actions", len(r.output.Actions), r.step.ID)
+			for _, action := range r.output.Actions {
+				resolver.observe(action)
 			}
+			result.Actions = append(result.Actions, r.output.Actions...)
 		}
+		if len(r.output.Notes) > 0 {
+			placed = append(placed, r)
+		}
+	}
 
-		// Create a DAW action to add MIDI notes
-		if len(allNoteEvents) > 0 {
-			// Convert models.NoteEvent to map format expected by DAW
-			notesArray := make([]map[string]any, len(allNoteEvents))
-			for i, note := range allNoteEvents {
-				notesArray[i] = map[string]any{
-					"pitch":    note.MidiNoteNumber,
-					"velocity": note.Velocity,
-					"start":    note.StartBeats,
-					"length":   note.DurationBeats,
-				}
-			}
+	// Notes without a target all go to the same track, as one batch
+	var untargeted []models.NoteEvent
+	for _, r := range placed {
+		if !r.agent.Descriptor().NeedsTrack {
+			untargeted = append(untargeted, r.output.Notes...)
+			continue
+		}
+		track, ok := resolver.resolve(r.step.Target)
+		if !ok {
+			log.Printf("⚠️ No track found for step %s (target %q), adding its output unplaced", r.step.ID, r.step.Target.String())
+		}
+		output := offsetOutput(r.output, r.step.Target, beatsPerBar)
+		held := &heldOutput{actions: output.Actions}
+		result.Actions = placeOnTrack(result.Actions, held.take(track, ok), track, ok)
+		if r.step.Target == nil {
+			untargeted = append(untargeted, output.Notes...)
+			continue
+		}
+		result.Actions = placeNotes(result.Actions, output.Notes, track, ok)
+	}
+	if len(untargeted) > 0 {
+		track, ok := resolver.resolve(nil)
+		result.Actions = placeNotes(result.Actions, untargeted, track, ok)
+	}
+	return result
+}
 
-			// Create add_midi action
-			midiAction := map[string]any{
-				"action": "add_midi",
-				"notes":  notesArray,
+// placeOnTrack inserts actions after the last action on the track, or at the end
+func placeOnTrack(actions, insert []map[string]any, track int, ok bool) []map[string]any {
+	if len(insert) == 0 {
+		return actions
+	}
+	at := len(actions)
+	if ok {
+		for i, action := range actions {
+			if actionTrack(action) == track {
+				at = i + 1
 			}
-			result.Actions = append(result.Actions, midiAction)
 		}
 	}
+	return append(actions[:at], append(insert, actions[at:]...)...)
+}
 
-	// Add DAW actions
-	if dawResult != nil {
-		// If we have both DAW and arranger results, inject arranger NoteEvents into DAW actions
-		if arrangerResult != nil && len(arrangerResult.Actions) > 0 {
-			log.Printf("🔄 Merging %d DAW actions with %d arranger actions", len(dawResult.Actions), len(arrangerResult.Actions))
-
-			// Convert all arranger actions to NoteEvents
-			allNoteEvents := []models.NoteEvent{}
-			currentBeat := 0.0
-
-			for _, action := range arrangerResult.Actions {
-				log.Printf("🎵 Converting arranger action: type=%v, chord=%v", action["type"], action["chord"])
-				noteEvents, err := arranger.ConvertArrangerActionToNoteEvents(action, currentBeat)
-				if err != nil {
-					log.Printf("⚠️ Failed to convert arranger action to NoteEvents: %v", err)
-					continue
-				}
+// placeNotes adds notes to one add_midi action: the track's last one, or
+// without a track the last one. If there is none, it adds a new add_midi after
+// the track's last clip (or last action).
+func placeNotes(actions []map[string]any, notes []models.NoteEvent, track int, ok bool) []map[string]any {
+	if len(notes) == 0 {
+		return actions
+	}
+	log.Printf("📊 Placing %d NoteEvents (track=%d)", len(notes), track)
 
-				log.Printf("✅ Converted to %d NoteEvents (starting at beat %.2f)", len(noteEvents), currentBeat)
-				allNoteEvents = append(allNoteEvents, noteEvents...)
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+		if action["action"] != "add_midi" || (ok && actionTrack(action) != track) {
+			continue
+		}
+		action["notes"] = appendNotes(action["notes"], noteMaps(notes))
+		log.Printf("✅ Injected %d notes into add_midi action", len(notes))
+		return actions
+	}
 
-				// Update currentBeat for next action
-				if length, ok := getFloat(action, "length"); ok {
-					if repeat, ok := getInt(action, "repeat"); ok {
-						currentBeat += length * float64(repeat)
-					} else {
-						currentBeat += length
-					}
-				}
+	midiAction := map[string]any{
+		"action": "add_midi",
+		"notes":  noteMaps(notes),
+	}
+	if ok {
+		midiAction["track"] = track
+		// Right after the track's clip, if there is one
+		for i := len(actions) - 1; i >= 0; i-- {
+			if isClipAction(actions[i]) && actionTrack(actions[i]) == track {
+				log.Printf("✅ Created new add_midi action with %d notes (track=%d)", len(notes), track)
+				return append(actions[:i+1], append([]map[string]any{midiAction}, actions[i+1:]...)...)
 			}
+		}
+	}
+	log.Printf("✅ Created new add_midi action with %d notes (track=%d)", len(notes), track)
+	return placeOnTrack(actions, []map[string]any{midiAction}, track, ok)
+}
 
-			log.Printf("📊 Total NoteEvents from arranger: %d", len(allNoteEvents))
-
-			// Find add_midi actions and inject NoteEvents, or create one if needed
-			hasMidiAction := false
-			for _, action := range dawResult.Actions {
-				actionType, ok := action["action"].(string)
-				if !ok {
-					result.Actions = append(result.Actions, action)
-					continue
-				}
-
-				if actionType == "add_midi" {
-					hasMidiAction = true
-					// Convert models.NoteEvent to map format expected by DAW
-					notesArray := make([]map[string]any, len(allNoteEvents))
-					for i, note := range allNoteEvents {
-						notesArray[i] = map[string]any{
-							"pitch":    note.MidiNoteNumber,
-							"velocity": note.Velocity,
-							"start":    note.StartBeats,
-							"length":   note.DurationBeats,
-						}
-					}
-					action["notes"] = notesArray
-					log.Printf("✅ Injected %d notes into add_midi action", len(notesArray))
-				}
-				result.Actions = append(result.Actions, action)
-			}
+// offsetOutput returns the output moved to the target's bar: its notes start
+// that many beats later and its clips that many bars later. Output for bar 1
+// or without a target is returned as is.
+func offsetOutput(output *AgentOutput, target *StepTarget, beatsPerBar float64) *AgentOutput {
+	offset := target.startBeat(beatsPerBar)
+	if offset == 0 {
+		return output
+	}
 
-			// If no add_midi action exists but we have NoteEvents, create one
-			if !hasMidiAction && len(allNoteEvents) > 0 {
-				// Find the last track index from DAW actions
-				lastTrackIndex := -1
-				for _, action := range dawResult.Actions {
-					if track, ok := action["track"].(int); ok {
-						lastTrackIndex = track
-					} else if track, ok := action["index"].(int); ok {
-						lastTrackIndex = track
-					}
-				}
+	moved := *output
+	moved.Notes = make([]models.NoteEvent, len(output.Notes))
+	for i, note := range output.Notes {
+		note.StartBeats += offset
+		moved.Notes[i] = note
+	}
+	moved.Actions = make([]map[string]any, len(output.Actions))
+	for i, action := range output.Actions {
+		if bar, ok := getInt(action, "bar"); ok && isClipAction(action) {
+			action = maps.Clone(action)
+			action["bar"] = bar + target.Bar - 1
+		}
+		moved.Actions[i] = action
+	}
+	return &moved
+}
 
-				// Convert NoteEvents to map format
-				notesArray := make([]map[string]any, len(allNoteEvents))
-				for i, note := range allNoteEvents {
-					notesArray[i] = map[string]any{
-						"pitch":    note.MidiNoteNumber,
-						"velocity": note.Velocity,
-						"start":    note.StartBeats,
-						"length":   note.DurationBeats,
-					}
-				}
+// appendNotes appends notes to an add_midi action's notes, which agents give
+// as []map[string]any or, decoded from JSON, as []any
+func appendNotes(existing any, notes []map[string]any) any {
+	switch existing := existing.(type) {
+	case []any:
+		for _, note := range notes {
+			existing = append(existing, note)
+		}
+		return existing
+	case []map[string]any:
+		return append(existing, notes...)
+	}
+	return notes
+}
 
-				midiAction := map[string]any{
-					"action": "add_midi",
-					"notes":  notesArray,
-				}
-				if lastTrackIndex >= 0 {
-					midiAction["track"] = lastTrackIndex
-				}
+// isClipAction reports whether an action creates a clip
+func isClipAction(action map[string]any) bool {
+	switch action["action"] {
+	case "create_clip_at_bar", "create_clip", "new_clip":
+		return true
+	}
+	return false
+}
 
-				result.Actions = append(result.Actions, midiAction)
-				log.Printf("✅ Created new add_midi action with %d notes (track=%d)", len(notesArray), lastTrackIndex)
-			}
-		} else {
-			// No arranger results, just add DAW actions as-is
-			result.Actions = append(result.Actions, dawResult.Actions...)
+// actionTrack returns the track an action is on, or -1
+func actionTrack(action map[string]any) int {
+	if track, ok := action["track"].(int); ok {
+		return track
+	}
+	if action["action"] == "create_track" {
+		if index, ok := action["index"].(int); ok {
+			return index
 		}
-		result.Usage = dawResult.Usage // TODO: merge usage from all agents
 	}
+	return -1
+}
 
-	// Add drummer results (drum patterns)
-	if drummerResult != nil && len(drummerResult.Actions) > 0 {
-		log.Printf("🥁 Adding %d drummer actions", len(drummerResult.Actions))
-		result.Actions = append(result.Actions, drummerResult.Actions...)
+// noteMaps converts NoteEvents to the map format expected by the DAW
+func noteMaps(notes []models.NoteEvent) []map[string]any {
+	notesArray := make([]map[string]any, len(notes))
+	for i, note := range notes {
+		notesArray[i] = map[string]any{
+			"pitch":    note.MidiNoteNumber,
+			"velocity": note.Velocity,
+			"start":    note.StartBeats,
+			"length":   note.DurationBeats,
+		}
 	}
-
-	return result, nil
+	return notesArray
 }
 
 // Helper functions for type conversion
@@ -748,17 +954,5 @@ func getInt(m map[string]any, key string) (int, bool) {
 
 // getTrackCount extracts the number of tracks from the REAPER state
 func getTrackCount(state map[string]any) int {
-	if state == nil {
-		return 0
-	}
-	if tracks, ok := state["tracks"]; ok {
-		if trackArr, ok := tracks.([]any); ok {
-			return len(trackArr)
-		}
-		// Handle typed slice (e.g., from JSON unmarshaling)
-		if trackArr, ok := tracks.([]map[string]any); ok {
-			return len(trackArr)
-		}
-	}
-	return 0
+	return len(stateTrackList(state))
 }
@@ -6,6 +6,9 @@ import (
 	"testing"
 	"time"
 
+	arranger "github.com/Conceptual-Machines/magda-agents-go/agents/arranger"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/drummer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,16 +41,9 @@ func TestOrchestrator_Integration_DrummerAndDAW(t *testing.T) {
 
 				// Should have track creation action from DAW agent
 				hasTrackCreation := false
-				hasDrumPattern := false
 
 				for _, action := range result.Actions {
 					actionType, _ := action["action"].(string)
-					drumType, _ := action["type"].(string)
-
-					if drumType == "drum_pattern" {
-						hasDrumPattern = true
-						t.Logf("🥁 Found drum_pattern: drum=%v, grid=%v", action["drum"], action["grid"])
-					}
 
 					if actionType == "create_track" {
 						hasTrackCreation = true
@@ -58,7 +54,7 @@ func TestOrchestrator_Integration_DrummerAndDAW(t *testing.T) {
 				}
 
 				assert.True(t, hasTrackCreation, "Should have track creation action from DAW agent")
-				assert.True(t, hasDrumPattern, "Should have drum pattern action from Drummer agent")
+				assert.Positive(t, drumNotes(result.Actions), "Should have drum notes from the Drummer agent")
 			},
 		},
 		{
@@ -71,24 +67,17 @@ func TestOrchestrator_Integration_DrummerAndDAW(t *testing.T) {
 				require.NotEmpty(t, result.Actions, "Should have actions")
 
 				hasTrackCreation := false
-				hasDrumPattern := false
 
 				for _, action := range result.Actions {
 					actionType, _ := action["action"].(string)
-					drumType, _ := action["type"].(string)
 
 					if actionType == "create_track" {
 						hasTrackCreation = true
 					}
-
-					if drumType == "drum_pattern" {
-						hasDrumPattern = true
-						t.Logf("🥁 Found drum_pattern: drum=%v", action["drum"])
-					}
 				}
 
 				assert.True(t, hasTrackCreation, "Should have track creation action")
-				assert.True(t, hasDrumPattern, "Should have drum_pattern action")
+				assert.Positive(t, drumNotes(result.Actions), "Should have drum notes from the Drummer agent")
 			},
 		},
 		{
@@ -101,24 +90,17 @@ func TestOrchestrator_Integration_DrummerAndDAW(t *testing.T) {
 				require.NotEmpty(t, result.Actions, "Should have actions")
 
 				hasTrackCreation := false
-				hasDrumPattern := false
 
 				for _, action := range result.Actions {
 					actionType, _ := action["action"].(string)
-					drumType, _ := action["type"].(string)
 
 					if actionType == "create_track" {
 						hasTrackCreation = true
 					}
-
-					if drumType == "drum_pattern" {
-						hasDrumPattern = true
-						t.Logf("🥁 Found drum_pattern: drum=%v", action["drum"])
-					}
 				}
 
 				assert.True(t, hasTrackCreation, "Should have track creation action")
-				assert.True(t, hasDrumPattern, "Should have drum_pattern action")
+				assert.Positive(t, drumNotes(result.Actions), "Should have drum notes from the Drummer agent")
 			},
 		},
 		{
@@ -129,18 +111,7 @@ func TestOrchestrator_Integration_DrummerAndDAW(t *testing.T) {
 				t.Helper()
 				require.NotNil(t, result, "Result should not be nil")
 				require.NotEmpty(t, result.Actions, "Should have actions")
-
-				hasDrumPattern := false
-				for _, action := range result.Actions {
-					drumType, _ := action["type"].(string)
-
-					if drumType == "drum_pattern" {
-						hasDrumPattern = true
-						t.Logf("🥁 Found drum_pattern: drum=%v", action["drum"])
-					}
-				}
-
-				assert.True(t, hasDrumPattern, "Should have drum_pattern action")
+				assert.Positive(t, drumNotes(result.Actions), "Should have drum notes from the Drummer agent")
 			},
 		},
 	}
@@ -250,29 +221,27 @@ func TestOrchestrator_Integration_DrummerWithArranger(t *testing.T) {
 			actionsJSON, _ := json.MarshalIndent(result.Actions, "", "  ")
 			t.Logf("📋 Actions:\n%s", string(actionsJSON))
 
-			// Count action types
+			// Count action types; drum patterns arrive as notes in add_midi
 			trackCount := 0
-			drumPatternCount := 0
 			midiCount := 0
 
 			for _, action := range result.Actions {
 				actionType, _ := action["action"].(string)
-				drumType, _ := action["type"].(string)
 
 				if actionType == "create_track" {
 					trackCount++
 				}
-				if drumType == "drum_pattern" || actionType == "drum_pattern" {
-					drumPatternCount++
-				}
 				if actionType == "add_midi" {
 					midiCount++
 				}
 			}
+			drumNoteCount := drumNotes(result.Actions)
 
-			t.Logf("📊 Action summary: tracks=%d, drum_patterns=%d, midi=%d",
-				trackCount, drumPatternCount, midiCount)
+			t.Logf("📊 Action summary: tracks=%d, midi=%d, drum notes=%d",
+				trackCount, midiCount, drumNoteCount)
 
+			assert.Positive(t, midiCount, "Should have add_midi actions")
+			assert.Positive(t, drumNoteCount, "Should have drum notes from the Drummer agent")
 			// Should have multiple types of actions
 			assert.Greater(t, len(result.Actions), 1, "Should have multiple actions")
 		})
@@ -287,7 +256,9 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 	}
 
 	cfg := getTestConfig(t)
-	orchestrator := NewOrchestrator(cfg)
+	drummerAgent := drummer.NewDrummerAgent(cfg)
+	arrangerAgent := arranger.NewBasicArrangerAgent(cfg)
+	dawAgent := daw.NewDawAgent(cfg)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -316,7 +287,7 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 					{"role": "user", "content": question},
 				}
 
-				result, err := orchestrator.drummerAgent.Generate(ctx, "gpt-5.1", inputArray)
+				result, err := drummerAgent.Generate(ctx, "gpt-5.1", inputArray)
 
 				// Drummer should either error or return empty actions
 				if err != nil {
@@ -346,7 +317,7 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 	t.Run("arranger_agent_rejects_out_of_scope", func(t *testing.T) {
 		for _, question := range outOfScopeQuestions {
 			t.Run(question, func(t *testing.T) {
-				result, err := orchestrator.arrangerAgent.GenerateActions(ctx, question)
+				result, err := arrangerAgent.GenerateActions(ctx, question)
 
 				// Arranger should either error or return empty actions
 				if err != nil {
@@ -374,7 +345,7 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 	t.Run("daw_agent_rejects_out_of_scope", func(t *testing.T) {
 		for _, question := range outOfScopeQuestions {
 			t.Run(question, func(t *testing.T) {
-				result, err := orchestrator.dawAgent.GenerateActions(ctx, question, nil)
+				result, err := dawAgent.GenerateActions(ctx, question, nil)
 
 				// DAW should either error or return empty/error comment
 				if err != nil {
@@ -420,7 +391,7 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 
 		for _, question := range validDAWOperations {
 			t.Run(question, func(t *testing.T) {
-				result, err := orchestrator.dawAgent.GenerateActions(ctx, question, nil)
+				result, err := dawAgent.GenerateActions(ctx, question, nil)
 
 				// These should succeed - they're valid DAW operations
 				if err != nil {
@@ -450,3 +421,20 @@ func TestAgents_OutOfScope_DirectCalls(t *testing.T) {
 		}
 	})
 }
+
+// drumNotes counts the notes on General MIDI drum keys in add_midi actions
+func drumNotes(actions []map[string]any) int {
+	count := 0
+	for _, action := range actions {
+		if action["action"] != "add_midi" {
+			continue
+		}
+		notes, _ := action["notes"].([]map[string]any)
+		for _, note := range notes {
+			if pitch, ok := note["pitch"].(int); ok && pitch >= 35 && pitch <= 81 {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,114 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	arranger "github.com/Conceptual-Machines/magda-agents-go/agents/arranger"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/daw"
+	"github.com/Conceptual-Machines/magda-agents-go/agents/drummer"
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider returns a fixed output, streamed in small chunks.
+type scriptedProvider struct {
+	output string
+}
+
+func (s *scriptedProvider) Name() string { return "scripted" }
+
+func (s *scriptedProvider) Generate(ctx context.Context, request *llm.GenerationRequest) (*llm.GenerationResponse, error) {
+	return &llm.GenerationResponse{RawOutput: s.output}, nil
+}
+
+func (s *scriptedProvider) GenerateStream(ctx context.Context, request *llm.GenerationRequest, callback llm.StreamCallback) (*llm.GenerationResponse, error) {
+	for start := 0; start < len(s.output); start += 7 {
+		_ = callback(llm.StreamEvent{Type: "text_delta", Message: s.output[start:min(start+7, len(s.output))]})
+	}
+	return &llm.GenerationResponse{RawOutput: s.output}, nil
+}
+
+// scriptedArranger streams the actions of a fixed arranger DSL script.
+type scriptedArranger struct {
+	dsl string
+}
+
+func (s *scriptedArranger) GenerateActions(ctx context.Context, question string) (*arranger.ArrangerResult, error) {
+	return s.GenerateActionsStream(ctx, question, func(map[string]any) error { return nil })
+}
+
+func (s *scriptedArranger) GenerateActionsStream(
+	ctx context.Context, question string, callback arranger.StreamActionCallback,
+) (*arranger.ArrangerResult, error) {
+	parser, err := arranger.NewArrangerDSLParser()
+	if err != nil {
+		return nil, err
+	}
+	stream := parser.Stream(ctx, callback)
+	if err := stream.Write(s.dsl); err != nil {
+		return nil, err
+	}
+	actions, err := stream.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &arranger.ArrangerResult{Actions: actions, DSL: s.dsl}, nil
+}
+
+func TestOrchestrator_GenerateActionsStream_AllAgentsStream(t *testing.T) {
+	cfg := &config.Config{}
+	orchestrator, err := NewOrchestratorWithAgents(
+		&scriptedProvider{output: `{"arranger": true, "drummer": true}`},
+		WrapDawAgent(daw.NewDawAgentWithProvider(cfg, &scriptedProvider{output: `track(name="Keys").new_clip(bar=1, length_bars=4)`})),
+		WrapArrangerAgent(&scriptedArranger{dsl: `arpeggio(symbol=Em, note_duration=1, length=4); arpeggio(symbol=C, note_duration=1, length=4)`}),
+		WrapDrummerAgent(drummer.NewDrummerAgentWithProvider(cfg, &scriptedProvider{
+			output: `pattern(drum=kick, grid="x---x---x---x---"); pattern(drum=snare, grid="----x-------x---")`,
+		})),
+	)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var streamed []map[string]any
+	result, err := orchestrator.GenerateActionsStream(context.Background(), "keys with a bassline and drums", nil, func(action map[string]any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = append(streamed, action)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, streamed, result.Actions)
+
+	var noteStarts []any
+	pitches := map[any]int{}
+	for _, action := range result.Actions {
+		assert.NotEqual(t, "drum_pattern", action["action"], "drum patterns become notes")
+		if action["action"] == "add_midi" {
+			assert.Equal(t, 0, action["track"], "notes go to the clip's track")
+			for _, note := range action["notes"].([]map[string]any) {
+				noteStarts = append(noteStarts, note["start"])
+				pitches[note["pitch"]]++
+			}
+		}
+	}
+	// Four General MIDI kicks and two snares
+	assert.Equal(t, 4, pitches[36])
+	assert.Equal(t, 2, pitches[38])
+	// The second arpeggio follows the first
+	assert.Contains(t, noteStarts, 0.0)
+	assert.Contains(t, noteStarts, 4.0)
+	assert.GreaterOrEqual(t, indexOfAction(result.Actions, "create_track"), 0)
+	assert.Less(t, indexOfAction(result.Actions, "create_clip_at_bar"), indexOfAction(result.Actions, "add_midi"))
+}
+
+func indexOfAction(actions []map[string]any, name string) int {
+	for i, action := range actions {
+		if action["action"] == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,340 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+)
+
+// ExecutionPlan is a DAG of agent steps. Steps run as soon as the steps they
+// depend on have finished, so independent steps run in parallel.
+type ExecutionPlan struct {
+	Steps []*PlanStep `json:"steps"`
+}
+
+// PlanStep is one agent run in an execution plan
+type PlanStep struct {
+	ID        string      `json:"id"`
+	Agent     string      `json:"agent"`
+	Task      string      `json:"task"`             // Instruction for the agent
+	Target    *StepTarget `json:"target,omitempty"` // Where the output goes; nil wires it to the last clip
+	DependsOn []string    `json:"dependsOn,omitempty"`
+}
+
+// StepTarget is the track and time range a step's output goes to
+type StepTarget struct {
+	Track      string `json:"track"`                // Track name
+	Bar        int    `json:"bar,omitempty"`        // First bar, 1-based
+	LengthBars int    `json:"lengthBars,omitempty"` // Length in bars
+}
+
+// String describes the target for prompts and logs: "Bass, bars 1-8"
+func (t *StepTarget) String() string {
+	if t == nil {
+		return ""
+	}
+	switch {
+	case t.Bar > 0 && t.LengthBars > 0:
+		return fmt.Sprintf("%s, bars %d-%d", t.Track, t.Bar, t.Bar+t.LengthBars-1)
+	case t.Bar > 0:
+		return fmt.Sprintf("%s, from bar %d", t.Track, t.Bar)
+	}
+	return t.Track
+}
+
+// startBeat is the beat the target range starts at: 0 for bar 1 or no bar
+func (t *StepTarget) startBeat(beatsPerBar float64) float64 {
+	if t == nil || t.Bar <= 1 {
+		return 0
+	}
+	return float64(t.Bar-1) * beatsPerBar
+}
+
+// defaultBeatsPerBar is the bar length when the state has no time signature
+const defaultBeatsPerBar = 4.0
+
+// stateBeatsPerBar returns the beats per bar of the project in the REAPER
+// state, from beats_per_bar or the time signature's numerator, at the top
+// level or under "project"
+func stateBeatsPerBar(state map[string]any) float64 {
+	if nested, ok := state["state"].(map[string]any); ok {
+		state = nested
+	}
+	beatsPerBar := defaultBeatsPerBar
+	sources := []map[string]any{state}
+	if project, ok := state["project"].(map[string]any); ok {
+		sources = append(sources, project)
+	}
+	for _, source := range sources {
+		if value, ok := getFloat(source, "beats_per_bar"); ok && value > 0 {
+			beatsPerBar = value
+		}
+		switch signature := source["time_signature"].(type) {
+		case map[string]any:
+			if value, ok := getFloat(signature, "numerator"); ok && value > 0 {
+				beatsPerBar = value
+			}
+		case string:
+			if numerator, _, found := strings.Cut(signature, "/"); found {
+				if value, err := strconv.ParseFloat(strings.TrimSpace(numerator), 64); err == nil && value > 0 {
+					beatsPerBar = value
+				}
+			}
+		}
+	}
+	return beatsPerBar
+}
+
+// Step returns the step with the given ID
+func (p *ExecutionPlan) Step(id string) (*PlanStep, bool) {
+	for _, step := range p.Steps {
+		if step.ID == id {
+			return step, true
+		}
+	}
+	return nil, false
+}
+
+// Validate checks that step IDs are unique, agents are registered,
+// dependencies exist and form no cycle, and that each required agent among
+// the agents the plan was made for has a step
+func (p *ExecutionPlan) Validate(registry *AgentRegistry, selected ...Agent) error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("plan has no steps")
+	}
+	ids := map[string]bool{}
+	for _, step := range p.Steps {
+		if step.ID == "" {
+			return fmt.Errorf("plan step for agent %q has no id", step.Agent)
+		}
+		if ids[step.ID] {
+			return fmt.Errorf("duplicate plan step %q", step.ID)
+		}
+		ids[step.ID] = true
+		if _, ok := registry.Get(step.Agent); !ok {
+			return fmt.Errorf("plan step %q uses unknown agent %q", step.ID, step.Agent)
+		}
+	}
+	for _, step := range p.Steps {
+		for _, dep := range step.DependsOn {
+			if !ids[dep] {
+				return fmt.Errorf("plan step %q depends on unknown step %q", step.ID, dep)
+			}
+		}
+	}
+	for _, agent := range selected {
+		descriptor := agent.Descriptor()
+		if descriptor.Required && !slices.ContainsFunc(p.Steps, func(step *PlanStep) bool { return step.Agent == descriptor.Name }) {
+			return fmt.Errorf("plan has no step for the required %s agent", descriptor.Name)
+		}
+	}
+	if _, err := p.Order(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Order returns the steps in dependency order, keeping the plan order among
+// steps that are ready at the same time
+func (p *ExecutionPlan) Order() ([]*PlanStep, error) {
+	done := map[string]bool{}
+	ordered := make([]*PlanStep, 0, len(p.Steps))
+	for len(ordered) < len(p.Steps) {
+		progress := false
+		for _, step := range p.Steps {
+			if done[step.ID] || !allDone(step.DependsOn, done) {
+				continue
+			}
+			done[step.ID] = true
+			ordered = append(ordered, step)
+			progress = true
+		}
+		if !progress {
+			var stuck []string
+			for _, step := range p.Steps {
+				if !done[step.ID] {
+					stuck = append(stuck, step.ID)
+				}
+			}
+			return nil, fmt.Errorf("plan has a dependency cycle between steps %s", strings.Join(stuck, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allDone(ids []string, done map[string]bool) bool {
+	for _, id := range ids {
+		if !done[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultPlan runs every agent on the whole request in parallel, with the
+// output wired to the last clip
+func defaultPlan(question string, agents []Agent) *ExecutionPlan {
+	plan := &ExecutionPlan{}
+	for _, agent := range agents {
+		plan.Steps = append(plan.Steps, &PlanStep{ID: agent.Descriptor().Name, Agent: agent.Descriptor().Name, Task: question})
+	}
+	return plan
+}
+
+// SetPlanning turns the LLM planner on or off; it is off by default. Without
+// it, content for several named tracks is split by clause, and otherwise every
+// selected agent runs on the whole request in parallel.
+func (o *Orchestrator) SetPlanning(enabled bool) {
+	o.planning = enabled
+}
+
+// plan builds the execution plan for the selected agents. A single agent needs
+// no planning; otherwise the LLM planner splits the request into steps. Without
+// the planner, or when it gets the plan wrong, the request is split by clause.
+func (o *Orchestrator) plan(ctx context.Context, question string, state map[string]any, agents []Agent) *ExecutionPlan {
+	if len(agents) < 2 {
+		return defaultPlan(question, agents)
+	}
+	if o.planning {
+		plan, err := o.planWithLLM(ctx, question, state, agents)
+		if err == nil {
+			return plan
+		}
+		log.Printf("⚠️ Planner failed, splitting the request by clause: %v", err)
+	}
+	return o.agents.splitPlan(question, agents)
+}
+
+// planWithLLM asks a small LLM to split the request into steps
+func (o *Orchestrator) planWithLLM(ctx context.Context, question string, state map[string]any, agents []Agent) (*ExecutionPlan, error) {
+	names := make([]string, len(agents))
+	for i, agent := range agents {
+		names[i] = agent.Descriptor().Name
+	}
+
+	request := &llm.GenerationRequest{
+		Model:         "gpt-4.1-mini",
+		InputArray:    []map[string]any{{"role": "user", "content": plannerPrompt(question, state, agents)}},
+		ReasoningMode: "none",
+		OutputSchema: &llm.OutputSchema{
+			Name:        "ExecutionPlan",
+			Description: "Steps of the request, each run by one agent",
+			Schema:      planSchema(names),
+		},
+	}
+	resp, err := o.llmProvider.Generate(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Steps []struct {
+			ID         string   `json:"id"`
+			Agent      string   `json:"agent"`
+			Task       string   `json:"task"`
+			Track      string   `json:"track"`
+			Bar        int      `json:"bar"`
+			LengthBars int      `json:"lengthBars"`
+			DependsOn  []string `json:"dependsOn"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(resp.RawOutput), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	plan := &ExecutionPlan{}
+	for _, s := range raw.Steps {
+		if !slices.Contains(names, s.Agent) {
+			return nil, fmt.Errorf("plan step %q uses agent %q, which was not selected", s.ID, s.Agent)
+		}
+		step := &PlanStep{ID: s.ID, Agent: s.Agent, Task: s.Task, DependsOn: s.DependsOn}
+		if s.Track != "" {
+			step.Target = &StepTarget{Track: s.Track, Bar: s.Bar, LengthBars: s.LengthBars}
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	if err := plan.Validate(o.agents, agents...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// plannerPrompt builds the planner prompt from the selected agents and the
+// tracks in the project
+func plannerPrompt(question string, state map[string]any, agents []Agent) string {
+	var b strings.Builder
+	b.WriteString("You plan how a music production AI system carries out a request. Split it into steps, each run by one agent.\n\nAGENTS:\n")
+	for _, agent := range agents {
+		descriptor := agent.Descriptor()
+		fmt.Fprintf(&b, "- %s: %s\n", descriptor.Name, descriptor.Description)
+	}
+
+	var tracks []string
+	for _, track := range stateTrackList(state) {
+		if trackMap, ok := track.(map[string]any); ok {
+			if name, ok := trackMap["name"].(string); ok && name != "" {
+				tracks = append(tracks, fmt.Sprintf("%q", name))
+			}
+		}
+	}
+	if len(tracks) > 0 {
+		fmt.Fprintf(&b, "\nEXISTING TRACKS: %s\n", strings.Join(tracks, ", "))
+	}
+
+	var serial []string
+	for _, agent := range agents {
+		if descriptor := agent.Descriptor(); descriptor.Required {
+			serial = append(serial, descriptor.Name)
+		}
+	}
+
+	b.WriteString(`
+RULES:
+- Each step has a short unique id, one agent, and a task written as an instruction to that agent.
+- Content for different tracks goes in separate steps, one per track, even when the same agent writes all of it.
+- Steps that generate content set "track" to the name of the track the content goes on, and "bar"/"lengthBars" to its time range. Other steps set "track" to "" and "bar"/"lengthBars" to 0.
+- If the track does not exist yet, add a step that creates it (with a clip covering the time range) and list that step in "dependsOn" only if the content step needs its result; content is placed on the track once it exists either way.
+- A step that changes something another step creates must depend on that step. Otherwise leave "dependsOn" empty so steps run in parallel.
+- Use each agent for what it does; do not ask an agent to do another agent's work.
+`)
+	if len(serial) > 0 {
+		fmt.Fprintf(&b, "- Steps of the %s agent run one after another in plan order, each seeing the tracks the ones before it created; they need no \"dependsOn\" between them.\n",
+			strings.Join(serial, "/"))
+	}
+	fmt.Fprintf(&b, "\nREQUEST: %q\n\nReturn JSON: {\"steps\": [{\"id\", \"agent\", \"task\", \"track\", \"bar\", \"lengthBars\", \"dependsOn\"}]}", question)
+	return b.String()
+}
+
+// planSchema is the JSON schema of the planner's answer
+func planSchema(agents []string) map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"steps": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]any{
+						"id":         map[string]any{"type": "string"},
+						"agent":      map[string]any{"type": "string", "enum": agents},
+						"task":       map[string]any{"type": "string"},
+						"track":      map[string]any{"type": "string"},
+						"bar":        map[string]any{"type": "integer"},
+						"lengthBars": map[string]any{"type": "integer"},
+						"dependsOn":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					},
+					"required": []string{"id", "agent", "task", "track", "bar", "lengthBars", "dependsOn"},
+				},
+			},
+		},
+		"required": []string{"steps"},
+	}
+}
@@ -0,0 +1,243 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaProvider answers each structured request by its output schema name
+type schemaProvider struct {
+	answers map[string]string
+}
+
+func (s *schemaProvider) Name() string { return "schema" }
+
+func (s *schemaProvider) Generate(ctx context.Context, request *llm.GenerationRequest) (*llm.GenerationResponse, error) {
+	return &llm.GenerationResponse{RawOutput: s.answers[request.OutputSchema.Name]}, nil
+}
+
+func TestExecutionPlan_Validate(t *testing.T) {
+	registry := NewAgentRegistry()
+	require.NoError(t, registry.Register(&stubAgent{descriptor: AgentDescriptor{Name: "daw", Description: "DAW"}}))
+
+	step := func(id string, deps ...string) *PlanStep {
+		return &PlanStep{ID: id, Agent: "daw", DependsOn: deps}
+	}
+	tests := []struct {
+		name string
+		plan *ExecutionPlan
+		err  string
+	}{
+		{"empty", &ExecutionPlan{}, "no steps"},
+		{"duplicate", &ExecutionPlan{Steps: []*PlanStep{step("a"), step("a")}}, `duplicate plan step "a"`},
+		{"unknown agent", &ExecutionPlan{Steps: []*PlanStep{{ID: "a", Agent: "mixer"}}}, `unknown agent "mixer"`},
+		{"unknown dependency", &ExecutionPlan{Steps: []*PlanStep{step("a", "b")}}, `unknown step "b"`},
+		{"cycle", &ExecutionPlan{Steps: []*PlanStep{step("a", "c"), step("b", "a"), step("c", "b"), step("d")}}, "cycle between steps a, b, c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.plan.Validate(registry), tt.err)
+		})
+	}
+
+	// Every selected required agent needs a step
+	required := &stubAgent{descriptor: AgentDescriptor{Name: "tracks", Description: "Tracks", Required: true}}
+	require.NoError(t, registry.Register(required))
+	assert.ErrorContains(t, (&ExecutionPlan{Steps: []*PlanStep{step("a")}}).Validate(registry, required),
+		"plan has no step for the required tracks agent")
+	assert.NoError(t, (&ExecutionPlan{Steps: []*PlanStep{step("a"), {ID: "b", Agent: "tracks"}}}).Validate(registry, required))
+
+	plan := &ExecutionPlan{Steps: []*PlanStep{step("c", "a", "b"), step("b", "a"), step("a")}}
+	require.NoError(t, plan.Validate(registry))
+	ordered, err := plan.Order()
+	require.NoError(t, err)
+	var ids []string
+	for _, s := range ordered {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+}
+
+func TestStepTarget_String(t *testing.T) {
+	assert.Equal(t, "Bass, bars 1-8", (&StepTarget{Track: "Bass", Bar: 1, LengthBars: 8}).String())
+	assert.Equal(t, "Bass, from bar 5", (&StepTarget{Track: "Bass", Bar: 5}).String())
+	assert.Equal(t, "", (*StepTarget)(nil).String())
+}
+
+// plannedOrchestrator routes to all agents and plans: create the Bass and
+// Drums tracks, write a bassline on Bass, drums on Drums, and add FX to Bass
+// once the DAW step has created it.
+func plannedOrchestrator(t *testing.T) (*Orchestrator, map[string]*stubAgent) {
+	t.Helper()
+	agents := map[string]*stubAgent{
+		"daw": {
+			descriptor: AgentDescriptor{Name: "daw", Description: "DAW", AlwaysRun: true, Required: true},
+			output: &AgentOutput{Actions: []map[string]any{
+				{"action": "create_track", "index": 1, "name": "Bass"},
+				{"action": "create_clip_at_bar", "track": 1, "bar": 1, "length_bars": 8},
+				{"action": "create_track", "index": 2, "name": "Drums"},
+				{"action": "create_clip_at_bar", "track": 2, "bar": 1, "length_bars": 8},
+			}},
+		},
+		"melody": {
+			descriptor: AgentDescriptor{Name: "melody", Description: "Writes melodies", NeedsTrack: true},
+			output:     &AgentOutput{Notes: []models.NoteEvent{{MidiNoteNumber: 40, Velocity: 100, DurationBeats: 1}}},
+		},
+		"beats": {
+			descriptor: AgentDescriptor{Name: "beats", Description: "Writes drums", NeedsTrack: true},
+			output:     &AgentOutput{Actions: []map[string]any{{"action": "drum_pattern", "drum": "kick", "grid": "x---"}}},
+		},
+		"fx": {
+			descriptor: AgentDescriptor{Name: "fx", Description: "Adds FX"},
+			output:     &AgentOutput{Actions: []map[string]any{{"action": "add_track_fx", "track": 1, "fxname": "ReaComp"}}},
+		},
+	}
+
+	provider := &schemaProvider{answers: map[string]string{
+		"AgentClassification": `{"melody": true, "beats": true, "fx": true}`,
+		"ExecutionPlan": `{"steps": [
+			{"id": "tracks", "agent": "daw", "task": "create tracks Bass and Drums with 8 bar clips", "track": "", "bar": 0, "lengthBars": 0, "dependsOn": []},
+			{"id": "bassline", "agent": "melody", "task": "write a bassline", "track": "Bass", "bar": 1, "lengthBars": 8, "dependsOn": []},
+			{"id": "drums", "agent": "beats", "task": "write a kick pattern", "track": "drums", "bar": 1, "lengthBars": 8, "dependsOn": []},
+			{"id": "compress", "agent": "fx", "task": "compress the bass", "track": "", "bar": 0, "lengthBars": 0, "dependsOn": ["tracks"]}
+		]}`,
+	}}
+	orchestrator, err := NewOrchestratorWithAgents(provider, agents["daw"], agents["melody"], agents["beats"], agents["fx"])
+	require.NoError(t, err)
+	orchestrator.SetPlanning(true)
+	return orchestrator, agents
+}
+
+func TestOrchestrator_GenerateActions_Plan(t *testing.T) {
+	orchestrator, agents := plannedOrchestrator(t)
+	state := map[string]any{"tracks": []any{map[string]any{"index": 0, "name": "Keys"}}}
+
+	result, err := orchestrator.GenerateActions(context.Background(), "bass and drums, compressed", state)
+	require.NoError(t, err)
+	require.NotNil(t, result.Plan)
+	assert.Len(t, result.Plan.Steps, 4)
+
+	// Each step gets its task and target
+	assert.Equal(t, "write a bassline", agents["melody"].request.Question)
+	assert.Equal(t, &StepTarget{Track: "Bass", Bar: 1, LengthBars: 8}, agents["melody"].request.Target)
+	// The dependent step sees the tracks created before it
+	assert.Len(t, stateTrackList(agents["fx"].request.State), 3)
+	assert.Len(t, stateTrackList(agents["melody"].request.State), 1)
+
+	var summary []any
+	for _, action := range result.Actions {
+		summary = append(summary, []any{action["action"], action["track"]})
+	}
+	assert.Equal(t, []any{
+		[]any{"create_track", nil},
+		[]any{"create_clip_at_bar", 1},
+		[]any{"add_midi", 1},
+		[]any{"create_track", nil},
+		[]any{"create_clip_at_bar", 2},
+		[]any{"drum_pattern", 2},
+		[]any{"add_track_fx", 1},
+	}, summary)
+}
+
+func TestOrchestrator_GenerateActionsStream_Plan(t *testing.T) {
+	orchestrator, _ := plannedOrchestrator(t)
+
+	var mu sync.Mutex
+	var streamed []map[string]any
+	result, err := orchestrator.GenerateActionsStream(context.Background(), "bass and drums, compressed", nil, func(action map[string]any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = append(streamed, action)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, streamed, result.Actions)
+	require.Len(t, result.Actions, 7)
+
+	// Targeted output waits for its track and clip
+	midi := indexOfAction(result.Actions, "add_midi")
+	drums := indexOfAction(result.Actions, "drum_pattern")
+	assert.Equal(t, 1, result.Actions[midi]["track"])
+	assert.Equal(t, 2, result.Actions[drums]["track"])
+	assert.Greater(t, midi, 1)
+	assert.Greater(t, drums, 3)
+}
+
+func TestOrchestrator_Plan_TargetBar(t *testing.T) {
+	orchestrator, agents := plannedOrchestrator(t)
+	orchestrator.llmProvider.(*schemaProvider).answers["ExecutionPlan"] = `{"steps": [
+		{"id": "tracks", "agent": "daw", "task": "create tracks Bass and Drums with 16 bar clips", "track": "", "bar": 0, "lengthBars": 0, "dependsOn": []},
+		{"id": "bassline", "agent": "melody", "task": "write a bassline", "track": "Bass", "bar": 9, "lengthBars": 8, "dependsOn": []}
+	]}`
+	state := map[string]any{"project": map[string]any{"time_signature": "3/4"}}
+
+	result, err := orchestrator.GenerateActions(context.Background(), "a bassline from bar 9", state)
+	require.NoError(t, err)
+	midi := result.Actions[indexOfAction(result.Actions, "add_midi")]
+	assert.Equal(t, 1, midi["track"])
+	assert.Equal(t, 24.0, midi["notes"].([]map[string]any)[0]["start"], "bar 9 of 3/4 starts at beat 24")
+	assert.Equal(t, 0.0, agents["melody"].output.Notes[0].StartBeats, "the agent's output is left alone")
+	assert.Equal(t, "write a bassline (8 bars)", stepQuestion(agents["melody"].request))
+
+	// Streamed output is moved the same way
+	result, err = orchestrator.GenerateActionsStream(context.Background(), "a bassline from bar 9", nil, nil)
+	require.NoError(t, err)
+	midi = result.Actions[indexOfAction(result.Actions, "add_midi")]
+	assert.Equal(t, 32.0, midi["notes"].([]map[string]any)[0]["start"], "bar 9 of 4/4 starts at beat 32")
+
+	// Clips move by bars
+	moved := offsetOutput(&AgentOutput{Actions: []map[string]any{{"action": "create_clip_at_bar", "bar": 1, "length_bars": 4}}},
+		&StepTarget{Track: "Bass", Bar: 9}, 4)
+	assert.Equal(t, 9, moved.Actions[0]["bar"])
+}
+
+func TestOrchestrator_Execute_SerialRequiredSteps(t *testing.T) {
+	// The DAW creates a track named after its task at the next free index
+	daw := &stubAgent{
+		descriptor: AgentDescriptor{Name: "daw", Description: "DAW", Required: true},
+		respond: func(request *AgentRequest) (*AgentOutput, error) {
+			return &AgentOutput{Actions: []map[string]any{
+				{"action": "create_track", "index": len(stateTrackList(request.State)), "name": request.Question},
+			}}, nil
+		},
+	}
+	orchestrator, err := NewOrchestratorWithAgents(&schemaProvider{}, daw)
+	require.NoError(t, err)
+
+	// Neither step depends on the other, yet the second sees the first's track
+	plan := &ExecutionPlan{Steps: []*PlanStep{
+		{ID: "bass", Agent: "daw", Task: "Bass"},
+		{ID: "drums", Agent: "daw", Task: "Drums"},
+	}}
+	state := map[string]any{"tracks": []any{map[string]any{"index": 0, "name": "Keys"}}}
+	results := orchestrator.execute(context.Background(), plan, state, executionHooks{}, "")
+
+	require.Len(t, results, 2)
+	for i, name := range []string{"Bass", "Drums"} {
+		require.NoError(t, results[i].err)
+		assert.Equal(t, name, results[i].output.Actions[0]["name"])
+		assert.Equal(t, i+1, results[i].output.Actions[0]["index"])
+	}
+}
+
+func TestOrchestrator_Plan_FallsBack(t *testing.T) {
+	orchestrator, _ := plannedOrchestrator(t)
+	agents := orchestrator.Agents().Agents()
+
+	orchestrator.llmProvider = &schemaProvider{answers: map[string]string{
+		"ExecutionPlan": `{"steps": [{"id": "a", "agent": "daw", "task": "x", "track": "", "bar": 0, "lengthBars": 0, "dependsOn": ["a"]}]}`,
+	}}
+	plan := orchestrator.plan(context.Background(), "bass and drums", nil, agents)
+	assert.Equal(t, defaultPlan("bass and drums", agents), plan)
+
+	orchestrator.SetPlanning(false)
+	plan = orchestrator.plan(context.Background(), "bass and drums", nil, agents)
+	assert.Len(t, plan.Steps, 4)
+	assert.Nil(t, plan.Steps[1].Target)
+}
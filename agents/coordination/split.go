@@ -0,0 +1,120 @@
+package coordination
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// clausePattern separates the clauses of a request
+var clausePattern = regexp.MustCompile(`(?i)\s*(?:[,;]|\.\s|\b(?:and then|then|and|plus|also)\b)\s*`)
+
+// destinationPattern matches the track a clause puts its content on:
+// "on the drums", "to my keys track", `into "Lead Synth"`
+var destinationPattern = regexp.MustCompile(`(?i)\b(?:on|to|for|into)\s+(?:(?:the|my)\s+([\p{L}\d#-]+)(?:\s+track)?|"([^"]+)"|“([^”]+)”)`)
+
+// notTrackNames are words a destination names that are not tracks: "for the chorus"
+var notTrackNames = map[string]bool{
+	"intro": true, "verse": true, "chorus": true, "bridge": true, "outro": true, "drop": true, "breakdown": true,
+	"song": true, "project": true, "mix": true, "start": true, "beginning": true, "end": true, "rest": true,
+	"same": true, "selected": true, "current": true, "new": true, "last": true, "first": true, "next": true,
+}
+
+// contentClause is a clause of a request, with the track it puts content on
+type contentClause struct {
+	text  string
+	track string // Empty if the clause names no track
+}
+
+// splitClauses splits a request into clauses and finds the track each one
+// names: "a funk groove on the drums and a walking bass on the bass"
+func splitClauses(question string) []contentClause {
+	var clauses []contentClause
+	for _, text := range clausePattern.Split(question, -1) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		clause := contentClause{text: text}
+		for _, match := range destinationPattern.FindAllStringSubmatch(text, -1) {
+			name := strings.TrimSpace(match[1] + match[2] + match[3])
+			if name != "" && !notTrackNames[strings.ToLower(name)] {
+				clause.track = name
+			}
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+// destinationTrack returns the existing track a request puts something on:
+// "put it on the vocal". The last one named wins.
+func destinationTrack(question string, state map[string]any) (int, bool) {
+	resolver := newTrackResolver(state)
+	index, found := 0, false
+	for _, clause := range splitClauses(question) {
+		if clause.track == "" {
+			continue
+		}
+		if track, ok := resolver.track(clause.track); ok {
+			index, found = track, true
+		}
+	}
+	return index, found
+}
+
+// splitPlan plans a request that puts content on several tracks without the
+// LLM: each clause naming a track becomes a step targeting it, run by the
+// content agent whose keywords the clause matches best, with the track name as
+// a tie-breaker. Clauses naming no track that clearly ask a content agent for
+// something become untargeted steps, and the other agents run on the whole
+// request. Requests with content for fewer than two tracks get the default plan.
+func (r *AgentRegistry) splitPlan(question string, agents []Agent) *ExecutionPlan {
+	var content []string
+	for _, agent := range agents {
+		if agent.Descriptor().NeedsTrack {
+			content = append(content, agent.Descriptor().Name)
+		}
+	}
+
+	var steps []*PlanStep
+	tracks := map[string]bool{}
+	for i, clause := range splitClauses(question) {
+		// The track name is not a request for content
+		scores, _ := r.keywords.score(destinationPattern.ReplaceAllString(clause.text, " "))
+		var trackScores map[string]float64
+		if clause.track != "" {
+			trackScores, _ = r.keywords.score(clause.track)
+		}
+
+		best, bestScore := "", 0.0
+		for _, name := range content {
+			if score := scores[name] + trackScores[name]/2; score > bestScore {
+				best, bestScore = name, score
+			}
+		}
+		if best == "" || (clause.track == "" && scores[best] < keywordNeeded) {
+			continue
+		}
+		step := &PlanStep{ID: fmt.Sprintf("%s_%d", best, i+1), Agent: best, Task: clause.text}
+		if clause.track != "" {
+			step.Target = &StepTarget{Track: clause.track}
+			tracks[strings.ToLower(clause.track)] = true
+		}
+		steps = append(steps, step)
+	}
+	if len(tracks) < 2 {
+		return defaultPlan(question, agents)
+	}
+
+	plan := &ExecutionPlan{}
+	for _, agent := range agents {
+		if descriptor := agent.Descriptor(); !descriptor.NeedsTrack {
+			plan.Steps = append(plan.Steps, &PlanStep{ID: descriptor.Name, Agent: descriptor.Name, Task: question})
+		}
+	}
+	plan.Steps = append(plan.Steps, steps...)
+	log.Printf("✂️ Split request into %d content steps for %d tracks", len(steps), len(tracks))
+	return plan
+}
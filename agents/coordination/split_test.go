@@ -0,0 +1,170 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multiTrackRequest = "Create a drum track, a bass track and a keys track, then put a funk groove on the drums, " +
+	"a walking bass on the bass and a melody on the keys"
+
+func TestSplitClauses(t *testing.T) {
+	assert.Equal(t, []contentClause{
+		{text: "Create a drum track"},
+		{text: "a bass track"},
+		{text: "put a funk groove on the drums", track: "drums"},
+		{text: "a walking bass on the bass", track: "bass"},
+	}, splitClauses("Create a drum track and a bass track, put a funk groove on the drums and a walking bass on the bass"))
+
+	assert.Equal(t, []contentClause{
+		{text: `chords on "Lead Synth"`, track: "Lead Synth"},
+		{text: "a pad for the chorus"},
+		{text: "hats on my perc track", track: "perc"},
+	}, splitClauses(`chords on "Lead Synth"; a pad for the chorus. hats on my perc track`))
+}
+
+func TestAgentRegistry_SplitPlan(t *testing.T) {
+	registry := builtinRegistry(t)
+	// The agents the router selects for the request
+	var agents []Agent
+	for _, name := range registry.routeByKeywords(multiTrackRequest).Agents {
+		agent, _ := registry.Get(name)
+		agents = append(agents, agent)
+	}
+
+	plan := registry.splitPlan(multiTrackRequest, agents)
+	require.NoError(t, plan.Validate(registry))
+	var steps [][]any
+	for _, step := range plan.Steps {
+		steps = append(steps, []any{step.ID, step.Task, step.Target.String()})
+	}
+	assert.Equal(t, [][]any{
+		{"daw", multiTrackRequest, ""},
+		{"drummer_4", "put a funk groove on the drums", "drums"},
+		{"arranger_5", "a walking bass on the bass", "bass"},
+		{"arranger_6", "a melody on the keys", "keys"},
+	}, steps)
+
+	// Content for one track, or none, is not split
+	for _, question := range []string{
+		"add a walking bass on the bass",
+		"add reverb to the bass and a delay to the keys",
+	} {
+		assert.Equal(t, defaultPlan(question, agents), registry.splitPlan(question, agents), question)
+	}
+}
+
+func TestTrackResolver_FuzzyNames(t *testing.T) {
+	resolver := newTrackResolver(map[string]any{"tracks": []any{
+		map[string]any{"index": 0, "name": "Drum Kit"},
+		map[string]any{"index": 1, "name": "Bass"},
+	}})
+	resolver.observe(map[string]any{"action": "create_track", "index": 2, "name": "Bass Synth"})
+
+	for name, want := range map[string]int{"bass": 1, "drums": 0, "BASS SYNTH": 2, "synth": 2, "drum kit one": 0} {
+		track, ok := resolver.resolve(&StepTarget{Track: name})
+		assert.True(t, ok, name)
+		assert.Equal(t, want, track, name)
+	}
+	_, ok := resolver.resolve(&StepTarget{Track: "keys"})
+	assert.False(t, ok)
+}
+
+// multiTrackOrchestrator routes the multi-track request by keywords and splits
+// it without the LLM planner
+func multiTrackOrchestrator(t *testing.T) *Orchestrator {
+	t.Helper()
+	notes := func(pitch int) *AgentOutput {
+		return &AgentOutput{Notes: []models.NoteEvent{{MidiNoteNumber: pitch, Velocity: 100, DurationBeats: 1}}}
+	}
+	return testOrchestrator(t, `{"arranger": true, "drummer": true}`,
+		&stubAgent{
+			descriptor: AgentDescriptor{Name: DAWAgentName, Description: "DAW", AlwaysRun: true, Required: true},
+			respond: byTask(map[string]*AgentOutput{multiTrackRequest: {Actions: []map[string]any{
+				{"action": "create_track", "index": 0, "name": "Drum Kit"},
+				{"action": "create_clip_at_bar", "track": 0, "bar": 1, "length_bars": 4},
+				{"action": "create_track", "index": 1, "name": "Bass"},
+				{"action": "create_clip_at_bar", "track": 1, "bar": 1, "length_bars": 4},
+				{"action": "create_track", "index": 2, "name": "Keys"},
+				{"action": "create_clip_at_bar", "track": 2, "bar": 1, "length_bars": 4},
+			}}}),
+		},
+		&stubAgent{
+			descriptor: AgentDescriptor{Name: ArrangerAgentName, Description: "Writes melodies", NeedsTrack: true, Keywords: BuiltinKeywords(ArrangerAgentName)},
+			respond: byTask(map[string]*AgentOutput{
+				"a walking bass on the bass": notes(36),
+				"a melody on the keys":       notes(72),
+			}),
+		},
+		&stubAgent{
+			descriptor: AgentDescriptor{Name: DrummerAgentName, Description: "Writes drums", NeedsTrack: true, Keywords: BuiltinKeywords(DrummerAgentName)},
+			respond: byTask(map[string]*AgentOutput{"put a funk groove on the drums": {Actions: []map[string]any{
+				{"action": "drum_pattern", "drum": "kick", "grid": "x---"},
+			}}}),
+		},
+	)
+}
+
+// trackSummary lists each action with its track, and each add_midi's first pitch
+func trackSummary(actions []map[string]any) [][]any {
+	var summary [][]any
+	for _, action := range actions {
+		entry := []any{action["action"], action["track"]}
+		if notes, ok := action["notes"].([]map[string]any); ok {
+			entry = append(entry, notes[0]["pitch"])
+		}
+		summary = append(summary, entry)
+	}
+	return summary
+}
+
+func TestOrchestrator_GenerateActions_MultiTrack(t *testing.T) {
+	orchestrator := multiTrackOrchestrator(t)
+
+	result, err := orchestrator.GenerateActions(context.Background(), multiTrackRequest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, RoutingMethodKeywords, result.Routing.Method)
+	assert.Equal(t, [][]any{
+		{"create_track", nil},
+		{"create_clip_at_bar", 0},
+		{"drum_pattern", 0},
+		{"create_track", nil},
+		{"create_clip_at_bar", 1},
+		{"add_midi", 1, 36},
+		{"create_track", nil},
+		{"create_clip_at_bar", 2},
+		{"add_midi", 2, 72},
+	}, trackSummary(result.Actions))
+}
+
+func TestOrchestrator_GenerateActionsStream_MultiTrack(t *testing.T) {
+	orchestrator := multiTrackOrchestrator(t)
+
+	var mu sync.Mutex
+	var streamed []map[string]any
+	result, err := orchestrator.GenerateActionsStream(context.Background(), multiTrackRequest, nil, func(action map[string]any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = append(streamed, action)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, streamed, result.Actions)
+	require.Len(t, result.Actions, 9)
+
+	placed := map[any]any{}
+	for _, entry := range trackSummary(result.Actions) {
+		switch entry[0] {
+		case "drum_pattern":
+			placed["drums"] = entry[1]
+		case "add_midi":
+			placed[entry[2]] = entry[1]
+		}
+	}
+	assert.Equal(t, map[any]any{"drums": 0, 36: 1, 72: 2}, placed)
+}
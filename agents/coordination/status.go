@@ -0,0 +1,142 @@
+package coordination
+
+import (
+	"fmt"
+	"log"
+)
+
+// Agent statuses
+const (
+	AgentSucceeded = "succeeded" // Ran and produced output
+	AgentEmpty     = "empty"     // Ran but produced nothing, e.g. a request out of its scope
+	AgentFailed    = "failed"    // Returned an error
+	AgentSkipped   = "skipped"   // Not run because a step it depends on failed
+	AgentCanceled  = "canceled"  // Stopped because a required agent failed
+	AgentPending   = "pending"   // Still running when a first useful result was returned
+)
+
+// AgentStatus is the outcome of one plan step, so callers can tell a request
+// that made music from one whose musical agents failed
+type AgentStatus struct {
+	Step       string `json:"step"`
+	Agent      string `json:"agent"`
+	Status     string `json:"status"` // AgentSucceeded, AgentEmpty, AgentFailed, AgentSkipped, AgentCanceled or AgentPending
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Attempts   int    `json:"attempts,omitempty"` // More than 1 if the step was retried
+	Usage      any    `json:"usage,omitempty"`
+	Reason     string `json:"reason,omitempty"` // Why the agent was selected
+}
+
+// StreamStatusCallback is called with the status of each step as it finishes
+type StreamStatusCallback func(status *AgentStatus)
+
+// FailurePolicy decides what a failed step of an agent that is not required
+// (a musical agent, say) does to the request. Failures of required agents
+// always fail the request.
+type FailurePolicy string
+
+const (
+	FailurePolicyWarn  FailurePolicy = "warn"  // Return the other agents' output, with the failure in the agent statuses
+	FailurePolicyFail  FailurePolicy = "fail"  // Fail the request
+	FailurePolicyRetry FailurePolicy = "retry" // Run the step again, then warn if it fails again
+)
+
+// failureRetries is how many times FailurePolicyRetry reruns a failed step
+const failureRetries = 1
+
+// SetFailurePolicy sets what happens when an agent that is not required fails.
+// The default is FailurePolicyWarn.
+func (o *Orchestrator) SetFailurePolicy(policy FailurePolicy) {
+	o.failurePolicy = policy
+}
+
+// retryable reports whether a failed step is run again
+func (o *Orchestrator) retryable(result *stepResult) bool {
+	return o.failurePolicy == FailurePolicyRetry &&
+		!result.agent.Descriptor().Required &&
+		result.attempts <= failureRetries
+}
+
+// failure returns the error that fails the request, if any. A failed step of a
+// required agent always fails it, so musical output isn't returned for a
+// request the DAW agent rejected. Failed steps of other agents fail it only
+// with FailurePolicyFail; with FailurePolicyWarn, and with FailurePolicyRetry
+// once the retry has failed too, they are logged and the request goes on.
+// Steps that were skipped or canceled did not fail themselves, so the step
+// that did decides.
+func (o *Orchestrator) failure(results []*stepResult) error {
+	for _, result := range results {
+		if result.err == nil || result.skipped || result.canceled {
+			continue
+		}
+		if descriptor := result.agent.Descriptor(); descriptor.Required || o.failurePolicy == FailurePolicyFail {
+			return fmt.Errorf("%s agent failed: %w", descriptor.Name, result.err)
+		}
+		log.Printf("⚠️ Step %s (%s agent) failed, returning the other agents' output: %v", result.step.ID, result.step.Agent, result.err)
+	}
+	for _, result := range results {
+		if descriptor := result.agent.Descriptor(); result.err != nil && descriptor.Required {
+			return fmt.Errorf("%s agent failed: %w", descriptor.Name, result.err)
+		}
+	}
+	return nil
+}
+
+// status returns the outcome of a step, with the reason its agent was selected
+func (r *stepResult) status(decision *RoutingDecision) *AgentStatus {
+	status := &AgentStatus{
+		Step:       r.step.ID,
+		Agent:      r.step.Agent,
+		DurationMs: r.duration.Milliseconds(),
+		Attempts:   r.attempts,
+	}
+	if decision != nil {
+		status.Reason = decision.Reasons[r.step.Agent]
+	}
+	switch {
+	case r.skipped:
+		status.Status = AgentSkipped
+		status.Error = r.err.Error()
+	case r.canceled:
+		status.Status = AgentCanceled
+		status.Error = r.err.Error()
+	case r.err != nil:
+		status.Status = AgentFailed
+		status.Error = r.err.Error()
+	case r.output == nil || (len(r.output.Actions) == 0 && len(r.output.Notes) == 0):
+		status.Status = AgentEmpty
+	default:
+		status.Status = AgentSucceeded
+	}
+	if r.output != nil {
+		status.Usage = r.output.Usage
+	}
+	return status
+}
+
+// pendingStatuses returns the outcome of the finished steps and AgentPending
+// for the others, in plan order
+func pendingStatuses(plan *ExecutionPlan, done map[string]*AgentStatus, decision *RoutingDecision) []*AgentStatus {
+	out := make([]*AgentStatus, len(plan.Steps))
+	for i, step := range plan.Steps {
+		if status, ok := done[step.ID]; ok {
+			out[i] = status
+			continue
+		}
+		out[i] = &AgentStatus{Step: step.ID, Agent: step.Agent, Status: AgentPending}
+		if decision != nil {
+			out[i].Reason = decision.Reasons[step.Agent]
+		}
+	}
+	return out
+}
+
+// statuses returns the outcome of each step, in plan order
+func statuses(results []*stepResult, decision *RoutingDecision) []*AgentStatus {
+	out := make([]*AgentStatus, len(results))
+	for i, result := range results {
+		out[i] = result.status(decision)
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusOrchestrator runs a DAW agent, the given melody agent and a mix agent
+// that finds nothing to do, routed by the LLM router
+func statusOrchestrator(t *testing.T, policy FailurePolicy, melody *stubAgent) (*Orchestrator, *stubAgent) {
+	t.Helper()
+	daw := newDAWAgent()
+	orchestrator := testOrchestrator(t, `{"melody": true, "mix": true}`,
+		daw,
+		melody,
+		&stubAgent{descriptor: AgentDescriptor{Name: "mix", Description: "Analyzes the mix"}, output: &AgentOutput{}},
+	)
+	orchestrator.SetFailurePolicy(policy)
+	return orchestrator, daw
+}
+
+// newMelodyAgent fails its first runs, then succeeds
+func newMelodyAgent(failures int) *stubAgent {
+	return &stubAgent{
+		descriptor: AgentDescriptor{Name: "melody", Description: "Writes melodies", NeedsTrack: true},
+		err:        errors.New("model timed out"),
+		failures:   failures,
+		output:     &AgentOutput{Notes: []models.NoteEvent{{MidiNoteNumber: 60, Velocity: 100, DurationBeats: 1}}},
+	}
+}
+
+func TestOrchestrator_AgentStatus(t *testing.T) {
+	summary := func(result *OrchestratorResult) [][]any {
+		var out [][]any
+		for _, status := range result.Agents {
+			out = append(out, []any{status.Agent, status.Status, status.Error, status.Attempts, status.Reason})
+		}
+		return out
+	}
+
+	generate := func(t *testing.T, policy FailurePolicy, melody *stubAgent) (*OrchestratorResult, error) {
+		orchestrator, _ := statusOrchestrator(t, policy, melody)
+		return orchestrator.GenerateActions(context.Background(), "add a melody", nil)
+	}
+
+	t.Run("warn reports the failure", func(t *testing.T) {
+		result, err := generate(t, FailurePolicyWarn, newMelodyAgent(1))
+		require.NoError(t, err)
+		assert.Len(t, result.Actions, 2)
+		assert.Equal(t, [][]any{
+			{"daw", AgentSucceeded, "", 1, "always runs"},
+			{"melody", AgentFailed, "model timed out", 1, "selected by the LLM router"},
+			{"mix", AgentEmpty, "", 1, "selected by the LLM router"},
+		}, summary(result))
+	})
+
+	t.Run("fail fails the request", func(t *testing.T) {
+		_, err := generate(t, FailurePolicyFail, newMelodyAgent(1))
+		assert.ErrorContains(t, err, "melody agent failed: model timed out")
+	})
+
+	t.Run("retry runs the step again", func(t *testing.T) {
+		result, err := generate(t, FailurePolicyRetry, newMelodyAgent(1))
+		require.NoError(t, err)
+		assert.Equal(t, "add_midi", result.Actions[2]["action"])
+		assert.Equal(t, []any{"melody", AgentSucceeded, "", 2, "selected by the LLM router"}, summary(result)[1])
+	})
+
+	t.Run("retry gives up after one retry", func(t *testing.T) {
+		result, err := generate(t, FailurePolicyRetry, newMelodyAgent(5))
+		require.NoError(t, err)
+		assert.Equal(t, []any{"melody", AgentFailed, "model timed out", 2, "selected by the LLM router"}, summary(result)[1])
+	})
+}
+
+func TestOrchestrator_GenerateActionsStreamWithStatus(t *testing.T) {
+	run := func(t *testing.T, melody *stubAgent) (*OrchestratorResult, map[string]*AgentStatus) {
+		t.Helper()
+		orchestrator, daw := statusOrchestrator(t, FailurePolicyRetry, melody)
+		// Until the DAW has made a clip, the melody's notes are held
+		daw.gate = melody.retried
+		var mu sync.Mutex
+		streamed := map[string]*AgentStatus{}
+		result, err := orchestrator.GenerateActionsStreamWithStatus(
+			context.Background(), "add a melody", nil, nil,
+			func(status *AgentStatus) {
+				mu.Lock()
+				defer mu.Unlock()
+				streamed[status.Step] = status
+			})
+		require.NoError(t, err)
+		assert.Len(t, streamed, 3)
+		assert.Equal(t, result.Agents[1], streamed["melody"])
+		return result, streamed
+	}
+
+	t.Run("held output is dropped before a retry", func(t *testing.T) {
+		melody := newMelodyAgent(1)
+		melody.emitFirst = true
+		melody.retried = make(chan struct{})
+		result, statuses := run(t, melody)
+		assert.Equal(t, AgentSucceeded, statuses["melody"].Status)
+		assert.Equal(t, 2, statuses["melody"].Attempts)
+		require.Len(t, result.Actions, 3)
+		assert.Len(t, result.Actions[2]["notes"], 1, "the failed attempt's notes are not sent")
+	})
+
+	t.Run("sent output is not retried", func(t *testing.T) {
+		melody := newMelodyAgent(1)
+		melody.emitFirst = true
+		melody.descriptor.NeedsTrack = false
+		melody.output = &AgentOutput{Actions: []map[string]any{{"action": "set_tempo", "bpm": 90}}}
+		_, statuses := run(t, melody)
+		assert.Equal(t, AgentFailed, statuses["melody"].Status)
+		assert.Equal(t, 1, statuses["melody"].Attempts)
+	})
+}
+
+func TestStepResult_Status_Skipped(t *testing.T) {
+	result := &stepResult{
+		step:    &PlanStep{ID: "compress", Agent: "fx"},
+		err:     errors.New(`skipped: step "tracks" failed`),
+		skipped: true,
+	}
+	status := result.status(&RoutingDecision{Reasons: map[string]string{"fx": "keyword score 1.0"}})
+	assert.Equal(t, &AgentStatus{
+		Step:   "compress",
+		Agent:  "fx",
+		Status: AgentSkipped,
+		Error:  `skipped: step "tracks" failed`,
+		Reason: "keyword score 1.0",
+	}, status)
+}
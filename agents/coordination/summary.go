@@ -0,0 +1,376 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+)
+
+// maxSummarySteps is how many steps the summary text spells out before it
+// counts the rest
+const maxSummarySteps = 5
+
+// PlanSummary explains to the user what a result's actions will do
+type PlanSummary struct {
+	Text     string   `json:"text"`               // One or two sentences for the user
+	Steps    []string `json:"steps,omitempty"`    // One line per change, in action order
+	Polished bool     `json:"polished,omitempty"` // Text was rewritten by the LLM
+}
+
+// SummarizeActions describes the actions in words. Tracks are named from the
+// state and the tracks the actions create, and actions with a "description"
+// (such as mix recommendations) are described by it. Repeated steps are
+// counted rather than listed again.
+func SummarizeActions(actions []map[string]any, state map[string]any) *PlanSummary {
+	names := newTrackNames(state)
+	effects := map[string]string{} // Installed JSFX names, by path
+
+	var steps []string
+	last, repeats := "", 0
+	for _, action := range actions {
+		step := describeAction(action, names, effects)
+		if step == "" {
+			continue
+		}
+		if step == last {
+			repeats++
+			steps[len(steps)-1] = fmt.Sprintf("%s (×%d)", step, repeats+1)
+			continue
+		}
+		last, repeats = step, 0
+		steps = append(steps, step)
+	}
+	return &PlanSummary{Text: summaryText(steps), Steps: steps}
+}
+
+// summaryText joins the steps into a sentence: "Create track "Bass", add a
+// 4-bar clip at bar 1 on Bass and write 16 notes on Bass."
+func summaryText(steps []string) string {
+	if len(steps) == 0 {
+		return "No changes."
+	}
+	parts := make([]string, 0, maxSummarySteps+1)
+	for i, step := range steps {
+		if i == maxSummarySteps-1 && len(steps) > maxSummarySteps {
+			parts = append(parts, fmt.Sprintf("%d more changes", len(steps)-i))
+			break
+		}
+		if i > 0 {
+			step = lowerFirst(step)
+		}
+		parts = append(parts, step)
+	}
+	text := parts[0]
+	if len(parts) > 1 {
+		text = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+	return text + "."
+}
+
+// describeAction returns one summary step for an action, or "" for actions
+// the user doesn't need to hear about
+func describeAction(action map[string]any, names *trackNames, effects map[string]string) string {
+	name, _ := action["action"].(string)
+	track := names.of(action)
+	on := ""
+	if track != "" {
+		on = " on " + track
+	}
+
+	if description, ok := action["description"].(string); ok && description != "" && name != "install_jsfx" {
+		return upperFirst(strings.TrimSuffix(strings.TrimSpace(description), "."))
+	}
+
+	switch name {
+	case "":
+		return ""
+	case "create_track":
+		step := "Create a new track"
+		if trackName, _ := action["name"].(string); trackName != "" {
+			step = fmt.Sprintf("Create track %q", trackName)
+		}
+		if instrument, _ := action["instrument"].(string); instrument != "" {
+			step += " with " + effectName(instrument, effects)
+		}
+		return step
+	case "create_clip", "create_clip_at_bar":
+		clip := "a clip"
+		if length, ok := getInt(action, "length_bars"); ok && length > 0 {
+			clip = fmt.Sprintf("a %d-bar clip", length)
+		}
+		if bar, ok := getInt(action, "bar"); ok {
+			return fmt.Sprintf("Add %s at bar %d%s", clip, bar, on)
+		}
+		return "Add " + clip + on
+	case "add_midi":
+		count := noteCount(action["notes"])
+		if count == 1 {
+			return "Write 1 note" + on
+		}
+		return fmt.Sprintf("Write %d notes%s", count, on)
+	case "drum_pattern":
+		if drum, _ := action["drum"].(string); drum != "" {
+			return fmt.Sprintf("Add a %s pattern%s", drum, on)
+		}
+		return "Add a drum pattern" + on
+	case "install_jsfx":
+		path, _ := action["path"].(string)
+		effect, _ := action["name"].(string)
+		if effect == "" {
+			effect = path
+		}
+		effects[path] = effect
+		return fmt.Sprintf("Install the %q JSFX effect", effect)
+	case "add_track_fx", "add_instrument":
+		fx, _ := action["fxname"].(string)
+		verb := "Add "
+		if name == "add_instrument" {
+			verb = "Load "
+		}
+		if track == "" {
+			return verb + effectName(fx, effects)
+		}
+		return verb + effectName(fx, effects) + " to " + track
+	case "set_track":
+		return describeTrackChanges(action, track)
+	case "delete_track":
+		if track == "" {
+			return "Delete a track"
+		}
+		return "Delete " + track
+	case "modify_fx_param":
+		parameter, _ := action["parameter"].(string)
+		if parameter == "" {
+			parameter = "an FX parameter"
+		}
+		if value, ok := action["value"]; ok {
+			return fmt.Sprintf("Set %s to %v%s", parameter, value, on)
+		}
+		return "Change " + parameter + on
+	case "add_automation":
+		if parameter, _ := action["param"].(string); parameter != "" {
+			return fmt.Sprintf("Automate %s%s", parameter, on)
+		}
+		return "Add automation" + on
+	case "set_clip", "set_clip_selected":
+		return "Edit a clip" + on
+	case "set_clip_position":
+		return "Move a clip" + on
+	case "delete_clip":
+		return "Delete a clip" + on
+	}
+	return upperFirst(strings.ReplaceAll(name, "_", " ")) + on
+}
+
+// describeTrackChanges describes a set_track action: "Change Bass: volume
+// -2 dB, pan 30% left"
+func describeTrackChanges(action map[string]any, track string) string {
+	if track == "" {
+		track = "the track"
+	}
+	var changes []string
+	if name, _ := action["name"].(string); name != "" {
+		changes = append(changes, fmt.Sprintf("rename to %q", name))
+	}
+	if volume, ok := getFloat(action, "volume_db"); ok {
+		changes = append(changes, fmt.Sprintf("volume %s dB", formatNumber(volume)))
+	}
+	if pan, ok := getFloat(action, "pan"); ok {
+		switch {
+		case pan < 0:
+			changes = append(changes, fmt.Sprintf("pan %s%% left", formatNumber(-pan*100)))
+		case pan > 0:
+			changes = append(changes, fmt.Sprintf("pan %s%% right", formatNumber(pan*100)))
+		default:
+			changes = append(changes, "pan center")
+		}
+	}
+	for _, flag := range [][2]string{{"mute", "muted"}, {"solo", "soloed"}} {
+		if on, ok := action[flag[0]].(bool); ok {
+			if on {
+				changes = append(changes, flag[1])
+			} else {
+				changes = append(changes, "un"+flag[1])
+			}
+		}
+	}
+	if color, _ := action["color"].(string); color != "" {
+		changes = append(changes, "color "+color)
+	}
+
+	if len(changes) == 0 {
+		if selected, _ := action["selected"].(bool); selected {
+			return "Select " + track
+		}
+		return "Change " + track
+	}
+	return fmt.Sprintf("Change %s: %s", track, strings.Join(changes, ", "))
+}
+
+// trackNames names tracks by index, from the state and the created tracks
+type trackNames struct {
+	names   map[int]string
+	created int // Tracks created so far, to index create_track without one
+	count   int // Tracks in the state
+}
+
+func newTrackNames(state map[string]any) *trackNames {
+	t := &trackNames{names: map[int]string{}}
+	for i, track := range stateTrackList(state) {
+		trackMap, _ := track.(map[string]any)
+		index, ok := getInt(trackMap, "index")
+		if !ok {
+			index = i
+		}
+		if name, _ := trackMap["name"].(string); name != "" {
+			t.names[index] = name
+		}
+		t.count = max(t.count, index+1)
+	}
+	return t
+}
+
+// of returns the name of the action's track, and records the tracks
+// create_track actions add. It is "" for actions without a track.
+func (t *trackNames) of(action map[string]any) string {
+	if action["action"] == "create_track" {
+		index, ok := getInt(action, "index")
+		if !ok {
+			index = t.count + t.created
+		}
+		t.created++
+		if name, _ := action["name"].(string); name != "" {
+			t.names[index] = name
+		}
+		return ""
+	}
+	index, ok := getInt(action, "track")
+	if !ok {
+		return ""
+	}
+	if name, ok := t.names[index]; ok {
+		return name
+	}
+	return fmt.Sprintf("track %d", index+1)
+}
+
+// effectName is the FX name to show the user: the name of a JSFX effect
+// installed by the same actions, or the FX name without its "JS: " prefix
+func effectName(fx string, effects map[string]string) string {
+	path := strings.TrimPrefix(fx, "JS: ")
+	if name, ok := effects[path]; ok {
+		return fmt.Sprintf("the %q effect", name)
+	}
+	if path == "" {
+		return "an effect"
+	}
+	return path
+}
+
+// noteCount counts the notes of an add_midi action, as built here or decoded
+// from JSON
+func noteCount(notes any) int {
+	switch n := notes.(type) {
+	case []map[string]any:
+		return len(n)
+	case []any:
+		return len(n)
+	}
+	return 0
+}
+
+// formatNumber drops a whole number's decimals: -2, 1.5
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%d", int(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// lowerFirst lowers the first letter of a step joined into a sentence,
+// unless the first word is an acronym such as "EQ"
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// SetSummaryPolish turns on rewriting the plan summary with the LLM. The
+// summary built from the actions is kept when the LLM fails.
+func (o *Orchestrator) SetSummaryPolish(enabled bool) {
+	o.polishSummary = enabled
+}
+
+// summarize builds the plan summary of the actions, polished by the LLM if
+// that is turned on
+func (o *Orchestrator) summarize(ctx context.Context, question string, actions []map[string]any, state map[string]any, logPrefix string) *PlanSummary {
+	summary := SummarizeActions(actions, state)
+	if !o.polishSummary || len(summary.Steps) == 0 {
+		return summary
+	}
+	text, err := o.polish(ctx, question, summary)
+	if err != nil {
+		log.Printf("⚠️ %sSummary polish failed, keeping the summary built from the actions: %v", logPrefix, err)
+		return summary
+	}
+	summary.Text = text
+	summary.Polished = true
+	return summary
+}
+
+// polish asks a small LLM to rewrite the summary for the user
+func (o *Orchestrator) polish(ctx context.Context, question string, summary *PlanSummary) (string, error) {
+	var b strings.Builder
+	b.WriteString("A music production assistant is about to make these changes in REAPER for the user:\n")
+	for _, step := range summary.Steps {
+		fmt.Fprintf(&b, "- %s\n", step)
+	}
+	fmt.Fprintf(&b, "\nREQUEST: %q\n\n", question)
+	b.WriteString("Tell the user what is about to happen in one or two short, friendly sentences. " +
+		"Mention only the changes listed, leave out indexes and technical details, and do not ask questions.\n\n" +
+		"Return JSON: {\"text\"}")
+
+	request := &llm.GenerationRequest{
+		Model:         "gpt-4.1-mini",
+		InputArray:    []map[string]any{{"role": "user", "content": b.String()}},
+		ReasoningMode: "none",
+		OutputSchema: &llm.OutputSchema{
+			Name:        "PlanSummary",
+			Description: "What the changes do, for the user",
+			Schema: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           map[string]any{"text": map[string]any{"type": "string"}},
+				"required":             []string{"text"},
+			},
+		},
+	}
+	resp, err := o.llmProvider.Generate(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	var answer struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(resp.RawOutput), &answer); err != nil {
+		return "", fmt.Errorf("failed to parse summary: %w", err)
+	}
+	if strings.TrimSpace(answer.Text) == "" {
+		return "", fmt.Errorf("summary is empty")
+	}
+	return strings.TrimSpace(answer.Text), nil
+}
@@ -0,0 +1,124 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeActions(t *testing.T) {
+	state := map[string]any{"tracks": []any{
+		map[string]any{"index": 0, "name": "Vocal"},
+		map[string]any{"index": 1},
+	}}
+	summary := SummarizeActions([]map[string]any{
+		{"action": "create_track", "name": "Bass", "instrument": "ReaSynth"},
+		{"action": "create_clip_at_bar", "track": 2, "bar": 1, "length_bars": 4},
+		{"action": "add_midi", "track": 2, "notes": []any{map[string]any{"pitch": 36}, map[string]any{"pitch": 38}}},
+		{"action": "install_jsfx", "path": "magda/tape_saturation.jsfx", "name": "Tape Saturation"},
+		{"action": "add_track_fx", "track": 0, "fxname": "JS: magda/tape_saturation.jsfx"},
+		{"action": "set_track", "track": 1, "volume_db": -2.5, "pan": -0.3, "mute": false},
+		{"action": "set_track", "track": float64(0), "selected": true},
+		{"action": "add_track_fx", "track": 1, "fxname": "ReaEQ", "description": "Cut 250 Hz to clear the mud."},
+		{"action": "drum_pattern", "drum": "kick", "track": 2},
+		{"action": "drum_pattern", "drum": "kick", "track": 2},
+	}, state)
+
+	assert.Equal(t, []string{
+		`Create track "Bass" with ReaSynth`,
+		"Add a 4-bar clip at bar 1 on Bass",
+		"Write 2 notes on Bass",
+		`Install the "Tape Saturation" JSFX effect`,
+		`Add the "Tape Saturation" effect to Vocal`,
+		"Change track 2: volume -2.5 dB, pan 30% left, unmuted",
+		"Select Vocal",
+		"Cut 250 Hz to clear the mud",
+		"Add a kick pattern on Bass (×2)",
+	}, summary.Steps)
+	assert.Equal(t, `Create track "Bass" with ReaSynth, add a 4-bar clip at bar 1 on Bass, write 2 notes on Bass, `+
+		`install the "Tape Saturation" JSFX effect and 5 more changes.`, summary.Text)
+	assert.False(t, summary.Polished)
+
+	assert.Equal(t, "Add ReaComp to Vocal and set ratio to 4 on Vocal.", SummarizeActions([]map[string]any{
+		{"action": "add_track_fx", "track": 0, "fxname": "ReaComp"},
+		{"action": "modify_fx_param", "track": 0, "parameter": "ratio", "value": 4},
+	}, state).Text)
+	assert.Equal(t, &PlanSummary{Text: "No changes."}, SummarizeActions(nil, state))
+}
+
+func TestOrchestrator_Summary(t *testing.T) {
+	want := `Create track "Drum Kit", add a 4-bar clip at bar 1 on Drum Kit, add a kick pattern on Drum Kit, ` +
+		`create track "Bass" and 5 more changes.`
+
+	orchestrator := multiTrackOrchestrator(t)
+	result, err := orchestrator.GenerateActions(context.Background(), multiTrackRequest, nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Summary)
+	assert.Equal(t, want, result.Summary.Text)
+	assert.Len(t, result.Summary.Steps, 9)
+
+	// The stream ends with the summary
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	result, err = orchestrator.GenerateActionsStreamWithOptions(context.Background(), multiTrackRequest, nil, StreamOptions{
+		OnAction: func(action map[string]any) error {
+			record("action")
+			return nil
+		},
+		OnSummary: func(summary *PlanSummary) { record(summary.Text) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, SummarizeActions(result.Actions, nil), result.Summary, "streamed actions come in their own order")
+	assert.Equal(t, result.Summary.Text, events[len(events)-1])
+
+	// The router's answer is no summary, so the polish falls back
+	orchestrator.SetSummaryPolish(true)
+	result, err = orchestrator.GenerateActions(context.Background(), multiTrackRequest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, result.Summary.Text)
+	assert.False(t, result.Summary.Polished)
+}
+
+func TestOrchestrator_SummaryPolish(t *testing.T) {
+	orchestrator, err := NewOrchestratorWithAgents(
+		&schemaProvider{answers: map[string]string{"PlanSummary": `{"text": "I'll add a Drums track with a 4-bar clip."}`}},
+		newDAWAgent(),
+	)
+	require.NoError(t, err)
+	orchestrator.SetSummaryPolish(true)
+
+	result, err := orchestrator.GenerateActions(context.Background(), "add a drums track", nil)
+	require.NoError(t, err)
+	assert.Equal(t, &PlanSummary{
+		Text:     "I'll add a Drums track with a 4-bar clip.",
+		Steps:    []string{"Create a new track", "Add a clip at bar 1 on track 1"},
+		Polished: true,
+	}, result.Summary)
+}
+
+func TestOrchestrator_FirstUsefulResultSummary(t *testing.T) {
+	orchestrator, melody := timeoutOrchestrator(t, newDAWAgent())
+
+	followUps := make(chan *FollowUpEvent, 4)
+	result, err := orchestrator.GenerateActionsStreamWithOptions(context.Background(), "add a melody", nil, StreamOptions{
+		FirstUsefulResult: true,
+		OnFollowUp:        func(event *FollowUpEvent) { followUps <- event },
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.Summary, "the summary comes with the last follow-up event")
+
+	close(melody.gate)
+	<-followUps
+	event := <-followUps
+	require.True(t, event.Done)
+	require.NotNil(t, event.Summary)
+	assert.Equal(t, "Create a new track, add a clip at bar 1 on track 1 and write 1 note on track 1.", event.Summary.Text)
+}
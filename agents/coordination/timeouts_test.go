@@ -0,0 +1,154 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timeoutOrchestrator runs the given DAW agent and a melody agent that waits
+// for its gate
+func timeoutOrchestrator(t *testing.T, daw Agent) (*Orchestrator, *stubAgent) {
+	t.Helper()
+	melody := &stubAgent{
+		descriptor: AgentDescriptor{Name: "melody", Description: "Writes melodies", NeedsTrack: true},
+		output:     &AgentOutput{Notes: []models.NoteEvent{{MidiNoteNumber: 60, Velocity: 100, DurationBeats: 1}}},
+		gate:       make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		select {
+		case <-melody.gate:
+		default:
+			close(melody.gate)
+		}
+	})
+	return testOrchestrator(t, `{"melody": true}`, daw, melody), melody
+}
+
+func TestOrchestrator_AgentTimeout(t *testing.T) {
+	for _, ignoreContext := range []bool{false, true} {
+		orchestrator, melody := timeoutOrchestrator(t, newDAWAgent())
+		melody.ignoreContext = ignoreContext
+		orchestrator.SetAgentTimeout("melody", 20*time.Millisecond)
+
+		result, err := orchestrator.GenerateActions(context.Background(), "add a melody", nil)
+		require.NoError(t, err)
+		assert.Len(t, result.Actions, 2, "the DAW actions are returned")
+		require.Len(t, result.Agents, 2)
+		assert.Equal(t, AgentFailed, result.Agents[1].Status)
+		assert.Contains(t, result.Agents[1].Error, "melody agent timed out after 20ms")
+	}
+
+	orchestrator, _ := timeoutOrchestrator(t, newDAWAgent())
+	orchestrator.SetDefaultAgentTimeout(time.Minute)
+	orchestrator.SetAgentTimeout("melody", 20*time.Millisecond)
+	assert.Equal(t, time.Minute, orchestrator.agentTimeout("daw"))
+	assert.Equal(t, 20*time.Millisecond, orchestrator.agentTimeout("melody"))
+	orchestrator.SetAgentTimeout("melody", 0)
+	assert.Equal(t, time.Minute, orchestrator.agentTimeout("melody"))
+}
+
+func TestOrchestrator_RequiredFailureCancelsSiblings(t *testing.T) {
+	daw := newDAWAgent()
+	daw.err = errors.New("not a music request")
+	orchestrator, _ := timeoutOrchestrator(t, daw)
+
+	var mu sync.Mutex
+	streamed := map[string]*AgentStatus{}
+	start := time.Now()
+	_, err := orchestrator.GenerateActionsStreamWithOptions(context.Background(), "add a melody", nil, StreamOptions{
+		OnStatus: func(status *AgentStatus) {
+			mu.Lock()
+			defer mu.Unlock()
+			streamed[status.Step] = status
+		},
+		FirstUsefulResult: true,
+	})
+	assert.ErrorContains(t, err, "daw agent failed: not a music request")
+	assert.Less(t, time.Since(start), time.Second, "the melody agent does not hold up the response")
+	assert.Equal(t, AgentFailed, streamed["daw"].Status)
+	assert.Equal(t, AgentCanceled, streamed["melody"].Status)
+	assert.Equal(t, "canceled: daw agent failed", streamed["melody"].Error)
+}
+
+func TestOrchestrator_FirstUsefulResult(t *testing.T) {
+	orchestrator, melody := timeoutOrchestrator(t, newDAWAgent())
+
+	var streamed []map[string]any
+	followUps := make(chan *FollowUpEvent, 4)
+	result, err := orchestrator.GenerateActionsStreamWithOptions(context.Background(), "add a melody", nil, StreamOptions{
+		OnAction: func(action map[string]any) error {
+			streamed = append(streamed, action)
+			return nil
+		},
+		FirstUsefulResult: true,
+		OnFollowUp:        func(event *FollowUpEvent) { followUps <- event },
+	})
+	require.NoError(t, err)
+	assert.Len(t, result.Actions, 2, "the DAW actions are returned at once")
+	assert.Equal(t, result.Actions, streamed)
+	require.Len(t, result.Agents, 2)
+	assert.Equal(t, AgentSucceeded, result.Agents[0].Status)
+	assert.Equal(t, AgentPending, result.Agents[1].Status)
+
+	// The melody arrives as a follow-up, placed on the DAW's clip
+	close(melody.gate)
+	event := <-followUps
+	assert.False(t, event.Done)
+	require.Len(t, event.Actions, 1)
+	assert.Equal(t, "add_midi", event.Actions[0]["action"])
+	assert.Equal(t, 0, event.Actions[0]["track"])
+
+	event = <-followUps
+	assert.True(t, event.Done)
+	require.Len(t, event.Agents, 2)
+	assert.Equal(t, AgentSucceeded, event.Agents[1].Status)
+	assert.Len(t, streamed, 2, "follow-up actions do not go to OnAction")
+}
+
+func TestOrchestrator_FirstUsefulResult_LateFailure(t *testing.T) {
+	orchestrator, _ := timeoutOrchestrator(t, newDAWAgent())
+	orchestrator.SetFailurePolicy(FailurePolicyFail)
+	orchestrator.SetAgentTimeout("melody", 20*time.Millisecond)
+
+	followUps := make(chan *FollowUpEvent, 4)
+	_, err := orchestrator.GenerateActionsStreamWithOptions(context.Background(), "add a melody", nil, StreamOptions{
+		FirstUsefulResult: true,
+		OnFollowUp:        func(event *FollowUpEvent) { followUps <- event },
+	})
+	require.NoError(t, err, "the request has returned before the melody fails")
+
+	event := <-followUps
+	require.True(t, event.Done)
+	assert.Contains(t, event.Error, "melody agent failed: melody agent timed out")
+	assert.Equal(t, AgentFailed, event.Agents[1].Status)
+}
+
+func TestOrchestrator_FirstUsefulResult_OutlivesContext(t *testing.T) {
+	orchestrator, melody := timeoutOrchestrator(t, newDAWAgent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	followUps := make(chan *FollowUpEvent, 4)
+	_, err := orchestrator.GenerateActionsStreamWithOptions(ctx, "add a melody", nil, StreamOptions{
+		FirstUsefulResult: true,
+		OnFollowUp:        func(event *FollowUpEvent) { followUps <- event },
+	})
+	require.NoError(t, err)
+
+	// The caller is done with its context; the melody still arrives
+	cancel()
+	close(melody.gate)
+	event := <-followUps
+	require.Len(t, event.Actions, 1)
+	assert.Equal(t, "add_midi", event.Actions[0]["action"])
+	event = <-followUps
+	require.True(t, event.Done)
+	assert.Empty(t, event.Error)
+	assert.Equal(t, AgentSucceeded, event.Agents[1].Status)
+}
@@ -0,0 +1,187 @@
+package daw
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+)
+
+// trackOperationPattern matches requests that act on an existing track
+var trackOperationPattern = regexp.MustCompile(`(?i)\b(?:louder|quieter|softer|volume|gain|pan|panning|mute|unmute|solo|unsolo|` +
+	`reverb|delay|echo|eq|equali[sz]e|compress|compressor|compression|limiter|chorus|flanger|phaser|distortion|saturation|` +
+	`fx|effects?|plugins?|rename|colou?r|arm)\b`)
+
+// trackCreationPattern matches requests that create the track they act on
+var trackCreationPattern = regexp.MustCompile(`(?i)\b(?:new|create|add)\s+(?:an?\s+)?(?:new\s+)?(?:\w+\s+)?track\b`)
+
+// explicitTrackPattern matches requests that say which tracks they mean
+var explicitTrackPattern = regexp.MustCompile(`(?i)\btrack\s*#?\d+\b|\b(?:all|every|each|everything|master|selected|` +
+	`first|second|third|last|both|other|others)\b|"[^"]+"|“[^”]+”`)
+
+// genericNameWords are track name words that don't tell tracks apart
+var genericNameWords = map[string]bool{"track": true, "audio": true, "midi": true, "bus": true, "new": true}
+
+// Clarify returns a clarification when a request acts on a track without
+// saying which one, and the state doesn't settle it: there are several
+// tracks and none is selected. The options are the tracks. It returns nil
+// when the request can be acted on.
+func Clarify(question string, state map[string]any) *models.Clarification {
+	tracks := stateTracks(state)
+	if len(tracks) < 2 || !trackOperationPattern.MatchString(question) ||
+		trackCreationPattern.MatchString(question) || explicitTrackPattern.MatchString(question) {
+		return nil
+	}
+
+	words := nameWords(question)
+	var options []models.ClarificationOption
+	for i, track := range tracks {
+		trackMap, ok := track.(map[string]any)
+		if !ok {
+			continue
+		}
+		if selected, _ := trackMap["selected"].(bool); selected {
+			return nil
+		}
+		name, _ := trackMap["name"].(string)
+		for word := range nameWords(name) {
+			if words[word] && !genericNameWords[word] {
+				// The request names the track
+				return nil
+			}
+		}
+
+		index := stateTrackIndex(trackMap, i)
+		label := strings.TrimSpace(name)
+		if label == "" {
+			label = fmt.Sprintf("Track %d", index+1)
+		}
+		options = append(options, models.ClarificationOption{ID: fmt.Sprint(index + 1), Label: label, Track: &index})
+	}
+	if len(options) < 2 {
+		return nil
+	}
+	return &models.Clarification{
+		Request:  question,
+		Question: "Which track do you mean? No track is selected.",
+		Options:  options,
+		Agent:    "daw",
+	}
+}
+
+// ResolveClarification returns the request to resume a clarification with.
+// For a track option, it is the original request with a copy of the state in
+// which that track is the only selected one, so the request applies to it.
+// Other options are added to the request: "add reverb (Hall)".
+func ResolveClarification(clarification *models.Clarification, choice string, state map[string]any) (string, map[string]any, error) {
+	option, ok := clarification.Option(choice)
+	if !ok {
+		ids := make([]string, len(clarification.Options))
+		for i, option := range clarification.Options {
+			ids[i] = option.ID
+		}
+		return "", nil, fmt.Errorf("unknown option %q, want one of %s", choice, strings.Join(ids, ", "))
+	}
+	if option.Track == nil {
+		return fmt.Sprintf("%s (%s)", clarification.Request, option.Label), state, nil
+	}
+	return clarification.Request, selectTrack(state, *option.Track), nil
+}
+
+// selectTrack returns a copy of the state in which only the track with the
+// given index is selected
+func selectTrack(state map[string]any, index int) map[string]any {
+	out := make(map[string]any, len(state))
+	for k, v := range state {
+		out[k] = v
+	}
+	inner := out
+	if nested, ok := out["state"].(map[string]any); ok {
+		inner = make(map[string]any, len(nested))
+		for k, v := range nested {
+			inner[k] = v
+		}
+		out["state"] = inner
+	}
+
+	tracks := stateTracks(state)
+	selected := make([]any, len(tracks))
+	for i, track := range tracks {
+		trackMap, ok := track.(map[string]any)
+		if !ok {
+			selected[i] = track
+			continue
+		}
+		copied := make(map[string]any, len(trackMap)+1)
+		for k, v := range trackMap {
+			copied[k] = v
+		}
+		copied["selected"] = stateTrackIndex(trackMap, i) == index
+		selected[i] = copied
+	}
+	inner["tracks"] = selected
+	return out
+}
+
+// stateTrackIndex returns a state track's index, or its position in the
+// tracks array if it has none
+func stateTrackIndex(track map[string]any, position int) int {
+	switch index := track["index"].(type) {
+	case int:
+		return index
+	case float64:
+		return int(index)
+	}
+	return position
+}
+
+// nameWords returns the lowercase words of a name or request, without plural endings
+func nameWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = word[:len(word)-1]
+		}
+		if len(word) > 1 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// SetClarification turns the clarifying-question mode on or off. When on,
+// an ambiguous request gets a DawResult with a Clarification and no actions,
+// instead of a guess; Resume runs it with the user's answer.
+func (a *DawAgent) SetClarification(enabled bool) {
+	a.clarify = enabled
+}
+
+// clarification returns the clarification for a request, if the mode is on
+// and the request needs one
+func (a *DawAgent) clarification(question string, state map[string]any) *models.Clarification {
+	if !a.clarify {
+		return nil
+	}
+	clarification := Clarify(question, state)
+	if clarification != nil {
+		log.Printf("❓ MAGDA asks which track %q is for (%d options)", question, len(clarification.Options))
+	}
+	return clarification
+}
+
+// Resume runs the request a clarification was asked for, with the chosen option
+func (a *DawAgent) Resume(
+	ctx context.Context, clarification *models.Clarification, choice string, state map[string]any,
+) (*DawResult, error) {
+	question, state, err := ResolveClarification(clarification, choice, state)
+	if err != nil {
+		return nil, err
+	}
+	return a.GenerateActions(ctx, question, state)
+}
@@ -0,0 +1,111 @@
+package daw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func clarifyState(selected int) map[string]any {
+	tracks := []any{}
+	for i, name := range []string{"Drums", "Bass", "Lead Vocals", ""} {
+		tracks = append(tracks, map[string]any{"index": i, "name": name, "selected": i == selected})
+	}
+	return map[string]any{"state": map[string]any{"tracks": tracks}}
+}
+
+func TestClarify(t *testing.T) {
+	for _, question := range []string{
+		"make it louder",
+		"add reverb",
+		"pan it a little to the left",
+		"mute this",
+	} {
+		clarification := Clarify(question, clarifyState(-1))
+		require.NotNil(t, clarification, question)
+		assert.Equal(t, question, clarification.Request)
+		assert.Equal(t, "daw", clarification.Agent)
+		require.Len(t, clarification.Options, 4, question)
+		assert.Equal(t, "Lead Vocals", clarification.Options[2].Label)
+		assert.Equal(t, "Track 4", clarification.Options[3].Label, "unnamed tracks get a number")
+		assert.Equal(t, "2", clarification.Options[1].ID)
+		assert.Equal(t, 1, *clarification.Options[1].Track)
+	}
+
+	for _, question := range []string{
+		"add reverb to the vocal",  // Names a track
+		"make track 2 louder",      // Names a track by number
+		"mute all tracks",          // Says which tracks
+		`add reverb to "Drums"`,    // Quoted name
+		"create a new track",       // Creates the track
+		"add a bass track with eq", // Creates the track
+		"set the tempo to 120",     // Not a track operation
+		"add a clip at bar 4",      // Not a track operation
+	} {
+		assert.Nil(t, Clarify(question, clarifyState(-1)), question)
+	}
+
+	assert.Nil(t, Clarify("make it louder", clarifyState(0)), "the selected track is used")
+	assert.Nil(t, Clarify("make it louder", map[string]any{"tracks": []any{map[string]any{"index": 0, "name": "Bass"}}}), "a single track is used")
+	assert.Nil(t, Clarify("make it louder", nil))
+}
+
+func TestResolveClarification(t *testing.T) {
+	state := clarifyState(-1)
+	clarification := Clarify("make it louder", state)
+	require.NotNil(t, clarification)
+
+	question, resumed, err := ResolveClarification(clarification, "3", state)
+	require.NoError(t, err)
+	assert.Equal(t, "make it louder", question)
+	for i, track := range stateTracks(resumed) {
+		assert.Equal(t, i == 2, track.(map[string]any)["selected"], i)
+	}
+	assert.Equal(t, false, stateTracks(state)[2].(map[string]any)["selected"], "the state is not changed")
+	assert.Nil(t, Clarify(question, resumed), "the resumed request is not ambiguous")
+
+	_, _, err = ResolveClarification(clarification, "9", state)
+	assert.ErrorContains(t, err, `unknown option "9", want one of 1, 2, 3, 4`)
+
+	// Other options are added to the request
+	question, resumed, err = ResolveClarification(&models.Clarification{
+		Request: "add reverb",
+		Options: []models.ClarificationOption{{ID: "room", Label: "A small room"}},
+	}, "room", state)
+	require.NoError(t, err)
+	assert.Equal(t, "add reverb (A small room)", question)
+	assert.Equal(t, state, resumed)
+}
+
+func TestDawAgent_Clarification(t *testing.T) {
+	agent := NewDawAgentWithProvider(&config.Config{}, &chunkedProvider{output: `track(selected=true).set_track(volume_db=3)`, chunkSize: 8})
+	state := clarifyState(-1)
+
+	// Off by default: the model guesses, here the selected track there isn't
+	_, err := agent.GenerateActions(context.Background(), "make it louder", state)
+	assert.ErrorContains(t, err, "no selected track found in state")
+
+	agent.SetClarification(true)
+	result, err := agent.GenerateActions(context.Background(), "make it louder", state)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actions)
+	require.NotNil(t, result.Clarification)
+
+	streamed, err := agent.GenerateActionsStream(context.Background(), "make it louder", state, func(map[string]any) error {
+		t.Fatal("no actions are streamed")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, result.Clarification, streamed.Clarification)
+
+	result, err = agent.Resume(context.Background(), result.Clarification, "2", state)
+	require.NoError(t, err)
+	assert.Nil(t, result.Clarification)
+	require.Len(t, result.Actions, 1)
+	assert.Equal(t, "set_track", result.Actions[0]["action"])
+	assert.Equal(t, 1, result.Actions[0]["track"])
+}
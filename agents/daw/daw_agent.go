@@ -2,6 +2,7 @@ package daw
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/Conceptual-Machines/magda-agents-go/config"
 	"github.com/Conceptual-Machines/magda-agents-go/llm"
 	"github.com/Conceptual-Machines/magda-agents-go/metrics"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
 	"github.com/Conceptual-Machines/magda-agents-go/prompt"
 	"github.com/getsentry/sentry-go"
 	"github.com/openai/openai-go/responses"
@@ -23,17 +25,29 @@ type DawAgent struct {
 	promptBuilder *prompt.MagdaPromptBuilder
 	metrics       *metrics.SentryMetrics
 	useDSL        bool // If true, use CFG/DSL mode; if false, use JSON Schema mode
+	maxRepairs    int  // Times rejected DSL is sent back to the model (0 = no repair)
+	sourceMap     bool // If true, actions carry the DSL statement that produced them
+	macros        *MacroRegistry
+	templates     *TemplateRegistry
+	clarify       bool // If true, ambiguous requests get a clarifying question instead of actions
 }
 
 func NewDawAgent(cfg *config.Config) *DawAgent {
+	return NewDawAgentWithProvider(cfg, nil)
+}
+
+// NewDawAgentWithProvider creates a DAW agent with a specific LLM provider
+func NewDawAgentWithProvider(cfg *config.Config, provider llm.Provider) *DawAgent {
 	promptBuilder := prompt.NewMagdaPromptBuilder()
 	systemPrompt, err := promptBuilder.BuildPrompt()
 	if err != nil {
 		log.Fatal("Failed to load MAGDA system prompt:", err)
 	}
 
-	// Use OpenAI provider (default for now)
-	provider := llm.NewOpenAIProvider(cfg.OpenAIAPIKey)
+	// Use provided provider or create OpenAI provider (default)
+	if provider == nil {
+		provider = llm.NewOpenAIProvider(cfg.OpenAIAPIKey)
+	}
 
 	// Always use DSL mode (CFG grammar) for better latency and structured output
 	useDSL := true
@@ -44,19 +58,72 @@ func NewDawAgent(cfg *config.Config) *DawAgent {
 		promptBuilder: promptBuilder,
 		metrics:       metrics.NewSentryMetrics(),
 		useDSL:        useDSL,
+		maxRepairs:    cfg.DSLRepairAttempts,
+		sourceMap:     cfg.DSLSourceMap,
+		macros:        DefaultMacroRegistry(),
+		templates:     DefaultTemplateRegistry(),
 	}
 
 	log.Printf("🤖 DAW AGENT INITIALIZED:")
 	log.Printf("   Provider: %s", provider.Name())
 	log.Printf("   System prompt loaded: %d chars", len(systemPrompt))
 	log.Printf("   Mode: DSL (CFG) - always enabled")
+	log.Printf("   DSL repair attempts: %d", agent.maxRepairs)
 
 	return agent
 }
 
+// SetMacroRegistry sets the user macros the agent can call. They are listed
+// in the tool description so the model can call them by name.
+func (a *DawAgent) SetMacroRegistry(registry *MacroRegistry) {
+	if registry == nil {
+		registry = DefaultMacroRegistry()
+	}
+	a.macros = registry
+}
+
+// macroInstructions describes the available user macros for the tool
+// description, or returns "" when there are none.
+func (a *DawAgent) macroInstructions() string {
+	macros := a.macros.Describe()
+	if macros == "" {
+		return ""
+	}
+	return "**USER MACROS**: The user has saved these macros. When the user asks for one by name (e.g. 'apply my vocal chain to track 4'), " +
+		"call it as its own statement with named arguments: @vocal_chain(target=4). Track arguments take a 1-based track id, a track name in quotes, or a variable. " +
+		"Apply a macro to several tracks with let group = filter(tracks, ...); for_each(group, @name). Available macros:\n" + macros + "\n"
+}
+
+// SetTemplateRegistry sets the track templates the agent can instantiate.
+// They are listed in the tool description so the model can use them by name.
+func (a *DawAgent) SetTemplateRegistry(registry *TemplateRegistry) {
+	if registry == nil {
+		registry = DefaultTemplateRegistry()
+	}
+	a.templates = registry
+}
+
+// templateInstructions describes the available track templates for the tool
+// description, or returns "" when there are none.
+func (a *DawAgent) templateInstructions() string {
+	templates := a.templates.Describe()
+	if templates == "" {
+		return ""
+	}
+	return "**TRACK TEMPLATES**: For a whole setup (e.g. 'set up a podcast', 'create a band setup'), instantiate a template as its own statement: template(name=\"rock_band\"). " +
+		"Change how many tracks of a role it creates with role=count, and leave a role out with role=0: 'a band setup but with two guitars and no keys' → template(name=\"rock_band\", guitar=2, keys=0). " +
+		"Sends to other tracks use .add_send(to=\"Track Name\", volume_db=-12). Available templates:\n" + templates + "\n"
+}
+
 type DawResult struct {
-	Actions []map[string]any `json:"actions"`
-	Usage   any              `json:"usage"`
+	Actions []map[string]any    `json:"actions"`
+	Usage   any                 `json:"usage"`
+	DSL     string              `json:"dsl,omitempty"`     // Accepted DSL code
+	Repairs []llm.RepairAttempt `json:"repairs,omitempty"` // Rejected DSL sent back for repair, oldest first
+
+	// Clarification is set, and there are no actions, when the clarifying-question
+	// mode is on and the request is ambiguous
+	Clarification *models.Clarification `json:"clarification,omitempty"`
 }
 
 // getCFGGrammarConfig returns the CFG grammar configuration for the DAW agent
@@ -67,17 +134,27 @@ func (a *DawAgent) getCFGGrammarConfig() *llm.CFGConfig {
 		Description: "**YOU MUST USE THIS TOOL TO GENERATE YOUR RESPONSE. DO NOT GENERATE TEXT OUTPUT DIRECTLY.** " +
 			"Executes REAPER operations using the MAGDA DSL. " +
 			"Generate functional script code like: track(instrument=\"Serum\").new_clip(bar=3, length_bars=4). " +
-			"Your job is to create tracks, clips, set track properties, and add automation. " +
+			"Your job is to create tracks, clips, set track properties, add automation, and run project-level commands. " +
 			"**IMPORTANT**: Musical content (notes, chords, arpeggios, progressions) is handled by the ARRANGER agent, NOT you. " +
 			"When user requests musical content like 'add E1 note', 'sustained note', 'chord', 'arpeggio', just create the track/clip structure - the arranger will add the notes. " +
-			"**AUTOMATION**: For automation, use curve functions: .addAutomation(param=\"...\", curve=\"...\", start=X, end=Y). " +
-			"Available curves: fade_in, fade_out, ramp, sine, saw, square, exp_in, exp_out. " +
+			"Editing notes that already exist in clips IS your job: .quantize(grid=\"1/16\"), .transpose(semitones=7), .set_velocity(velocity+=10), .humanize(), .legato(), .reverse() on a clip (track(id=1).quantize(clip=0)) or on filter(clips, ...) / filter(notes, note.pitch == 42). " +
+			"**AUTOMATION**: For automation, use curve functions: .add_automation(param=\"...\", curve=\"...\", start=X, end=Y). " +
+			"Available curves: " + strings.Join(AutomationCurveNames(), ", ") + " (aliases: ramp=linear, exp_in=exp, exp_out=log). " +
+			"Targets: param=\"volume\"|\"pan\"|\"mute\", param=\"send\" with send=<name or 1-based index>, or an FX parameter with fx=<name or 1-based index>, fx_param=<name or 1-based index>. " +
+			"freq is cycles per bar, or a tempo-synced note value like freq=\"1/8\" (one cycle per eighth note). " +
 			"- Fade in: curve=\"fade_in\", start=0, end=4 (beats) " +
 			"- Fade out: curve=\"fade_out\", start_bar=8, end_bar=12 " +
 			"- LFO/oscillator: curve=\"sine\", freq=0.5, amplitude=1.0 (freq = cycles per bar) " +
 			"- Linear sweep: curve=\"ramp\", from=0.2, to=1.0 " +
-			"- Example: track(id=1).addAutomation(param=\"volume\", curve=\"fade_in\", start=0, end=4) " +
-			"- Example LFO: track(id=1).addAutomation(param=\"pan\", curve=\"sine\", freq=0.5, amplitude=1.0, start=0, end=16) " +
+			"- Example: track(id=1).add_automation(param=\"volume\", curve=\"fade_in\", start=0, end=4) " +
+			"- Example LFO: track(id=1).add_automation(param=\"pan\", curve=\"sine\", freq=0.5, amplitude=1.0, start=0, end=16) " +
+			"- Example FX: track(id=1).add_automation(fx=\"ReaEQ\", fx_param=\"Gain\", curve=\"sine\", freq=\"1/8\", start=0, end=16) " +
+			"- Example sidechain pump: track(id=2).add_automation(param=\"volume\", curve=\"duck\", from=0, to=-12, start_bar=1, end_bar=9) " +
+			"**PROJECT AND TRANSPORT**: Project-level commands are statements of their own, never chained onto track(): " +
+			"render(range=\"project\"|\"loop\"|\"selection\", format=\"wav\"|\"flac\"|\"aiff\"|\"mp3\"|\"ogg\", stems=true, sample_rate=48000, bit_depth=24, file=\"Mix\") or render(start_bar=1, end_bar=17, ...) for a bar range; " +
+			"save_project() or save_project(path=\"Song v2\"); set_project(name=\"...\", sample_rate=48000, tempo=120); set_loop(start_bar=9, end_bar=17); set_cursor(bar=5); play(); stop(). " +
+			"- Example: 'export the chorus (bars 17-24) as mp3 stems' → render(start_bar=17, end_bar=25, format=\"mp3\", stems=true) " +
+			"- Example: 'loop bars 9 to 16 and play' → set_loop(start_bar=9, end_bar=17); set_cursor(bar=9); play() " +
 			"When user says 'create track with [instrument]' or 'track with [instrument]', ALWAYS generate track(instrument=\"[instrument]\") - never generate track() without the instrument parameter when an instrument is mentioned. " +
 			"**TRACK CREATION**: To create a new track, use track() or track(name=\"Track Name\") - DO NOT chain .set_track() after track() unless you explicitly need to set a property. For simple track creation, track() or track(name=\"...\") is sufficient. " +
 			"**MULTIPLE TRACK CREATION**: When user requests multiple tracks (e.g., 'create 5 tracks'), generate separate track() calls: track(); track(); track(); track(); track(). For named tracks: track(name=\"Track 1\"); track(name=\"Track 2\"); etc. Each track() call creates ONE track - do NOT chain .set_track() unless explicitly needed. " +
@@ -97,7 +174,12 @@ func (a *DawAgent) getCFGGrammarConfig() *llm.CFGConfig {
 			"- 'solo' means audio isolation and uses set_track(solo=true), but 'select' means visual highlighting and uses set_track(selected=true). " +
 			"For selection operations on multiple tracks, ALWAYS use: filter(tracks, track.name == \"X\").set_track(selected=true). " +
 			"This efficiently filters the collection and applies the action to all matching tracks. " +
-			"Use functional methods for collections when appropriate: filter(tracks, track.name == \"FX\"), map(@get_name, tracks), for_each(tracks, @add_reverb). " +
+			"Use functional methods for collections when appropriate: filter(tracks, track.name == \"FX\"), map(tracks, @get_name), for_each(tracks, @normalize_volume(target_db=-6)). " +
+			"Available functions for map/for_each: " + strings.Join(DefaultFunctionRegistry().Names(), ", ") + " (reference them as @name). " +
+			"**VARIABLES**: Bind tracks or collections with let and reuse them in later statements: let bass = track(name=\"Bass\"); bass.add_fx(fxname=\"ReaEQ\"); bass.new_clip(bar=1). " +
+			"let drums = filter(tracks, track.name == \"Drums\"); drums.set_track(mute=true). " +
+			a.macroInstructions() +
+			a.templateInstructions() +
 			"ALWAYS check the current REAPER state to see which tracks exist and use the correct track indices. " +
 			"If no track is specified in a chain, it applies to the track created by track(). " +
 			"YOU MUST REASON HEAVILY ABOUT THE OPERATIONS AND MAKE SURE THE CODE OBEYS THE GRAMMAR. " +
@@ -112,6 +194,9 @@ func (a *DawAgent) GenerateActions(
 ) (*DawResult, error) {
 	startTime := time.Now()
 	log.Printf("🤖 MAGDA REQUEST STARTED: question=%s", question)
+	if clarification := a.clarification(question, state); clarification != nil {
+		return &DawResult{Clarification: clarification}, nil
+	}
 
 	// Start Sentry transaction
 	transaction := sentry.StartTransaction(ctx, "magda.generate_actions")
@@ -141,28 +226,31 @@ func (a *DawAgent) GenerateActions(
 	// Call provider
 	log.Printf("🚀 MAGDA PROVIDER REQUEST: %s", a.provider.Name())
 
-	resp, err := a.provider.Generate(ctx, request)
-	if err != nil {
-		transaction.SetTag("success", "false")
-		transaction.SetTag("error_type", "provider_error")
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("provider request failed: %w", err)
+	// Generate and parse, sending rejected DSL back to the model when repair is enabled
+	repair, err := llm.GenerateWithRepair(ctx, a.provider, request, a.maxRepairs,
+		func(resp *llm.GenerationResponse) ([]map[string]any, error) {
+			return a.parseActionsFromResponse(resp, state)
+		})
+	if a.maxRepairs > 0 && repair != nil && len(repair.History) > 0 {
+		a.metrics.RecordDSLRepair(ctx, "daw", repair.Repairs, err == nil)
 	}
-
-	// Parse actions from response
-	// For MAGDA, we need to parse the raw JSON since the provider expects MusicalOutput format
-	// We'll need to get the raw response text and parse it into MagdaActionsOutput
-	actions, err := a.parseActionsFromResponse(resp, state)
 	if err != nil {
 		transaction.SetTag("success", "false")
-		transaction.SetTag("error_type", "parse_error")
 		sentry.CaptureException(err)
+		if errors.Is(err, llm.ErrProviderFailed) {
+			transaction.SetTag("error_type", "provider_error")
+			return nil, err
+		}
+		transaction.SetTag("error_type", "parse_error")
 		return nil, fmt.Errorf("failed to parse actions: %w", err)
 	}
+	actions := repair.Actions
 
 	result := &DawResult{
 		Actions: actions,
-		Usage:   resp.Usage,
+		Usage:   repair.Response.Usage,
+		DSL:     strings.TrimSpace(repair.DSL),
+		Repairs: repair.History,
 	}
 
 	// Mark transaction as successful
@@ -229,7 +317,7 @@ func (a *DawAgent) parseActionsFromResponse(resp *llm.GenerationResponse, state
 	if strings.HasPrefix(dslCode, "// ERROR:") {
 		errorMsg := strings.TrimPrefix(dslCode, "// ERROR:")
 		errorMsg = strings.TrimSpace(errorMsg)
-		return nil, fmt.Errorf("request is out of scope: %s", errorMsg)
+		return nil, llm.Permanent(fmt.Errorf("request is out of scope: %s", errorMsg))
 	}
 
 	// Check if it's DSL (starts with "track" or similar function call)
@@ -264,6 +352,9 @@ func (a *DawAgent) parseActionsFromResponse(resp *llm.GenerationResponse, state
 	}
 	// Pass state directly - SetState handles both {"state": {...}} and {...} formats
 	parser.SetState(state)
+	parser.SetSourceMap(a.sourceMap)
+	parser.SetMacroRegistry(a.macros)
+	parser.SetTemplateRegistry(a.templates)
 	actions, err := parser.ParseDSL(dslCode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse DSL: %w", err)
@@ -284,8 +375,13 @@ func truncate(s string, maxLen int) string {
 // StreamActionCallback is called for each action found in the stream
 type StreamActionCallback func(action map[string]any) error
 
-// GenerateActionsStream generates actions using streaming (without structured output)
-// It parses JSON incrementally from the text stream and calls callback for each action found
+// GenerateActionsStream generates actions using streaming (without structured output).
+// With a streaming provider, each DSL statement is executed as soon as it has
+// fully arrived and its actions are passed to callback while the model is
+// still writing the rest of the script. Other providers fall back to parsing
+// the complete output and calling callback for each action afterwards.
+// When a statement fails, the stream stops; actions already passed to
+// callback belong to the statements before it.
 func (a *DawAgent) GenerateActionsStream(
 	ctx context.Context,
 	question string,
@@ -294,13 +390,18 @@ func (a *DawAgent) GenerateActionsStream(
 ) (*DawResult, error) {
 	startTime := time.Now()
 	log.Printf("🤖 MAGDA STREAMING REQUEST STARTED: question=%s", question)
+	if clarification := a.clarification(question, state); clarification != nil {
+		return &DawResult{Clarification: clarification}, nil
+	}
 
 	// Start Sentry transaction
 	transaction := sentry.StartTransaction(ctx, "magda.generate_actions_stream")
 	defer transaction.Finish()
 
+	streamingProvider, canStream := a.provider.(llm.StreamingProvider)
+
 	transaction.SetTag("model", "gpt-5.1")
-	transaction.SetTag("streaming", "false")
+	transaction.SetTag("streaming", fmt.Sprintf("%v", canStream))
 	transaction.SetContext("magda", map[string]any{
 		"question_length": len(question),
 		"has_state":       state != nil,
@@ -321,6 +422,144 @@ func (a *DawAgent) GenerateActionsStream(
 	request.CFGGrammar = a.getCFGGrammarConfig()
 	log.Printf("🔧 Using DSL mode (CFG grammar) - always enabled")
 
+	var resp *llm.GenerationResponse
+	var allActions []map[string]any
+	var err error
+	if canStream {
+		resp, allActions, err = a.streamActions(ctx, streamingProvider, request, state, callback, transaction)
+	} else {
+		log.Printf("⚠️  Provider %s does not support streaming, parsing complete output", a.provider.Name())
+		resp, allActions, err = a.bufferActions(ctx, request, state, callback, transaction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(allActions) == 0 {
+		transaction.SetTag("success", "false")
+		transaction.SetTag("error_type", "no_actions")
+		return nil, fmt.Errorf("no actions found in DSL output")
+	}
+
+	result := &DawResult{
+		Actions: allActions,
+		Usage:   nil,
+	}
+
+	if resp != nil && resp.Usage != nil {
+		result.Usage = resp.Usage
+	}
+
+	transaction.SetTag("success", "true")
+	transaction.SetTag("actions_count", fmt.Sprintf("%d", len(allActions)))
+
+	duration := time.Since(startTime)
+	a.metrics.RecordGenerationDuration(ctx, duration, true)
+
+	log.Printf("✅ MAGDA STREAMING REQUEST COMPLETE: actions=%d, duration=%v", len(allActions), duration)
+
+	return result, nil
+}
+
+// streamActions streams the model output into a DSLStream, so the actions of
+// each statement reach callback as soon as the statement is complete.
+func (a *DawAgent) streamActions(
+	ctx context.Context,
+	provider llm.StreamingProvider,
+	request *llm.GenerationRequest,
+	state map[string]any,
+	callback StreamActionCallback,
+	transaction *sentry.Span,
+) (*llm.GenerationResponse, []map[string]any, error) {
+	parser, err := NewFunctionalDSLParser()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create functional DSL parser: %w", err)
+	}
+	// Pass state directly - SetState handles both {"state": {...}} and {...} formats
+	parser.SetState(state)
+	parser.SetSourceMap(a.sourceMap)
+	parser.SetMacroRegistry(a.macros)
+	parser.SetTemplateRegistry(a.templates)
+	stream := parser.Stream(ctx, callback)
+
+	var text strings.Builder
+	fed := 0 // Bytes of text written to the stream
+	outOfScope := false
+
+	// feed writes newly received text to the stream. The first characters are
+	// held back until it is clear the output is not a "// ERROR:" comment,
+	// which is handled once the whole message has arrived.
+	feed := func(final bool) error {
+		if outOfScope {
+			return nil
+		}
+		received := text.String()
+		if fed == 0 {
+			trimmed := strings.TrimSpace(received)
+			if strings.HasPrefix(trimmed, "//") {
+				outOfScope = true
+				return nil
+			}
+			if len(trimmed) < len("//") && !final {
+				return nil
+			}
+		}
+		chunk := received[fed:]
+		fed = len(received)
+		return stream.Write(chunk)
+	}
+
+	log.Printf("🚀 MAGDA PROVIDER STREAM REQUEST: %s", a.provider.Name())
+	resp, err := llm.StreamDSL(ctx, provider, request, func(delta string) error {
+		text.WriteString(delta)
+		return feed(false)
+	})
+	if err == nil && text.Len() == 0 {
+		transaction.SetTag("success", "false")
+		transaction.SetTag("error_type", "no_output")
+		return nil, nil, fmt.Errorf("no DSL output from provider")
+	}
+	if err == nil {
+		err = feed(true)
+	}
+	if errors.Is(err, llm.ErrProviderFailed) {
+		transaction.SetTag("success", "false")
+		transaction.SetTag("error_type", "provider_error")
+		sentry.CaptureException(err)
+		return nil, nil, err
+	}
+
+	var actions []map[string]any
+	if err == nil && outOfScope {
+		actions, err = a.parseActionsIncremental(text.String(), state)
+		if err == nil {
+			for _, action := range actions {
+				_ = callback(action)
+			}
+		}
+	} else if err == nil {
+		actions, err = stream.Close()
+	}
+	if err != nil {
+		transaction.SetTag("success", "false")
+		transaction.SetTag("error_type", "parse_error")
+		sentry.CaptureException(err)
+		return nil, nil, fmt.Errorf("failed to parse DSL: %w", err)
+	}
+
+	log.Printf("✅ Streamed %d REAPER API actions from %d chars of DSL", len(actions), text.Len())
+	return resp, actions, nil
+}
+
+// bufferActions generates the complete output with the non-streaming provider,
+// parses it, and then calls callback for each action.
+func (a *DawAgent) bufferActions(
+	ctx context.Context,
+	request *llm.GenerationRequest,
+	state map[string]any,
+	callback StreamActionCallback,
+	transaction *sentry.Span,
+) (*llm.GenerationResponse, []map[string]any, error) {
 	// Call non-streaming provider
 	log.Printf("🚀 MAGDA PROVIDER REQUEST: %s", a.provider.Name())
 	resp, err := a.provider.Generate(ctx, request)
@@ -329,14 +568,14 @@ func (a *DawAgent) GenerateActionsStream(
 		transaction.SetTag("success", "false")
 		transaction.SetTag("error_type", "provider_error")
 		sentry.CaptureException(err)
-		return nil, fmt.Errorf("provider failed: %w", err)
+		return nil, nil, fmt.Errorf("provider failed: %w", err)
 	}
 
 	// Extract DSL code from response
 	if resp == nil || resp.RawOutput == "" {
 		transaction.SetTag("success", "false")
 		transaction.SetTag("error_type", "no_output")
-		return nil, fmt.Errorf("no DSL output from provider")
+		return nil, nil, fmt.Errorf("no DSL output from provider")
 	}
 
 	// Parse DSL code into actions
@@ -345,7 +584,7 @@ func (a *DawAgent) GenerateActionsStream(
 		transaction.SetTag("success", "false")
 		transaction.SetTag("error_type", "parse_error")
 		sentry.CaptureException(err)
-		return nil, fmt.Errorf("failed to parse DSL: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse DSL: %w", err)
 	}
 
 	// Call callback for each action
@@ -353,30 +592,7 @@ func (a *DawAgent) GenerateActionsStream(
 		_ = callback(action)
 	}
 
-	if len(allActions) == 0 {
-		transaction.SetTag("success", "false")
-		transaction.SetTag("error_type", "no_actions")
-		return nil, fmt.Errorf("no actions found in DSL output")
-	}
-
-	result := &DawResult{
-		Actions: allActions,
-		Usage:   nil,
-	}
-
-	if resp != nil && resp.Usage != nil {
-		result.Usage = resp.Usage
-	}
-
-	transaction.SetTag("success", "true")
-	transaction.SetTag("actions_count", fmt.Sprintf("%d", len(allActions)))
-
-	duration := time.Since(startTime)
-	a.metrics.RecordGenerationDuration(ctx, duration, true)
-
-	log.Printf("✅ MAGDA STREAMING REQUEST COMPLETE: actions=%d, duration=%v", len(allActions), duration)
-
-	return result, nil
+	return resp, allActions, nil
 }
 
 // parseActionsIncremental tries to parse actions from accumulated text (DSL or JSON)
@@ -421,7 +637,7 @@ func (a *DawAgent) parseActionsIncremental(text string, state map[string]any) ([
 	if strings.HasPrefix(text, "// ERROR:") {
 		errorMsg := strings.TrimPrefix(text, "// ERROR:")
 		errorMsg = strings.TrimSpace(errorMsg)
-		return nil, fmt.Errorf("request is out of scope: %s", errorMsg)
+		return nil, llm.Permanent(fmt.Errorf("request is out of scope: %s", errorMsg))
 	}
 
 	if !isDSL {
@@ -440,6 +656,9 @@ func (a *DawAgent) parseActionsIncremental(text string, state map[string]any) ([
 	}
 	// Pass state directly - SetState handles both {"state": {...}} and {...} formats
 	parser.SetState(state)
+	parser.SetSourceMap(a.sourceMap)
+	parser.SetMacroRegistry(a.macros)
+	parser.SetTemplateRegistry(a.templates)
 	actions, err := parser.ParseDSL(text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse DSL: %w", err)
@@ -0,0 +1,596 @@
+package daw
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// Automation rendering limits.
+const (
+	DefaultCurveResolution = 4    // Points per beat for shaped curves
+	minPointsPerCycle      = 16   // Points per oscillator cycle
+	maxAutomationPoints    = 4096 // Upper bound on rendered points per envelope
+	stepEpsilon            = 1e-3 // Beats between the two points of a vertical edge
+)
+
+// shapeSquare is REAPER's square envelope point shape, used for stepped curves.
+const shapeSquare = 1
+
+// automationRange is the value range of an automation target, used for curve
+// defaults: fades go from Silence to Unity, other curves span Min to Max.
+type automationRange struct {
+	Min, Max       float64
+	Silence, Unity float64
+}
+
+var automationRanges = map[string]automationRange{
+	"volume": {Min: -60, Max: 0, Silence: -60, Unity: 0},
+	"send":   {Min: -60, Max: 0, Silence: -60, Unity: 0},
+	"pan":    {Min: -1, Max: 1, Silence: 0, Unity: 0},
+	"mute":   {Min: 0, Max: 1, Silence: 1, Unity: 0},
+}
+
+// normalizedRange is used for FX parameters, which REAPER exposes as 0-1.
+var normalizedRange = automationRange{Min: 0, Max: 1, Silence: 0, Unity: 1}
+
+// curveSpec holds the resolved parameters of a curve-based automation call.
+// Times are in beats from the project start.
+type curveSpec struct {
+	Start, End float64
+	From, To   float64
+	CycleBeats float64 // Length of one oscillator cycle, step or duck
+	Amplitude  float64
+	Phase      float64
+	Resolution float64
+	Range      automationRange
+	Args       gs.Args
+}
+
+// AutomationCurve is an entry in the automation curve library.
+type AutomationCurve struct {
+	Name        string
+	Description string
+	Params      []string // Curve-specific parameters besides start/end and from/to
+	Stepped     bool     // Rendered with square point shapes
+	render      func(spec curveSpec) ([]map[string]any, error)
+}
+
+// automationCurves is the curve library. Aliases point at the same curve.
+var automationCurves = map[string]*AutomationCurve{}
+
+func init() {
+	curves := []*AutomationCurve{
+		{Name: "linear", Description: "Straight line from `from` to `to`", render: renderLinear},
+		{Name: "exp", Description: "Exponential: slow start, fast finish", render: shapedCurve(easeExp)},
+		{Name: "log", Description: "Logarithmic: fast start, slow finish", render: shapedCurve(easeLog)},
+		{Name: "s_curve", Description: "Smooth S-shaped transition", render: shapedCurve(easeSmooth)},
+		{Name: "fade_in", Description: "Silence to unity (volume: -60 dB to 0 dB)", render: renderFade(true)},
+		{Name: "fade_out", Description: "Unity to silence (volume: 0 dB to -60 dB)", render: renderFade(false)},
+		{Name: "sine", Description: "Sine oscillation between `from` and `to`", Params: []string{"freq", "amplitude", "phase"}, render: oscillator(waveSine)},
+		{Name: "triangle", Description: "Triangle oscillation between `from` and `to`", Params: []string{"freq", "amplitude", "phase"}, render: oscillator(waveTriangle)},
+		{Name: "square", Description: "Square wave alternating between `from` and `to`", Params: []string{"freq", "amplitude", "phase"}, Stepped: true, render: renderSquare},
+		{Name: "saw", Description: "Rising sawtooth from `from` to `to` each cycle", Params: []string{"freq", "amplitude", "phase"}, render: renderSaw},
+		{Name: "random", Description: "Sample-and-hold: a random value each step", Params: []string{"freq", "amplitude", "seed"}, Stepped: true, render: renderRandom},
+		{Name: "adsr", Description: "Attack/decay/sustain/release envelope from `from` to `to`", Params: []string{"attack", "decay", "sustain", "release"}, render: renderADSR},
+		{Name: "duck", Description: "Sidechain-style duck to `to` on every step, recovering to `from`", Params: []string{"freq", "release"}, render: renderDuck},
+	}
+	for _, curve := range curves {
+		automationCurves[curve.Name] = curve
+	}
+
+	aliases := map[string]string{
+		"ramp":            "linear",
+		"exp_in":          "exp",
+		"exp_out":         "log",
+		"scurve":          "s_curve",
+		"sample_and_hold": "random",
+		"sidechain":       "duck",
+		"sidechain_duck":  "duck",
+	}
+	for alias, name := range aliases {
+		automationCurves[alias] = automationCurves[name]
+	}
+}
+
+// AutomationCurveNames returns the names of the curves in the library
+// (without aliases), sorted.
+func AutomationCurveNames() []string {
+	var names []string
+	for name, curve := range automationCurves {
+		if curve.Name == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupAutomationCurve returns a curve by name or alias.
+func LookupAutomationCurve(name string) (*AutomationCurve, bool) {
+	curve, ok := automationCurves[strings.ToLower(strings.TrimSpace(name))]
+	return curve, ok
+}
+
+// automationTarget resolves what an automation call addresses: track volume,
+// pan, mute or send level (param=...), or an FX parameter (fx=..., fx_param=...).
+// FX, parameters and sends can be given by name or 1-based index; names are
+// resolved to indexes when the track's FX chain or sends are in state.
+// Returns the target fields for the action and the value range of the target.
+func (p *FunctionalDSLParser) automationTarget(trackIndex int, args gs.Args) (map[string]any, automationRange, error) {
+	fields := make(map[string]any)
+	track := p.trackItem(trackIndex)
+
+	if fxValue, ok := args["fx"]; ok {
+		fields["param"] = "fx"
+		fx, err := resolveIndexed(fxValue, "fx", track["fx"], fields, "fx_index", "fx_name")
+		if err != nil {
+			return nil, automationRange{}, err
+		}
+
+		fxParamValue, ok := args["fx_param"]
+		if !ok {
+			return nil, automationRange{}, fmt.Errorf("automation on fx requires fx_param (name or 1-based index)")
+		}
+		if _, err := resolveIndexed(fxParamValue, "fx_param", fx["params"], fields, "param_index", "param_name"); err != nil {
+			return nil, automationRange{}, err
+		}
+		return fields, normalizedRange, nil
+	}
+
+	paramValue, ok := args["param"]
+	if !ok || paramValue.Kind != gs.ValueString {
+		return nil, automationRange{}, fmt.Errorf("add_automation requires param (string) or fx and fx_param")
+	}
+	param := strings.ToLower(strings.TrimSpace(paramValue.Str))
+
+	switch param {
+	case "volume", "pan", "mute":
+		fields["param"] = param
+		return fields, automationRanges[param], nil
+	case "send":
+		fields["param"] = param
+		sendValue, ok := args["send"]
+		if !ok {
+			return nil, automationRange{}, fmt.Errorf("send automation requires send (name or 1-based index)")
+		}
+		if _, err := resolveIndexed(sendValue, "send", track["sends"], fields, "send_index", "send_name"); err != nil {
+			return nil, automationRange{}, err
+		}
+		return fields, automationRanges[param], nil
+	}
+
+	// Legacy "FXName:ParamName" addressing is passed through unchanged
+	if strings.Contains(paramValue.Str, ":") {
+		fields["param"] = paramValue.Str
+		return fields, normalizedRange, nil
+	}
+
+	return nil, automationRange{}, fmt.Errorf("unknown automation param %q (use volume, pan, mute, send, or fx=..., fx_param=...)", paramValue.Str)
+}
+
+// resolveIndexed resolves a name or 1-based index against an optional list
+// from state (FX chain, FX params, sends) and writes indexKey/nameKey into
+// fields. Returns the matched entry when it is a map, for nested lookups.
+func resolveIndexed(value gs.Value, what string, list any, fields map[string]any, indexKey, nameKey string) (map[string]any, error) {
+	entries, _ := list.([]any)
+
+	switch value.Kind {
+	case gs.ValueNumber:
+		index := int(value.Num) - 1
+		if index < 0 || float64(index+1) != value.Num {
+			return nil, fmt.Errorf("%s index must be a positive integer (1-based), got %g", what, value.Num)
+		}
+		if entries != nil && index >= len(entries) {
+			return nil, fmt.Errorf("%s %d not found (track has %d)", what, index+1, len(entries))
+		}
+		fields[indexKey] = index
+		if entries != nil {
+			entry, _ := entries[index].(map[string]any)
+			if name := entryName(entries[index]); name != "" {
+				fields[nameKey] = name
+			}
+			return entry, nil
+		}
+		return nil, nil
+
+	case gs.ValueString:
+		name := strings.TrimSpace(value.Str)
+		fields[nameKey] = name
+		if entries == nil {
+			return nil, nil
+		}
+
+		// Prefer an exact (case-insensitive) match, then a partial one so
+		// "ReaEQ" finds "VST: ReaEQ (Cockos)"
+		match := -1
+		for i, entry := range entries {
+			if strings.EqualFold(entryName(entry), name) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			for i, entry := range entries {
+				if strings.Contains(strings.ToLower(entryName(entry)), strings.ToLower(name)) {
+					match = i
+					break
+				}
+			}
+		}
+		if match < 0 {
+			return nil, fmt.Errorf("%s %q not found", what, name)
+		}
+
+		entry, _ := entries[match].(map[string]any)
+		index := match
+		if entryIndex, ok := getNumericValue(entry["index"]); ok {
+			index = int(entryIndex)
+		}
+		fields[indexKey] = index
+		fields[nameKey] = entryName(entries[match])
+		return entry, nil
+
+	default:
+		return nil, fmt.Errorf("%s must be a name or 1-based index", what)
+	}
+}
+
+// entryName returns the name of a state list entry, which is either a string
+// or a map with a name (or dest_name for sends).
+func entryName(entry any) string {
+	switch e := entry.(type) {
+	case string:
+		return e
+	case map[string]any:
+		for _, key := range []string{"name", "dest_name"} {
+			if name, ok := e[key].(string); ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// curveSpecFromArgs resolves timing, range and rate parameters of a curve call.
+func (p *FunctionalDSLParser) curveSpecFromArgs(curve *AutomationCurve, args gs.Args, valueRange automationRange) (curveSpec, error) {
+	_, beatsPerBar := p.projectTiming()
+	spec := curveSpec{
+		From:       valueRange.Min,
+		To:         valueRange.Max,
+		Amplitude:  1,
+		Resolution: DefaultCurveResolution,
+		Range:      valueRange,
+		Args:       args,
+	}
+
+	number := func(name string) (float64, bool) {
+		if value, ok := args[name]; ok && value.Kind == gs.ValueNumber {
+			return value.Num, true
+		}
+		return 0, false
+	}
+
+	// Timing: start/end in beats, or start_bar/end_bar (1-based bars)
+	hasStart, hasEnd := false, false
+	if value, ok := number("start"); ok {
+		spec.Start, hasStart = value, true
+	}
+	if value, ok := number("start_bar"); ok {
+		spec.Start, hasStart = (value-1)*beatsPerBar, true
+	}
+	if value, ok := number("end"); ok {
+		spec.End, hasEnd = value, true
+	}
+	if value, ok := number("end_bar"); ok {
+		spec.End, hasEnd = (value-1)*beatsPerBar, true
+	}
+	if !hasStart {
+		spec.Start = 0
+	}
+	if !hasEnd {
+		return spec, fmt.Errorf("curve %s requires end (beats) or end_bar", curve.Name)
+	}
+	if spec.End <= spec.Start {
+		return spec, fmt.Errorf("curve end (%g beats) must be after start (%g beats)", spec.End, spec.Start)
+	}
+
+	if value, ok := number("from"); ok {
+		spec.From = value
+	}
+	if value, ok := number("to"); ok {
+		spec.To = value
+	}
+	if value, ok := number("amplitude"); ok {
+		if value < 0 || value > 1 {
+			return spec, fmt.Errorf("amplitude must be between 0 and 1 (got %g)", value)
+		}
+		spec.Amplitude = value
+	}
+	if value, ok := number("phase"); ok {
+		spec.Phase = value
+	}
+	if value, ok := number("resolution"); ok {
+		if value <= 0 {
+			return spec, fmt.Errorf("resolution must be positive (got %g)", value)
+		}
+		spec.Resolution = value
+	}
+
+	// Rate: freq is cycles per bar, or a tempo-synced note value ("1/8")
+	// giving the length of one cycle. Steps and ducks default to one per
+	// beat, oscillators to one cycle per bar.
+	spec.CycleBeats = beatsPerBar
+	if curve.Name == "random" || curve.Name == "duck" {
+		spec.CycleBeats = 1
+	}
+	if freqValue, ok := args["freq"]; ok {
+		cycleBeats, err := parseRate(freqValue, beatsPerBar)
+		if err != nil {
+			return spec, err
+		}
+		spec.CycleBeats = cycleBeats
+	}
+
+	return spec, nil
+}
+
+// parseRate converts a freq argument to the length of one cycle in beats.
+func parseRate(value gs.Value, beatsPerBar float64) (float64, error) {
+	if value.Kind == gs.ValueNumber {
+		if value.Num <= 0 {
+			return 0, fmt.Errorf("freq must be positive (got %g)", value.Num)
+		}
+		return beatsPerBar / value.Num, nil
+	}
+	cycleBeats, err := parseGrid(value)
+	if err != nil {
+		return 0, fmt.Errorf("freq: %w", err)
+	}
+	return cycleBeats, nil
+}
+
+// renderCurve renders a curve to explicit automation points.
+func renderCurve(curve *AutomationCurve, spec curveSpec) ([]map[string]any, error) {
+	points, err := curve.render(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) > maxAutomationPoints {
+		return nil, fmt.Errorf("curve %s renders %d points (max %d); lower freq or resolution", curve.Name, len(points), maxAutomationPoints)
+	}
+	return points, nil
+}
+
+func point(time, value float64) map[string]any {
+	return map[string]any{"time": time, "value": value}
+}
+
+// samples returns evenly spaced sample times covering [start, end] with at
+// least n segments.
+func samples(start, end float64, n int) []float64 {
+	if n < 1 {
+		n = 1
+	}
+	times := make([]float64, n+1)
+	for i := range times {
+		times[i] = start + (end-start)*float64(i)/float64(n)
+	}
+	return times
+}
+
+func renderLinear(spec curveSpec) ([]map[string]any, error) {
+	return []map[string]any{point(spec.Start, spec.From), point(spec.End, spec.To)}, nil
+}
+
+// Easing functions map progress in [0, 1] to [0, 1].
+func easeExp(x float64) float64 {
+	const k = 5.0
+	return (math.Exp(k*x) - 1) / (math.Exp(k) - 1)
+}
+
+func easeLog(x float64) float64 {
+	return 1 - easeExp(1-x)
+}
+
+func easeSmooth(x float64) float64 {
+	return x * x * (3 - 2*x)
+}
+
+// shapedCurve renders a transition from `from` to `to` following an easing function.
+func shapedCurve(ease func(float64) float64) func(spec curveSpec) ([]map[string]any, error) {
+	return func(spec curveSpec) ([]map[string]any, error) {
+		n := int(math.Ceil((spec.End - spec.Start) * spec.Resolution))
+		var points []map[string]any
+		for _, t := range samples(spec.Start, spec.End, n) {
+			x := (t - spec.Start) / (spec.End - spec.Start)
+			points = append(points, point(t, spec.From+(spec.To-spec.From)*ease(x)))
+		}
+		return points, nil
+	}
+}
+
+// renderFade renders fade_in/fade_out. Without explicit from/to the fade
+// runs between the target's silence and unity values.
+func renderFade(in bool) func(spec curveSpec) ([]map[string]any, error) {
+	return func(spec curveSpec) ([]map[string]any, error) {
+		from, to := spec.Range.Silence, spec.Range.Unity
+		if !in {
+			from, to = to, from
+		}
+		if value, ok := spec.Args["from"]; ok && value.Kind == gs.ValueNumber {
+			from = value.Num
+		}
+		if value, ok := spec.Args["to"]; ok && value.Kind == gs.ValueNumber {
+			to = value.Num
+		}
+		return []map[string]any{point(spec.Start, from), point(spec.End, to)}, nil
+	}
+}
+
+// oscillatorValue maps a wave value in [-1, 1] into the curve range.
+func oscillatorValue(spec curveSpec, wave float64) float64 {
+	mid := (spec.From + spec.To) / 2
+	return mid + wave*spec.Amplitude*(spec.To-spec.From)/2
+}
+
+func waveSine(cycle float64) float64 {
+	return math.Sin(2 * math.Pi * cycle)
+}
+
+func waveTriangle(cycle float64) float64 {
+	frac := cycle - math.Floor(cycle)
+	return 1 - 4*math.Abs(frac-0.5)
+}
+
+// oscillator renders a continuous periodic wave.
+func oscillator(wave func(cycle float64) float64) func(spec curveSpec) ([]map[string]any, error) {
+	return func(spec curveSpec) ([]map[string]any, error) {
+		cycles := (spec.End - spec.Start) / spec.CycleBeats
+		n := int(math.Ceil(math.Max(cycles*minPointsPerCycle, (spec.End-spec.Start)*spec.Resolution)))
+		var points []map[string]any
+		for _, t := range samples(spec.Start, spec.End, n) {
+			cycle := (t-spec.Start)/spec.CycleBeats + spec.Phase
+			points = append(points, point(t, oscillatorValue(spec, wave(cycle))))
+		}
+		return points, nil
+	}
+}
+
+// steps returns the times at which a stepped curve changes value: every
+// stepBeats from start, offset by phase (in cycles).
+func steps(spec curveSpec, stepBeats float64) []float64 {
+	times := []float64{spec.Start}
+	offset := math.Mod(spec.Phase*spec.CycleBeats, stepBeats)
+	if offset < 0 {
+		offset += stepBeats
+	}
+	first := spec.Start + stepBeats - offset
+	if offset == 0 {
+		first = spec.Start + stepBeats
+	}
+	for t := first; t < spec.End-stepEpsilon; t += stepBeats {
+		times = append(times, t)
+	}
+	return times
+}
+
+func renderSquare(spec curveSpec) ([]map[string]any, error) {
+	var points []map[string]any
+	for _, t := range steps(spec, spec.CycleBeats/2) {
+		cycle := (t-spec.Start)/spec.CycleBeats + spec.Phase + stepEpsilon
+		wave := 1.0
+		if cycle-math.Floor(cycle) >= 0.5 {
+			wave = -1
+		}
+		points = append(points, point(t, oscillatorValue(spec, wave)))
+	}
+	last := points[len(points)-1]["value"]
+	return append(points, map[string]any{"time": spec.End, "value": last}), nil
+}
+
+func renderSaw(spec curveSpec) ([]map[string]any, error) {
+	var points []map[string]any
+	wave := func(t float64) float64 {
+		cycle := (t-spec.Start)/spec.CycleBeats + spec.Phase
+		return 2*(cycle-math.Floor(cycle)) - 1
+	}
+	times := steps(spec, spec.CycleBeats)
+	for i, t := range times {
+		points = append(points, point(t, oscillatorValue(spec, wave(t+stepEpsilon/2))))
+		next := spec.End
+		if i+1 < len(times) {
+			next = times[i+1] - stepEpsilon
+		}
+		points = append(points, point(next, oscillatorValue(spec, wave(next-stepEpsilon/2))))
+	}
+	return points, nil
+}
+
+func renderRandom(spec curveSpec) ([]map[string]any, error) {
+	seed := int64(1)
+	if value, ok := spec.Args["seed"]; ok && value.Kind == gs.ValueNumber {
+		seed = int64(value.Num)
+	}
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec // Deterministic sequence, not security sensitive
+
+	var points []map[string]any
+	for _, t := range steps(spec, spec.CycleBeats) {
+		points = append(points, point(t, oscillatorValue(spec, 2*rng.Float64()-1)))
+	}
+	last := points[len(points)-1]["value"]
+	return append(points, map[string]any{"time": spec.End, "value": last}), nil
+}
+
+func renderADSR(spec curveSpec) ([]map[string]any, error) {
+	length := spec.End - spec.Start
+	param := func(name string, defaultValue float64) float64 {
+		if value, ok := spec.Args[name]; ok && value.Kind == gs.ValueNumber {
+			return value.Num
+		}
+		return defaultValue
+	}
+	attack := param("attack", length/8)
+	decay := param("decay", length/8)
+	sustain := param("sustain", 0.7)
+	release := param("release", length/4)
+
+	if attack < 0 || decay < 0 || release < 0 {
+		return nil, fmt.Errorf("adsr attack, decay and release must not be negative")
+	}
+	if sustain < 0 || sustain > 1 {
+		return nil, fmt.Errorf("adsr sustain must be between 0 and 1 (got %g)", sustain)
+	}
+	if attack+decay+release > length {
+		return nil, fmt.Errorf("adsr attack+decay+release (%g beats) exceeds the envelope length (%g beats)", attack+decay+release, length)
+	}
+
+	sustainLevel := spec.From + (spec.To-spec.From)*sustain
+	return []map[string]any{
+		point(spec.Start, spec.From),
+		point(spec.Start+attack, spec.To),
+		point(spec.Start+attack+decay, sustainLevel),
+		point(spec.End-release, sustainLevel),
+		point(spec.End, spec.From),
+	}, nil
+}
+
+// renderDuck renders a sidechain-style duck: on every step the value drops
+// to `to` and recovers to `from` over release (fraction of a step).
+// Without explicit from/to it ducks from unity by half the range.
+func renderDuck(spec curveSpec) ([]map[string]any, error) {
+	release := 0.5
+	if value, ok := spec.Args["release"]; ok && value.Kind == gs.ValueNumber {
+		release = value.Num
+	}
+	if release <= 0 || release >= 1 {
+		return nil, fmt.Errorf("duck release must be between 0 and 1 of a step (got %g)", release)
+	}
+
+	rest, ducked := spec.To, spec.From+(spec.To-spec.From)/2
+	if value, ok := spec.Args["from"]; ok && value.Kind == gs.ValueNumber {
+		rest = value.Num
+	}
+	if value, ok := spec.Args["to"]; ok && value.Kind == gs.ValueNumber {
+		ducked = value.Num
+	}
+
+	var points []map[string]any
+	times := steps(spec, spec.CycleBeats)
+	for i, t := range times {
+		next := spec.End
+		if i+1 < len(times) {
+			next = times[i+1]
+		}
+		recovered := math.Min(t+release*spec.CycleBeats, next)
+		points = append(points, point(t, ducked), point(recovered, rest))
+		if next-stepEpsilon > recovered {
+			points = append(points, point(next-stepEpsilon, rest))
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,201 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func automationTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"project": map[string]any{"bpm": 120.0},
+			"tracks": []any{
+				map[string]any{
+					"index": 0, "name": "Synth",
+					"fx": []any{
+						map[string]any{"name": "VST: ReaEQ (Cockos)", "params": []any{"Frequency", "Gain", "Bandwidth"}},
+						map[string]any{"name": "VSTi: Serum (Xfer Records)", "params": []any{
+							map[string]any{"name": "Filter Cutoff", "index": 12},
+						}},
+					},
+					"sends": []any{
+						map[string]any{"dest_name": "Reverb Bus"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func parseAutomation(t *testing.T, dslCode string) map[string]any {
+	t.Helper()
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(automationTestState())
+
+	actions, err := parser.ParseDSL(dslCode)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	return actions[0]
+}
+
+func TestAddAutomation_Targets(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		want    map[string]any
+	}{
+		{
+			name:    "fx and param by name",
+			dslCode: `track(id=1).add_automation(fx="ReaEQ", fx_param="gain", curve="linear", start=0, end=4)`,
+			want:    map[string]any{"param": "fx", "fx_index": 0, "fx_name": "VST: ReaEQ (Cockos)", "param_index": 1, "param_name": "Gain"},
+		},
+		{
+			name:    "fx by index, param with explicit index in state",
+			dslCode: `track(id=1).add_automation(fx=2, fx_param="cutoff", curve="linear", start=0, end=4)`,
+			want:    map[string]any{"param": "fx", "fx_index": 1, "fx_name": "VSTi: Serum (Xfer Records)", "param_index": 12, "param_name": "Filter Cutoff"},
+		},
+		{
+			name:    "send level by name",
+			dslCode: `track(id=1).add_automation(param="send", send="reverb", curve="fade_in", start=0, end=4)`,
+			want:    map[string]any{"param": "send", "send_index": 0, "send_name": "Reverb Bus"},
+		},
+		{
+			name:    "legacy fx param string",
+			dslCode: `track(id=1).add_automation(param="Serum:Cutoff", curve="ramp", start=0, end=4)`,
+			want:    map[string]any{"param": "Serum:Cutoff"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := parseAutomation(t, tt.dslCode)
+			for key, want := range tt.want {
+				assert.Equal(t, want, action[key], key)
+			}
+		})
+	}
+}
+
+func TestAddAutomation_TargetErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{"unknown fx", `track(id=1).add_automation(fx="Valhalla", fx_param=1, curve="linear", start=0, end=4)`, `fx "Valhalla" not found`},
+		{"missing fx_param", `track(id=1).add_automation(fx=1, curve="linear", start=0, end=4)`, "requires fx_param"},
+		{"unknown param", `track(id=1).add_automation(param="tempo", curve="linear", start=0, end=4)`, "unknown automation param"},
+		{"unknown curve", `track(id=1).add_automation(param="volume", curve="wobble", start=0, end=4)`, "unknown curve"},
+		{"missing end", `track(id=1).add_automation(param="volume", curve="sine", start=0)`, "requires end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(automationTestState())
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestAddAutomation_TempoSyncedFreq(t *testing.T) {
+	action := parseAutomation(t, `track(id=1).add_automation(fx=1, fx_param=1, curve="sine", freq="1/8", start=0, end=4)`)
+
+	// An eighth-note cycle in 4/4 is 8 cycles per bar
+	assert.InDelta(t, 8.0, action["freq"], 1e-9)
+
+	points := action["points"].([]map[string]any)
+	// 8 cycles over 4 beats at 16 points per cycle
+	assert.Len(t, points, 8*minPointsPerCycle+1)
+	assert.InDelta(t, 0.5, points[0]["value"], 1e-9)
+	assert.InDelta(t, 1.0, points[4]["value"], 1e-9) // quarter of the first cycle
+}
+
+func TestRenderAutomationCurves(t *testing.T) {
+	volume := automationRanges["volume"]
+	spec := func(curve string, args gs.Args) curveSpec {
+		parser, err := NewFunctionalDSLParser()
+		require.NoError(t, err)
+		c, ok := LookupAutomationCurve(curve)
+		require.True(t, ok)
+		s, err := parser.curveSpecFromArgs(c, args, volume)
+		require.NoError(t, err)
+		return s
+	}
+	num := func(v float64) gs.Value { return gs.Value{Kind: gs.ValueNumber, Num: v} }
+	render := func(curve string, args gs.Args) []map[string]any {
+		c, _ := LookupAutomationCurve(curve)
+		points, err := renderCurve(c, spec(curve, args))
+		require.NoError(t, err)
+		return points
+	}
+
+	t.Run("fade_in uses silence and unity", func(t *testing.T) {
+		points := render("fade_in", gs.Args{"start": num(0), "end": num(4)})
+		assert.Equal(t, []map[string]any{point(0, -60), point(4, 0)}, points)
+	})
+
+	t.Run("fade_out with bars", func(t *testing.T) {
+		points := render("fade_out", gs.Args{"start_bar": num(2), "end_bar": num(3)})
+		assert.Equal(t, []map[string]any{point(4, 0), point(8, -60)}, points)
+	})
+
+	t.Run("exp and log are mirrored", func(t *testing.T) {
+		args := gs.Args{"start": num(0), "end": num(1), "from": num(0), "to": num(1)}
+		exp := render("exp", args)
+		log := render("log", args)
+		require.Len(t, exp, DefaultCurveResolution+1)
+		assert.Less(t, exp[2]["value"].(float64), 0.5)
+		assert.Greater(t, log[2]["value"].(float64), 0.5)
+		assert.InDelta(t, 1.0, exp[len(exp)-1]["value"], 1e-9)
+	})
+
+	t.Run("square alternates", func(t *testing.T) {
+		points := render("square", gs.Args{"start": num(0), "end": num(4), "freq": num(2), "from": num(-12), "to": num(0)})
+		var values []float64
+		for _, p := range points {
+			values = append(values, p["value"].(float64))
+		}
+		assert.Equal(t, []float64{0, -12, 0, -12, -12}, values)
+	})
+
+	t.Run("random is deterministic per seed", func(t *testing.T) {
+		args := gs.Args{"start": num(0), "end": num(4), "seed": num(7)}
+		first := render("random", args)
+		second := render("random", args)
+		assert.Equal(t, first, second)
+		assert.Len(t, first, 5) // one step per beat plus the end point
+		for _, p := range first {
+			assert.GreaterOrEqual(t, p["value"].(float64), -60.0)
+			assert.LessOrEqual(t, p["value"].(float64), 0.0)
+		}
+	})
+
+	t.Run("adsr", func(t *testing.T) {
+		points := render("adsr", gs.Args{"start": num(0), "end": num(8), "from": num(0), "to": num(1),
+			"attack": num(1), "decay": num(1), "sustain": num(0.5), "release": num(2)})
+		assert.Equal(t, []map[string]any{point(0, 0), point(1, 1), point(2, 0.5), point(6, 0.5), point(8, 0)}, points)
+	})
+
+	t.Run("duck on every beat", func(t *testing.T) {
+		points := render("duck", gs.Args{"start": num(0), "end": num(2), "from": num(0), "to": num(-18), "release": num(0.25)})
+		assert.Equal(t, []map[string]any{
+			point(0, -18), point(0.25, 0), point(1-stepEpsilon, 0),
+			point(1, -18), point(1.25, 0), point(2-stepEpsilon, 0),
+		}, points)
+	})
+
+	t.Run("too many points", func(t *testing.T) {
+		c, _ := LookupAutomationCurve("sine")
+		_, err := renderCurve(c, spec("sine", gs.Args{"start": num(0), "end": num(4096), "freq": num(16)}))
+		assert.Error(t, err)
+	})
+}
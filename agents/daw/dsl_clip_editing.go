@@ -0,0 +1,321 @@
+package daw
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// clipTarget is a clip an editing operation applies to.
+type clipTarget struct {
+	Track int
+	ID    map[string]any // Fields identifying the clip in an action (clip, position or bar)
+	Item  map[string]any // State entry for the clip, nil if the clip isn't in state
+}
+
+// newClipAction builds an action for a clip target.
+func (t clipTarget) newClipAction(action string) map[string]any {
+	result := map[string]any{
+		"action": action,
+		"track":  t.Track,
+	}
+	for k, v := range t.ID {
+		result[k] = v
+	}
+	return result
+}
+
+// bounds returns the clip's start and end in seconds, if known from state.
+func (t clipTarget) bounds() (start, end float64, ok bool) {
+	position, hasPosition := getNumericValue(t.Item["position"])
+	length, hasLength := getNumericValue(t.Item["length"])
+	if !hasPosition || !hasLength {
+		return 0, 0, false
+	}
+	return position, position + length, true
+}
+
+// clipTargets returns the clips a clip-editing call applies to: every clip in
+// the filtered collection, or the clip on the current track identified by
+// clip (index), position (seconds) or bar (number).
+// The second return value reports whether the targets came from a filter.
+func (p *FunctionalDSLParser) clipTargets(method string, args gs.Args) ([]clipTarget, bool, error) {
+	if filteredCollection, hasFiltered := p.data["current_filtered"]; hasFiltered {
+		delete(p.data, "current_filtered")
+		filtered, _ := filteredCollection.([]any)
+		log.Printf("🔍 %s: Filtered collection has %d items", method, len(filtered))
+
+		targets := make([]clipTarget, 0, len(filtered))
+		for _, item := range filtered {
+			clipMap, ok := item.(map[string]any)
+			if !ok {
+				log.Printf("⚠️  %s: Clip item is not a map: %T", method, item)
+				continue
+			}
+			trackIndex, ok := itemTrackIndex(clipMap)
+			if !ok {
+				log.Printf("⚠️  %s: Could not extract track index from clip %+v", method, clipMap)
+				continue
+			}
+
+			target := clipTarget{Track: trackIndex, Item: clipMap}
+			if position, ok := getNumericValue(clipMap["position"]); ok {
+				target.ID = map[string]any{"position": position}
+			} else if index, ok := getNumericValue(clipMap["index"]); ok {
+				target.ID = map[string]any{"clip": int(index)}
+			} else {
+				log.Printf("⚠️  %s: Could not identify clip (no index or position): %+v", method, clipMap)
+				continue
+			}
+			targets = append(targets, target)
+		}
+		return targets, true, nil
+	}
+
+	if p.currentTrackIndex < 0 {
+		return nil, false, fmt.Errorf("no track context for %s call", method)
+	}
+
+	target := clipTarget{Track: p.currentTrackIndex}
+	if clipValue, ok := args["clip"]; ok && clipValue.Kind == gs.ValueNumber {
+		target.ID = map[string]any{"clip": int(clipValue.Num)}
+	} else if positionValue, ok := args["position"]; ok && positionValue.Kind == gs.ValueNumber {
+		target.ID = map[string]any{"position": positionValue.Num}
+	} else if barValue, ok := args["bar"]; ok && barValue.Kind == gs.ValueNumber {
+		target.ID = map[string]any{"bar": int(barValue.Num)}
+	} else {
+		return nil, false, fmt.Errorf("%s requires one of: clip (index), position (seconds), or bar (number)", method)
+	}
+
+	// The clip may have been created earlier in the script, so a clip missing
+	// from state is only an error for operations that need its bounds
+	target.Item, _ = p.findClip(p.currentTrackIndex, args)
+	return []clipTarget{target}, false, nil
+}
+
+// numericArg reads a numeric argument given as a literal or as an expression
+// over the clip, e.g. at=clip.position + bar(2).
+func (p *FunctionalDSLParser) numericArg(args gs.Args, name string, item map[string]any) (float64, bool, error) {
+	value, ok := args[name]
+	if !ok {
+		return 0, false, nil
+	}
+	switch value.Kind {
+	case gs.ValueNumber:
+		return value.Num, true, nil
+	case gs.ValueString, gs.ValueIdentifier:
+		result, err := p.evaluateExpression(value.Str, item)
+		if err != nil {
+			return 0, true, fmt.Errorf("%s: %w", name, err)
+		}
+		return result, true, nil
+	default:
+		return 0, true, fmt.Errorf("%s must be a number or expression", name)
+	}
+}
+
+// barPosition returns the start of a 1-based bar in seconds.
+func (p *FunctionalDSLParser) barPosition(bar float64) float64 {
+	return (bar - 1) * p.secondsPerBar()
+}
+
+// SplitClip handles .split_clip() calls to split clips at a time position.
+// at is an absolute position in seconds (or an expression over the clip);
+// at_bar is a 1-based bar number. Filtered clips that don't span the split
+// point are left alone.
+// Example: filter(clips, clip.length > 8).split_clip(at=clip.position + bar(2))
+func (r *ReaperDSL) SplitClip(args gs.Args) error {
+	p := r.parser
+
+	_, hasAt := args["at"]
+	barValue, hasAtBar := args["at_bar"]
+	if hasAt == hasAtBar {
+		return fmt.Errorf("split_clip requires exactly one of: at (seconds) or at_bar (number)")
+	}
+	if hasAtBar && barValue.Kind != gs.ValueNumber {
+		return fmt.Errorf("split_clip: at_bar must be a number")
+	}
+
+	targets, filtered, err := p.clipTargets("split_clip", args)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		var at float64
+		if hasAtBar {
+			at = p.barPosition(barValue.Num)
+		} else if at, _, err = p.numericArg(args, "at", target.Item); err != nil {
+			return fmt.Errorf("split_clip on track %d: %w", target.Track, err)
+		}
+
+		if start, end, ok := target.bounds(); ok && (at <= start || at >= end) {
+			if filtered {
+				log.Printf("⏭️  SplitClip: Clip at %.3fs on track %d doesn't span %.3fs, skipping", start, target.Track, at)
+				continue
+			}
+			return fmt.Errorf("split_clip: position %gs is outside the clip (%gs-%gs)", at, start, end)
+		}
+
+		action := target.newClipAction("split_clip")
+		action["at"] = at
+		p.actions = append(p.actions, action)
+	}
+
+	log.Printf("✅ SplitClip: Processed %d clips", len(targets))
+	return nil
+}
+
+// DuplicateClip handles .duplicate_clip() calls. Each clip is copied times
+// times (default 1), placed back to back with gap seconds (default 0) between
+// copies. When the clip is in state, the positions of the copies are included.
+// Example: track(id=1).duplicate_clip(clip=0, times=3, gap=bar(1))
+func (r *ReaperDSL) DuplicateClip(args gs.Args) error {
+	p := r.parser
+
+	times := 1
+	if timesValue, ok := args["times"]; ok {
+		if timesValue.Kind != gs.ValueNumber || timesValue.Num < 1 || timesValue.Num != math.Trunc(timesValue.Num) {
+			return fmt.Errorf("duplicate_clip: times must be a positive integer")
+		}
+		times = int(timesValue.Num)
+	}
+
+	targets, _, err := p.clipTargets("duplicate_clip", args)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		gap, _, err := p.numericArg(args, "gap", target.Item)
+		if err != nil {
+			return fmt.Errorf("duplicate_clip on track %d: %w", target.Track, err)
+		}
+		if gap < 0 {
+			return fmt.Errorf("duplicate_clip: gap must not be negative (got %g)", gap)
+		}
+
+		action := target.newClipAction("duplicate_clip")
+		action["times"] = times
+		action["gap"] = gap
+
+		if start, end, ok := target.bounds(); ok {
+			positions := make([]float64, times)
+			for i := range positions {
+				positions[i] = start + float64(i+1)*(end-start+gap)
+			}
+			action["positions"] = positions
+		}
+
+		p.actions = append(p.actions, action)
+	}
+
+	log.Printf("✅ DuplicateClip: Processed %d clips", len(targets))
+	return nil
+}
+
+// LoopClip handles .loop_clip() calls. The clip's content is looped until the
+// start of bar to_bar (1-based), so loop_clip(to_bar=9) fills bars 1-8 for a
+// clip at bar 1.
+// Example: filter(clips, clip.length <= 2.0).loop_clip(to_bar=17)
+func (r *ReaperDSL) LoopClip(args gs.Args) error {
+	p := r.parser
+
+	toBarValue, ok := args["to_bar"]
+	if !ok || toBarValue.Kind != gs.ValueNumber {
+		return fmt.Errorf("loop_clip requires to_bar (number)")
+	}
+	if toBarValue.Num < 2 {
+		return fmt.Errorf("loop_clip: to_bar must be 2 or greater (got %g)", toBarValue.Num)
+	}
+	end := p.barPosition(toBarValue.Num)
+
+	targets, filtered, err := p.clipTargets("loop_clip", args)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		action := target.newClipAction("loop_clip")
+		action["to_bar"] = int(toBarValue.Num)
+		action["end"] = end
+
+		if start, _, ok := target.bounds(); ok {
+			if end <= start {
+				if filtered {
+					log.Printf("⏭️  LoopClip: Clip at %.3fs on track %d starts after bar %d, skipping", start, target.Track, int(toBarValue.Num))
+					continue
+				}
+				return fmt.Errorf("loop_clip: clip starts at %gs, after bar %d", start, int(toBarValue.Num))
+			}
+			action["length"] = end - start
+		}
+
+		p.actions = append(p.actions, action)
+	}
+
+	log.Printf("✅ LoopClip: Processed %d clips", len(targets))
+	return nil
+}
+
+// TrimClip handles .trim_clip() calls to move a clip's start and/or end edge.
+// start and end are absolute positions in seconds, or expressions over the
+// clip, e.g. trim_clip(end=clip.position + clip.length - beat(1)). Filtered
+// clips the new edges would leave empty are left alone.
+func (r *ReaperDSL) TrimClip(args gs.Args) error {
+	p := r.parser
+
+	_, hasStart := args["start"]
+	_, hasEnd := args["end"]
+	if !hasStart && !hasEnd {
+		return fmt.Errorf("trim_clip requires at least one of: start (seconds) or end (seconds)")
+	}
+
+	targets, filtered, err := p.clipTargets("trim_clip", args)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		action := target.newClipAction("trim_clip")
+		clipStart, clipEnd, known := target.bounds()
+
+		start, hasStart, err := p.numericArg(args, "start", target.Item)
+		if err != nil {
+			return fmt.Errorf("trim_clip on track %d: %w", target.Track, err)
+		}
+		end, hasEnd, err := p.numericArg(args, "end", target.Item)
+		if err != nil {
+			return fmt.Errorf("trim_clip on track %d: %w", target.Track, err)
+		}
+
+		if hasStart {
+			start = math.Max(0, start)
+			action["start"] = start
+		} else {
+			start = clipStart
+		}
+		if hasEnd {
+			action["end"] = end
+		} else {
+			end = clipEnd
+		}
+
+		if (hasStart && hasEnd) || known {
+			if end <= start {
+				if filtered {
+					log.Printf("⏭️  TrimClip: Clip at %.3fs on track %d would end before it starts, skipping", clipStart, target.Track)
+					continue
+				}
+				return fmt.Errorf("trim_clip on track %d: end (%gs) must be after start (%gs)", target.Track, end, start)
+			}
+		}
+
+		p.actions = append(p.actions, action)
+	}
+
+	log.Printf("✅ TrimClip: Processed %d clips", len(targets))
+	return nil
+}
@@ -0,0 +1,187 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clipEditingTestState is a 120 BPM 4/4 project (2 seconds per bar).
+func clipEditingTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"project": map[string]any{"bpm": 120.0},
+			"tracks": []any{
+				map[string]any{
+					"index": 0, "name": "Drums",
+					"clips": []any{
+						map[string]any{"index": 0, "position": 0.0, "length": 8.0, "selected": true},
+						map[string]any{"index": 1, "position": 10.0, "length": 2.0, "selected": true},
+					},
+				},
+				map[string]any{
+					"index": 1, "name": "Bass",
+					"clips": []any{
+						map[string]any{"index": 0, "position": 4.0, "length": 4.0, "selected": false, "gain_db": -2.0},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestFunctionalDSLParser_ClipEditing(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]any
+	}{
+		{
+			name:    "split single clip at bar",
+			dslCode: `track(id=1).split_clip(clip=0, at_bar=3)`,
+			want: []map[string]any{
+				{"action": "split_clip", "track": 0, "clip": 0, "at": 4.0},
+			},
+		},
+		{
+			name:    "split filtered clips skips clips not spanning the point",
+			dslCode: `filter(clips, clip.selected == true).split_clip(at=6)`,
+			want: []map[string]any{
+				{"action": "split_clip", "track": 0, "position": 0.0, "at": 6.0},
+			},
+		},
+		{
+			name:    "split filtered clips at expression",
+			dslCode: `filter(clips, clip.length >= 4).split_clip(at=clip.position + bar(1))`,
+			want: []map[string]any{
+				{"action": "split_clip", "track": 0, "position": 0.0, "at": 2.0},
+				{"action": "split_clip", "track": 1, "position": 4.0, "at": 6.0},
+			},
+		},
+		{
+			name:    "duplicate with gap",
+			dslCode: `track(id=1).duplicate_clip(clip=1, times=2, gap=bar(1))`,
+			want: []map[string]any{
+				{"action": "duplicate_clip", "track": 0, "clip": 1, "times": 2, "gap": 2.0, "positions": []float64{14.0, 18.0}},
+			},
+		},
+		{
+			name:    "duplicate clip created earlier in the script",
+			dslCode: `track(id=2).new_clip(bar=9, length_bars=1).duplicate_clip(bar=9)`,
+			want: []map[string]any{
+				{"action": "create_clip_at_bar", "track": 1, "bar": 9, "length_bars": 1},
+				{"action": "duplicate_clip", "track": 1, "bar": 9, "times": 1, "gap": 0.0},
+			},
+		},
+		{
+			name:    "loop filtered clips",
+			dslCode: `filter(clips, clip.selected == true).loop_clip(to_bar=9)`,
+			want: []map[string]any{
+				{"action": "loop_clip", "track": 0, "position": 0.0, "to_bar": 9, "end": 16.0, "length": 16.0},
+				{"action": "loop_clip", "track": 0, "position": 10.0, "to_bar": 9, "end": 16.0, "length": 6.0},
+			},
+		},
+		{
+			name:    "trim end by one beat",
+			dslCode: `track(id=2).trim_clip(clip=0, end=clip.position + clip.length - beat(1))`,
+			want: []map[string]any{
+				{"action": "trim_clip", "track": 1, "clip": 0, "end": 7.5},
+			},
+		},
+		{
+			name:    "trim start and end",
+			dslCode: `track(id=1).trim_clip(position=0, start=1, end=5)`,
+			want: []map[string]any{
+				{"action": "trim_clip", "track": 0, "position": 0.0, "start": 1.0, "end": 5.0},
+			},
+		},
+		{
+			name:    "trim filtered clips skips clips the edge falls before",
+			dslCode: `filter(clips, clip.selected == true).trim_clip(end=9)`,
+			want: []map[string]any{
+				{"action": "trim_clip", "track": 0, "position": 0.0, "end": 9.0},
+			},
+		},
+		{
+			name:    "fades, gain and mute",
+			dslCode: `filter(clips, clip.selected == false).set_clip(fade_in=beat(1), fade_out=0.25, gain_db+=-3, mute=true)`,
+			want: []map[string]any{
+				{"action": "set_clip", "track": 1, "position": 4.0, "fade_in": 0.5, "fade_out": 0.25, "gain_db": -5.0, "mute": true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(clipEditingTestState())
+
+			actions, err := parser.ParseDSL(tt.dslCode)
+			require.NoError(t, err)
+			require.Len(t, actions, len(tt.want))
+			for i := range tt.want {
+				for key, want := range tt.want[i] {
+					if wantNum, ok := want.(float64); ok {
+						assert.InDelta(t, wantNum, actions[i][key], 1e-9, "action %d %s", i, key)
+					} else {
+						assert.Equal(t, want, actions[i][key], "action %d %s", i, key)
+					}
+				}
+				assert.Len(t, actions[i], len(tt.want[i]))
+			}
+		})
+	}
+}
+
+func TestFunctionalDSLParser_ClipEditingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{
+			name:    "split outside clip",
+			dslCode: `track(id=1).split_clip(clip=1, at=20)`,
+			wantErr: "outside the clip",
+		},
+		{
+			name:    "split needs a position",
+			dslCode: `track(id=1).split_clip(clip=0)`,
+			wantErr: "split_clip requires exactly one of",
+		},
+		{
+			name:    "duplicate times must be an integer",
+			dslCode: `track(id=1).duplicate_clip(clip=0, times=1.5)`,
+			wantErr: "times must be a positive integer",
+		},
+		{
+			name:    "loop clip starting after target bar",
+			dslCode: `track(id=1).loop_clip(clip=1, to_bar=3)`,
+			wantErr: "after bar 3",
+		},
+		{
+			name:    "trim end before start",
+			dslCode: `track(id=1).trim_clip(clip=1, end=9)`,
+			wantErr: "must be after start",
+		},
+		{
+			name:    "clip identification required",
+			dslCode: `track(id=1).trim_clip(start=1)`,
+			wantErr: "trim_clip requires one of: clip (index), position (seconds), or bar (number)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(clipEditingTestState())
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
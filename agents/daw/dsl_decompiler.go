@@ -0,0 +1,426 @@
+package daw
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DSLDecompiler converts DAW actions back into canonical MAGDA DSL, the
+// reverse of FunctionalDSLParser. Consecutive actions on the same track are
+// chained onto one statement; each statement goes on its own line.
+//
+// Actions carry resolved values, so the DSL is literal: relative updates
+// (volume_db+=-3) come back as absolute values, filter(...) expansions as one
+// statement per track, and curve automation with the curve parameters the
+// action kept (start, end, from, to, freq, ...).
+type DSLDecompiler struct {
+	trackCounter int // Index the parser gives the next created track
+}
+
+// NewDSLDecompiler creates a decompiler for a project without tracks.
+func NewDSLDecompiler() *DSLDecompiler {
+	return &DSLDecompiler{}
+}
+
+// SetState sets the REAPER state the actions apply to. Like the parser, the
+// decompiler numbers created tracks after the existing ones, and only writes
+// track(index=...) when an action's index differs from that.
+func (d *DSLDecompiler) SetState(state map[string]any) {
+	d.trackCounter = len(stateTracks(state))
+}
+
+// DecompileActions converts actions to DSL for a project without tracks.
+func DecompileActions(actions []map[string]any) (string, error) {
+	return NewDSLDecompiler().Decompile(actions)
+}
+
+// Decompile converts actions to DSL, one statement per line.
+func (d *DSLDecompiler) Decompile(actions []map[string]any) (string, error) {
+	trackCounter := d.trackCounter
+
+	var statements []string
+	var chain strings.Builder
+	chainTrack := -1 // Track the open chain applies to, -1 if none
+
+	flush := func() {
+		if chain.Len() > 0 {
+			statements = append(statements, chain.String())
+			chain.Reset()
+		}
+		chainTrack = -1
+	}
+
+	for i, action := range actions {
+		actionType, _ := action["action"].(string)
+		trackValue, hasTrack := getNumericValue(action["track"])
+		track := int(trackValue)
+
+		if actionType == "create_track" {
+			flush()
+			call, index, err := decompileCreateTrack(action, trackCounter)
+			if err != nil {
+				return "", fmt.Errorf("action %d: %w", i, err)
+			}
+			trackCounter = index + 1
+			chain.WriteString(call)
+			chainTrack = index
+			continue
+		}
+
+		if keys, ok := projectActionCalls[actionType]; ok {
+			flush()
+			if actionType == "render" && action["range"] == "bars" {
+				keys = keys[1:]
+			}
+			args, err := copyDSLArgs(action, keys...)
+			if err != nil {
+				return "", fmt.Errorf("action %d: %s: %w", i, actionType, err)
+			}
+			statements = append(statements, formatDSLCall(actionType, args))
+			continue
+		}
+
+		if !hasTrack {
+			return "", fmt.Errorf("action %d (%s): missing track", i, actionType)
+		}
+		call, err := decompileTrackAction(actionType, action)
+		if err != nil {
+			return "", fmt.Errorf("action %d: %w", i, err)
+		}
+
+		if track != chainTrack {
+			flush()
+			chain.WriteString(formatDSLCall("track", []dslArg{{"id", strconv.Itoa(track + 1)}}))
+			chainTrack = track
+		}
+		chain.WriteString(".")
+		chain.WriteString(call)
+
+		if actionType == "delete_track" {
+			flush()
+		}
+	}
+	flush()
+
+	return strings.Join(statements, "\n"), nil
+}
+
+// dslArg is a named argument of a DSL call with its value already rendered.
+type dslArg struct {
+	Name  string
+	Value string
+}
+
+// formatDSLCall renders name(arg=value, ...) with arguments in canonical order.
+func formatDSLCall(name string, args []dslArg) string {
+	rank := make(map[string]int)
+	for i, param := range dslParamOrder[name] {
+		rank[param] = i
+	}
+	position := func(arg dslArg) int {
+		if r, ok := rank[arg.Name]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	sort.SliceStable(args, func(a, b int) bool {
+		return position(args[a]) < position(args[b])
+	})
+
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Name + "=" + arg.Value
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// formatDSLValue renders a Go value as a DSL literal.
+func formatDSLValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, `"`) {
+			return "", fmt.Errorf("string %q contains a double quote", v)
+		}
+		return `"` + v + `"`, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	if num, ok := getNumericValue(value); ok {
+		return strconv.FormatFloat(num, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value %v (%T)", value, value)
+}
+
+// copyDSLArgs renders the action fields in keys that are present.
+func copyDSLArgs(action map[string]any, keys ...string) ([]dslArg, error) {
+	var args []dslArg
+	for _, key := range keys {
+		value, ok := action[key]
+		if !ok {
+			continue
+		}
+		rendered, err := formatDSLValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		args = append(args, dslArg{key, rendered})
+	}
+	return args, nil
+}
+
+// decompileCreateTrack renders a create_track action as a track() call and
+// returns the index of the created track.
+func decompileCreateTrack(action map[string]any, nextIndex int) (string, int, error) {
+	args, err := copyDSLArgs(action, "instrument", "name")
+	if err != nil {
+		return "", 0, fmt.Errorf("create_track: %w", err)
+	}
+
+	index := nextIndex
+	if value, ok := getNumericValue(action["index"]); ok {
+		index = int(value)
+	}
+	if index != nextIndex {
+		args = append(args, dslArg{"index", strconv.Itoa(index)})
+	}
+	return formatDSLCall("track", args), index, nil
+}
+
+// decompileTrackAction renders an action on an existing track as the chained
+// method call that produces it, without the leading ".".
+func decompileTrackAction(actionType string, action map[string]any) (string, error) {
+	method, keys, ok := trackActionCalls(actionType)
+	if !ok {
+		return "", fmt.Errorf("cannot decompile action type %q", actionType)
+	}
+	if _, hasNotes := action["notes"]; hasNotes {
+		return "", fmt.Errorf("%s on selected notes has no DSL equivalent without the filter that selected them", actionType)
+	}
+
+	var args []dslArg
+	var err error
+	switch actionType {
+	case "add_track_fx":
+		args, err = renameDSLArg(action, "fxname", "fxname")
+	case "add_instrument":
+		args, err = renameDSLArg(action, "fxname", "instrument")
+	case "add_automation":
+		args, err = automationDSLArgs(action)
+	case "add_send":
+		args, err = sendDSLArgs(action)
+	default:
+		args, err = copyDSLArgs(action, keys...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", actionType, err)
+	}
+	return formatDSLCall(method, args), nil
+}
+
+// projectActionCalls maps project-level action types, which are statements
+// of their own named like the action, to the action fields that are
+// arguments. A render's bar range is written as start_bar/end_bar, not
+// range="bars", so range comes first to be dropped.
+var projectActionCalls = map[string][]string{
+	"render":       {"range", "start_bar", "end_bar", "format", "stems", "sample_rate", "bit_depth", "file"},
+	"save_project": {"path"},
+	"set_project":  {"name", "sample_rate", "tempo"},
+	"set_loop":     {"start_bar", "end_bar"},
+	"set_cursor":   {"bar", "position"},
+	"play":         nil,
+	"stop":         nil,
+}
+
+// trackActionCalls maps an action type to the DSL method producing it and the
+// action fields that are arguments of that method. Derived fields (positions
+// of duplicates, the end of a loop, rendered automation points) are left out.
+func trackActionCalls(actionType string) (string, []string, bool) {
+	clipRef := []string{"clip", "position", "bar"}
+	switch actionType {
+	case "add_track_fx", "add_instrument":
+		return "add_fx", nil, true
+	case "set_track":
+		return "set_track", []string{"name", "volume_db", "pan", "mute", "solo", "selected", "color", "folder_depth"}, true
+	case "add_send":
+		return "add_send", nil, true
+	case "delete_track":
+		return "delete", nil, true
+	case "create_clip_at_bar":
+		return "new_clip", []string{"bar", "length_bars"}, true
+	case "create_clip":
+		return "new_clip", []string{"position", "length"}, true
+	case "delete_clip":
+		return "delete_clip", clipRef, true
+	case "set_clip":
+		return "set_clip", append(clipRef, "name", "color", "selected", "length", "fade_in", "fade_out", "gain_db", "mute"), true
+	case "set_clip_position":
+		return "move_clip", []string{"clip", "old_position", "bar", "position"}, true
+	case "split_clip":
+		return "split_clip", append(clipRef, "at"), true
+	case "duplicate_clip":
+		return "duplicate_clip", append(clipRef, "times", "gap"), true
+	case "loop_clip":
+		return "loop_clip", append(clipRef, "to_bar"), true
+	case "trim_clip":
+		return "trim_clip", append(clipRef, "start", "end"), true
+	case "quantize_notes":
+		return "quantize", append(clipRef, "grid", "strength", "swing"), true
+	case "transpose_notes":
+		return "transpose", append(clipRef, "semitones"), true
+	case "set_note_velocity":
+		return "set_velocity", append(clipRef, "velocity"), true
+	case "humanize_notes":
+		return "humanize", append(clipRef, "timing", "velocity"), true
+	case "legato_notes":
+		return "legato", append(clipRef, "gap"), true
+	case "reverse_notes":
+		return "reverse", clipRef, true
+	case "add_automation":
+		return "add_automation", nil, true
+	}
+	return "", nil, false
+}
+
+// renameDSLArg renders a single action field under a different argument name.
+func renameDSLArg(action map[string]any, field, name string) ([]dslArg, error) {
+	value, ok := action[field]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", field)
+	}
+	rendered, err := formatDSLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return []dslArg{{name, rendered}}, nil
+}
+
+// sendDSLArgs renders an add_send action, writing the destination as a
+// 1-based track id.
+func sendDSLArgs(action map[string]any) ([]dslArg, error) {
+	target, ok := getNumericValue(action["target"])
+	if !ok {
+		return nil, fmt.Errorf("missing target")
+	}
+	levels, err := copyDSLArgs(action, "volume_db", "pan")
+	if err != nil {
+		return nil, err
+	}
+	return append([]dslArg{{"to", strconv.Itoa(int(target) + 1)}}, levels...), nil
+}
+
+// automationDSLArgs renders the target and curve (or points) of an
+// add_automation action. FX, parameters and sends are written by name when
+// the action has one, otherwise by 1-based index.
+func automationDSLArgs(action map[string]any) ([]dslArg, error) {
+	var args []dslArg
+	indexed := func(name, nameKey, indexKey string) error {
+		if value, ok := action[nameKey].(string); ok {
+			rendered, err := formatDSLValue(value)
+			if err != nil {
+				return err
+			}
+			args = append(args, dslArg{name, rendered})
+			return nil
+		}
+		if index, ok := getNumericValue(action[indexKey]); ok {
+			args = append(args, dslArg{name, strconv.Itoa(int(index) + 1)})
+			return nil
+		}
+		return fmt.Errorf("missing %s or %s", nameKey, indexKey)
+	}
+
+	param, _ := action["param"].(string)
+	switch param {
+	case "fx":
+		if err := indexed("fx", "fx_name", "fx_index"); err != nil {
+			return nil, err
+		}
+		if err := indexed("fx_param", "param_name", "param_index"); err != nil {
+			return nil, err
+		}
+	case "send":
+		args = append(args, dslArg{"param", `"send"`})
+		if err := indexed("send", "send_name", "send_index"); err != nil {
+			return nil, err
+		}
+	default:
+		rendered, err := formatDSLValue(param)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, dslArg{"param", rendered})
+	}
+
+	if _, ok := action["curve"]; ok {
+		curveArgs, err := copyDSLArgs(action, "curve", "start", "end", "start_bar", "end_bar", "from", "to", "freq", "amplitude", "phase")
+		if err != nil {
+			return nil, err
+		}
+		return append(args, curveArgs...), nil
+	}
+
+	points, err := automationPointsDSL(action["points"])
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, dslArg{"points", points})
+	shapeArgs, err := copyDSLArgs(action, "shape")
+	if err != nil {
+		return nil, err
+	}
+	return append(args, shapeArgs...), nil
+}
+
+// automationPointsDSL renders automation points as [{time=0, value=-60}, ...].
+func automationPointsDSL(value any) (string, error) {
+	var points []map[string]any
+	switch v := value.(type) {
+	case []map[string]any:
+		points = v
+	case []any:
+		for _, point := range v {
+			pointMap, ok := point.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("invalid automation point %v", point)
+			}
+			points = append(points, pointMap)
+		}
+	}
+	if len(points) == 0 {
+		return "", fmt.Errorf("automation has neither curve nor points")
+	}
+
+	parts := make([]string, len(points))
+	for i, point := range points {
+		fields, err := copyDSLArgs(point, "time", "bar", "value")
+		if err != nil {
+			return "", fmt.Errorf("point %d: %w", i, err)
+		}
+		rendered := make([]string, len(fields))
+		for j, field := range fields {
+			rendered[j] = field.Name + "=" + field.Value
+		}
+		parts[i] = "{" + strings.Join(rendered, ", ") + "}"
+	}
+	return "[" + strings.Join(parts, ", ") + "]", nil
+}
+
+// stateTracks returns the tracks of a REAPER state in either the
+// {"state": {...}} or {...} format.
+func stateTracks(state map[string]any) []any {
+	if state == nil {
+		return nil
+	}
+	stateMap, ok := state["state"].(map[string]any)
+	if !ok {
+		stateMap = state
+	}
+	tracks, _ := stateMap["tracks"].([]any)
+	return tracks
+}
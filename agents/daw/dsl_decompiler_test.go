@@ -0,0 +1,150 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decompilerTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{
+					"index": 0, "name": "Drums", "volume_db": -6.0,
+					"clips": []any{
+						map[string]any{"index": 0, "position": 0.0, "length": 8.0, "notes": []any{
+							map[string]any{"pitch": 36, "velocity": 100, "start": 0.0, "length": 1.0},
+						}},
+					},
+				},
+				map[string]any{"index": 1, "name": "Bass"},
+			},
+		},
+	}
+}
+
+func parseForDecompiler(t *testing.T, dslCode string) []map[string]any {
+	t.Helper()
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(decompilerTestState())
+	actions, err := parser.ParseDSL(dslCode)
+	require.NoError(t, err, dslCode)
+	return actions
+}
+
+func TestDSLDecompiler_RoundTrip(t *testing.T) {
+	scripts := []string{
+		`track(name="Lead", instrument="Serum").new_clip(bar=3, length_bars=2).add_fx(fxname="ReaEQ")`,
+		`track(name="Pad", index=5)`,
+		`track(id=1).set_track(name="Kit", volume_db=-3, pan=0.5, mute=true, solo=false, selected=true)`,
+		`track(id=1).set_track(volume_db+=-3)`,
+		`filter(tracks, track.index < 2).set_track(mute=true)`,
+		`track(id=2).add_fx(instrument="Serum").new_clip(position=2.5, length=1)`,
+		`track(id=2).delete()`,
+		`track(id=1).delete_clip(clip=0)`,
+		`track(id=1).set_clip(clip=0, name="A", color="#ff0000", length=4, fade_in=0.5, gain_db=-2, mute=true)`,
+		`track(id=1).move_clip(clip=0, position=8)`,
+		`track(id=1).split_clip(clip=0, at=2)`,
+		`track(id=1).duplicate_clip(clip=0, times=2, gap=1)`,
+		`track(id=1).loop_clip(clip=0, to_bar=9)`,
+		`track(id=1).trim_clip(clip=0, start=1, end=6)`,
+		`track(id=1).quantize(clip=0, grid="1/16", strength=0.5, swing=0.1)`,
+		`track(id=1).transpose(clip=0, semitones=3)`,
+		`track(id=1).set_velocity(clip=0, velocity=90)`,
+		`track(id=1).humanize(clip=0, timing=0.02, velocity=5)`,
+		`track(id=1).legato(clip=0, gap=0.1)`,
+		`track(id=1).reverse(clip=0)`,
+		`track(id=1).add_automation(param="volume", curve="sine", start=0, end=4, freq=2)`,
+		`track(id=1).add_automation(fx="ReaEQ", fx_param="Gain", curve="ramp", start_bar=1, end_bar=2, from=0, to=1)`,
+		`track(id=1).set_track(color="#ff0000", folder_depth=1)`,
+		`track(id=2).add_send(to=1, volume_db=-12, pan=0.5)`,
+		`render(range="loop", format="flac", stems=true, sample_rate=96000, bit_depth=24, file="Loop")`,
+		`render(start_bar=17, end_bar=25, format="mp3")`,
+		`track(id=1).set_track(mute=true); save_project(path="Song v2"); set_project(name="Song", tempo=96)`,
+		`set_loop(start_bar=9, end_bar=17); set_cursor(bar=9); play(); stop()`,
+	}
+
+	for _, script := range scripts {
+		t.Run(script, func(t *testing.T) {
+			actions := parseForDecompiler(t, script)
+
+			decompiler := NewDSLDecompiler()
+			decompiler.SetState(decompilerTestState())
+			dslCode, err := decompiler.Decompile(actions)
+			require.NoError(t, err)
+
+			assert.Equal(t, actions, parseForDecompiler(t, dslCode), "decompiled DSL:\n%s", dslCode)
+		})
+	}
+}
+
+func TestDSLDecompiler_Decompile(t *testing.T) {
+	actions := []map[string]any{
+		{"action": "create_track", "index": 0, "name": "Bass", "instrument": "Serum"},
+		{"action": "create_clip_at_bar", "track": 0, "bar": 1, "length_bars": 4},
+		{"action": "set_track", "track": 1, "volume_db": -9.0},
+		{"action": "set_track", "track": 2, "mute": true},
+		{"action": "add_track_fx", "track": 2, "fxname": "ReaComp"},
+		{"action": "delete_track", "track": 2},
+		{"action": "set_track", "track": 2, "solo": true},
+		{"action": "add_automation", "track": 0, "param": "fx", "fx_index": 0, "param_index": 2, "curve": "ramp", "start": 0.0, "end": 4.0},
+		{"action": "add_automation", "track": 0, "param": "pan", "points": []any{
+			map[string]any{"time": 0.0, "value": -1.0},
+			map[string]any{"time": 4.0, "value": 1.0},
+		}},
+	}
+
+	dslCode, err := DecompileActions(actions)
+	require.NoError(t, err)
+	assert.Equal(t, `track(name="Bass", instrument="Serum").new_clip(bar=1, length_bars=4)
+track(id=2).set_track(volume_db=-9)
+track(id=3).set_track(mute=true).add_fx(fxname="ReaComp").delete()
+track(id=3).set_track(solo=true)
+track(id=1).add_automation(fx=1, fx_param=3, curve="ramp", start=0, end=4).add_automation(param="pan", points=[{time=0, value=-1}, {time=4, value=1}])`, dslCode)
+}
+
+func TestDSLDecompiler_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		action map[string]any
+		want   string
+	}{
+		{"unknown action", map[string]any{"action": "add_midi", "track": 0}, `cannot decompile action type "add_midi"`},
+		{"missing track", map[string]any{"action": "set_track", "mute": true}, "missing track"},
+		{"selected notes", map[string]any{"action": "transpose_notes", "track": 0, "clip": 0, "notes": []int{1, 2}, "semitones": 2}, "no DSL equivalent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecompileActions([]map[string]any{tt.action})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestDecompileStateDiff(t *testing.T) {
+	before := decompilerTestState()
+	after := map[string]any{
+		"tracks": []any{
+			map[string]any{
+				"index": 0, "name": "Drums", "volume_db": -3, "muted": true,
+				"fx": []any{map[string]any{"name": "ReaComp"}},
+				"clips": []any{
+					map[string]any{"index": 0, "position": 4.0, "length": 8.0},
+					map[string]any{"index": 1, "position": 16.0, "length": 4.0},
+				},
+			},
+			map[string]any{"index": 2, "name": "Keys"},
+		},
+	}
+
+	dslCode, err := DecompileStateDiff(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, `track(id=1).set_track(volume_db=-3, mute=true).add_fx(fxname="ReaComp").move_clip(clip=0, position=4).new_clip(position=16, length=4)
+track(name="Keys")
+track(id=2).delete()`, dslCode)
+}
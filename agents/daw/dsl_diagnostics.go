@@ -0,0 +1,66 @@
+package daw
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+)
+
+// trackNamePredicatePattern matches track name comparisons in filter
+// predicates, e.g. filter(tracks, track.name == "Bass").
+var trackNamePredicatePattern = regexp.MustCompile(`\btrack\.name\s*==\s*"([^"]*)"`)
+
+// Diagnostics returns the warnings found in the last script passed to ParseDSL.
+// When ParseDSL fails, the same diagnostics and the error itself are carried by
+// the returned *diagnostics.Error.
+func (p *FunctionalDSLParser) Diagnostics() []diagnostics.Diagnostic {
+	return p.diags
+}
+
+// trackNameDiagnostics warns about filter predicates in dslCode[start:end]
+// naming tracks that do not exist in the current state, suggesting the
+// closest existing names.
+func (p *FunctionalDSLParser) trackNameDiagnostics(dslCode string, start, end int) []diagnostics.Diagnostic {
+	tracks, ok := p.data["tracks"].([]any)
+	if !ok || len(tracks) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(tracks))
+	exists := make(map[string]bool, len(tracks))
+	for _, trackInterface := range tracks {
+		if track, ok := trackInterface.(map[string]any); ok {
+			if name, ok := track["name"].(string); ok {
+				names = append(names, name)
+				exists[name] = true
+			}
+		}
+	}
+
+	var diags []diagnostics.Diagnostic
+	for _, match := range trackNamePredicatePattern.FindAllStringSubmatchIndex(dslCode[start:end], -1) {
+		nameStart, nameEnd := start+match[2], start+match[3]
+		name := dslCode[nameStart:nameEnd]
+		if exists[name] {
+			continue
+		}
+		// Span covers the quoted name
+		diags = append(diags, diagnostics.Diagnostic{
+			Severity:    diagnostics.SeverityWarning,
+			Kind:        diagnostics.KindUnknownTrack,
+			Message:     fmt.Sprintf("no track named \"%s\"", name),
+			Span:        diagnostics.NewSpan(dslCode, nameStart-1, nameEnd+1),
+			Expected:    names,
+			Suggestions: diagnostics.Suggest(name, names),
+		})
+	}
+	return diags
+}
+
+// failParse returns the error for a failed script. The first error diagnostic
+// is appended to the warnings collected before execution.
+func (p *FunctionalDSLParser) failParse(dslCode string, diag diagnostics.Diagnostic, err error) error {
+	p.diags = append(p.diags, diag)
+	return diagnostics.NewError(dslCode, p.diags, err)
+}
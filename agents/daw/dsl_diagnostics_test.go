@@ -0,0 +1,102 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diagnosticsTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Drums"},
+				map[string]any{"index": 1, "name": "Bass"},
+			},
+		},
+	}
+}
+
+func parseWithDiagnostics(t *testing.T, dslCode string) *diagnostics.Error {
+	t.Helper()
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(diagnosticsTestState())
+
+	_, err = parser.ParseDSL(dslCode)
+	require.Error(t, err)
+	diagErr, ok := diagnostics.AsError(err)
+	require.True(t, ok, "expected *diagnostics.Error, got %T", err)
+	assert.Equal(t, dslCode, diagErr.Source)
+	return diagErr
+}
+
+func TestFunctionalDSLParser_Diagnostics(t *testing.T) {
+	t.Run("unknown parameter is a warning", func(t *testing.T) {
+		parser, err := NewFunctionalDSLParser()
+		require.NoError(t, err)
+
+		actions, err := parser.ParseDSL(`track(id=1).set_track(mute=true, volume=-3)`)
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+
+		diags := parser.Diagnostics()
+		require.Len(t, diags, 1)
+		assert.Equal(t, diagnostics.KindUnknownParameter, diags[0].Kind)
+		assert.Equal(t, diagnostics.SeverityWarning, diags[0].Severity)
+		assert.Equal(t, "set_track", diags[0].Method)
+		assert.Equal(t, "volume", diags[0].Parameter)
+		assert.Equal(t, []string{"volume_db"}, diags[0].Suggestions)
+		assert.Equal(t, 34, diags[0].Span.Start.Column)
+	})
+
+	t.Run("unknown method stops before execution", func(t *testing.T) {
+		diagErr := parseWithDiagnostics(t, "track(id=1).set_track(mute=true)\ntrack(id=2).addAutomation(param=\"volume\", curve=\"sine\", start=0, end=4)")
+		assert.Contains(t, diagErr.Error(), "unknown method 'addAutomation'")
+
+		require.Len(t, diagErr.Diagnostics, 1)
+		diag := diagErr.Diagnostics[0]
+		assert.Equal(t, diagnostics.KindUnknownMethod, diag.Kind)
+		assert.Equal(t, []string{"add_automation"}, diag.Suggestions)
+		assert.Equal(t, diagnostics.Position{Offset: 45, Line: 2, Column: 13}, diag.Span.Start)
+	})
+
+	t.Run("execution error points at the failing call", func(t *testing.T) {
+		diagErr := parseWithDiagnostics(t, "track(id=1).set_track(mute=true)\ntrack(id=2).split_clip(clip=0)")
+		assert.Contains(t, diagErr.Error(), "failed to execute DSL")
+
+		require.Len(t, diagErr.Diagnostics, 1)
+		diag := diagErr.Diagnostics[0]
+		assert.Equal(t, diagnostics.KindExecution, diag.Kind)
+		assert.Equal(t, "split_clip", diag.Method)
+		assert.Contains(t, diag.Message, "split_clip requires exactly one of")
+		assert.Equal(t, diagnostics.Position{Offset: 45, Line: 2, Column: 13}, diag.Span.Start)
+		assert.Equal(t, 2, diag.Span.End.Line)
+		assert.Equal(t, 31, diag.Span.End.Column)
+	})
+
+	t.Run("unknown track name suggests existing tracks", func(t *testing.T) {
+		diagErr := parseWithDiagnostics(t, `filter(tracks, track.name == "bas").set_track(mute=true)`)
+		// The empty filter leaves set_track without a target
+		assert.Contains(t, diagErr.Error(), "no track context")
+
+		require.Len(t, diagErr.Diagnostics, 2)
+		warning := diagErr.Diagnostics[0]
+		assert.Equal(t, diagnostics.KindUnknownTrack, warning.Kind)
+		assert.Equal(t, []string{"Bass"}, warning.Suggestions)
+		assert.Equal(t, []string{"Drums", "Bass"}, warning.Expected)
+		assert.Equal(t, 30, warning.Span.Start.Column)
+		assert.Equal(t, diagnostics.KindExecution, diagErr.Diagnostics[1].Kind)
+		assert.Equal(t, "set_track", diagErr.Diagnostics[1].Method)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		diagErr := parseWithDiagnostics(t, `track(id=1).set_track(mute=true`)
+		require.Len(t, diagErr.Diagnostics, 1)
+		assert.Equal(t, diagnostics.KindSyntax, diagErr.Diagnostics[0].Kind)
+		assert.Equal(t, []string{")"}, diagErr.Diagnostics[0].Expected)
+		assert.Contains(t, diagErr.Format(), "track(id=1).set_track(mute=true\n                       ^")
+	})
+}
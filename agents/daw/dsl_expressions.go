@@ -0,0 +1,509 @@
+package daw
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// Default project timing used when state doesn't include tempo information.
+const (
+	DefaultBPM         = 120.0
+	DefaultBeatsPerBar = 4.0
+)
+
+// propertyDefaults are the values assumed for relative updates when the
+// item's current value isn't in state (REAPER's defaults for new tracks).
+var propertyDefaults = map[string]float64{
+	"volume_db": 0,
+	"pan":       0,
+	"gain_db":   0,
+	"fade_in":   0,
+	"fade_out":  0,
+}
+
+// relativeOperators maps the suffix Grammar School leaves on a parameter name
+// (volume_db+=-3 parses as "volume_db+" = -3) to the assignment operator.
+var relativeOperators = map[string]string{
+	"+": "+=",
+	"-": "-=",
+	"*": "*=",
+	"/": "/=",
+}
+
+// propertyUpdate is a numeric property assignment that may depend on the
+// target item's current value, e.g. volume_db+=-3 or
+// position=clip.position + bar(1).
+type propertyUpdate struct {
+	Property string
+	Op       string  // "=", "+=", "-=", "*=", "/="
+	Value    float64 // Literal right-hand side
+	Expr     string  // Expression right-hand side (empty for literals)
+}
+
+// isStatic reports whether the update is a plain literal assignment that
+// doesn't depend on the item.
+func (u propertyUpdate) isStatic() bool {
+	return u.Op == "=" && u.Expr == ""
+}
+
+// parsePropertyUpdates extracts numeric updates for the given properties.
+// Each property may be assigned (=) a literal or expression, or updated
+// relatively with +=, -=, *= or /=.
+func parsePropertyUpdates(args gs.Args, properties ...string) ([]propertyUpdate, error) {
+	var updates []propertyUpdate
+
+	for _, property := range properties {
+		candidates := map[string]string{property: "="}
+		for suffix, op := range relativeOperators {
+			candidates[property+suffix] = op
+		}
+
+		found := 0
+		for key, op := range candidates {
+			value, ok := args[key]
+			if !ok {
+				continue
+			}
+			found++
+
+			update := propertyUpdate{Property: property, Op: op}
+			switch value.Kind {
+			case gs.ValueNumber:
+				update.Value = value.Num
+			case gs.ValueString, gs.ValueIdentifier:
+				update.Expr = strings.TrimSpace(value.Str)
+				if update.Expr == "" {
+					return nil, fmt.Errorf("%s%s requires a value", property, op)
+				}
+			default:
+				return nil, fmt.Errorf("%s must be a number or expression", property)
+			}
+			updates = append(updates, update)
+		}
+
+		if found > 1 {
+			return nil, fmt.Errorf("%s is assigned more than once", property)
+		}
+	}
+
+	return updates, nil
+}
+
+// applyPropertyUpdates resolves updates against an item and writes the
+// results into action.
+func (p *FunctionalDSLParser) applyPropertyUpdates(action map[string]any, updates []propertyUpdate, item map[string]any) error {
+	for _, update := range updates {
+		value, err := p.resolvePropertyUpdate(update, item)
+		if err != nil {
+			return err
+		}
+		action[update.Property] = value
+	}
+	return nil
+}
+
+// resolvePropertyUpdate computes the new value of a property for a specific item.
+func (p *FunctionalDSLParser) resolvePropertyUpdate(update propertyUpdate, item map[string]any) (float64, error) {
+	rhs := update.Value
+	if update.Expr != "" {
+		var err error
+		rhs, err = p.evaluateExpression(update.Expr, item)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", update.Property, err)
+		}
+	}
+
+	result := rhs
+	if update.Op != "=" {
+		current, ok := getNumericValue(item[update.Property])
+		if !ok {
+			current, ok = propertyDefaults[update.Property]
+		}
+		if !ok {
+			return 0, fmt.Errorf("cannot apply %s%s: current %s not found in state", update.Property, update.Op, update.Property)
+		}
+
+		switch update.Op {
+		case "+=":
+			result = current + rhs
+		case "-=":
+			result = current - rhs
+		case "*=":
+			result = current * rhs
+		case "/=":
+			if rhs == 0 {
+				return 0, fmt.Errorf("%s/=0: division by zero", update.Property)
+			}
+			result = current / rhs
+		}
+	}
+
+	switch update.Property {
+	case "pan":
+		result = math.Max(-1, math.Min(1, result))
+	case "position", "fade_in", "fade_out":
+		result = math.Max(0, result)
+	case "length":
+		if result <= 0 {
+			return 0, fmt.Errorf("length must be positive (got %g)", result)
+		}
+	}
+
+	return result, nil
+}
+
+// projectTiming returns the project tempo and beats per bar from state.
+// Looks for state.project.{bpm,tempo,time_signature} and top-level bpm/tempo,
+// falling back to 120 BPM in 4/4.
+func (p *FunctionalDSLParser) projectTiming() (bpm, beatsPerBar float64) {
+	bpm, beatsPerBar = DefaultBPM, DefaultBeatsPerBar
+	if p.state == nil {
+		return bpm, beatsPerBar
+	}
+
+	stateMap, ok := p.state["state"].(map[string]any)
+	if !ok {
+		stateMap = p.state
+	}
+
+	sources := []map[string]any{stateMap}
+	if project, ok := stateMap["project"].(map[string]any); ok {
+		sources = append(sources, project)
+	}
+
+	for _, source := range sources {
+		for _, key := range []string{"bpm", "tempo"} {
+			if value, ok := getNumericValue(source[key]); ok && value > 0 {
+				bpm = value
+			}
+		}
+		if value, ok := getNumericValue(source["beats_per_bar"]); ok && value > 0 {
+			beatsPerBar = value
+		}
+		switch sig := source["time_signature"].(type) {
+		case map[string]any:
+			if value, ok := getNumericValue(sig["numerator"]); ok && value > 0 {
+				beatsPerBar = value
+			}
+		case string:
+			if numerator, _, found := strings.Cut(sig, "/"); found {
+				if value, err := strconv.ParseFloat(strings.TrimSpace(numerator), 64); err == nil && value > 0 {
+					beatsPerBar = value
+				}
+			}
+		}
+	}
+
+	return bpm, beatsPerBar
+}
+
+// findClip looks up the clip on a track identified by clip (index),
+// position/old_position (seconds) or bar (1-based) in state.
+func (p *FunctionalDSLParser) findClip(trackIndex int, args gs.Args) (map[string]any, error) {
+	clips, _ := p.data["clips"].([]any)
+
+	matches := func(clipMap map[string]any) bool {
+		if clipValue, ok := args["clip"]; ok && clipValue.Kind == gs.ValueNumber {
+			index, ok := getNumericValue(clipMap["index"])
+			return ok && int(index) == int(clipValue.Num)
+		}
+		position, hasPosition := getNumericValue(clipMap["position"])
+		for _, key := range []string{"position", "old_position"} {
+			if positionValue, ok := args[key]; ok && positionValue.Kind == gs.ValueNumber {
+				return hasPosition && math.Abs(position-positionValue.Num) < 1e-6
+			}
+		}
+		if barValue, ok := args["bar"]; ok && barValue.Kind == gs.ValueNumber && hasPosition {
+			barStart := (barValue.Num - 1) * p.secondsPerBar()
+			length, _ := getNumericValue(clipMap["length"])
+			return math.Abs(position-barStart) < 1e-6 || (position <= barStart && barStart < position+length)
+		}
+		return false
+	}
+
+	for _, clip := range clips {
+		clipMap, ok := clip.(map[string]any)
+		if !ok {
+			continue
+		}
+		if clipTrack, ok := itemTrackIndex(clipMap); !ok || clipTrack != trackIndex {
+			continue
+		}
+		if matches(clipMap) {
+			return clipMap, nil
+		}
+	}
+
+	return nil, fmt.Errorf("clip not found on track %d in state", trackIndex)
+}
+
+// secondsPerBeat returns the length of one beat in seconds.
+func (p *FunctionalDSLParser) secondsPerBeat() float64 {
+	bpm, _ := p.projectTiming()
+	return 60.0 / bpm
+}
+
+// secondsPerBar returns the length of one bar in seconds.
+func (p *FunctionalDSLParser) secondsPerBar() float64 {
+	bpm, beatsPerBar := p.projectTiming()
+	return 60.0 / bpm * beatsPerBar
+}
+
+// evaluateExpression evaluates a numeric expression against an item.
+//
+// Supported: numbers, + - * / and parentheses, item properties (track.volume_db,
+// clip.position, or a bare property name), and the functions bar(n)/bars(n)
+// and beat(n)/beats(n) (n bars/beats in seconds), abs(x), min(a, b), max(a, b).
+func (p *FunctionalDSLParser) evaluateExpression(expr string, item map[string]any) (float64, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	parser := &exprParser{tokens: tokens, dsl: p, item: item}
+	value, err := parser.parseSum()
+	if err != nil {
+		return 0, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+	if parser.pos < len(parser.tokens) {
+		return 0, fmt.Errorf("invalid expression %q: unexpected %q", expr, parser.tokens[parser.pos].text)
+	}
+	return value, nil
+}
+
+// exprToken is a lexical token of a numeric expression.
+type exprToken struct {
+	kind byte // 'n' number, 'i' identifier, 'o' operator or punctuation
+	text string
+	num  float64
+}
+
+func tokenizeExpression(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+
+	for i := 0; i < len(expr); {
+		char := expr[i]
+		switch {
+		case char == ' ' || char == '\t':
+			i++
+		case strings.IndexByte("+-*/(),", char) >= 0:
+			tokens = append(tokens, exprToken{kind: 'o', text: string(char)})
+			i++
+		case (char >= '0' && char <= '9') || char == '.':
+			start := i
+			for i < len(expr) && ((expr[i] >= '0' && expr[i] <= '9') || expr[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(expr[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", expr[start:i])
+			}
+			tokens = append(tokens, exprToken{kind: 'n', text: expr[start:i], num: num})
+		case isIdentifierChar(char):
+			start := i
+			for i < len(expr) && (isIdentifierChar(expr[i]) || expr[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: 'i', text: expr[start:i]})
+		default:
+			return nil, fmt.Errorf("unexpected character %q in expression %q", char, expr)
+		}
+	}
+
+	return tokens, nil
+}
+
+// exprParser is a recursive-descent evaluator over expression tokens.
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	dsl    *FunctionalDSLParser
+	item   map[string]any
+}
+
+func (e *exprParser) peek() (exprToken, bool) {
+	if e.pos >= len(e.tokens) {
+		return exprToken{}, false
+	}
+	return e.tokens[e.pos], true
+}
+
+func (e *exprParser) accept(op string) bool {
+	if token, ok := e.peek(); ok && token.kind == 'o' && token.text == op {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+func (e *exprParser) parseSum() (float64, error) {
+	left, err := e.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case e.accept("+"):
+			right, err := e.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case e.accept("-"):
+			right, err := e.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (e *exprParser) parseProduct() (float64, error) {
+	left, err := e.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case e.accept("*"):
+			right, err := e.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			left *= right
+		case e.accept("/"):
+			right, err := e.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (e *exprParser) parseUnary() (float64, error) {
+	if e.accept("-") {
+		value, err := e.parseUnary()
+		return -value, err
+	}
+	if e.accept("+") {
+		return e.parseUnary()
+	}
+	return e.parsePrimary()
+}
+
+func (e *exprParser) parsePrimary() (float64, error) {
+	token, ok := e.peek()
+	if !ok {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	switch token.kind {
+	case 'n':
+		e.pos++
+		return token.num, nil
+	case 'i':
+		e.pos++
+		if e.accept("(") {
+			return e.parseCall(token.text)
+		}
+		return e.property(token.text)
+	}
+
+	if e.accept("(") {
+		value, err := e.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if !e.accept(")") {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		return value, nil
+	}
+
+	return 0, fmt.Errorf("unexpected %q", token.text)
+}
+
+// parseCall evaluates a function call whose opening parenthesis was consumed.
+func (e *exprParser) parseCall(name string) (float64, error) {
+	var args []float64
+	if !e.accept(")") {
+		for {
+			value, err := e.parseSum()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, value)
+			if e.accept(")") {
+				break
+			}
+			if !e.accept(",") {
+				return 0, fmt.Errorf("expected ',' or ')' in %s()", name)
+			}
+		}
+	}
+
+	expectArgs := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s() takes %d argument(s), got %d", name, n, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "bar", "bars":
+		if err := expectArgs(1); err != nil {
+			return 0, err
+		}
+		return args[0] * e.dsl.secondsPerBar(), nil
+	case "beat", "beats":
+		if err := expectArgs(1); err != nil {
+			return 0, err
+		}
+		return args[0] * e.dsl.secondsPerBeat(), nil
+	case "abs":
+		if err := expectArgs(1); err != nil {
+			return 0, err
+		}
+		return math.Abs(args[0]), nil
+	case "min", "max":
+		if err := expectArgs(2); err != nil {
+			return 0, err
+		}
+		if name == "min" {
+			return math.Min(args[0], args[1]), nil
+		}
+		return math.Max(args[0], args[1]), nil
+	default:
+		return 0, fmt.Errorf("unknown function %s()", name)
+	}
+}
+
+// property resolves track.volume_db, clip.position or a bare property name
+// against the current item.
+func (e *exprParser) property(name string) (float64, error) {
+	property := name
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		property = name[dot+1:]
+	}
+
+	if value, ok := getNumericValue(e.item[property]); ok {
+		return value, nil
+	}
+	if value, ok := propertyDefaults[property]; ok {
+		return value, nil
+	}
+	return 0, fmt.Errorf("unknown property %q", name)
+}
@@ -0,0 +1,176 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relativeTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"project": map[string]any{"bpm": 90.0, "time_signature": "3/4"},
+			"tracks": []any{
+				map[string]any{
+					"index": 0, "name": "Drums", "volume_db": -2.0, "pan": 0.8,
+					"clips": []any{
+						map[string]any{"index": 0, "position": 0.0, "length": 2.0, "selected": true},
+						map[string]any{"index": 1, "position": 4.0, "length": 1.0, "selected": false},
+					},
+				},
+				map[string]any{"index": 1, "name": "Bass", "volume_db": 1.5},
+			},
+		},
+	}
+}
+
+func TestFunctionalDSLParser_RelativeUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]any
+	}{
+		{
+			name:    "relative volume on filtered tracks",
+			dslCode: `filter(tracks, track.index >= 0).set_track(volume_db+=-3)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 0, "volume_db": -5.0},
+				{"action": "set_track", "track": 1, "volume_db": -1.5},
+			},
+		},
+		{
+			name:    "pan expression is clamped",
+			dslCode: `track(id=1).set_track(pan=track.pan * 2)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 0, "pan": 1.0},
+			},
+		},
+		{
+			name:    "relative pan uses default when missing from state",
+			dslCode: `track(id=2).set_track(pan-=0.25, mute=true)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 1, "pan": -0.25, "mute": true},
+			},
+		},
+		{
+			name:    "nudge selected clips one bar later",
+			dslCode: `filter(clips, clip.selected == true).move_clip(position+=bar(1))`,
+			want: []map[string]any{
+				{"action": "set_clip_position", "track": 0, "position": 2.0, "old_position": 0.0},
+			},
+		},
+		{
+			name:    "clip length multiplied",
+			dslCode: `track(id=1).set_clip(clip=1, length*=2)`,
+			want: []map[string]any{
+				{"action": "set_clip", "track": 0, "clip": 1, "length": 2.0},
+			},
+		},
+		{
+			name:    "move clip to expression with beats",
+			dslCode: `track(id=1).move_clip(clip=1, position=clip.position - beat(2))`,
+			want: []map[string]any{
+				{"action": "set_clip_position", "track": 0, "clip": 1, "position": 4.0 - 2*60.0/90.0},
+			},
+		},
+		{
+			name:    "absolute literals unchanged",
+			dslCode: `track(id=2).set_track(volume_db=-6)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 1, "volume_db": -6.0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(relativeTestState())
+
+			actions, err := parser.ParseDSL(tt.dslCode)
+			require.NoError(t, err)
+			require.Len(t, actions, len(tt.want))
+			for i := range tt.want {
+				for key, want := range tt.want[i] {
+					if wantNum, ok := want.(float64); ok {
+						assert.InDelta(t, wantNum, actions[i][key], 1e-9, "action %d %s", i, key)
+					} else {
+						assert.Equal(t, want, actions[i][key], "action %d %s", i, key)
+					}
+				}
+				assert.Len(t, actions[i], len(tt.want[i]))
+			}
+		})
+	}
+}
+
+func TestFunctionalDSLParser_RelativeUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{
+			name:    "unknown property in expression",
+			dslCode: `track(id=1).set_track(volume_db=track.loudness - 3)`,
+			wantErr: `unknown property "track.loudness"`,
+		},
+		{
+			name:    "clip not in state",
+			dslCode: `track(id=2).move_clip(clip=0, position+=bar(1))`,
+			wantErr: "clip not found on track 1 in state",
+		},
+		{
+			name:    "non-positive length",
+			dslCode: `track(id=1).set_clip(clip=0, length-=5)`,
+			wantErr: "length must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(relativeTestState())
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+
+	item := map[string]any{"volume_db": -6.0, "position": 8.0}
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-volume_db / 2", 3},
+		{"track.volume_db + 6", 0},
+		{"clip.position + bar(1)", 10},
+		{"beats(3)", 1.5},
+		{"max(volume_db, -3)", -3},
+		{"abs(min(-1, 2))", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parser.evaluateExpression(tt.expr, item)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+
+	_, err = parser.evaluateExpression("1 / 0", item)
+	assert.Error(t, err)
+	_, err = parser.evaluateExpression("foo(1)", item)
+	assert.Error(t, err)
+}
@@ -0,0 +1,288 @@
+package daw
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dslParamOrder is the canonical argument order of each DSL call. Targets and
+// clip references (clip, position, bar) come first, then values. Arguments
+// not listed keep their relative order after the listed ones.
+var dslParamOrder = map[string][]string{
+	"track":             {"id", "selected", "name", "instrument", "index"},
+	"new_clip":          {"bar", "length_bars", "start", "position", "length"},
+	"add_fx":            {"fxname", "instrument"},
+	"add_send":          {"to", "volume_db", "pan"},
+	"set_track":         {"name", "volume_db", "pan", "mute", "solo", "selected", "color", "folder_depth"},
+	"delete_clip":       {"clip", "position", "bar"},
+	"set_clip":          {"clip", "position", "bar", "name", "color", "selected", "length", "fade_in", "fade_out", "gain_db", "mute"},
+	"move_clip":         {"clip", "old_position", "bar", "position"},
+	"set_clip_position": {"clip", "old_position", "bar", "position"},
+	"split_clip":        {"clip", "position", "bar", "at", "at_bar"},
+	"duplicate_clip":    {"clip", "position", "bar", "times", "gap"},
+	"loop_clip":         {"clip", "position", "bar", "to_bar"},
+	"trim_clip":         {"clip", "position", "bar", "start", "end"},
+	"quantize":          {"clip", "position", "bar", "grid", "strength", "swing"},
+	"transpose":         {"clip", "position", "bar", "semitones", "octaves"},
+	"set_velocity":      {"clip", "position", "bar", "velocity"},
+	"humanize":          {"clip", "position", "bar", "timing", "velocity"},
+	"legato":            {"clip", "position", "bar", "gap"},
+	"reverse":           {"clip", "position", "bar"},
+	"render":            {"range", "start_bar", "end_bar", "format", "stems", "sample_rate", "bit_depth", "file"},
+	"set_project":       {"name", "sample_rate", "tempo"},
+	"set_loop":          {"start_bar", "end_bar"},
+	"set_cursor":        {"bar", "position"},
+	"add_automation": {
+		"param", "send", "fx", "fx_param", "curve",
+		"start", "end", "start_bar", "end_bar", "from", "to",
+		"freq", "amplitude", "phase", "attack", "decay", "sustain", "release",
+		"seed", "resolution", "shape", "points",
+	},
+}
+
+// FormatDSL normalises a DSL script: one statement per line, method chains
+// joined onto the statement's line, a single space after commas and around
+// comparison and arithmetic operators, no spaces around argument assignments,
+// and named arguments in canonical order. String literals and numbers are
+// kept as written.
+func FormatDSL(dslCode string) (string, error) {
+	var lines []string
+	for _, statement := range splitDSLStatements(dslCode) {
+		tokens, err := tokenizeDSL(statement.Text)
+		if err != nil {
+			return "", fmt.Errorf("format statement %q: %w", statement.Text, err)
+		}
+		tokens = reorderCallArgs(tokens)
+		lines = append(lines, printDSLTokens(tokens))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// dslTokenKind classifies DSL tokens for formatting.
+type dslTokenKind int
+
+const (
+	dslTokenIdent dslTokenKind = iota
+	dslTokenNumber
+	dslTokenString
+	dslTokenPunct    // ( ) [ ] { } , . @
+	dslTokenAssign   // = += -= *= /=
+	dslTokenOperator // == != <= >= < > + - * /
+)
+
+type dslToken struct {
+	Kind dslTokenKind
+	Text string
+}
+
+// tokenizeDSL splits a statement into tokens, dropping whitespace.
+func tokenizeDSL(code string) ([]dslToken, error) {
+	var tokens []dslToken
+	for i := 0; i < len(code); {
+		char := code[i]
+		switch {
+		case char == ' ' || char == '\t' || char == '\r' || char == '\n':
+			i++
+		case char == '"':
+			end := i + 1
+			for end < len(code) && code[end] != '"' {
+				if code[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(code) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, dslToken{dslTokenString, code[i : end+1]})
+			i = end + 1
+		case char >= '0' && char <= '9':
+			end := i
+			for end < len(code) && (code[end] == '.' || (code[end] >= '0' && code[end] <= '9')) {
+				end++
+			}
+			tokens = append(tokens, dslToken{dslTokenNumber, code[i:end]})
+			i = end
+		case isIdentifierChar(char):
+			end := i
+			for end < len(code) && isIdentifierChar(code[end]) {
+				end++
+			}
+			tokens = append(tokens, dslToken{dslTokenIdent, code[i:end]})
+			i = end
+		case strings.ContainsRune("()[]{},.@", rune(char)):
+			tokens = append(tokens, dslToken{dslTokenPunct, string(char)})
+			i++
+		default:
+			op := code[i : i+1]
+			if i+1 < len(code) && code[i+1] == '=' {
+				op = code[i : i+2]
+			}
+			switch op {
+			case "=", "+=", "-=", "*=", "/=":
+				tokens = append(tokens, dslToken{dslTokenAssign, op})
+			case "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/":
+				tokens = append(tokens, dslToken{dslTokenOperator, op})
+			default:
+				return nil, fmt.Errorf("unexpected character %q at offset %d", char, i)
+			}
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// reorderCallArgs sorts the named arguments of known calls into canonical
+// order. Calls with positional arguments are left alone.
+func reorderCallArgs(tokens []dslToken) []dslToken {
+	result := make([]dslToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		result = append(result, tokens[i])
+		order, known := dslParamOrder[tokens[i].Text]
+		if tokens[i].Kind != dslTokenIdent || !known || i+1 >= len(tokens) || tokens[i+1].Text != "(" {
+			continue
+		}
+
+		closeIndex := matchingToken(tokens, i+1)
+		if closeIndex < 0 {
+			continue
+		}
+		args := splitTokenArgs(tokens[i+2 : closeIndex])
+		if !sortNamedArgs(args, order) {
+			continue
+		}
+
+		result = append(result, tokens[i+1])
+		for j, arg := range args {
+			if j > 0 {
+				result = append(result, dslToken{dslTokenPunct, ","})
+			}
+			result = append(result, reorderCallArgs(arg)...)
+		}
+		result = append(result, tokens[closeIndex])
+		i = closeIndex
+	}
+	return result
+}
+
+// sortNamedArgs sorts args by their position in order. It reports false,
+// leaving args untouched, when any argument is positional.
+func sortNamedArgs(args [][]dslToken, order []string) bool {
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		rank[name] = i
+	}
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0].Kind != dslTokenIdent || arg[1].Kind != dslTokenAssign {
+			return false
+		}
+	}
+
+	position := func(arg []dslToken) int {
+		if r, ok := rank[arg[0].Text]; ok {
+			return r
+		}
+		return len(order)
+	}
+	sort.SliceStable(args, func(a, b int) bool {
+		return position(args[a]) < position(args[b])
+	})
+	return true
+}
+
+// matchingToken returns the index of the bracket closing the one at open.
+func matchingToken(tokens []dslToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		if tokens[i].Kind != dslTokenPunct {
+			continue
+		}
+		switch tokens[i].Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTokenArgs splits call arguments on top-level commas.
+func splitTokenArgs(tokens []dslToken) [][]dslToken {
+	var args [][]dslToken
+	depth := 0
+	start := 0
+	for i, token := range tokens {
+		if token.Kind != dslTokenPunct {
+			continue
+		}
+		switch token.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ",":
+			if depth == 0 {
+				args = append(args, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(tokens) {
+		args = append(args, tokens[start:])
+	}
+	return args
+}
+
+// printDSLTokens renders tokens with canonical spacing.
+func printDSLTokens(tokens []dslToken) string {
+	var b strings.Builder
+	depth := 0
+	for i, token := range tokens {
+		var prev *dslToken
+		if i > 0 {
+			prev = &tokens[i-1]
+		}
+
+		switch {
+		case token.Kind == dslTokenOperator && !isUnaryMinus(token, prev):
+			b.WriteString(" " + token.Text + " ")
+		case token.Kind == dslTokenAssign && depth == 0:
+			// let name = value
+			b.WriteString(" " + token.Text + " ")
+		case token.Kind == dslTokenPunct && token.Text == ",":
+			b.WriteString(", ")
+		case token.Kind == dslTokenIdent && token.Text == "in" && prev != nil && prev.Kind != dslTokenPunct:
+			b.WriteString(" in ")
+		case token.Kind == dslTokenIdent && prev != nil && prev.Kind == dslTokenIdent && prev.Text == "let":
+			b.WriteString(" " + token.Text)
+		default:
+			b.WriteString(token.Text)
+		}
+
+		if token.Kind == dslTokenPunct {
+			switch token.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			}
+		}
+	}
+	return b.String()
+}
+
+// isUnaryMinus reports whether a "-" token negates the following operand.
+func isUnaryMinus(token dslToken, prev *dslToken) bool {
+	if token.Text != "-" {
+		return false
+	}
+	if prev == nil || prev.Kind == dslTokenAssign || prev.Kind == dslTokenOperator {
+		return true
+	}
+	return prev.Kind == dslTokenPunct && prev.Text != ")" && prev.Text != "]" && prev.Text != "}"
+}
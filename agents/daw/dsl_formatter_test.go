@@ -0,0 +1,68 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDSL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "spacing and statements",
+			input: "track( name = \"Bass\" ,instrument=\"Serum\" );track(id=1)\n  .set_track( mute=true )",
+			want:  "track(name=\"Bass\", instrument=\"Serum\")\ntrack(id=1).set_track(mute=true)",
+		},
+		{
+			name:  "canonical argument order",
+			input: `track(id=1).set_track(selected=true, pan=0.5, volume_db=-3).new_clip(length_bars=2, bar=3)`,
+			want:  `track(id=1).set_track(volume_db=-3, pan=0.5, selected=true).new_clip(bar=3, length_bars=2)`,
+		},
+		{
+			name:  "predicates and expressions",
+			input: `filter(tracks,track.volume_db<-6).set_track(volume_db+=-3,pan=track.pan*0.5)`,
+			want:  `filter(tracks, track.volume_db < -6).set_track(volume_db+=-3, pan=track.pan * 0.5)`,
+		},
+		{
+			name:  "let and functions",
+			input: "let  drums=filter(tracks, track.name==\"Drums\")\nfor_each(drums,@normalize_volume(target_db=-6))",
+			want:  "let drums = filter(tracks, track.name == \"Drums\")\nfor_each(drums, @normalize_volume(target_db=-6))",
+		},
+		{
+			name:  "nested calls and literals",
+			input: `filter(clips,clip.length>8).split_clip(at=clip.position+bar(2)); track(id=1).add_automation(points=[{time=0,value=-60},{time=4,value=0}], param="volume")`,
+			want:  "filter(clips, clip.length > 8).split_clip(at=clip.position + bar(2))\ntrack(id=1).add_automation(param=\"volume\", points=[{time=0, value=-60}, {time=4, value=0}])",
+		},
+		{
+			name:  "membership",
+			input: `filter(tracks, track.name in ["Kick","Snare"]).set_track(mute=true)`,
+			want:  `filter(tracks, track.name in ["Kick", "Snare"]).set_track(mute=true)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := FormatDSL(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatted)
+
+			// Formatting is idempotent
+			again, err := FormatDSL(formatted)
+			require.NoError(t, err)
+			assert.Equal(t, formatted, again)
+		})
+	}
+}
+
+func TestFormatDSL_Errors(t *testing.T) {
+	_, err := FormatDSL(`track(name="Bass)`)
+	assert.Error(t, err)
+
+	_, err = FormatDSL(`track(id=1) # comment`)
+	assert.Error(t, err)
+}
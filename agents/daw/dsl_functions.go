@@ -0,0 +1,442 @@
+package daw
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// FunctionValueType is the type of a DSL function argument or return value.
+type FunctionValueType string
+
+const (
+	FunctionTypeAny    FunctionValueType = "any"
+	FunctionTypeNumber FunctionValueType = "number"
+	FunctionTypeString FunctionValueType = "string"
+	FunctionTypeBool   FunctionValueType = "bool"
+	FunctionTypeTrack  FunctionValueType = "track"
+	FunctionTypeClip   FunctionValueType = "clip"
+)
+
+// FunctionParam describes a named argument accepted by a DSL function.
+type FunctionParam struct {
+	Name     string
+	Type     FunctionValueType
+	Required bool
+	Default  any
+}
+
+// FunctionCall is a single invocation of a DSL function on a collection item.
+type FunctionCall struct {
+	Item  any            // Current collection item (track, clip, fx, ...)
+	Index int            // Position of the item in the collection
+	Args  map[string]any // Typed arguments with defaults applied
+	State map[string]any // Current REAPER state (read-only)
+
+	emit func(action map[string]any)
+}
+
+// Emit appends a REAPER action produced by the function.
+func (c *FunctionCall) Emit(action map[string]any) {
+	if c.emit != nil {
+		c.emit(action)
+	}
+}
+
+// Number returns a number argument (0 if missing).
+func (c *FunctionCall) Number(name string) float64 {
+	num, _ := getNumericValue(c.Args[name])
+	return num
+}
+
+// String returns a string argument ("" if missing).
+func (c *FunctionCall) String(name string) string {
+	str, _ := c.Args[name].(string)
+	return str
+}
+
+// Bool returns a bool argument (false if missing).
+func (c *FunctionCall) Bool(name string) bool {
+	b, _ := c.Args[name].(bool)
+	return b
+}
+
+// ItemMap returns the current item as a map, if it is one.
+func (c *FunctionCall) ItemMap() (map[string]any, bool) {
+	itemMap, ok := c.Item.(map[string]any)
+	return itemMap, ok
+}
+
+// TrackIndex returns the track index of the current item.
+// Works for tracks (index) and clips (track).
+func (c *FunctionCall) TrackIndex() (int, bool) {
+	itemMap, ok := c.ItemMap()
+	if !ok {
+		return -1, false
+	}
+	return itemTrackIndex(itemMap)
+}
+
+// DSLFunction is a named function that DSL code references as @name in
+// map() and for_each(), e.g. map(tracks, @get_name) or
+// for_each(tracks, @normalize_volume(target_db=-6)).
+type DSLFunction struct {
+	Name        string
+	Description string
+	Params      []FunctionParam
+	Returns     FunctionValueType
+	Call        func(call *FunctionCall) (any, error)
+}
+
+// FunctionRegistry holds the functions available to DSL function references.
+// It is safe for concurrent use.
+type FunctionRegistry struct {
+	mu        sync.RWMutex
+	functions map[string]DSLFunction
+}
+
+// NewFunctionRegistry creates a registry pre-populated with the built-in functions.
+func NewFunctionRegistry() *FunctionRegistry {
+	registry := &FunctionRegistry{
+		functions: make(map[string]DSLFunction),
+	}
+	for _, fn := range builtinFunctions() {
+		if err := registry.Register(fn); err != nil {
+			log.Printf("⚠️  Failed to register built-in function @%s: %v", fn.Name, err)
+		}
+	}
+	return registry
+}
+
+// Register adds a function to the registry, replacing any function with the same name.
+func (r *FunctionRegistry) Register(fn DSLFunction) error {
+	fn.Name = strings.TrimPrefix(strings.TrimSpace(fn.Name), "@")
+	if fn.Name == "" {
+		return fmt.Errorf("function name is required")
+	}
+	for i := 0; i < len(fn.Name); i++ {
+		if !isIdentifierChar(fn.Name[i]) {
+			return fmt.Errorf("invalid function name %q: must be an identifier", fn.Name)
+		}
+	}
+	if fn.Call == nil {
+		return fmt.Errorf("function @%s has no implementation", fn.Name)
+	}
+	if fn.Returns == "" {
+		fn.Returns = FunctionTypeAny
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.functions[fn.Name]; exists {
+		log.Printf("⚠️  Replacing DSL function @%s", fn.Name)
+	}
+	r.functions[fn.Name] = fn
+	return nil
+}
+
+// Get returns the function registered under name (with or without the @ prefix).
+func (r *FunctionRegistry) Get(name string) (DSLFunction, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.functions[strings.TrimPrefix(name, "@")]
+	return fn, ok
+}
+
+// Names returns the sorted names of all registered functions.
+func (r *FunctionRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.functions))
+	for name := range r.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultFunctionRegistry is shared by all parsers unless overridden with SetFunctionRegistry.
+var defaultFunctionRegistry = NewFunctionRegistry()
+
+// DefaultFunctionRegistry returns the registry used by new parsers.
+func DefaultFunctionRegistry() *FunctionRegistry {
+	return defaultFunctionRegistry
+}
+
+// RegisterFunction registers a host function in the default registry so that
+// DSL generated by the DAW agent can reference it as @name.
+func RegisterFunction(fn DSLFunction) error {
+	return defaultFunctionRegistry.Register(fn)
+}
+
+// functionRef is a parsed function reference: @name or @name(arg=value, ...).
+type functionRef struct {
+	Name string
+	Args gs.Args
+}
+
+// parseFunctionRef parses a raw function reference argument.
+func parseFunctionRef(raw string) (*functionRef, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "@") {
+		return nil, fmt.Errorf("function reference must start with @: %s", raw)
+	}
+	raw = raw[1:]
+
+	ref := &functionRef{Args: make(gs.Args)}
+	parenIndex := strings.Index(raw, "(")
+	if parenIndex < 0 {
+		ref.Name = strings.TrimSpace(raw)
+	} else {
+		ref.Name = strings.TrimSpace(raw[:parenIndex])
+		closeIndex := matchingParen(raw, parenIndex)
+		if closeIndex < 0 {
+			return nil, fmt.Errorf("unclosed parentheses in function reference @%s", raw)
+		}
+		ref.Args = parseCallParams(raw[parenIndex+1 : closeIndex])
+	}
+
+	if ref.Name == "" {
+		return nil, fmt.Errorf("function reference is missing a name")
+	}
+	return ref, nil
+}
+
+// bindArgs converts DSL arguments to typed values, applying defaults and
+// checking required parameters.
+func (fn DSLFunction) bindArgs(args gs.Args) (map[string]any, error) {
+	bound := make(map[string]any, len(fn.Params))
+
+	for name, value := range args {
+		param, ok := fn.param(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown argument %q for @%s", name, fn.Name)
+		}
+		converted, err := convertFunctionArg(param.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q for @%s: %w", name, fn.Name, err)
+		}
+		bound[name] = converted
+	}
+
+	for _, param := range fn.Params {
+		if _, ok := bound[param.Name]; ok {
+			continue
+		}
+		if param.Required {
+			return nil, fmt.Errorf("@%s requires argument %q", fn.Name, param.Name)
+		}
+		if param.Default != nil {
+			bound[param.Name] = param.Default
+		}
+	}
+
+	return bound, nil
+}
+
+func (fn DSLFunction) param(name string) (FunctionParam, bool) {
+	for _, param := range fn.Params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return FunctionParam{}, false
+}
+
+// convertFunctionArg converts a DSL literal to the declared parameter type.
+func convertFunctionArg(valueType FunctionValueType, value gs.Value) (any, error) {
+	switch valueType {
+	case FunctionTypeNumber:
+		if value.Kind != gs.ValueNumber {
+			return nil, fmt.Errorf("expected number, got %s", value.Kind)
+		}
+		return value.Num, nil
+	case FunctionTypeString:
+		if value.Kind != gs.ValueString && value.Kind != gs.ValueIdentifier {
+			return nil, fmt.Errorf("expected string, got %s", value.Kind)
+		}
+		return value.Str, nil
+	case FunctionTypeBool:
+		if value.Kind != gs.ValueBool {
+			return nil, fmt.Errorf("expected bool, got %s", value.Kind)
+		}
+		return value.Bool, nil
+	default:
+		return valueToAny(value), nil
+	}
+}
+
+// checkFunctionResult verifies a function result against its declared return type.
+func checkFunctionResult(valueType FunctionValueType, result any) error {
+	if result == nil {
+		return nil
+	}
+	ok := true
+	switch valueType {
+	case FunctionTypeNumber:
+		_, ok = getNumericValue(result)
+	case FunctionTypeString:
+		_, ok = result.(string)
+	case FunctionTypeBool:
+		_, ok = result.(bool)
+	case FunctionTypeTrack, FunctionTypeClip:
+		_, ok = result.(map[string]any)
+	}
+	if !ok {
+		return fmt.Errorf("expected %s return value, got %T", valueType, result)
+	}
+	return nil
+}
+
+// callFunction invokes fn on a single collection item.
+func (p *FunctionalDSLParser) callFunction(fn DSLFunction, args gs.Args, item any, index int) (any, error) {
+	boundArgs, err := fn.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &FunctionCall{
+		Item:  item,
+		Index: index,
+		Args:  boundArgs,
+		State: p.state,
+		emit: func(action map[string]any) {
+			p.actions = append(p.actions, action)
+		},
+	}
+
+	result, err := fn.Call(call)
+	if err != nil {
+		return nil, fmt.Errorf("@%s: %w", fn.Name, err)
+	}
+	if err := checkFunctionResult(fn.Returns, result); err != nil {
+		return nil, fmt.Errorf("@%s: %w", fn.Name, err)
+	}
+	return result, nil
+}
+
+// lookupFunction resolves a function reference against the parser's registry.
+func (p *FunctionalDSLParser) lookupFunction(raw string) (DSLFunction, *functionRef, error) {
+	ref, err := parseFunctionRef(raw)
+	if err != nil {
+		return DSLFunction{}, nil, err
+	}
+	fn, ok := p.functions.Get(ref.Name)
+	if !ok {
+		// Macros with a track parameter can be applied per item too
+		if macro, isMacro := p.macros.Get(ref.Name); isMacro {
+			if fn, ok := p.macroFunction(macro); ok {
+				return fn, ref, nil
+			}
+			return DSLFunction{}, nil, fmt.Errorf("macro @%s has no track parameter and can't be applied to a collection", ref.Name)
+		}
+		return DSLFunction{}, nil, fmt.Errorf("unknown function @%s (available: %s)", ref.Name, strings.Join(p.functions.Names(), ", "))
+	}
+	return fn, ref, nil
+}
+
+// SetFunctionRegistry sets the registry used to resolve @function references.
+func (p *FunctionalDSLParser) SetFunctionRegistry(registry *FunctionRegistry) {
+	if registry == nil {
+		registry = defaultFunctionRegistry
+	}
+	p.functions = registry
+}
+
+// itemTrackIndex returns the track index of a track (index) or clip (track) map.
+func itemTrackIndex(item map[string]any) (int, bool) {
+	key := "index"
+	if _, isClip := item["track"]; isClip {
+		key = "track"
+	}
+	if num, ok := getNumericValue(item[key]); ok {
+		return int(num), true
+	}
+	return -1, false
+}
+
+// copyItem returns a shallow copy of a collection item with updates applied.
+func copyItem(item map[string]any, updates map[string]any) map[string]any {
+	result := make(map[string]any, len(item)+len(updates))
+	for k, v := range item {
+		result[k] = v
+	}
+	for k, v := range updates {
+		result[k] = v
+	}
+	return result
+}
+
+// setTrackFunction builds a built-in that sets a track property and returns the updated track.
+func setTrackFunction(name, description, property string, value func(call *FunctionCall) any, params ...FunctionParam) DSLFunction {
+	return DSLFunction{
+		Name:        name,
+		Description: description,
+		Params:      params,
+		Returns:     FunctionTypeTrack,
+		Call: func(call *FunctionCall) (any, error) {
+			item, ok := call.ItemMap()
+			if !ok {
+				return nil, fmt.Errorf("expected a track, got %T", call.Item)
+			}
+			trackIndex, ok := call.TrackIndex()
+			if !ok {
+				return nil, fmt.Errorf("item has no track index")
+			}
+			v := value(call)
+			call.Emit(map[string]any{
+				"action": "set_track",
+				"track":  trackIndex,
+				property: v,
+			})
+			return copyItem(item, map[string]any{property: v}), nil
+		},
+	}
+}
+
+// builtinFunctions returns the functions every registry starts with.
+func builtinFunctions() []DSLFunction {
+	return []DSLFunction{
+		{
+			Name:        "get_name",
+			Description: "Returns the item's name",
+			Returns:     FunctionTypeString,
+			Call: func(call *FunctionCall) (any, error) {
+				item, ok := call.ItemMap()
+				if !ok {
+					return nil, fmt.Errorf("expected a map item, got %T", call.Item)
+				}
+				name, _ := item["name"].(string)
+				return name, nil
+			},
+		},
+		{
+			Name:        "get_index",
+			Description: "Returns the item's index",
+			Returns:     FunctionTypeNumber,
+			Call: func(call *FunctionCall) (any, error) {
+				item, ok := call.ItemMap()
+				if !ok {
+					return nil, fmt.Errorf("expected a map item, got %T", call.Item)
+				}
+				if index, ok := getNumericValue(item["index"]); ok {
+					return index, nil
+				}
+				return float64(call.Index), nil
+			},
+		},
+		setTrackFunction("normalize_volume", "Sets the track volume to target_db (default 0 dB)", "volume_db",
+			func(call *FunctionCall) any { return call.Number("target_db") },
+			FunctionParam{Name: "target_db", Type: FunctionTypeNumber, Default: 0.0}),
+		setTrackFunction("reset_pan", "Centers the track pan", "pan",
+			func(call *FunctionCall) any { return 0.0 }),
+		setTrackFunction("mute", "Mutes the track", "mute",
+			func(call *FunctionCall) any { return true }),
+		setTrackFunction("unmute", "Unmutes the track", "mute",
+			func(call *FunctionCall) any { return false }),
+	}
+}
@@ -0,0 +1,165 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func functionTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Drums", "volume_db": -6.0},
+				map[string]any{"index": 1, "name": "Bass", "volume_db": 2.0},
+			},
+		},
+	}
+}
+
+func TestFunctionalDSLParser_MapFunctionRef(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(functionTestState())
+
+	actions, err := parser.ParseDSL(`map(tracks, @get_name); track(name="Names")`)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+
+	assert.Equal(t, []any{"Drums", "Bass"}, parser.data["tracks_mapped"])
+}
+
+func TestFunctionalDSLParser_MapResultFeedsChain(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(functionTestState())
+
+	actions, err := parser.ParseDSL(`map(tracks, @normalize_volume(target_db=-3)).set_track(selected=true)`)
+	require.NoError(t, err)
+
+	want := []map[string]any{
+		{"action": "set_track", "track": 0, "volume_db": -3.0},
+		{"action": "set_track", "track": 1, "volume_db": -3.0},
+		{"action": "set_track", "track": 0, "selected": true},
+		{"action": "set_track", "track": 1, "selected": true},
+	}
+	assert.Equal(t, want, actions)
+}
+
+func TestFunctionalDSLParser_MapResultEndsWithStatement(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(functionTestState())
+
+	actions, err := parser.ParseDSL(`map(tracks, @normalize_volume(target_db=-3)); track(name="New").set_track(mute=true)`)
+	require.NoError(t, err)
+
+	want := []map[string]any{
+		{"action": "set_track", "track": 0, "volume_db": -3.0},
+		{"action": "set_track", "track": 1, "volume_db": -3.0},
+		{"action": "create_track", "index": 2, "name": "New"},
+		{"action": "set_track", "track": 2, "mute": true},
+	}
+	assert.Equal(t, want, actions)
+}
+
+func TestFunctionalDSLParser_ForEachFunctionRef(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(functionTestState())
+
+	actions, err := parser.ParseDSL(`for_each(tracks, @mute)`)
+	require.NoError(t, err)
+
+	want := []map[string]any{
+		{"action": "set_track", "track": 0, "mute": true},
+		{"action": "set_track", "track": 1, "mute": true},
+	}
+	assert.Equal(t, want, actions)
+}
+
+func TestFunctionalDSLParser_HostFunction(t *testing.T) {
+	registry := NewFunctionRegistry()
+	err := registry.Register(DSLFunction{
+		Name: "add_reverb",
+		Params: []FunctionParam{
+			{Name: "fxname", Type: FunctionTypeString, Default: "ReaVerbate"},
+		},
+		Call: func(call *FunctionCall) (any, error) {
+			trackIndex, _ := call.TrackIndex()
+			call.Emit(map[string]any{
+				"action": "add_track_fx",
+				"track":  trackIndex,
+				"fxname": call.String("fxname"),
+			})
+			return nil, nil
+		},
+	})
+	require.NoError(t, err)
+
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetFunctionRegistry(registry)
+	parser.SetState(functionTestState())
+
+	actions, err := parser.ParseDSL(`for_each(tracks, @add_reverb(fxname="ReaVerb"))`)
+	require.NoError(t, err)
+
+	want := []map[string]any{
+		{"action": "add_track_fx", "track": 0, "fxname": "ReaVerb"},
+		{"action": "add_track_fx", "track": 1, "fxname": "ReaVerb"},
+	}
+	assert.Equal(t, want, actions)
+}
+
+func TestFunctionalDSLParser_FunctionRefErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{
+			name:    "unknown function",
+			dslCode: `for_each(tracks, @does_not_exist)`,
+			wantErr: "unknown function @does_not_exist",
+		},
+		{
+			name:    "wrong argument type",
+			dslCode: `for_each(tracks, @normalize_volume(target_db="loud"))`,
+			wantErr: `argument "target_db" for @normalize_volume: expected number`,
+		},
+		{
+			name:    "unknown argument",
+			dslCode: `for_each(tracks, @mute(level=1))`,
+			wantErr: `unknown argument "level" for @mute`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(functionTestState())
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestFunctionRegistry_Register(t *testing.T) {
+	registry := NewFunctionRegistry()
+
+	assert.Error(t, registry.Register(DSLFunction{Name: "", Call: func(*FunctionCall) (any, error) { return nil, nil }}))
+	assert.Error(t, registry.Register(DSLFunction{Name: "bad name", Call: func(*FunctionCall) (any, error) { return nil, nil }}))
+	assert.Error(t, registry.Register(DSLFunction{Name: "no_impl"}))
+
+	require.NoError(t, registry.Register(DSLFunction{Name: "@custom", Call: func(*FunctionCall) (any, error) { return nil, nil }}))
+	fn, ok := registry.Get("@custom")
+	require.True(t, ok)
+	assert.Equal(t, "custom", fn.Name)
+	assert.Equal(t, FunctionTypeAny, fn.Returns)
+	assert.Contains(t, registry.Names(), "get_name")
+}
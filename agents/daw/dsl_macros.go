@@ -0,0 +1,494 @@
+package daw
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxMacroDepth limits how deeply macros can call other macros, which also
+// stops runaway recursion.
+const MaxMacroDepth = 8
+
+// DSLMacro is a named, parameterised DSL snippet, e.g. a user's vocal chain.
+// Scripts call it as a statement, @vocal_chain(target=4, reverb_mix=0.2), or
+// apply it to every track of a collection with for_each(tracks, @vocal_chain).
+//
+// Parameters are bound as variables while the body runs, so the body uses
+// them like let-bound names: target.add_fx(...), set_track(volume_db=gain).
+// Track parameters accept a 1-based track id, a track name or a variable
+// holding a track or collection.
+type DSLMacro struct {
+	Name        string
+	Description string
+	Params      []FunctionParam
+	Body        string // DSL statements
+}
+
+// trackParam returns the first track parameter, which for_each binds to each item.
+func (m DSLMacro) trackParam() (FunctionParam, bool) {
+	for _, param := range m.Params {
+		if param.Type == FunctionTypeTrack {
+			return param, true
+		}
+	}
+	return FunctionParam{}, false
+}
+
+// Signature renders the macro as @name(param: type = default, ...).
+func (m DSLMacro) Signature() string {
+	params := make([]string, len(m.Params))
+	for i, param := range m.Params {
+		params[i] = param.Name
+		if param.Type != "" && param.Type != FunctionTypeAny {
+			params[i] += ": " + string(param.Type)
+		}
+		if param.Default != nil {
+			if literal, err := formatDSLValue(param.Default); err == nil {
+				params[i] += " = " + literal
+			}
+		}
+	}
+	return "@" + m.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// MacroRegistry holds the macros available to DSL scripts.
+// It is safe for concurrent use.
+type MacroRegistry struct {
+	mu     sync.RWMutex
+	macros map[string]DSLMacro
+}
+
+// NewMacroRegistry creates an empty macro registry.
+func NewMacroRegistry() *MacroRegistry {
+	return &MacroRegistry{
+		macros: make(map[string]DSLMacro),
+	}
+}
+
+// Register adds a macro to the registry, replacing any macro with the same name.
+func (r *MacroRegistry) Register(macro DSLMacro) error {
+	macro.Name = strings.TrimPrefix(strings.TrimSpace(macro.Name), "@")
+	if !isDSLIdentifier(macro.Name) {
+		return fmt.Errorf("invalid macro name %q: must be an identifier", macro.Name)
+	}
+	if len(splitDSLStatements(macro.Body)) == 0 {
+		return fmt.Errorf("macro @%s has an empty body", macro.Name)
+	}
+
+	macro.Params = append([]FunctionParam(nil), macro.Params...)
+	seen := make(map[string]bool, len(macro.Params))
+	for i, param := range macro.Params {
+		if !isDSLIdentifier(param.Name) || param.Name == "let" || param.Name == "true" || param.Name == "false" {
+			return fmt.Errorf("macro @%s: invalid parameter name %q", macro.Name, param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("macro @%s: duplicate parameter %q", macro.Name, param.Name)
+		}
+		seen[param.Name] = true
+		if param.Type == "" {
+			macro.Params[i].Type = FunctionTypeAny
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.macros[macro.Name]; exists {
+		log.Printf("⚠️  Replacing DSL macro @%s", macro.Name)
+	}
+	r.macros[macro.Name] = macro
+	return nil
+}
+
+// Load parses macro definitions (see ParseMacros) and registers them.
+func (r *MacroRegistry) Load(source string) error {
+	macros, err := ParseMacros(source)
+	if err != nil {
+		return err
+	}
+	for _, macro := range macros {
+		if err := r.Register(macro); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the macro registered under name (with or without the @ prefix).
+func (r *MacroRegistry) Get(name string) (DSLMacro, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	macro, ok := r.macros[strings.TrimPrefix(name, "@")]
+	return macro, ok
+}
+
+// Names returns the sorted names of all registered macros.
+func (r *MacroRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.macros))
+	for name := range r.macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe lists the registered macros for the model, one per line:
+// "@name(params) - description". Empty when there are no macros.
+func (r *MacroRegistry) Describe() string {
+	var lines []string
+	for _, name := range r.Names() {
+		macro, _ := r.Get(name)
+		line := macro.Signature()
+		if macro.Description != "" {
+			line += " - " + macro.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// defaultMacroRegistry is shared by all parsers unless overridden with SetMacroRegistry.
+var defaultMacroRegistry = NewMacroRegistry()
+
+// DefaultMacroRegistry returns the registry used by new parsers.
+func DefaultMacroRegistry() *MacroRegistry {
+	return defaultMacroRegistry
+}
+
+var macroHeaderPattern = regexp.MustCompile(`^macro\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+
+// ParseMacros parses macro definitions. Comment lines directly above a macro
+// become its description:
+//
+//	// EQ, compression and reverb for lead vocals
+//	macro vocal_chain(target: track, reverb_mix: number = 0.25) {
+//	    target.add_fx(fxname="ReaEQ")
+//	    target.add_fx(fxname="ReaComp")
+//	    target.add_fx(fxname="ReaVerbate")
+//	}
+//
+// Parameter types are track, number, string, bool or any. Without a type the
+// type of the default is used; parameters without a default are required.
+func ParseMacros(source string) ([]DSLMacro, error) {
+	var macros []DSLMacro
+	var comments []string
+
+	rest := source
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			return macros, nil
+		}
+		line := strings.Count(source[:len(source)-len(rest)], "\n") + 1
+
+		if strings.HasPrefix(rest, "//") {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			comments = append(comments, strings.TrimSpace(rest[2:end]))
+			rest = rest[end:]
+			continue
+		}
+
+		header := macroHeaderPattern.FindStringSubmatch(rest)
+		if header == nil {
+			return nil, fmt.Errorf("line %d: expected macro definition", line)
+		}
+		name := header[1]
+
+		open := len(header[0]) - 1
+		closeIndex := matchingParen(rest, open)
+		if closeIndex < 0 {
+			return nil, fmt.Errorf("line %d: macro %s: unclosed parameter list", line, name)
+		}
+		params, err := parseMacroParams(rest[open+1 : closeIndex])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: macro %s: %w", line, name, err)
+		}
+
+		bodyStart := len(rest) - len(strings.TrimLeft(rest[closeIndex+1:], " \t\r\n"))
+		if bodyStart >= len(rest) || rest[bodyStart] != '{' {
+			return nil, fmt.Errorf("line %d: macro %s: expected '{'", line, name)
+		}
+		bodyEnd := matchingBrace(rest, bodyStart)
+		if bodyEnd < 0 {
+			return nil, fmt.Errorf("line %d: macro %s: unclosed body", line, name)
+		}
+
+		macros = append(macros, DSLMacro{
+			Name:        name,
+			Description: strings.Join(comments, " "),
+			Params:      params,
+			Body:        strings.TrimSpace(rest[bodyStart+1 : bodyEnd]),
+		})
+		comments = nil
+		rest = rest[bodyEnd+1:]
+	}
+}
+
+// parseMacroParams parses "target: track, gain: number = -3, name".
+func parseMacroParams(paramsStr string) ([]FunctionParam, error) {
+	var params []FunctionParam
+	for _, part := range splitTopLevel(paramsStr, ',') {
+		param := FunctionParam{Type: FunctionTypeAny}
+		if eqIndex := strings.Index(part, "="); eqIndex >= 0 {
+			literal := parseDSLLiteral(part[eqIndex+1:])
+			param.Default = valueToAny(literal)
+			param.Type = literalType(param.Default)
+			part = strings.TrimSpace(part[:eqIndex])
+		}
+		if colonIndex := strings.Index(part, ":"); colonIndex >= 0 {
+			valueType := FunctionValueType(strings.TrimSpace(part[colonIndex+1:]))
+			switch valueType {
+			case FunctionTypeTrack, FunctionTypeNumber, FunctionTypeString, FunctionTypeBool, FunctionTypeAny:
+				param.Type = valueType
+			default:
+				return nil, fmt.Errorf("unknown parameter type %q", valueType)
+			}
+			part = strings.TrimSpace(part[:colonIndex])
+		}
+		param.Name = part
+		param.Required = param.Default == nil
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// literalType returns the parameter type of a default value.
+func literalType(value any) FunctionValueType {
+	switch value.(type) {
+	case float64:
+		return FunctionTypeNumber
+	case string:
+		return FunctionTypeString
+	case bool:
+		return FunctionTypeBool
+	}
+	return FunctionTypeAny
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or
+// -1 if it is unbalanced. Braces inside strings are ignored.
+func matchingBrace(code string, open int) int {
+	depth := 0
+	inString := false
+	for i := open; i < len(code); i++ {
+		switch char := code[i]; {
+		case inString:
+			if char == '\\' {
+				i++
+			} else if char == '"' {
+				inString = false
+			}
+		case char == '"':
+			inString = true
+		case char == '{':
+			depth++
+		case char == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isDSLIdentifier reports whether name is a valid DSL identifier.
+func isDSLIdentifier(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetMacroRegistry sets the registry used to resolve @macro calls.
+func (p *FunctionalDSLParser) SetMacroRegistry(registry *MacroRegistry) {
+	if registry == nil {
+		registry = defaultMacroRegistry
+	}
+	p.macros = registry
+}
+
+// isMacroCall reports whether a statement is a macro call: @name(...).
+func isMacroCall(statement string) bool {
+	return strings.HasPrefix(statement, "@")
+}
+
+// executeMacroCall runs a top-level @name(arg=value, ...) statement.
+func (p *FunctionalDSLParser) executeMacroCall(ctx context.Context, statement string) error {
+	statement = p.substituteVariables(statement)
+
+	name := statement[1:]
+	var rawArgs []string
+	if open := strings.IndexByte(statement, '('); open >= 0 {
+		closeIndex := matchingParen(statement, open)
+		if closeIndex != len(statement)-1 {
+			return fmt.Errorf("macro call must be a statement of its own: %s", statement)
+		}
+		name = statement[1:open]
+		rawArgs = splitTopLevel(statement[open+1:closeIndex], ',')
+	}
+	name = strings.TrimSpace(name)
+
+	macro, ok := p.macros.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown macro @%s (available: %s)", name, strings.Join(p.macros.Names(), ", "))
+	}
+
+	args := make(map[string]any, len(rawArgs))
+	for _, rawArg := range rawArgs {
+		eqIndex := strings.Index(rawArg, "=")
+		if eqIndex < 0 {
+			return fmt.Errorf("@%s: arguments must be named (name=value): %s", name, rawArg)
+		}
+		argName := strings.TrimSpace(rawArg[:eqIndex])
+		param, ok := macroParam(macro, argName)
+		if !ok {
+			return fmt.Errorf("unknown argument %q for @%s", argName, name)
+		}
+		value, err := p.macroArgValue(param, strings.TrimSpace(rawArg[eqIndex+1:]))
+		if err != nil {
+			return fmt.Errorf("argument %q for @%s: %w", argName, name, err)
+		}
+		args[argName] = value
+	}
+
+	return p.expandMacro(ctx, macro, args)
+}
+
+// macroParam returns the macro parameter with the given name.
+func macroParam(macro DSLMacro, name string) (FunctionParam, bool) {
+	for _, param := range macro.Params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return FunctionParam{}, false
+}
+
+// macroArgValue converts a raw argument of a macro call. Unquoted identifiers
+// refer to variables of the calling script.
+func (p *FunctionalDSLParser) macroArgValue(param FunctionParam, raw string) (any, error) {
+	if isDSLIdentifier(raw) && raw != "true" && raw != "false" {
+		value, ok := p.scope.lookup(raw)
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %s", raw)
+		}
+		switch value.(type) {
+		case trackRef, []any:
+			if param.Type != FunctionTypeTrack && param.Type != FunctionTypeAny {
+				return nil, fmt.Errorf("expected %s, got a track", param.Type)
+			}
+		}
+		return value, nil
+	}
+
+	literal := parseDSLLiteral(raw)
+	if param.Type != FunctionTypeTrack {
+		return convertFunctionArg(param.Type, literal)
+	}
+	return p.resolveMacroTrack(valueToAny(literal))
+}
+
+// resolveMacroTrack converts a 1-based track id or a track name to a track.
+func (p *FunctionalDSLParser) resolveMacroTrack(value any) (trackRef, error) {
+	switch v := value.(type) {
+	case float64:
+		if v < 1 || v != float64(int(v)) {
+			return trackRef{}, fmt.Errorf("track id must be a positive integer (1-based), got %g", v)
+		}
+		return trackRef{Index: int(v) - 1}, nil
+	case string:
+		if index, ok := p.trackIndexByName(v); ok {
+			return trackRef{Index: index}, nil
+		}
+		return trackRef{}, fmt.Errorf("no track named %q", v)
+	}
+	return trackRef{}, fmt.Errorf("expected a track id or name, got %v", value)
+}
+
+// expandMacro runs the body of a macro with its arguments bound as variables.
+// The body sees only its parameters, not the caller's variables, and its own
+// let-bindings stay local to the call.
+func (p *FunctionalDSLParser) expandMacro(ctx context.Context, macro DSLMacro, args map[string]any) error {
+	if len(p.macroStack) >= MaxMacroDepth {
+		return fmt.Errorf("macro recursion limit (%d) exceeded: @%s", MaxMacroDepth, strings.Join(append(p.macroStack, macro.Name), " → @"))
+	}
+
+	scope := newDSLScope()
+	for _, param := range macro.Params {
+		value, ok := args[param.Name]
+		if !ok {
+			if param.Required {
+				return fmt.Errorf("@%s requires argument %q", macro.Name, param.Name)
+			}
+			value = param.Default
+		}
+		scope.set(param.Name, value)
+	}
+
+	p.markExpansion("@" + macro.Name)
+	callerScope := p.scope
+	p.scope = scope
+	p.macroStack = append(p.macroStack, macro.Name)
+	defer func() {
+		p.scope = callerScope
+		p.macroStack = p.macroStack[:len(p.macroStack)-1]
+	}()
+
+	log.Printf("🧩 Expanding macro @%s with %v", macro.Name, args)
+	for _, statement := range splitDSLStatements(macro.Body) {
+		if err := p.executeStatement(ctx, statement.Text); err != nil {
+			return fmt.Errorf("@%s: %w", macro.Name, err)
+		}
+	}
+	return nil
+}
+
+// macroFunction exposes a macro with a track parameter as a DSL function, so
+// for_each(tracks, @vocal_chain) runs it once per track.
+func (p *FunctionalDSLParser) macroFunction(macro DSLMacro) (DSLFunction, bool) {
+	target, ok := macro.trackParam()
+	if !ok {
+		return DSLFunction{}, false
+	}
+
+	var params []FunctionParam
+	for _, param := range macro.Params {
+		if param.Name != target.Name {
+			params = append(params, param)
+		}
+	}
+
+	return DSLFunction{
+		Name:        macro.Name,
+		Description: macro.Description,
+		Params:      params,
+		Returns:     FunctionTypeAny,
+		Call: func(call *FunctionCall) (any, error) {
+			trackIndex, ok := call.TrackIndex()
+			if !ok {
+				return nil, fmt.Errorf("expected a track, got %T", call.Item)
+			}
+			args := make(map[string]any, len(call.Args)+1)
+			for name, value := range call.Args {
+				args[name] = value
+			}
+			args[target.Name] = trackRef{Index: trackIndex}
+			return nil, p.expandMacro(context.Background(), macro, args)
+		},
+	}, true
+}
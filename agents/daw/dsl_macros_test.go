@@ -0,0 +1,169 @@
+package daw
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMacros = `
+// EQ, compression and reverb for lead vocals
+macro vocal_chain(target: track, reverb: string = "ReaVerbate") {
+    target.add_fx(fxname="ReaEQ")
+    target.add_fx(fxname="ReaComp")
+    target.add_fx(fxname=reverb)
+}
+
+// Routes drums to a new bus at the given level
+macro drum_bus(level = -6) {
+    let bus = track(name="Drum Bus")
+    bus.set_track(volume_db=level)
+    @vocal_chain(target=bus, reverb="ReaDelay")
+}
+
+macro forever(target: track) {
+    @forever(target=target)
+}
+`
+
+func macroTestParser(t *testing.T) *FunctionalDSLParser {
+	t.Helper()
+	registry := NewMacroRegistry()
+	require.NoError(t, registry.Load(testMacros))
+
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetMacroRegistry(registry)
+	parser.SetState(map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Lead Vox"},
+				map[string]any{"index": 1, "name": "Backing Vox"},
+				map[string]any{"index": 2, "name": "Drums"},
+			},
+		},
+	})
+	return parser
+}
+
+func TestParseMacros(t *testing.T) {
+	macros, err := ParseMacros(testMacros)
+	require.NoError(t, err)
+	require.Len(t, macros, 3)
+
+	vocal := macros[0]
+	assert.Equal(t, "vocal_chain", vocal.Name)
+	assert.Equal(t, "EQ, compression and reverb for lead vocals", vocal.Description)
+	assert.Equal(t, []FunctionParam{
+		{Name: "target", Type: FunctionTypeTrack, Required: true},
+		{Name: "reverb", Type: FunctionTypeString, Default: "ReaVerbate"},
+	}, vocal.Params)
+	assert.Len(t, splitDSLStatements(vocal.Body), 3)
+	assert.Equal(t, `@vocal_chain(target: track, reverb: string = "ReaVerbate")`, vocal.Signature())
+
+	assert.Equal(t, []FunctionParam{{Name: "level", Type: FunctionTypeNumber, Default: -6.0}}, macros[1].Params)
+	assert.Empty(t, macros[2].Description)
+
+	_, err = ParseMacros("macro broken(target: clipboard) { target.delete() }")
+	assert.ErrorContains(t, err, `unknown parameter type "clipboard"`)
+	_, err = ParseMacros("macro open(target: track) { target.delete()")
+	assert.ErrorContains(t, err, "unclosed body")
+	_, err = ParseMacros("\n\nvocal_chain(target: track) {}")
+	assert.ErrorContains(t, err, "line 3: expected macro definition")
+}
+
+func TestMacroRegistry_Describe(t *testing.T) {
+	registry := NewMacroRegistry()
+	assert.Empty(t, registry.Describe())
+
+	require.NoError(t, registry.Load(testMacros))
+	assert.Equal(t, []string{"drum_bus", "forever", "vocal_chain"}, registry.Names())
+	assert.Equal(t, `@drum_bus(level: number = -6) - Routes drums to a new bus at the given level
+@forever(target: track)
+@vocal_chain(target: track, reverb: string = "ReaVerbate") - EQ, compression and reverb for lead vocals`, registry.Describe())
+
+	assert.Error(t, registry.Register(DSLMacro{Name: "bad name", Body: "track()"}))
+	assert.Error(t, registry.Register(DSLMacro{Name: "empty", Body: " "}))
+}
+
+func TestFunctionalDSLParser_Macros(t *testing.T) {
+	t.Run("track id argument", func(t *testing.T) {
+		actions, err := macroTestParser(t).ParseDSL(`@vocal_chain(target=2)`)
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]any{
+			{"action": "add_track_fx", "track": 1, "fxname": "ReaEQ"},
+			{"action": "add_track_fx", "track": 1, "fxname": "ReaComp"},
+			{"action": "add_track_fx", "track": 1, "fxname": "ReaVerbate"},
+		}, actions)
+	})
+
+	t.Run("track name and variables", func(t *testing.T) {
+		actions, err := macroTestParser(t).ParseDSL("let verb = \"ReaDelay\"\n@vocal_chain(target=\"Lead Vox\", reverb=verb)")
+		require.NoError(t, err)
+		require.Len(t, actions, 3)
+		assert.Equal(t, 0, actions[0]["track"])
+		assert.Equal(t, "ReaDelay", actions[2]["fxname"])
+	})
+
+	t.Run("nested macros", func(t *testing.T) {
+		actions, err := macroTestParser(t).ParseDSL(`@drum_bus(level=-3)`)
+		require.NoError(t, err)
+		require.Len(t, actions, 5)
+		assert.Equal(t, map[string]any{"action": "create_track", "index": 3, "name": "Drum Bus"}, actions[0])
+		assert.Equal(t, map[string]any{"action": "set_track", "track": 3, "volume_db": -3.0}, actions[1])
+		assert.Equal(t, "ReaDelay", actions[4]["fxname"])
+	})
+
+	t.Run("for_each over a collection", func(t *testing.T) {
+		parser := macroTestParser(t)
+		parser.SetSourceMap(true)
+		actions, err := parser.ParseDSL(`let vox = filter(tracks, track.index < 2); for_each(vox, @vocal_chain(reverb="ReaDelay"))`)
+		require.NoError(t, err)
+		require.Len(t, actions, 6)
+		assert.Equal(t, 0, actions[0]["track"])
+		assert.Equal(t, 1, actions[5]["track"])
+		assert.Equal(t, "ReaDelay", actions[5]["fxname"])
+		assert.Equal(t, "for_each", actions[5][ActionSourceKey].(map[string]any)["expanded_from"])
+	})
+
+	t.Run("macro variables stay local", func(t *testing.T) {
+		parser := macroTestParser(t)
+		_, err := parser.ParseDSL(`@drum_bus()`)
+		require.NoError(t, err)
+		_, leaked := parser.Variable("bus")
+		assert.False(t, leaked)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			dslCode string
+			want    string
+		}{
+			{`@reverb_send(target=1)`, "unknown macro @reverb_send (available: drum_bus, forever, vocal_chain)"},
+			{`@vocal_chain(reverb="ReaDelay")`, `@vocal_chain requires argument "target"`},
+			{`@vocal_chain(target=1, wet=0.5)`, `unknown argument "wet" for @vocal_chain`},
+			{`@vocal_chain(target="Bass")`, `no track named "Bass"`},
+			{`@drum_bus(level="loud")`, "expected number"},
+			{`@forever(target=1)`, "macro recursion limit (8) exceeded"},
+		}
+		for _, tt := range tests {
+			_, err := macroTestParser(t).ParseDSL(tt.dslCode)
+			require.Error(t, err, tt.dslCode)
+			assert.Contains(t, err.Error(), tt.want, tt.dslCode)
+		}
+	})
+}
+
+func TestDawAgent_MacroInstructions(t *testing.T) {
+	agent := &DawAgent{macros: NewMacroRegistry(), templates: NewTemplateRegistry()}
+	assert.NotContains(t, agent.getCFGGrammarConfig().Description, "USER MACROS")
+
+	registry := NewMacroRegistry()
+	require.NoError(t, registry.Load(testMacros))
+	agent.SetMacroRegistry(registry)
+	description := agent.getCFGGrammarConfig().Description
+	assert.Contains(t, description, "USER MACROS")
+	assert.True(t, strings.Contains(description, `@vocal_chain(target: track, reverb: string = "ReaVerbate")`))
+}
@@ -0,0 +1,379 @@
+package daw
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// Defaults for humanize() when no amounts are given.
+const (
+	DefaultHumanizeTiming   = 0.05 // beats
+	DefaultHumanizeVelocity = 8
+)
+
+// noteFieldAliases maps the normalized note fields used in filter predicates
+// (note.pitch, note.start, ...) to the names they may have in state.
+var noteFieldAliases = map[string][]string{
+	"pitch":    {"midiNoteNumber", "note"},
+	"velocity": {"vel"},
+	"start":    {"startBeats", "position"},
+	"length":   {"durationBeats", "lengthBeats", "duration"},
+}
+
+// collectNotes builds the notes collection from the note data of clips in
+// state. Each note is tagged with its index within the clip and, when state
+// gives them, the clip's track and index, and gets normalized
+// pitch/velocity/start/length fields (start and length in beats from the clip
+// start). The notes are copies; the state is not changed.
+func collectNotes(clips []any) []any {
+	notes := make([]any, 0)
+	for _, clip := range clips {
+		clipMap, ok := clip.(map[string]any)
+		if !ok {
+			continue
+		}
+		clipNotes, ok := clipMap["notes"].([]any)
+		if !ok {
+			continue
+		}
+		trackIndex, hasTrack := itemTrackIndex(clipMap)
+		clipIndex, hasClip := getNumericValue(clipMap["index"])
+
+		for i, note := range clipNotes {
+			stateNote, ok := note.(map[string]any)
+			if !ok {
+				continue
+			}
+			noteMap := maps.Clone(stateNote)
+			for field, aliases := range noteFieldAliases {
+				if _, ok := noteMap[field]; ok {
+					continue
+				}
+				for _, alias := range aliases {
+					if value, ok := noteMap[alias]; ok {
+						noteMap[field] = value
+						break
+					}
+				}
+			}
+			if hasTrack {
+				noteMap["track"] = trackIndex
+			}
+			if hasClip {
+				noteMap["clip"] = int(clipIndex)
+			}
+			if _, ok := noteMap["index"]; !ok {
+				noteMap["index"] = i
+			}
+			notes = append(notes, noteMap)
+		}
+	}
+	return notes
+}
+
+// isNoteItem reports whether a collection item is a note rather than a clip.
+func isNoteItem(item any) bool {
+	itemMap, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+	_, hasPitch := itemMap["pitch"]
+	return hasPitch
+}
+
+// midiTarget is a clip a MIDI transform applies to, optionally restricted to
+// some of its notes.
+type midiTarget struct {
+	clipTarget
+	Notes []int // Note indexes within the clip, nil for all notes
+}
+
+// newMidiAction builds an action for a MIDI target.
+func (t midiTarget) newMidiAction(action string) map[string]any {
+	result := t.newClipAction(action)
+	if t.Notes != nil {
+		result["notes"] = t.Notes
+	}
+	return result
+}
+
+// midiTargets returns the clips a MIDI transform applies to. A filtered notes
+// collection is grouped by clip so each clip gets one action listing the
+// selected notes; otherwise the targets are resolved like other clip edits.
+func (p *FunctionalDSLParser) midiTargets(method string, args gs.Args) ([]midiTarget, error) {
+	filtered, _ := p.data["current_filtered"].([]any)
+	if len(filtered) == 0 || !isNoteItem(filtered[0]) {
+		clips, _, err := p.clipTargets(method, args)
+		if err != nil {
+			return nil, err
+		}
+		targets := make([]midiTarget, len(clips))
+		for i, clip := range clips {
+			targets[i] = midiTarget{clipTarget: clip}
+		}
+		return targets, nil
+	}
+
+	delete(p.data, "current_filtered")
+	log.Printf("🔍 %s: Filtered collection has %d notes", method, len(filtered))
+
+	type clipKey struct{ track, clip int }
+	var order []clipKey
+	byClip := make(map[clipKey]*midiTarget)
+	for _, item := range filtered {
+		noteMap, ok := item.(map[string]any)
+		if !ok || !isNoteItem(noteMap) {
+			log.Printf("⚠️  %s: Item is not a note: %+v", method, item)
+			continue
+		}
+		trackIndex, hasTrack := getNumericValue(noteMap["track"])
+		clipIndex, hasClip := getNumericValue(noteMap["clip"])
+		if !hasTrack || !hasClip {
+			return nil, fmt.Errorf("%s: note %v is in a clip without a track or clip index", method, noteMap["pitch"])
+		}
+		noteIndex, hasIndex := getNumericValue(noteMap["index"])
+		if !hasIndex {
+			log.Printf("⚠️  %s: Could not identify note (no index): %+v", method, noteMap)
+			continue
+		}
+
+		key := clipKey{int(trackIndex), int(clipIndex)}
+		target, ok := byClip[key]
+		if !ok {
+			target = &midiTarget{
+				clipTarget: clipTarget{
+					Track: key.track,
+					ID:    map[string]any{"clip": key.clip},
+				},
+				Notes: []int{},
+			}
+			byClip[key] = target
+			order = append(order, key)
+		}
+		target.Notes = append(target.Notes, int(noteIndex))
+	}
+
+	targets := make([]midiTarget, 0, len(order))
+	for _, key := range order {
+		targets = append(targets, *byClip[key])
+	}
+	return targets, nil
+}
+
+// parseGrid converts a quantize grid to beats. Accepts note values such as
+// "1/16", "1/8t" (triplet) and "1/4d" (dotted), or a number of beats.
+func parseGrid(value gs.Value) (float64, error) {
+	switch value.Kind {
+	case gs.ValueNumber:
+		if value.Num <= 0 {
+			return 0, fmt.Errorf("grid must be positive (got %g)", value.Num)
+		}
+		return value.Num, nil
+	case gs.ValueString, gs.ValueIdentifier:
+		grid := strings.ToLower(strings.TrimSpace(value.Str))
+		multiplier := 1.0
+		switch {
+		case strings.HasSuffix(grid, "t"):
+			multiplier = 2.0 / 3.0
+			grid = strings.TrimSuffix(grid, "t")
+		case strings.HasSuffix(grid, "d"):
+			multiplier = 1.5
+			grid = strings.TrimSuffix(grid, "d")
+		}
+		numerator, denominator, found := strings.Cut(grid, "/")
+		if !found {
+			return 0, fmt.Errorf("invalid grid %q (expected a note value like \"1/16\")", value.Str)
+		}
+		num, err1 := strconv.ParseFloat(numerator, 64)
+		den, err2 := strconv.ParseFloat(denominator, 64)
+		if err1 != nil || err2 != nil || num <= 0 || den <= 0 {
+			return 0, fmt.Errorf("invalid grid %q (expected a note value like \"1/16\")", value.Str)
+		}
+		// A whole note is four beats
+		return 4 * num / den * multiplier, nil
+	default:
+		return 0, fmt.Errorf("grid must be a note value like \"1/16\" or a number of beats")
+	}
+}
+
+// unitArg reads an optional argument that must be in [0, 1].
+func unitArg(args gs.Args, name string, defaultValue float64) (float64, error) {
+	value, ok := args[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	if value.Kind != gs.ValueNumber || value.Num < 0 || value.Num > 1 {
+		return 0, fmt.Errorf("%s must be a number between 0 and 1", name)
+	}
+	return value.Num, nil
+}
+
+// applyMidiTransform emits one action per target with the given parameters.
+func (p *FunctionalDSLParser) applyMidiTransform(method, action string, args gs.Args, params map[string]any) error {
+	targets, err := p.midiTargets(method, args)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		result := target.newMidiAction(action)
+		for k, v := range params {
+			result[k] = v
+		}
+		p.actions = append(p.actions, result)
+	}
+	log.Printf("✅ %s: Processed %d clips", method, len(targets))
+	return nil
+}
+
+// Quantize handles .quantize() calls to snap notes to a grid.
+// grid is a note value ("1/16", "1/8t") or beats; strength and swing are 0-1.
+// Example: filter(clips, clip.selected == true).quantize(grid="1/16", strength=0.8)
+func (r *ReaperDSL) Quantize(args gs.Args) error {
+	p := r.parser
+
+	gridValue, ok := args["grid"]
+	if !ok {
+		gridValue = gs.Value{Kind: gs.ValueString, Str: "1/16"}
+	}
+	grid, err := parseGrid(gridValue)
+	if err != nil {
+		return fmt.Errorf("quantize: %w", err)
+	}
+	strength, err := unitArg(args, "strength", 1)
+	if err != nil {
+		return fmt.Errorf("quantize: %w", err)
+	}
+	swing, err := unitArg(args, "swing", 0)
+	if err != nil {
+		return fmt.Errorf("quantize: %w", err)
+	}
+
+	params := map[string]any{"grid": grid, "strength": strength}
+	if swing > 0 {
+		params["swing"] = swing
+	}
+	return p.applyMidiTransform("quantize", "quantize_notes", args, params)
+}
+
+// Transpose handles .transpose() calls to shift note pitches by semitones
+// and/or octaves.
+// Example: track(id=2).transpose(clip=0, semitones=7)
+func (r *ReaperDSL) Transpose(args gs.Args) error {
+	p := r.parser
+
+	semitones := 0.0
+	for name, scale := range map[string]float64{"semitones": 1, "octaves": 12} {
+		if value, ok := args[name]; ok {
+			if value.Kind != gs.ValueNumber || value.Num != math.Trunc(value.Num) {
+				return fmt.Errorf("transpose: %s must be a whole number", name)
+			}
+			semitones += value.Num * scale
+		}
+	}
+	if semitones == 0 {
+		return fmt.Errorf("transpose requires a non-zero semitones or octaves")
+	}
+	if math.Abs(semitones) > 127 {
+		return fmt.Errorf("transpose: %d semitones is out of MIDI range", int(semitones))
+	}
+
+	return p.applyMidiTransform("transpose", "transpose_notes", args, map[string]any{"semitones": int(semitones)})
+}
+
+// SetVelocity handles .set_velocity() calls. velocity=N sets an absolute
+// velocity (1-127); velocity+=N/-=N offsets and velocity*=N//=N scales each
+// note's velocity.
+// Example: filter(notes, note.pitch == 42).set_velocity(velocity+=15)
+func (r *ReaperDSL) SetVelocity(args gs.Args) error {
+	p := r.parser
+
+	updates, err := parsePropertyUpdates(args, "velocity")
+	if err != nil {
+		return fmt.Errorf("set_velocity: %w", err)
+	}
+	if len(updates) == 0 {
+		return fmt.Errorf("set_velocity requires velocity")
+	}
+	update := updates[0]
+	if update.Expr != "" {
+		return fmt.Errorf("set_velocity: velocity must be a number")
+	}
+
+	params := make(map[string]any)
+	switch update.Op {
+	case "=":
+		params["velocity"] = int(math.Max(1, math.Min(127, math.Round(update.Value))))
+	case "+=":
+		params["offset"] = int(math.Round(update.Value))
+	case "-=":
+		params["offset"] = -int(math.Round(update.Value))
+	case "*=":
+		params["scale"] = update.Value
+	case "/=":
+		if update.Value == 0 {
+			return fmt.Errorf("set_velocity: velocity/=0: division by zero")
+		}
+		params["scale"] = 1 / update.Value
+	}
+	if scale, ok := params["scale"].(float64); ok && scale <= 0 {
+		return fmt.Errorf("set_velocity: scale must be positive (got %g)", scale)
+	}
+
+	return p.applyMidiTransform("set_velocity", "set_note_velocity", args, params)
+}
+
+// Humanize handles .humanize() calls to randomize note timing (in beats) and
+// velocity by up to the given amounts.
+// Example: track(id=1).humanize(clip=0, timing=0.03, velocity=10)
+func (r *ReaperDSL) Humanize(args gs.Args) error {
+	p := r.parser
+
+	timing := DefaultHumanizeTiming
+	if value, ok := args["timing"]; ok {
+		if value.Kind != gs.ValueNumber || value.Num < 0 {
+			return fmt.Errorf("humanize: timing must be a non-negative number of beats")
+		}
+		timing = value.Num
+	}
+	velocity := DefaultHumanizeVelocity
+	if value, ok := args["velocity"]; ok {
+		if value.Kind != gs.ValueNumber || value.Num < 0 || value.Num > 127 {
+			return fmt.Errorf("humanize: velocity must be between 0 and 127")
+		}
+		velocity = int(math.Round(value.Num))
+	}
+	if timing == 0 && velocity == 0 {
+		return fmt.Errorf("humanize requires a non-zero timing or velocity")
+	}
+
+	return p.applyMidiTransform("humanize", "humanize_notes", args, map[string]any{"timing": timing, "velocity": velocity})
+}
+
+// Legato handles .legato() calls to extend each note to the start of the
+// next, leaving gap beats between them (default 0).
+// Example: filter(clips, clip.name == "Pad").legato()
+func (r *ReaperDSL) Legato(args gs.Args) error {
+	p := r.parser
+
+	gap := 0.0
+	if value, ok := args["gap"]; ok {
+		if value.Kind != gs.ValueNumber || value.Num < 0 {
+			return fmt.Errorf("legato: gap must be a non-negative number of beats")
+		}
+		gap = value.Num
+	}
+
+	return p.applyMidiTransform("legato", "legato_notes", args, map[string]any{"gap": gap})
+}
+
+// Reverse handles .reverse() calls to reverse the order of notes in time.
+// Example: track(id=3).reverse(clip=1)
+func (r *ReaperDSL) Reverse(args gs.Args) error {
+	return r.parser.applyMidiTransform("reverse", "reverse_notes", args, nil)
+}
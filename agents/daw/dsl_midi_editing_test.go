@@ -0,0 +1,231 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func midiEditingTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{
+					"index": 0, "name": "Drums",
+					"clips": []any{
+						map[string]any{
+							"index": 0, "position": 0.0, "length": 4.0, "selected": true,
+							"notes": []any{
+								map[string]any{"midiNoteNumber": 36, "velocity": 110, "startBeats": 0.0, "durationBeats": 0.5},
+								map[string]any{"midiNoteNumber": 42, "velocity": 70, "startBeats": 0.5, "durationBeats": 0.25},
+								map[string]any{"midiNoteNumber": 42, "velocity": 60, "startBeats": 1.5, "durationBeats": 0.25},
+							},
+						},
+					},
+				},
+				map[string]any{
+					"index": 1, "name": "Pad",
+					"clips": []any{
+						map[string]any{"index": 0, "position": 0.0, "length": 8.0, "selected": false},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestFunctionalDSLParser_MidiEditing(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]any
+	}{
+		{
+			name:    "quantize single clip",
+			dslCode: `track(id=2).quantize(clip=0, grid="1/16", strength=0.75)`,
+			want: []map[string]any{
+				{"action": "quantize_notes", "track": 1, "clip": 0, "grid": 0.25, "strength": 0.75},
+			},
+		},
+		{
+			name:    "quantize to triplets with swing",
+			dslCode: `filter(clips, clip.selected == true).quantize(grid="1/8t", swing=0.5)`,
+			want: []map[string]any{
+				{"action": "quantize_notes", "track": 0, "position": 0.0, "grid": 1.0 / 3.0, "strength": 1.0, "swing": 0.5},
+			},
+		},
+		{
+			name:    "transpose up a fifth",
+			dslCode: `track(id=2).transpose(clip=0, semitones=7)`,
+			want: []map[string]any{
+				{"action": "transpose_notes", "track": 1, "clip": 0, "semitones": 7},
+			},
+		},
+		{
+			name:    "transpose down an octave",
+			dslCode: `track(id=2).transpose(bar=1, octaves=-1)`,
+			want: []map[string]any{
+				{"action": "transpose_notes", "track": 1, "bar": 1, "semitones": -12},
+			},
+		},
+		{
+			name:    "raise hi-hat velocities",
+			dslCode: `filter(notes, note.pitch == 42).set_velocity(velocity+=15)`,
+			want: []map[string]any{
+				{"action": "set_note_velocity", "track": 0, "clip": 0, "notes": []int{1, 2}, "offset": 15},
+			},
+		},
+		{
+			name:    "absolute velocity is clamped",
+			dslCode: `track(id=1).set_velocity(clip=0, velocity=200)`,
+			want: []map[string]any{
+				{"action": "set_note_velocity", "track": 0, "clip": 0, "velocity": 127},
+			},
+		},
+		{
+			name:    "humanize with defaults",
+			dslCode: `track(id=1).humanize(clip=0)`,
+			want: []map[string]any{
+				{"action": "humanize_notes", "track": 0, "clip": 0, "timing": DefaultHumanizeTiming, "velocity": DefaultHumanizeVelocity},
+			},
+		},
+		{
+			name:    "legato the pad",
+			dslCode: `filter(clips, clip.length > 6).legato()`,
+			want: []map[string]any{
+				{"action": "legato_notes", "track": 1, "position": 0.0, "gap": 0.0},
+			},
+		},
+		{
+			name:    "reverse selected notes",
+			dslCode: `filter(notes, note.start > 0.25).reverse()`,
+			want: []map[string]any{
+				{"action": "reverse_notes", "track": 0, "clip": 0, "notes": []int{1, 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(midiEditingTestState())
+
+			actions, err := parser.ParseDSL(tt.dslCode)
+			require.NoError(t, err)
+			require.Len(t, actions, len(tt.want))
+			for i := range tt.want {
+				for key, want := range tt.want[i] {
+					if wantNum, ok := want.(float64); ok {
+						assert.InDelta(t, wantNum, actions[i][key], 1e-9, "action %d %s", i, key)
+					} else {
+						assert.Equal(t, want, actions[i][key], "action %d %s", i, key)
+					}
+				}
+				assert.Len(t, actions[i], len(tt.want[i]))
+			}
+		})
+	}
+}
+
+func TestFunctionalDSLParser_NotesCollection(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(midiEditingTestState())
+
+	notes, ok := parser.data["notes"].([]any)
+	require.True(t, ok)
+	require.Len(t, notes, 3)
+
+	note := notes[1].(map[string]any)
+	assert.Equal(t, 42, note["pitch"])
+	assert.Equal(t, 0.5, note["start"])
+	assert.Equal(t, 0.25, note["length"])
+	assert.Equal(t, 0, note["track"])
+	assert.Equal(t, 0, note["clip"])
+	assert.Equal(t, 1, note["index"])
+
+	// The state's note maps are left as they were
+	state := midiEditingTestState()
+	parser.SetState(state)
+	_, err = parser.ParseDSL(`filter(notes, note.pitch == 42).transpose(semitones=12)`)
+	require.NoError(t, err)
+	assert.Equal(t, midiEditingTestState(), state)
+}
+
+func TestFunctionalDSLParser_NotesWithoutClipIndex(t *testing.T) {
+	state := midiEditingTestState()
+	tracks := state["state"].(map[string]any)["tracks"].([]any)
+	delete(tracks[0].(map[string]any)["clips"].([]any)[0].(map[string]any), "index")
+
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(state)
+
+	// The notes' clip is unknown, so they are not edited in whatever clip 0 is
+	_, err = parser.ParseDSL(`filter(notes, note.pitch == 42).transpose(semitones=12)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without a track or clip index")
+}
+
+func TestFunctionalDSLParser_MidiEditingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{
+			name:    "invalid grid",
+			dslCode: `track(id=1).quantize(clip=0, grid="sixteenth")`,
+			wantErr: "invalid grid",
+		},
+		{
+			name:    "transpose needs an amount",
+			dslCode: `track(id=1).transpose(clip=0, semitones=0)`,
+			wantErr: "non-zero semitones or octaves",
+		},
+		{
+			name:    "strength out of range",
+			dslCode: `track(id=1).quantize(clip=0, strength=2)`,
+			wantErr: "strength must be a number between 0 and 1",
+		},
+		{
+			name:    "no clip context",
+			dslCode: `track(id=1).legato()`,
+			wantErr: "legato requires one of: clip (index), position (seconds), or bar (number)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(midiEditingTestState())
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestParseGrid(t *testing.T) {
+	tests := []struct {
+		value gs.Value
+		want  float64
+	}{
+		{gs.Value{Kind: gs.ValueString, Str: "1/4"}, 1},
+		{gs.Value{Kind: gs.ValueString, Str: "1/16"}, 0.25},
+		{gs.Value{Kind: gs.ValueString, Str: "1/8d"}, 0.75},
+		{gs.Value{Kind: gs.ValueString, Str: "1/4T"}, 2.0 / 3.0},
+		{gs.Value{Kind: gs.ValueNumber, Num: 0.5}, 0.5},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGrid(tt.value)
+		require.NoError(t, err)
+		assert.InDelta(t, tt.want, got, 1e-9)
+	}
+}
@@ -996,6 +996,7 @@ func TestAutomation(t *testing.T) {
 					"amplitude": 0.8,
 					"start":     0.0,
 					"end":       8.0,
+					"shape":     shapeSquare,
 				},
 			},
 			wantErr: false,
@@ -1043,6 +1044,16 @@ func TestAutomation(t *testing.T) {
 				t.Errorf("ParseDSL() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			// Rendered curve points are covered by TestRenderAutomationCurves
+			for _, action := range got {
+				if action["action"] != "add_automation" {
+					continue
+				}
+				if points, ok := action["points"].([]map[string]any); !ok || len(points) < 2 {
+					t.Errorf("ParseDSL() curve action has no rendered points: %v", action)
+				}
+				delete(action, "points")
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseDSL() = %v, want %v", got, tt.want)
 			}
@@ -4,16 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"strconv"
 	"strings"
 
 	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
 )
 
 // FunctionalDSLParser parses MAGDA DSL code with functional method support.
 // Uses Grammar School Engine for parsing and supports filter, map, etc.
 type FunctionalDSLParser struct {
 	engine            *gs.Engine
+	callParser        gs.Parser // Parses statements with a variable receiver into call chains
 	reaperDSL         *ReaperDSL
 	currentTrackIndex int
 	trackCounter      int
@@ -21,6 +24,18 @@ type FunctionalDSLParser struct {
 	data              map[string]any // Storage for collections
 	iterationContext  map[string]any // Current iteration variables (track, fx, clip, etc.)
 	actions           []map[string]any
+	functions         *FunctionRegistry // Functions available to @name references
+	macros            *MacroRegistry    // Macros available to @name(...) statements
+	templates         *TemplateRegistry // Track templates available to template(...) statements
+	macroStack        []string          // Macros being expanded, outermost first
+	currentStatement  string            // Raw text of the statement being executed
+	scope             *dslScope         // let-bound variables of the current script
+	lastCollection    []any             // Collection produced by the last filter/map call
+	checker           *diagnostics.Checker
+	diags             []diagnostics.Diagnostic // Diagnostics of the last script
+	sourceMap         bool                     // Attach the producing statement to each action
+	expansion         string                   // Collection call or variable expanded by the current statement
+	expansionStart    int                      // Index of the first action emitted after the expansion began
 }
 
 // ReaperDSL implements the DSL methods for REAPER operations.
@@ -37,6 +52,10 @@ func NewFunctionalDSLParser() (*FunctionalDSLParser, error) {
 		data:              make(map[string]any),
 		iterationContext:  make(map[string]any),
 		actions:           make([]map[string]any, 0),
+		functions:         defaultFunctionRegistry,
+		macros:            defaultMacroRegistry,
+		templates:         defaultTemplateRegistry,
+		scope:             newDSLScope(),
 	}
 
 	parser.reaperDSL.parser = parser
@@ -54,6 +73,8 @@ func NewFunctionalDSLParser() (*FunctionalDSLParser, error) {
 	}
 
 	parser.engine = engine
+	parser.callParser = larkParser
+	parser.checker = diagnostics.NewChecker(grammar, parser.reaperDSL)
 
 	return parser, nil
 }
@@ -83,8 +104,11 @@ func (p *FunctionalDSLParser) SetState(state map[string]any) {
 						}
 						for _, clip := range clips {
 							if clipMap, ok := clip.(map[string]any); ok {
-								// Ensure clip has track reference
+								// Ensure clip has track reference, on a copy
+								// so the state is left as it was
+								clipMap = maps.Clone(clipMap)
 								clipMap["track"] = trackIndex
+								clip = clipMap
 							}
 							allClips = append(allClips, clip)
 						}
@@ -100,6 +124,13 @@ func (p *FunctionalDSLParser) SetState(state map[string]any) {
 		if clips, ok := stateMap["clips"].([]any); ok {
 			p.data["clips"] = clips
 		}
+		// Notes from clips with note data, for filter(notes, note.pitch < 48)
+		if clips, ok := p.data["clips"].([]any); ok {
+			if notes := collectNotes(clips); len(notes) > 0 {
+				p.data["notes"] = notes
+				log.Printf("📦 Extracted %d notes into global notes collection", len(notes))
+			}
+		}
 	}
 }
 
@@ -129,30 +160,59 @@ func (p *FunctionalDSLParser) ParseDSL(dslCode string) ([]map[string]any, error)
 		return nil, fmt.Errorf("empty DSL code")
 	}
 
-	// Reset actions for new parse
-	p.actions = make([]map[string]any, 0)
-	p.currentTrackIndex = -1
+	p.reset()
 
-	// Initialize trackCounter based on existing tracks in state
-	// This ensures new tracks are created at the correct index
-	p.trackCounter = p.getExistingTrackCount()
-
-	p.clearIterationContext()
+	// Syntax errors and unknown methods stop the script before anything runs;
+	// unknown parameters and track names are kept as warnings
+	p.diags = append(p.checker.Check(dslCode), p.trackNameDiagnostics(dslCode, 0, len(dslCode))...)
+	if first, ok := diagnostics.First(p.diags); ok {
+		return nil, diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %s", first))
+	}
+	for _, diag := range p.diags {
+		log.Printf("⚠️  DSL %s", diag)
+	}
 
-	// Execute DSL code using Grammar School Engine
+	// Execute DSL code statement by statement using Grammar School Engine.
+	// The raw statement is kept so functional calls can recover positional
+	// arguments that Grammar School collapses into a single key.
 	ctx := context.Background()
-	if err := p.engine.Execute(ctx, dslCode); err != nil {
-		return nil, fmt.Errorf("failed to execute DSL: %w", err)
+	for index, statement := range splitDSLStatements(dslCode) {
+		first := len(p.actions)
+		p.expansion = ""
+		if err := p.executeStatement(ctx, statement.Text); err != nil {
+			diag := p.checker.Explain(dslCode, statement.Start, statement.End, err)
+			return nil, p.failParse(dslCode, diag, fmt.Errorf("failed to execute DSL: %w", err))
+		}
+		if p.sourceMap {
+			p.annotateActions(dslCode, index, statement, first)
+		}
 	}
 
 	if len(p.actions) == 0 {
-		return nil, fmt.Errorf("no actions found in DSL code")
+		err := fmt.Errorf("no actions found in DSL code")
+		return nil, p.failParse(dslCode, p.checker.Explain(dslCode, 0, len(dslCode), err), err)
 	}
 
 	log.Printf("✅ Functional DSL Parser: Translated %d actions from DSL", len(p.actions))
 	return p.actions, nil
 }
 
+// reset prepares the parser for a new script.
+func (p *FunctionalDSLParser) reset() {
+	// Reset actions for new parse
+	p.actions = make([]map[string]any, 0)
+	p.currentTrackIndex = -1
+
+	// Initialize trackCounter based on existing tracks in state
+	// This ensures new tracks are created at the correct index
+	p.trackCounter = p.getExistingTrackCount()
+
+	p.clearIterationContext()
+
+	// Variables are scoped to a single script
+	p.scope = newDSLScope()
+}
+
 // setIterationContext sets the current iteration variables.
 func (p *FunctionalDSLParser) setIterationContext(context map[string]any) {
 	p.iterationContext = context
@@ -182,6 +242,18 @@ func (p *FunctionalDSLParser) getIterVarFromCollection(collectionName string) st
 
 // resolveCollection resolves a collection name to actual data.
 func (p *FunctionalDSLParser) resolveCollection(name string) ([]any, error) {
+	// Check let-bound variables first so they can shadow stored collections
+	if value, ok := p.scope.lookup(name); ok {
+		switch v := value.(type) {
+		case []any:
+			return v, nil
+		case trackRef:
+			return []any{p.trackItem(v.Index)}, nil
+		default:
+			return nil, fmt.Errorf("variable %s is not a collection", name)
+		}
+	}
+
 	// Check if it's in data storage
 	if collection, ok := p.data[name]; ok {
 		if list, ok := collection.([]any); ok {
@@ -391,14 +463,19 @@ func (r *ReaperDSL) SetTrack(args gs.Args) error {
 		actionProps["name"] = nameValue.Str
 	}
 
-	// Handle volume_db
-	if volumeValue, ok := args["volume_db"]; ok && volumeValue.Kind == gs.ValueNumber {
-		actionProps["volume_db"] = volumeValue.Num
+	// Handle volume_db and pan: literals, relative operators (volume_db+=-3)
+	// and expressions over the track's current values (pan=track.pan * 0.5)
+	updates, err := parsePropertyUpdates(args, "volume_db", "pan")
+	if err != nil {
+		return fmt.Errorf("set_track: %w", err)
 	}
-
-	// Handle pan
-	if panValue, ok := args["pan"]; ok && panValue.Kind == gs.ValueNumber {
-		actionProps["pan"] = panValue.Num
+	var dynamicUpdates []propertyUpdate
+	for _, update := range updates {
+		if update.isStatic() {
+			actionProps[update.Property] = update.Value
+		} else {
+			dynamicUpdates = append(dynamicUpdates, update)
+		}
 	}
 
 	// Handle mute
@@ -439,9 +516,17 @@ func (r *ReaperDSL) SetTrack(args gs.Args) error {
 		actionProps["color"] = color
 	}
 
+	// Handle folder_depth: 1 opens a folder, -N closes N folder levels after this track
+	if folderValue, ok := args["folder_depth"]; ok {
+		if folderValue.Kind != gs.ValueNumber || folderValue.Num != float64(int(folderValue.Num)) {
+			return fmt.Errorf("folder_depth must be an integer")
+		}
+		actionProps["folder_depth"] = int(folderValue.Num)
+	}
+
 	// Must have at least one property
-	if len(actionProps) == 0 {
-		return fmt.Errorf("set_track requires at least one property: name, volume_db, pan, mute, solo, selected, or color")
+	if len(actionProps) == 0 && len(dynamicUpdates) == 0 {
+		return fmt.Errorf("set_track requires at least one property: name, volume_db, pan, mute, solo, selected, color, or folder_depth")
 	}
 
 	// Check if we have a filtered collection to apply to
@@ -475,6 +560,9 @@ func (r *ReaperDSL) SetTrack(args gs.Args) error {
 					for k, v := range actionProps {
 						action[k] = v
 					}
+					if err := p.applyPropertyUpdates(action, dynamicUpdates, trackMap); err != nil {
+						return fmt.Errorf("set_track on track %d: %w", trackIndex, err)
+					}
 
 					log.Printf("✅ SetTrack: Adding action for track %d, props=%+v", trackIndex, actionProps)
 					p.actions = append(p.actions, action)
@@ -499,6 +587,9 @@ func (r *ReaperDSL) SetTrack(args gs.Args) error {
 	for k, v := range actionProps {
 		action[k] = v
 	}
+	if err := p.applyPropertyUpdates(action, dynamicUpdates, p.trackItem(p.currentTrackIndex)); err != nil {
+		return fmt.Errorf("set_track on track %d: %w", p.currentTrackIndex, err)
+	}
 
 	p.actions = append(p.actions, action)
 	return nil
@@ -682,7 +773,7 @@ func (r *ReaperDSL) DeleteClip(args gs.Args) error {
 	return nil
 }
 
-// SetClip handles .set_clip() calls to set clip properties (name, color, selected, etc.).
+// SetClip handles .set_clip() calls to set clip properties (name, color, selected, length, fades, gain, mute).
 // If there's a filtered collection, applies to all clips; otherwise uses currentTrackIndex.
 func (r *ReaperDSL) SetClip(args gs.Args) error {
 	p := r.parser
@@ -723,14 +814,33 @@ func (r *ReaperDSL) SetClip(args gs.Args) error {
 		actionProps["selected"] = selectedValue.Bool
 	}
 
-	// Handle length
-	if lengthValue, ok := args["length"]; ok && lengthValue.Kind == gs.ValueNumber {
-		actionProps["length"] = lengthValue.Num
+	// Handle mute
+	if muteValue, ok := args["mute"]; ok && muteValue.Kind == gs.ValueBool {
+		actionProps["mute"] = muteValue.Bool
+	}
+
+	// Handle numeric properties: literal, relative (length*=2, gain_db+=-3) or
+	// expression (length=bar(2), fade_in=beat(1))
+	updates, err := parsePropertyUpdates(args, "length", "fade_in", "fade_out", "gain_db")
+	if err != nil {
+		return fmt.Errorf("set_clip: %w", err)
+	}
+	var dynamicUpdates []propertyUpdate
+	for _, update := range updates {
+		if update.isStatic() {
+			value, err := p.resolvePropertyUpdate(update, nil)
+			if err != nil {
+				return fmt.Errorf("set_clip: %w", err)
+			}
+			actionProps[update.Property] = value
+		} else {
+			dynamicUpdates = append(dynamicUpdates, update)
+		}
 	}
 
 	// Must have at least one property
-	if len(actionProps) == 0 {
-		return fmt.Errorf("set_clip requires at least one property: name, color, selected, or length")
+	if len(actionProps) == 0 && len(dynamicUpdates) == 0 {
+		return fmt.Errorf("set_clip requires at least one property: name, color, selected, length, fade_in, fade_out, gain_db, or mute")
 	}
 
 	// Check if we have a filtered collection to apply to
@@ -789,6 +899,9 @@ func (r *ReaperDSL) SetClip(args gs.Args) error {
 						log.Printf("⚠️  SetClip: Could not identify clip (no index or position): %+v", clipMap)
 						continue
 					}
+					if err := p.applyPropertyUpdates(action, dynamicUpdates, clipMap); err != nil {
+						return fmt.Errorf("set_clip on track %d: %w", trackIndex, err)
+					}
 
 					log.Printf("✅ SetClip: Adding action for clip on track %d, props=%+v", trackIndex, actionProps)
 					p.actions = append(p.actions, action)
@@ -825,6 +938,16 @@ func (r *ReaperDSL) SetClip(args gs.Args) error {
 		return fmt.Errorf("set_clip requires one of: clip (index), position (seconds), or bar (number)")
 	}
 
+	if len(dynamicUpdates) > 0 {
+		clipMap, err := p.findClip(p.currentTrackIndex, args)
+		if err != nil {
+			return fmt.Errorf("set_clip: %w", err)
+		}
+		if err := p.applyPropertyUpdates(action, dynamicUpdates, clipMap); err != nil {
+			return fmt.Errorf("set_clip on track %d: %w", p.currentTrackIndex, err)
+		}
+	}
+
 	p.actions = append(p.actions, action)
 	return nil
 }
@@ -834,25 +957,23 @@ func (r *ReaperDSL) SetClip(args gs.Args) error {
 func (r *ReaperDSL) MoveClip(args gs.Args) error {
 	p := r.parser
 
-	// Get position (required)
-	positionValue, ok := args["position"]
-	if !ok {
-		// Try "bar" as alternative
-		if barValue, ok := args["bar"]; ok && barValue.Kind == gs.ValueNumber {
-			// Convert bar to position (would need BPM, but for now just use bar number)
-			// This is a placeholder - in real implementation would convert bar to seconds
-			positionValue = barValue
-		} else {
-			return fmt.Errorf("move_clip requires position (seconds) or bar (number)")
-		}
+	// Get position (required): literal, relative (position+=bar(1)) or an
+	// expression over the clip's current values (position=clip.position + 2)
+	updates, err := parsePropertyUpdates(args, "position")
+	if err != nil {
+		return fmt.Errorf("move_clip: %w", err)
 	}
-
-	var position float64
-	if positionValue.Kind == gs.ValueNumber {
-		position = positionValue.Num
+	var positionUpdate propertyUpdate
+	if len(updates) == 1 {
+		positionUpdate = updates[0]
+	} else if barValue, ok := args["bar"]; ok && barValue.Kind == gs.ValueNumber {
+		// Convert bar to position (would need BPM, but for now just use bar number)
+		// This is a placeholder - in real implementation would convert bar to seconds
+		positionUpdate = propertyUpdate{Property: "position", Op: "=", Value: barValue.Num}
 	} else {
-		return fmt.Errorf("position must be a number")
+		return fmt.Errorf("move_clip requires position (seconds) or bar (number)")
 	}
+	position := positionUpdate.Value
 
 	// Check if we have a filtered collection to apply to
 	if filteredCollection, hasFiltered := p.data["current_filtered"]; hasFiltered {
@@ -892,6 +1013,11 @@ func (r *ReaperDSL) MoveClip(args gs.Args) error {
 						continue
 					}
 
+					position, err := p.resolvePropertyUpdate(positionUpdate, clipMap)
+					if err != nil {
+						return fmt.Errorf("move_clip on track %d: %w", trackIndex, err)
+					}
+
 					action := map[string]any{
 						"action":   "set_clip_position",
 						"track":    trackIndex,
@@ -939,74 +1065,87 @@ func (r *ReaperDSL) MoveClip(args gs.Args) error {
 		return fmt.Errorf("move_clip requires one of: clip (index), old_position (seconds), or bar (number)")
 	}
 
+	if !positionUpdate.isStatic() {
+		clipMap, err := p.findClip(p.currentTrackIndex, args)
+		if err != nil {
+			return fmt.Errorf("move_clip: %w", err)
+		}
+		position, err = p.resolvePropertyUpdate(positionUpdate, clipMap)
+		if err != nil {
+			return fmt.Errorf("move_clip on track %d: %w", p.currentTrackIndex, err)
+		}
+		action["position"] = position
+	}
+
 	p.actions = append(p.actions, action)
 	return nil
 }
 
-// AddAutomation handles .addAutomation() calls with curve-based or point-based syntax.
-// Curve-based (recommended): track(id=1).addAutomation(param="volume", curve="fade_in", start=0, end=4)
-// Point-based: track(id=1).addAutomation(param="volume", points=[{time=0, value=-60}, {time=4, value=0}])
+// AddAutomation handles .add_automation() calls with curve-based or point-based syntax.
+// Curve-based (recommended): track(id=1).add_automation(param="volume", curve="fade_in", start=0, end=4)
+// FX parameter: track(id=1).add_automation(fx="ReaEQ", fx_param="Gain", curve="sine", freq="1/8", start=0, end=16)
+// Point-based: track(id=1).add_automation(param="volume", points=[{time=0, value=-60}, {time=4, value=0}])
+// Curves are rendered to explicit points using the automation curve library.
 func (r *ReaperDSL) AddAutomation(args gs.Args) error {
 	p := r.parser
 
 	// Get track index
 	trackIndex := p.currentTrackIndex
 	if trackIndex < 0 {
-		return fmt.Errorf("no track context for addAutomation call")
+		return fmt.Errorf("no track context for add_automation call")
 	}
 
-	// Get param (required)
-	paramValue, ok := args["param"]
-	if !ok || paramValue.Kind != gs.ValueString {
-		return fmt.Errorf("addAutomation requires param (string)")
+	// Resolve the target: param (volume, pan, mute, send) or fx + fx_param
+	target, valueRange, err := p.automationTarget(trackIndex, args)
+	if err != nil {
+		return fmt.Errorf("add_automation: %w", err)
 	}
-	param := paramValue.Str
+	param, _ := target["param"].(string)
 
 	action := map[string]any{
 		"action": "add_automation",
 		"track":  trackIndex,
-		"param":  param,
+	}
+	for k, v := range target {
+		action[k] = v
 	}
 
 	// Check for curve-based syntax (preferred)
 	if curveValue, ok := args["curve"]; ok && curveValue.Kind == gs.ValueString {
+		curve, ok := LookupAutomationCurve(curveValue.Str)
+		if !ok {
+			return fmt.Errorf("add_automation: unknown curve %q (available: %s)", curveValue.Str, strings.Join(AutomationCurveNames(), ", "))
+		}
 		action["curve"] = curveValue.Str
 
-		// Parse timing parameters
-		if startValue, ok := args["start"]; ok && startValue.Kind == gs.ValueNumber {
-			action["start"] = startValue.Num
-		}
-		if endValue, ok := args["end"]; ok && endValue.Kind == gs.ValueNumber {
-			action["end"] = endValue.Num
-		}
-		if startBarValue, ok := args["start_bar"]; ok && startBarValue.Kind == gs.ValueNumber {
-			action["start_bar"] = startBarValue.Num
-		}
-		if endBarValue, ok := args["end_bar"]; ok && endBarValue.Kind == gs.ValueNumber {
-			action["end_bar"] = endBarValue.Num
+		// Keep the curve parameters alongside the rendered points
+		for _, key := range []string{"start", "end", "start_bar", "end_bar", "from", "to", "amplitude", "phase"} {
+			if value, ok := args[key]; ok && value.Kind == gs.ValueNumber {
+				action[key] = value.Num
+			}
 		}
 
-		// Parse value range (for ramp, exp curves)
-		if fromValue, ok := args["from"]; ok && fromValue.Kind == gs.ValueNumber {
-			action["from"] = fromValue.Num
+		spec, err := p.curveSpecFromArgs(curve, args, valueRange)
+		if err != nil {
+			return fmt.Errorf("add_automation: %w", err)
 		}
-		if toValue, ok := args["to"]; ok && toValue.Kind == gs.ValueNumber {
-			action["to"] = toValue.Num
+		if _, ok := args["freq"]; ok {
+			// Tempo-synced rates are reported in cycles per bar
+			_, beatsPerBar := p.projectTiming()
+			action["freq"] = beatsPerBar / spec.CycleBeats
 		}
 
-		// Parse oscillator parameters (for sine, saw, square)
-		if freqValue, ok := args["freq"]; ok && freqValue.Kind == gs.ValueNumber {
-			action["freq"] = freqValue.Num
-		}
-		if ampValue, ok := args["amplitude"]; ok && ampValue.Kind == gs.ValueNumber {
-			action["amplitude"] = ampValue.Num
+		points, err := renderCurve(curve, spec)
+		if err != nil {
+			return fmt.Errorf("add_automation: %w", err)
 		}
-		if phaseValue, ok := args["phase"]; ok && phaseValue.Kind == gs.ValueNumber {
-			action["phase"] = phaseValue.Num
+		action["points"] = points
+		if curve.Stepped {
+			action["shape"] = shapeSquare
 		}
 
 		p.actions = append(p.actions, action)
-		log.Printf("✅ AddAutomation (curve): track=%d, param=%s, curve=%s", trackIndex, param, curveValue.Str)
+		log.Printf("✅ AddAutomation (curve): track=%d, param=%s, curve=%s, points=%d", trackIndex, param, curveValue.Str, len(points))
 		return nil
 	}
 
@@ -1047,7 +1186,7 @@ func (r *ReaperDSL) AddAutomation(args gs.Args) error {
 	}
 
 	if len(points) == 0 {
-		return fmt.Errorf("addAutomation requires either 'curve' or 'points'")
+		return fmt.Errorf("add_automation requires either 'curve' or 'points'")
 	}
 
 	action["points"] = points
@@ -1158,6 +1297,7 @@ func parseAutomationPointFromString(content string) (map[string]any, error) {
 // Example: filter(tracks, @is_fx_track) or filter(tracks, "name", "==", "FX")
 func (r *ReaperDSL) Filter(args gs.Args) error {
 	p := r.parser
+	p.markExpansion("filter")
 
 	// Log all args for debugging
 	log.Printf("🔍 Filter: Received args with %d keys: %v", len(args), getArgsKeys(args))
@@ -1499,6 +1639,7 @@ func (r *ReaperDSL) Filter(args gs.Args) error {
 	// Store filtered result - return the filtered collection name for chaining
 	resultName := collectionName + "_filtered"
 	p.data[resultName] = filtered
+	p.lastCollection = filtered
 
 	// Also store as "current_filtered" for potential chaining
 	p.data["current_filtered"] = filtered
@@ -1519,57 +1660,91 @@ func (r *ReaperDSL) Filter(args gs.Args) error {
 }
 
 // Map maps a function over a collection.
+// Grammar: map(collection, @function) or map(collection, @function(arg=value))
+// Results are stored as <collection>_mapped. Functions returning tracks or clips
+// also become the current collection, so chained methods apply to them.
 func (r *ReaperDSL) Map(args gs.Args) error {
 	p := r.parser
+	p.markExpansion("map")
 
-	// Get collection
-	var collection []any
-	var collectionName string
+	collectionName, funcArg := p.functionalCallArgs("map", args)
+	if collectionName == "" {
+		return fmt.Errorf("map requires a collection argument")
+	}
+	collection, err := p.resolveCollection(collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to resolve collection: %w", err)
+	}
 
-	if collectionValue, ok := args["collection"]; ok && collectionValue.Kind == gs.ValueString {
-		collectionName = collectionValue.Str
-		var err error
-		collection, err = p.resolveCollection(collectionName)
+	if !strings.HasPrefix(funcArg, "@") {
+		return fmt.Errorf("map requires a function argument (e.g. map(%s, @get_name))", collectionName)
+	}
+	fn, ref, err := p.lookupFunction(funcArg)
+	if err != nil {
+		return err
+	}
+
+	iterVar := p.getIterVarFromCollection(collectionName)
+	mapped := make([]any, 0, len(collection))
+
+	for i, item := range collection {
+		p.setIterationContext(map[string]any{
+			iterVar: item,
+		})
+
+		result, err := p.callFunction(fn, ref.Args, item, i)
+		p.clearIterationContext()
 		if err != nil {
-			return fmt.Errorf("failed to resolve collection: %w", err)
+			return fmt.Errorf("map failed on item %d of '%s': %w", i, collectionName, err)
 		}
-	} else {
-		return fmt.Errorf("map requires a collection argument")
+		mapped = append(mapped, result)
 	}
 
-	// Get function reference
-	if funcValue, ok := args["func"]; ok && funcValue.Kind == gs.ValueFunction {
-		_ = funcValue.Str // funcName for future use
-		iterVar := p.getIterVarFromCollection(collectionName)
-
-		mapped := make([]any, 0, len(collection))
+	resultName := collectionName + "_mapped"
+	p.data[resultName] = mapped
+	p.lastCollection = mapped
+	if fn.Returns == FunctionTypeTrack || fn.Returns == FunctionTypeClip {
+		p.data["current_filtered"] = mapped
+		p.currentTrackIndex = -1
+	}
+	log.Printf("✅ Map: Applied @%s to %d items from '%s' (stored as '%s')", fn.Name, len(mapped), collectionName, resultName)
+	return nil
+}
 
-		for _, item := range collection {
-			p.setIterationContext(map[string]any{
-				iterVar: item,
-			})
+// functionalCallArgs returns the collection name and the function or method
+// argument of a map()/for_each() call. Both are positional, so they are read
+// from the raw statement; named collection= and func= arguments are also accepted.
+func (p *FunctionalDSLParser) functionalCallArgs(callName string, args gs.Args) (string, string) {
+	var collectionName, funcArg string
 
-			// Apply function to item
-			// Would need to call the function handler here
-			// For now, just pass through
-			mapped = append(mapped, item)
+	if rawArgs, ok := rawCallArgs(p.currentStatement, callName); ok && len(rawArgs) == 2 && !strings.Contains(rawArgs[0], "=") {
+		collectionName = rawArgs[0]
+		funcArg = rawArgs[1]
+	}
 
-			p.clearIterationContext()
+	if collectionValue, ok := args["collection"]; ok && collectionValue.Kind == gs.ValueString {
+		collectionName = collectionValue.Str
+	}
+	if funcValue, ok := args["func"]; ok && (funcValue.Kind == gs.ValueString || funcValue.Kind == gs.ValueFunction) {
+		funcArg = funcValue.Str
+		if !strings.HasPrefix(funcArg, "@") {
+			funcArg = "@" + funcArg
+		}
+	}
+	if funcArg == "" {
+		if value, ok := args[""]; ok && value.Kind == gs.ValueString && strings.HasPrefix(value.Str, "@") {
+			funcArg = value.Str
 		}
-
-		resultName := collectionName + "_mapped"
-		p.data[resultName] = mapped
-		log.Printf("Mapped %d items", len(collection))
-		return nil
 	}
 
-	return fmt.Errorf("map requires a function argument")
+	return collectionName, funcArg
 }
 
 // ForEach applies a function or method to each item in a collection (side effects).
 // Grammar: for_each(collection, @function) or for_each(collection, item.method())
 func (r *ReaperDSL) ForEach(args gs.Args) error {
 	p := r.parser
+	p.markExpansion("for_each")
 
 	// Get collection - similar to Filter and Map
 	var collection []any
@@ -1578,8 +1753,9 @@ func (r *ReaperDSL) ForEach(args gs.Args) error {
 	// Try to get collection from various argument positions
 	// Note: for_each(tracks, track.method()) has two positional args, both with Name=""
 	// The second one overwrites the first in the map, so we need to check both
-	if collectionValue, ok := args["collection"]; ok && collectionValue.Kind == gs.ValueString {
-		collectionName = collectionValue.Str
+	rawCollectionName, funcArg := p.functionalCallArgs("for_each", args)
+	if rawCollectionName != "" {
+		collectionName = rawCollectionName
 		var err error
 		collection, err = p.resolveCollection(collectionName)
 		if err != nil {
@@ -1616,7 +1792,6 @@ func (r *ReaperDSL) ForEach(args gs.Args) error {
 
 	// Get the function/method to execute
 	var methodCallStr string
-	var funcRef string
 
 	// Log all arguments for debugging
 	log.Printf("🔄 ForEach: Received args: %v", getArgsKeys(args))
@@ -1625,12 +1800,9 @@ func (r *ReaperDSL) ForEach(args gs.Args) error {
 	}
 
 	// Check for function reference (@func_name)
-	if funcValue, ok := args["func"]; ok && funcValue.Kind == gs.ValueFunction {
-		funcRef = funcValue.Str
-		log.Printf("🔄 ForEach: Found function reference: @%s", funcRef)
-		// TODO: Implement function registry and execution
-		// For now, function references are not yet supported
-		return fmt.Errorf("function references (@%s) are not yet implemented in for_each", funcRef)
+	if strings.HasPrefix(funcArg, "@") {
+		log.Printf("🔄 ForEach: Found function reference: %s", funcArg)
+		return p.forEachFunction(collection, collectionName, iterVar, funcArg)
 	}
 
 	// Check for method call string (e.g., "track.add_fx(fxname=\"ReaEQ\")")
@@ -1745,6 +1917,36 @@ func (r *ReaperDSL) ForEach(args gs.Args) error {
 	return nil
 }
 
+// forEachFunction calls a function reference on each item of a collection for its side effects.
+func (p *FunctionalDSLParser) forEachFunction(collection []any, collectionName, iterVar, funcArg string) error {
+	fn, ref, err := p.lookupFunction(funcArg)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range collection {
+		p.setIterationContext(map[string]any{
+			iterVar: item,
+		})
+
+		// If item is a track, set currentTrackIndex like method calls do
+		if itemMap, ok := item.(map[string]any); ok {
+			if trackIndex, ok := itemTrackIndex(itemMap); ok {
+				p.currentTrackIndex = trackIndex
+			}
+		}
+
+		_, err := p.callFunction(fn, ref.Args, item, i)
+		p.clearIterationContext()
+		if err != nil {
+			return fmt.Errorf("for_each failed on item %d of '%s': %w", i, collectionName, err)
+		}
+	}
+
+	log.Printf("✅ ForEach: Processed %d items from '%s' with @%s", len(collection), collectionName, fn.Name)
+	return nil
+}
+
 // parseMethodCallString parses a method call string like "track.add_fx(fxname=\"ReaEQ\")"
 // Returns the method name (e.g., "add_fx") and parsed arguments
 func (p *FunctionalDSLParser) parseMethodCallString(methodCallStr string) (string, gs.Args, error) {
@@ -1792,49 +1994,60 @@ func (p *FunctionalDSLParser) parseMethodCallString(methodCallStr string) (strin
 	paramsStr = paramsStr[:closeIndex]
 	paramsStr = strings.TrimSpace(paramsStr)
 
-	// Parse parameters into gs.Args
-	args := make(gs.Args)
-	if paramsStr != "" {
-		// Simple parameter parsing: key="value" or key=value
-		parts := strings.Split(paramsStr, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part == "" {
-				continue
-			}
-
-			// Split by = to get key and value
-			eqIndex := strings.Index(part, "=")
-			if eqIndex < 0 {
-				continue
-			}
+	return methodName, parseCallParams(paramsStr), nil
+}
 
-			key := strings.TrimSpace(part[:eqIndex])
-			valueStr := strings.TrimSpace(part[eqIndex+1:])
+// parseCallParams parses a parameter list like `fxname="ReaEQ", volume_db=-3` into gs.Args.
+// Parts without "=" are ignored.
+func parseCallParams(paramsStr string) gs.Args {
+	args := make(gs.Args)
+	for _, part := range splitTopLevel(paramsStr, ',') {
+		// Split by = to get key and value
+		eqIndex := strings.Index(part, "=")
+		if eqIndex < 0 {
+			continue
+		}
 
-			// Parse value
-			var value gs.Value
-			if strings.HasPrefix(valueStr, "\"") && strings.HasSuffix(valueStr, "\"") {
-				// String value
-				value = gs.Value{
-					Kind: gs.ValueString,
-					Str:  valueStr[1 : len(valueStr)-1], // Remove quotes
-				}
-			} else if valueStr == "true" {
-				value = gs.Value{Kind: gs.ValueBool, Bool: true}
-			} else if valueStr == "false" {
-				value = gs.Value{Kind: gs.ValueBool, Bool: false}
-			} else if num, err := strconv.ParseFloat(valueStr, 64); err == nil {
-				value = gs.Value{Kind: gs.ValueNumber, Num: num}
-			} else {
-				value = gs.Value{Kind: gs.ValueString, Str: valueStr}
-			}
+		key := strings.TrimSpace(part[:eqIndex])
+		args[key] = parseDSLLiteral(part[eqIndex+1:])
+	}
+	return args
+}
 
-			args[key] = value
+// parseDSLLiteral parses a literal value (string, bool, number) into a gs.Value.
+// Anything else is returned as a raw string.
+func parseDSLLiteral(valueStr string) gs.Value {
+	valueStr = strings.TrimSpace(valueStr)
+	if len(valueStr) >= 2 && strings.HasPrefix(valueStr, "\"") && strings.HasSuffix(valueStr, "\"") {
+		return gs.Value{
+			Kind: gs.ValueString,
+			Str:  valueStr[1 : len(valueStr)-1], // Remove quotes
 		}
 	}
+	if valueStr == "true" {
+		return gs.Value{Kind: gs.ValueBool, Bool: true}
+	}
+	if valueStr == "false" {
+		return gs.Value{Kind: gs.ValueBool, Bool: false}
+	}
+	if num, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return gs.Value{Kind: gs.ValueNumber, Num: num}
+	}
+	return gs.Value{Kind: gs.ValueString, Str: valueStr}
+}
 
-	return methodName, args, nil
+// valueToAny converts a gs.Value to a plain Go value.
+func valueToAny(value gs.Value) any {
+	switch value.Kind {
+	case gs.ValueString, gs.ValueIdentifier, gs.ValueFunction:
+		return value.Str
+	case gs.ValueNumber:
+		return value.Num
+	case gs.ValueBool:
+		return value.Bool
+	default:
+		return nil
+	}
 }
 
 // executeMethodOnItem executes a method on the current item in the iteration context
@@ -1849,6 +2062,8 @@ func (p *FunctionalDSLParser) executeMethodOnItem(methodName string, methodArgs
 		return p.reaperDSL.SetTrack(methodArgs)
 	case "AddFx":
 		return p.reaperDSL.AddFx(methodArgs)
+	case "AddSend":
+		return p.reaperDSL.AddSend(methodArgs)
 	// NOTE: AddMidi removed - add_midi is handled by ARRANGER agent, not DAW agent
 	case "NewClip":
 		return p.reaperDSL.NewClip(methodArgs)
@@ -1860,6 +2075,26 @@ func (p *FunctionalDSLParser) executeMethodOnItem(methodName string, methodArgs
 		return p.reaperDSL.SetClip(methodArgs)
 	case "MoveClip", "SetClipPosition":
 		return p.reaperDSL.MoveClip(methodArgs)
+	case "SplitClip":
+		return p.reaperDSL.SplitClip(methodArgs)
+	case "DuplicateClip":
+		return p.reaperDSL.DuplicateClip(methodArgs)
+	case "LoopClip":
+		return p.reaperDSL.LoopClip(methodArgs)
+	case "TrimClip":
+		return p.reaperDSL.TrimClip(methodArgs)
+	case "Quantize":
+		return p.reaperDSL.Quantize(methodArgs)
+	case "Transpose":
+		return p.reaperDSL.Transpose(methodArgs)
+	case "SetVelocity":
+		return p.reaperDSL.SetVelocity(methodArgs)
+	case "Humanize":
+		return p.reaperDSL.Humanize(methodArgs)
+	case "Legato":
+		return p.reaperDSL.Legato(methodArgs)
+	case "Reverse":
+		return p.reaperDSL.Reverse(methodArgs)
 	case "AddAutomation":
 		return p.reaperDSL.AddAutomation(methodArgs)
 	default:
@@ -1944,7 +2179,8 @@ func capitalizeMethodName(name string) string {
 	return strings.ToUpper(name[:1]) + name[1:]
 }
 
-// Store stores a value in data storage.
+// Store stores a value in data storage and binds it as a variable,
+// so later statements can reference it like a let-binding.
 func (r *ReaperDSL) Store(args gs.Args) error {
 	p := r.parser
 
@@ -1955,19 +2191,9 @@ func (r *ReaperDSL) Store(args gs.Args) error {
 
 	// Get value (would need to handle different types)
 	if valueValue, ok := args["value"]; ok {
-		// Convert Value to any
-		var value any
-		switch valueValue.Kind {
-		case gs.ValueString:
-			value = valueValue.Str
-		case gs.ValueNumber:
-			value = valueValue.Num
-		case gs.ValueBool:
-			value = valueValue.Bool
-		default:
-			value = nil
-		}
+		value := valueToAny(valueValue)
 		p.data[nameValue.Str] = value
+		p.scope.set(nameValue.Str, value)
 		log.Printf("Stored %s = %v", nameValue.Str, value)
 		return nil
 	}
@@ -2438,6 +2664,63 @@ start: statement (";"? statement)*
 
 statement: track_call chain*
          | functional_call
+         | let_statement
+         | variable_call
+         | macro_call
+         | template_call
+         | project_call
+
+// Variables: bind tracks, collections and values, then use them as receivers
+// Example: let bass = track(name="Bass"); bass.add_fx(fxname="ReaEQ")
+let_statement: "let" SP IDENTIFIER SP "=" SP let_value
+let_value: track_call chain*
+         | filter_call
+         | map_call
+         | NUMBER
+         | STRING
+         | BOOLEAN
+         | IDENTIFIER
+variable_call: IDENTIFIER chain+
+
+// Macros: saved DSL snippets called by name with named arguments
+// Example: @vocal_chain(target=4, reverb_mix=0.2)
+macro_call: "@" IDENTIFIER "(" macro_params? ")"
+macro_params: macro_param ("," SP macro_param)*
+macro_param: IDENTIFIER "=" (STRING | NUMBER | BOOLEAN | IDENTIFIER)
+
+// Project-level commands: rendering, saving, project settings and transport
+// Examples: render(range="loop", format="mp3"); set_loop(start_bar=9, end_bar=17); play()
+project_call: "render" "(" render_params? ")"
+            | "save_project" "(" save_project_params? ")"
+            | "set_project" "(" project_params ")"
+            | "set_loop" "(" loop_params ")"
+            | "set_cursor" "(" cursor_params ")"
+            | "play" "(" ")"
+            | "stop" "(" ")"
+render_params: render_param ("," SP render_param)*
+render_param: "range" "=" STRING
+            | "start_bar" "=" NUMBER
+            | "end_bar" "=" NUMBER
+            | "format" "=" STRING
+            | "stems" "=" BOOLEAN
+            | "sample_rate" "=" NUMBER
+            | "bit_depth" "=" NUMBER
+            | "file" "=" STRING
+save_project_params: "path" "=" STRING
+project_params: project_param ("," SP project_param)*
+project_param: "name" "=" STRING
+             | "sample_rate" "=" NUMBER
+             | "tempo" "=" NUMBER
+loop_params: loop_param ("," SP loop_param)*
+loop_param: "start_bar" "=" NUMBER
+          | "end_bar" "=" NUMBER
+cursor_params: "bar" "=" NUMBER
+             | "position" "=" NUMBER
+
+// Track templates: multi-track setups by name, with per-role track counts
+// Example: template(name="rock_band", guitar=2, keys=0)
+template_call: "template" "(" "name" "=" STRING ("," SP template_count)* ")"
+template_count: IDENTIFIER "=" NUMBER
 
 track_call: "track" "(" track_params? ")"
 track_params: track_param ("," SP track_param)*
@@ -2448,7 +2731,7 @@ track_param: "instrument" "=" STRING
            | "id" "=" NUMBER
            | "selected" "=" BOOLEAN
 
-chain: clip_chain | fx_chain | track_properties_chain | delete_chain | delete_clip_chain | clip_properties_chain | clip_move_chain | automation_chain
+chain: clip_chain | fx_chain | send_chain | track_properties_chain | delete_chain | delete_clip_chain | clip_properties_chain | clip_move_chain | clip_edit_chain | midi_edit_chain | automation_chain
 
 clip_chain: ".new_clip" "(" clip_params? ")"
 clip_params: clip_param ("," SP clip_param)*
@@ -2462,15 +2745,24 @@ fx_chain: ".add_fx" "(" fx_params? ")"
 fx_params: "fxname" "=" STRING
          | "instrument" "=" STRING
 
+// Sends route the track to another track, given by name or 1-based id
+send_chain: ".add_send" "(" send_params ")"
+send_params: send_param ("," SP send_param)*
+send_param: "to" "=" (STRING | NUMBER)
+          | "volume_db" "=" NUMBER
+          | "pan" "=" NUMBER
+
 // Unified track properties method
 track_properties_chain: ".set_track" "(" track_properties_params? ")"
 track_properties_params: track_property_param ("," SP track_property_param)*
 track_property_param: "name" "=" STRING
-                    | "volume_db" "=" NUMBER
-                    | "pan" "=" NUMBER
+                    | "volume_db" assign_op numeric_expr
+                    | "pan" assign_op numeric_expr
                     | "mute" "=" BOOLEAN
                     | "solo" "=" BOOLEAN
                     | "selected" "=" BOOLEAN
+                    | "color" "=" (STRING | NUMBER)
+                    | "folder_depth" "=" NUMBER
 
 // Deletion operations
 delete_chain: ".delete" "(" ")"
@@ -2486,22 +2778,94 @@ clip_properties_params: clip_property_param ("," SP clip_property_param)*
 clip_property_param: "name" "=" STRING
                    | "color" "=" (STRING | NUMBER)
                    | "selected" "=" BOOLEAN
-                   | "length" "=" NUMBER
+                   | "length" assign_op numeric_expr
+                   | "fade_in" assign_op numeric_expr
+                   | "fade_out" assign_op numeric_expr
+                   | "gain_db" assign_op numeric_expr
+                   | "mute" "=" BOOLEAN
                    | "clip" "=" NUMBER
                    | "position" "=" NUMBER
                    | "bar" "=" NUMBER
 clip_move_chain: ".move_clip" "(" move_clip_params? ")"
                 | ".set_clip_position" "(" move_clip_params? ")"
 move_clip_params: move_clip_param ("," SP move_clip_param)*
-move_clip_param: "position" "=" NUMBER
+move_clip_param: "position" assign_op numeric_expr
                | "bar" "=" NUMBER
                | "clip" "=" NUMBER
                | "old_position" "=" NUMBER
 
+// Clip editing operations - split, duplicate, loop and trim
+// Clips are identified by clip/position/bar, or come from filter(clips, ...)
+clip_edit_chain: ".split_clip" "(" split_clip_params ")"
+               | ".duplicate_clip" "(" duplicate_clip_params? ")"
+               | ".loop_clip" "(" loop_clip_params ")"
+               | ".trim_clip" "(" trim_clip_params ")"
+split_clip_params: split_clip_param ("," SP split_clip_param)*
+split_clip_param: "at" "=" numeric_expr
+                | "at_bar" "=" NUMBER
+                | clip_ref_param
+duplicate_clip_params: duplicate_clip_param ("," SP duplicate_clip_param)*
+duplicate_clip_param: "times" "=" NUMBER
+                    | "gap" "=" numeric_expr
+                    | clip_ref_param
+loop_clip_params: loop_clip_param ("," SP loop_clip_param)*
+loop_clip_param: "to_bar" "=" NUMBER
+               | clip_ref_param
+trim_clip_params: trim_clip_param ("," SP trim_clip_param)*
+trim_clip_param: "start" "=" numeric_expr
+               | "end" "=" numeric_expr
+               | clip_ref_param
+clip_ref_param: "clip" "=" NUMBER
+              | "position" "=" NUMBER
+              | "bar" "=" NUMBER
+
+// MIDI transforms on existing clips, or on notes from filter(notes, ...)
+// Note timing (grid, timing, gap) is in beats
+midi_edit_chain: ".quantize" "(" quantize_params? ")"
+               | ".transpose" "(" transpose_params ")"
+               | ".set_velocity" "(" velocity_params ")"
+               | ".humanize" "(" humanize_params? ")"
+               | ".legato" "(" legato_params? ")"
+               | ".reverse" "(" clip_ref_params? ")"
+quantize_params: quantize_param ("," SP quantize_param)*
+quantize_param: "grid" "=" (STRING | NUMBER)
+              | "strength" "=" NUMBER
+              | "swing" "=" NUMBER
+              | clip_ref_param
+transpose_params: transpose_param ("," SP transpose_param)*
+transpose_param: "semitones" "=" NUMBER
+               | "octaves" "=" NUMBER
+               | clip_ref_param
+velocity_params: velocity_param ("," SP velocity_param)*
+velocity_param: "velocity" assign_op NUMBER
+              | clip_ref_param
+humanize_params: humanize_param ("," SP humanize_param)*
+humanize_param: "timing" "=" NUMBER
+              | "velocity" "=" NUMBER
+              | clip_ref_param
+legato_params: legato_param ("," SP legato_param)*
+legato_param: "gap" "=" NUMBER
+            | clip_ref_param
+clip_ref_params: clip_ref_param ("," SP clip_ref_param)*
+
+// Relative and expression values, resolved against each item's current state
+// Examples: volume_db+=-3, position+=bar(1), pan=track.pan * 0.5, length*=2
+assign_op: "=" | "+=" | "-=" | "*=" | "/="
+numeric_expr: numeric_term (SP? ("+" | "-") SP? numeric_term)*
+numeric_term: numeric_factor (SP? ("*" | "/") SP? numeric_factor)*
+numeric_factor: NUMBER
+              | property_access
+              | numeric_function "(" numeric_expr ("," SP numeric_expr)* ")"
+              | "(" numeric_expr ")"
+numeric_function: "bar" | "bars" | "beat" | "beats" | "abs" | "min" | "max"
+
 // Automation operations - supports curve-based and point-based syntax
 automation_chain: ".add_automation" "(" automation_params ")"
 automation_params: automation_param ("," SP automation_param)*
 automation_param: "param" "=" STRING
+                | "fx" "=" (STRING | NUMBER)
+                | "fx_param" "=" (STRING | NUMBER)
+                | "send" "=" (STRING | NUMBER)
                 | "curve" "=" STRING
                 | "start" "=" NUMBER
                 | "end" "=" NUMBER
@@ -2509,9 +2873,15 @@ automation_param: "param" "=" STRING
                 | "end_bar" "=" NUMBER
                 | "from" "=" NUMBER
                 | "to" "=" NUMBER
-                | "freq" "=" NUMBER
+                | "freq" "=" (NUMBER | STRING)
                 | "amplitude" "=" NUMBER
                 | "phase" "=" NUMBER
+                | "attack" "=" NUMBER
+                | "decay" "=" NUMBER
+                | "sustain" "=" NUMBER
+                | "release" "=" NUMBER
+                | "seed" "=" NUMBER
+                | "resolution" "=" NUMBER
                 | "shape" "=" NUMBER
                 | "points" "=" automation_points
 automation_points: "[" automation_point ("," SP automation_point)* "]"
@@ -2524,7 +2894,7 @@ automation_point_field: "time" "=" NUMBER
 // Functional operations
 functional_call: filter_call chain+
                  | filter_call chain? ";" filter_call chain?
-                 | map_call
+                 | map_call chain*
                  | for_each_call
 
 filter_call: "filter" "(" IDENTIFIER "," filter_predicate ")"
@@ -2554,7 +2924,10 @@ property_access: IDENTIFIER "." IDENTIFIER
 
 comparison_op: "==" | "!=" | "<" | ">" | "<=" | ">="
 
+// Function references resolve against the function registry (built-ins: @get_name,
+// @get_index, @normalize_volume(target_db=NUMBER), @reset_pan, @mute, @unmute)
 function_ref: "@" IDENTIFIER
+            | "@" IDENTIFIER "(" method_params? ")"
 
 array: "[" (value ("," SP value)*)? "]"
 value: STRING | NUMBER | BOOLEAN | array
@@ -0,0 +1,251 @@
+package daw
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// renderFormats are the file formats render() accepts. Lossless formats
+// take a bit depth.
+var renderFormats = map[string]bool{
+	"wav":  true,
+	"flac": true,
+	"aiff": true,
+	"mp3":  false,
+	"ogg":  false,
+}
+
+// renderRanges are the named ranges render() accepts besides a bar range.
+var renderRanges = []string{"project", "loop", "selection"}
+
+// projectSampleRates are the sample rates set_project() and render() accept.
+var projectSampleRates = []int{44100, 48000, 88200, 96000, 176400, 192000}
+
+// Render handles render() calls, which export the project or its stems.
+// The range is "project" (default), "loop", "selection", or a bar range
+// given with start_bar/end_bar.
+// Example: render(start_bar=1, end_bar=17, format="mp3", stems=true)
+func (r *ReaperDSL) Render(args gs.Args) error {
+	p := r.parser
+
+	action := map[string]any{"action": "render"}
+
+	startBar, hasStart := args["start_bar"]
+	endBar, hasEnd := args["end_bar"]
+	rangeValue, hasRange := args["range"]
+	switch {
+	case hasStart || hasEnd:
+		if hasRange {
+			return fmt.Errorf("render: use either range or start_bar/end_bar, not both")
+		}
+		start, end, err := barRange("render", startBar, endBar, hasStart, hasEnd)
+		if err != nil {
+			return err
+		}
+		action["range"] = "bars"
+		action["start_bar"] = start
+		action["end_bar"] = end
+	case hasRange:
+		if rangeValue.Kind != gs.ValueString || !containsString(renderRanges, rangeValue.Str) {
+			return fmt.Errorf("render: range must be one of %s, or use start_bar/end_bar", strings.Join(renderRanges, ", "))
+		}
+		action["range"] = rangeValue.Str
+	default:
+		action["range"] = "project"
+	}
+
+	format := "wav"
+	if formatValue, ok := args["format"]; ok {
+		if formatValue.Kind != gs.ValueString {
+			return fmt.Errorf("render: format must be a string")
+		}
+		format = strings.ToLower(strings.TrimSpace(formatValue.Str))
+	}
+	lossless, known := renderFormats[format]
+	if !known {
+		return fmt.Errorf("render: unknown format %q (use wav, flac, aiff, mp3 or ogg)", format)
+	}
+	action["format"] = format
+
+	action["stems"] = false
+	if stemsValue, ok := args["stems"]; ok {
+		if stemsValue.Kind != gs.ValueBool {
+			return fmt.Errorf("render: stems must be true or false")
+		}
+		action["stems"] = stemsValue.Bool
+	}
+
+	if rateValue, ok := args["sample_rate"]; ok {
+		rate, err := sampleRateArg("render", rateValue)
+		if err != nil {
+			return err
+		}
+		action["sample_rate"] = rate
+	}
+
+	if depthValue, ok := args["bit_depth"]; ok {
+		if !lossless {
+			return fmt.Errorf("render: bit_depth only applies to wav, flac and aiff")
+		}
+		depth := int(depthValue.Num)
+		if depthValue.Kind != gs.ValueNumber || float64(depth) != depthValue.Num || (depth != 16 && depth != 24 && depth != 32) {
+			return fmt.Errorf("render: bit_depth must be 16, 24 or 32")
+		}
+		action["bit_depth"] = depth
+	}
+
+	if fileValue, ok := args["file"]; ok {
+		if fileValue.Kind != gs.ValueString || strings.TrimSpace(fileValue.Str) == "" {
+			return fmt.Errorf("render: file must be a non-empty string")
+		}
+		action["file"] = fileValue.Str
+	}
+
+	p.actions = append(p.actions, action)
+	log.Printf("✅ Render: range=%v, format=%s, stems=%v", action["range"], format, action["stems"])
+	return nil
+}
+
+// SaveProject handles save_project() calls. With path it saves a copy of
+// the project under that name.
+func (r *ReaperDSL) SaveProject(args gs.Args) error {
+	p := r.parser
+
+	action := map[string]any{"action": "save_project"}
+	if pathValue, ok := args["path"]; ok {
+		if pathValue.Kind != gs.ValueString || strings.TrimSpace(pathValue.Str) == "" {
+			return fmt.Errorf("save_project: path must be a non-empty string")
+		}
+		action["path"] = pathValue.Str
+	}
+
+	p.actions = append(p.actions, action)
+	return nil
+}
+
+// SetProject handles set_project() calls to change project settings
+// (name, sample_rate, tempo).
+func (r *ReaperDSL) SetProject(args gs.Args) error {
+	p := r.parser
+
+	action := map[string]any{"action": "set_project"}
+	if nameValue, ok := args["name"]; ok {
+		if nameValue.Kind != gs.ValueString || strings.TrimSpace(nameValue.Str) == "" {
+			return fmt.Errorf("set_project: name must be a non-empty string")
+		}
+		action["name"] = nameValue.Str
+	}
+	if rateValue, ok := args["sample_rate"]; ok {
+		rate, err := sampleRateArg("set_project", rateValue)
+		if err != nil {
+			return err
+		}
+		action["sample_rate"] = rate
+	}
+	if tempoValue, ok := args["tempo"]; ok {
+		if tempoValue.Kind != gs.ValueNumber || tempoValue.Num < 20 || tempoValue.Num > 960 {
+			return fmt.Errorf("set_project: tempo must be a number of BPM between 20 and 960")
+		}
+		action["tempo"] = tempoValue.Num
+	}
+
+	if len(action) == 1 {
+		return fmt.Errorf("set_project requires at least one property: name, sample_rate, or tempo")
+	}
+	p.actions = append(p.actions, action)
+	return nil
+}
+
+// SetLoop handles set_loop() calls, which set the loop range in bars.
+// Example: set_loop(start_bar=9, end_bar=17) loops bars 9-16.
+func (r *ReaperDSL) SetLoop(args gs.Args) error {
+	p := r.parser
+
+	startBar, hasStart := args["start_bar"]
+	endBar, hasEnd := args["end_bar"]
+	start, end, err := barRange("set_loop", startBar, endBar, hasStart, hasEnd)
+	if err != nil {
+		return err
+	}
+
+	p.actions = append(p.actions, map[string]any{
+		"action":    "set_loop",
+		"start_bar": start,
+		"end_bar":   end,
+	})
+	return nil
+}
+
+// SetCursor handles set_cursor() calls, which move the edit cursor to a
+// 1-based bar or a position in seconds.
+func (r *ReaperDSL) SetCursor(args gs.Args) error {
+	p := r.parser
+
+	barValue, hasBar := args["bar"]
+	positionValue, hasPosition := args["position"]
+	if hasBar == hasPosition {
+		return fmt.Errorf("set_cursor requires exactly one of bar or position")
+	}
+
+	action := map[string]any{"action": "set_cursor"}
+	if hasBar {
+		if barValue.Kind != gs.ValueNumber || barValue.Num < 1 {
+			return fmt.Errorf("set_cursor: bar must be a number >= 1")
+		}
+		action["bar"] = barValue.Num
+	} else {
+		if positionValue.Kind != gs.ValueNumber || positionValue.Num < 0 {
+			return fmt.Errorf("set_cursor: position must be a number of seconds >= 0")
+		}
+		action["position"] = positionValue.Num
+	}
+
+	p.actions = append(p.actions, action)
+	return nil
+}
+
+// Play handles play() calls, which start playback from the edit cursor.
+func (r *ReaperDSL) Play(args gs.Args) error {
+	r.parser.actions = append(r.parser.actions, map[string]any{"action": "play"})
+	return nil
+}
+
+// Stop handles stop() calls, which stop playback or recording.
+func (r *ReaperDSL) Stop(args gs.Args) error {
+	r.parser.actions = append(r.parser.actions, map[string]any{"action": "stop"})
+	return nil
+}
+
+// barRange validates a start_bar/end_bar pair: both 1-based bars, with the
+// end after the start.
+func barRange(method string, startBar, endBar gs.Value, hasStart, hasEnd bool) (float64, float64, error) {
+	if !hasStart || !hasEnd {
+		return 0, 0, fmt.Errorf("%s requires both start_bar and end_bar", method)
+	}
+	if startBar.Kind != gs.ValueNumber || endBar.Kind != gs.ValueNumber {
+		return 0, 0, fmt.Errorf("%s: start_bar and end_bar must be numbers", method)
+	}
+	if startBar.Num < 1 {
+		return 0, 0, fmt.Errorf("%s: start_bar must be >= 1", method)
+	}
+	if endBar.Num <= startBar.Num {
+		return 0, 0, fmt.Errorf("%s: end_bar (%g) must be after start_bar (%g)", method, endBar.Num, startBar.Num)
+	}
+	return startBar.Num, endBar.Num, nil
+}
+
+// sampleRateArg validates a sample rate argument.
+func sampleRateArg(method string, value gs.Value) (int, error) {
+	rate := int(value.Num)
+	if value.Kind == gs.ValueNumber && float64(rate) == value.Num {
+		for _, supported := range projectSampleRates {
+			if rate == supported {
+				return rate, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%s: sample_rate must be one of %v", method, projectSampleRates)
+}
@@ -0,0 +1,106 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionalDSLParser_ProjectCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]any
+	}{
+		{
+			name:    "render defaults",
+			dslCode: `render()`,
+			want:    []map[string]any{{"action": "render", "range": "project", "format": "wav", "stems": false}},
+		},
+		{
+			name:    "render bar range as stems",
+			dslCode: `render(start_bar=17, end_bar=25, format="MP3", stems=true, file="Chorus")`,
+			want: []map[string]any{{
+				"action": "render", "range": "bars", "start_bar": 17.0, "end_bar": 25.0,
+				"format": "mp3", "stems": true, "file": "Chorus",
+			}},
+		},
+		{
+			name:    "render loop with quality",
+			dslCode: `render(range="loop", format="flac", sample_rate=96000, bit_depth=24)`,
+			want: []map[string]any{{
+				"action": "render", "range": "loop", "format": "flac", "stems": false,
+				"sample_rate": 96000, "bit_depth": 24,
+			}},
+		},
+		{
+			name:    "project settings and save",
+			dslCode: `set_project(name="Demo", sample_rate=48000, tempo=128); save_project(); save_project(path="Demo v2")`,
+			want: []map[string]any{
+				{"action": "set_project", "name": "Demo", "sample_rate": 48000, "tempo": 128.0},
+				{"action": "save_project"},
+				{"action": "save_project", "path": "Demo v2"},
+			},
+		},
+		{
+			name:    "transport",
+			dslCode: `set_loop(start_bar=9, end_bar=17); set_cursor(bar=9); play(); set_cursor(position=2.5); stop()`,
+			want: []map[string]any{
+				{"action": "set_loop", "start_bar": 9.0, "end_bar": 17.0},
+				{"action": "set_cursor", "bar": 9.0},
+				{"action": "play"},
+				{"action": "set_cursor", "position": 2.5},
+				{"action": "stop"},
+			},
+		},
+		{
+			name:    "mixed with track statements",
+			dslCode: `track(name="Bass"); render(range="selection")`,
+			want: []map[string]any{
+				{"action": "create_track", "index": 0, "name": "Bass"},
+				{"action": "render", "range": "selection", "format": "wav", "stems": false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			actions, err := parser.ParseDSL(tt.dslCode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, actions)
+		})
+	}
+}
+
+func TestFunctionalDSLParser_ProjectCommandErrors(t *testing.T) {
+	tests := []struct {
+		dslCode string
+		want    string
+	}{
+		{`render(range="chorus")`, "range must be one of project, loop, selection"},
+		{`render(range="loop", start_bar=1, end_bar=5)`, "either range or start_bar/end_bar"},
+		{`render(start_bar=5)`, "requires both start_bar and end_bar"},
+		{`render(format="m4a")`, `unknown format "m4a"`},
+		{`render(format="mp3", bit_depth=24)`, "bit_depth only applies to wav, flac and aiff"},
+		{`render(bit_depth=20)`, "bit_depth must be 16, 24 or 32"},
+		{`set_project(sample_rate=22050)`, "sample_rate must be one of"},
+		{`set_project(tempo=5)`, "tempo must be a number of BPM"},
+		{`set_loop(start_bar=9, end_bar=9)`, "end_bar (9) must be after start_bar (9)"},
+		{`set_loop(start_bar=0, end_bar=4)`, "start_bar must be >= 1"},
+		{`set_cursor(bar=2, position=1)`, "exactly one of bar or position"},
+		{`set_cursor(bar=0)`, "bar must be a number >= 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dslCode, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package daw
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// AddSend handles .add_send() calls, which route the track to another track:
+// track(id=1).add_send(to="Reverb Bus", volume_db=-12).
+// The destination is a 1-based track id or a track name; names resolve to
+// tracks created earlier in the script before tracks in the current state.
+// If there's a filtered collection, every track in it gets the send.
+func (r *ReaperDSL) AddSend(args gs.Args) error {
+	p := r.parser
+
+	toValue, ok := args["to"]
+	if !ok {
+		return fmt.Errorf("add_send requires to (destination track name or 1-based id)")
+	}
+	target, err := p.resolveTrackArg(toValue)
+	if err != nil {
+		return fmt.Errorf("add_send: %w", err)
+	}
+
+	props := map[string]any{}
+	for _, key := range []string{"volume_db", "pan"} {
+		if value, ok := args[key]; ok {
+			if value.Kind != gs.ValueNumber {
+				return fmt.Errorf("add_send: %s must be a number", key)
+			}
+			props[key] = value.Num
+		}
+	}
+
+	var sources []int
+	if filteredCollection, hasFiltered := p.data["current_filtered"]; hasFiltered {
+		delete(p.data, "current_filtered")
+		filtered, _ := filteredCollection.([]any)
+		for _, item := range filtered {
+			trackMap, ok := item.(map[string]any)
+			if !ok {
+				log.Printf("⚠️  AddSend: Item is not a map: %T", item)
+				continue
+			}
+			if trackIndex, ok := itemTrackIndex(trackMap); ok {
+				sources = append(sources, trackIndex)
+			}
+		}
+	} else {
+		if p.currentTrackIndex < 0 {
+			return fmt.Errorf("no track context for add_send call")
+		}
+		sources = []int{p.currentTrackIndex}
+	}
+
+	for _, source := range sources {
+		if source == target {
+			return fmt.Errorf("add_send: track %d cannot send to itself", source+1)
+		}
+		action := map[string]any{
+			"action": "add_send",
+			"track":  source,
+			"target": target,
+		}
+		for k, v := range props {
+			action[k] = v
+		}
+		p.actions = append(p.actions, action)
+	}
+	log.Printf("✅ AddSend: %d send(s) to track %d", len(sources), target)
+	return nil
+}
+
+// resolveTrackArg converts a 1-based track id or a track name to a 0-based
+// track index.
+func (p *FunctionalDSLParser) resolveTrackArg(value gs.Value) (int, error) {
+	switch value.Kind {
+	case gs.ValueNumber:
+		if value.Num < 1 || value.Num != float64(int(value.Num)) {
+			return -1, fmt.Errorf("track id must be a positive integer (1-based), got %g", value.Num)
+		}
+		return int(value.Num) - 1, nil
+	case gs.ValueString:
+		if index, ok := p.trackIndexByName(value.Str); ok {
+			return index, nil
+		}
+		return -1, fmt.Errorf("no track named %q", value.Str)
+	}
+	return -1, fmt.Errorf("expected a track id or name")
+}
+
+// trackIndexByName finds a track by name, preferring the most recent track
+// created by the script over tracks in the current state.
+func (p *FunctionalDSLParser) trackIndexByName(name string) (int, bool) {
+	for i := len(p.actions) - 1; i >= 0; i-- {
+		action := p.actions[i]
+		if action["action"] == "create_track" && action["name"] == name {
+			if index, ok := action["index"].(int); ok {
+				return index, true
+			}
+		}
+	}
+
+	tracks, _ := p.data["tracks"].([]any)
+	for _, track := range tracks {
+		if trackMap, ok := track.(map[string]any); ok && trackMap["name"] == name {
+			if index, ok := itemTrackIndex(trackMap); ok {
+				return index, true
+			}
+		}
+	}
+	return -1, false
+}
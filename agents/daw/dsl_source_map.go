@@ -0,0 +1,55 @@
+package daw
+
+import (
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+)
+
+// ActionSourceKey is the action key holding the DSL statement that produced
+// the action when source mapping is enabled.
+//
+// The value is a map with:
+//   - "statement": index of the statement in the script (0-based)
+//   - "text": the statement text
+//   - "span": the statement's diagnostics.Span in the script
+//   - "expanded_from": set when the action was produced by expanding a
+//     collection, macro or template rather than written directly: "filter",
+//     "map", "for_each", "@" plus the macro name, "template:" plus the
+//     template name, or the name of the let-bound collection the statement
+//     was called on
+const ActionSourceKey = "source"
+
+// SetSourceMap enables or disables source mapping. When enabled, ParseDSL
+// adds an ActionSourceKey entry to every action it returns, so callers can
+// highlight the statement behind each action and tell actions the model wrote
+// from those produced by expanding filter(...) and friends.
+func (p *FunctionalDSLParser) SetSourceMap(enabled bool) {
+	p.sourceMap = enabled
+}
+
+// markExpansion records that the current statement started iterating a
+// collection. Only the first expansion of a statement is kept, so
+// filter(...).for_each(...) is reported as a filter expansion.
+func (p *FunctionalDSLParser) markExpansion(kind string) {
+	if p.expansion != "" {
+		return
+	}
+	p.expansion = kind
+	p.expansionStart = len(p.actions)
+}
+
+// annotateActions attaches the source of a statement to the actions it
+// produced, starting at index first.
+func (p *FunctionalDSLParser) annotateActions(dslCode string, index int, statement dslStatement, first int) {
+	span := diagnostics.NewSpan(dslCode, statement.Start, statement.End)
+	for i := first; i < len(p.actions); i++ {
+		source := map[string]any{
+			"statement": index,
+			"text":      statement.Text,
+			"span":      span,
+		}
+		if p.expansion != "" && i >= p.expansionStart {
+			source["expanded_from"] = p.expansion
+		}
+		p.actions[i][ActionSourceKey] = source
+	}
+}
@@ -0,0 +1,83 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sourceMapTestState() map[string]any {
+	return map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Kick"},
+				map[string]any{"index": 1, "name": "Snare"},
+				map[string]any{"index": 2, "name": "Bass"},
+			},
+		},
+	}
+}
+
+func actionSource(t *testing.T, action map[string]any) map[string]any {
+	t.Helper()
+	source, ok := action[ActionSourceKey].(map[string]any)
+	require.True(t, ok, "action has no source: %v", action)
+	return source
+}
+
+func TestFunctionalDSLParser_SourceMap(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		parser, err := NewFunctionalDSLParser()
+		require.NoError(t, err)
+
+		actions, err := parser.ParseDSL(`track(id=1).set_track(mute=true)`)
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		assert.NotContains(t, actions[0], ActionSourceKey)
+	})
+
+	t.Run("statements and expansions", func(t *testing.T) {
+		parser, err := NewFunctionalDSLParser()
+		require.NoError(t, err)
+		parser.SetState(sourceMapTestState())
+		parser.SetSourceMap(true)
+
+		dslCode := "track(id=1).set_track(solo=true)\nfilter(tracks, track.index < 2).set_track(mute=true)"
+		actions, err := parser.ParseDSL(dslCode)
+		require.NoError(t, err)
+		require.Len(t, actions, 3)
+
+		direct := actionSource(t, actions[0])
+		assert.Equal(t, 0, direct["statement"])
+		assert.Equal(t, "track(id=1).set_track(solo=true)", direct["text"])
+		assert.NotContains(t, direct, "expanded_from")
+
+		for _, action := range actions[1:] {
+			source := actionSource(t, action)
+			assert.Equal(t, 1, source["statement"])
+			assert.Equal(t, "filter", source["expanded_from"])
+			span, ok := source["span"].(diagnostics.Span)
+			require.True(t, ok)
+			assert.Equal(t, diagnostics.Position{Offset: 33, Line: 2, Column: 1}, span.Start)
+			assert.Equal(t, len(dslCode), span.End.Offset)
+		}
+	})
+
+	t.Run("let-bound collection", func(t *testing.T) {
+		parser, err := NewFunctionalDSLParser()
+		require.NoError(t, err)
+		parser.SetState(sourceMapTestState())
+		parser.SetSourceMap(true)
+
+		actions, err := parser.ParseDSL("let drums = filter(tracks, track.index < 2); drums.set_track(mute=true)")
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		for _, action := range actions {
+			source := actionSource(t, action)
+			assert.Equal(t, 1, source["statement"])
+			assert.Equal(t, "drums", source["expanded_from"])
+		}
+	})
+}
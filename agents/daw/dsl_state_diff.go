@@ -0,0 +1,188 @@
+package daw
+
+import (
+	"reflect"
+	"sort"
+)
+
+// stateTrackProperties maps track fields in REAPER state to set_track arguments.
+var stateTrackProperties = []struct {
+	State  string
+	Action string
+}{
+	{"name", "name"},
+	{"volume_db", "volume_db"},
+	{"pan", "pan"},
+	{"muted", "mute"},
+	{"soloed", "solo"},
+	{"selected", "selected"},
+}
+
+// stateClipProperties are the clip fields compared by StateDiffActions besides
+// position, which is a move.
+var stateClipProperties = []string{"name", "length", "color", "selected"}
+
+// StateDiffActions returns the actions that turn the before state into the
+// after state: created and deleted tracks, changed track properties, FX
+// appended to a chain, and created, moved, edited and deleted clips.
+//
+// Tracks are matched by index and clips by index within their track, so the
+// diff is exact for edits that don't reorder them. Deletions come last and in
+// descending order so earlier actions see the original indexes.
+func StateDiffActions(before, after map[string]any) []map[string]any {
+	beforeTracks := indexStateItems(stateTracks(before))
+	afterTracks := indexStateItems(stateTracks(after))
+
+	var actions []map[string]any
+	for _, index := range sortedItemIndexes(afterTracks) {
+		track := afterTracks[index]
+		previous, existed := beforeTracks[index]
+		if !existed {
+			action := map[string]any{"action": "create_track", "index": index}
+			if name, ok := track["name"].(string); ok {
+				action["name"] = name
+			}
+			actions = append(actions, action)
+			previous = map[string]any{"name": track["name"]}
+		}
+
+		setTrack := map[string]any{}
+		for _, property := range stateTrackProperties {
+			value, ok := track[property.State]
+			if ok && !sameStateValue(value, previous[property.State]) {
+				setTrack[property.Action] = value
+			}
+		}
+		if len(setTrack) > 0 {
+			setTrack["action"] = "set_track"
+			setTrack["track"] = index
+			actions = append(actions, setTrack)
+		}
+
+		actions = append(actions, fxDiffActions(index, previous["fx"], track["fx"])...)
+		actions = append(actions, clipDiffActions(index, previous["clips"], track["clips"])...)
+	}
+
+	removed := sortedItemIndexes(beforeTracks)
+	for i := len(removed) - 1; i >= 0; i-- {
+		if _, kept := afterTracks[removed[i]]; !kept {
+			actions = append(actions, map[string]any{"action": "delete_track", "track": removed[i]})
+		}
+	}
+	return actions
+}
+
+// DecompileStateDiff returns the DSL that turns the before state into the
+// after state.
+func DecompileStateDiff(before, after map[string]any) (string, error) {
+	decompiler := NewDSLDecompiler()
+	decompiler.SetState(before)
+	return decompiler.Decompile(StateDiffActions(before, after))
+}
+
+// fxDiffActions returns add_track_fx actions for FX appended to a chain.
+func fxDiffActions(track int, before, after any) []map[string]any {
+	beforeFX, _ := before.([]any)
+	afterFX, _ := after.([]any)
+
+	var actions []map[string]any
+	for i := len(beforeFX); i < len(afterFX); i++ {
+		if name := entryName(afterFX[i]); name != "" {
+			actions = append(actions, map[string]any{"action": "add_track_fx", "track": track, "fxname": name})
+		}
+	}
+	return actions
+}
+
+// clipDiffActions returns the actions for clip changes on one track.
+func clipDiffActions(track int, before, after any) []map[string]any {
+	beforeClips := indexStateItems(asItemList(before))
+	afterClips := indexStateItems(asItemList(after))
+
+	var actions []map[string]any
+	for _, index := range sortedItemIndexes(afterClips) {
+		clip := afterClips[index]
+		previous, existed := beforeClips[index]
+		if !existed {
+			action := map[string]any{"action": "create_clip", "track": track}
+			previous = map[string]any{}
+			for _, key := range []string{"position", "length"} {
+				if value, ok := clip[key]; ok {
+					action[key] = value
+					previous[key] = value
+				}
+			}
+			actions = append(actions, action)
+		}
+
+		if position, ok := clip["position"]; ok && !sameStateValue(position, previous["position"]) {
+			actions = append(actions, map[string]any{"action": "set_clip_position", "track": track, "clip": index, "position": position})
+		}
+
+		setClip := map[string]any{}
+		for _, property := range stateClipProperties {
+			if value, ok := clip[property]; ok && !sameStateValue(value, previous[property]) {
+				setClip[property] = value
+			}
+		}
+		if len(setClip) > 0 {
+			setClip["action"] = "set_clip"
+			setClip["track"] = track
+			setClip["clip"] = index
+			actions = append(actions, setClip)
+		}
+	}
+
+	removed := sortedItemIndexes(beforeClips)
+	for i := len(removed) - 1; i >= 0; i-- {
+		if _, kept := afterClips[removed[i]]; !kept {
+			actions = append(actions, map[string]any{"action": "delete_clip", "track": track, "clip": removed[i]})
+		}
+	}
+	return actions
+}
+
+// indexStateItems keys state entries by their index field, falling back to
+// their position in the list.
+func indexStateItems(items []any) map[int]map[string]any {
+	indexed := make(map[int]map[string]any, len(items))
+	for i, item := range items {
+		itemMap, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		index := i
+		if value, ok := getNumericValue(itemMap["index"]); ok {
+			index = int(value)
+		}
+		indexed[index] = itemMap
+	}
+	return indexed
+}
+
+// sortedItemIndexes returns the keys of indexed in ascending order.
+func sortedItemIndexes(indexed map[int]map[string]any) []int {
+	indexes := make([]int, 0, len(indexed))
+	for index := range indexed {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// sameStateValue compares state values, treating numbers of different types
+// (int from Go callers, float64 from JSON) as equal when their values are.
+func sameStateValue(a, b any) bool {
+	aNum, aIsNum := getNumericValue(a)
+	bNum, bIsNum := getNumericValue(b)
+	if aIsNum && bIsNum {
+		return aNum == bNum
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// asItemList returns value as a state list, or nil.
+func asItemList(value any) []any {
+	items, _ := value.([]any)
+	return items
+}
@@ -0,0 +1,70 @@
+package daw
+
+import (
+	"strings"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
+)
+
+// dslStatement is a single top-level statement of a DSL script together with
+// its byte offsets in the original source.
+type dslStatement = dslscan.Statement
+
+// splitDSLStatements splits DSL code into top-level statements.
+// Statements are separated by ";" or by a newline, unless the next line
+// continues a method chain (starts with "."). Separators inside strings,
+// parentheses, brackets and braces are ignored.
+func splitDSLStatements(code string) []dslStatement {
+	return dslscan.Split(code, true)
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside strings,
+// parentheses, brackets and braces. Parts are trimmed; empty parts are dropped.
+func splitTopLevel(s string, sep byte) []string {
+	return dslscan.SplitTopLevel(s, sep)
+}
+
+// rawCallArgs returns the raw top-level arguments of the first call to name in
+// a statement. For `map(tracks, @gain(db=-3))` and name "map" it returns
+// ["tracks", "@gain(db=-3)"].
+//
+// Grammar School stores positional arguments under a single empty key, so
+// calls taking several positional arguments (map, for_each) read them here.
+func rawCallArgs(statement, name string) ([]string, bool) {
+	open := findCallOpen(statement, name)
+	if open < 0 {
+		return nil, false
+	}
+
+	closeIndex := matchingParen(statement, open)
+	if closeIndex < 0 {
+		return nil, false
+	}
+
+	return splitTopLevel(statement[open+1:closeIndex], ','), true
+}
+
+// findCallOpen returns the index of the opening parenthesis of the first
+// top-level call to name in code, or -1 if there is none.
+func findCallOpen(code, name string) int {
+	prefix := name + "("
+	var scanner dslscan.Scanner
+	for i := 0; i < len(code); i++ {
+		depth, isCode := scanner.Next(code[i])
+		if isCode && depth == 0 && strings.HasPrefix(code[i:], prefix) && (i == 0 || !isIdentifierChar(code[i-1])) {
+			return i + len(name)
+		}
+	}
+	return -1
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open,
+// or -1 if it is unbalanced.
+func matchingParen(code string, open int) int {
+	return dslscan.Closing(code, open)
+}
+
+// isIdentifierChar reports whether c can appear in a DSL identifier.
+func isIdentifierChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
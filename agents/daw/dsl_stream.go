@@ -0,0 +1,121 @@
+package daw
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
+)
+
+// DSLStream executes a DSL script while its text is still arriving, e.g. from
+// a streaming LLM response. Text is passed to Write in chunks of any size;
+// each top-level statement runs as soon as it is complete and its actions are
+// passed to the callback before later text has arrived.
+//
+// Statements are split the same way as ParseDSL splits them: on ";" or on a
+// newline, unless the next line continues a method chain (starts with ".").
+// A statement ended by a newline therefore only runs once the first
+// character of the next line shows it is not a chain continuation, or when
+// the stream is closed.
+type DSLStream struct {
+	parser   *FunctionalDSLParser
+	ctx      context.Context
+	callback StreamActionCallback
+	splitter dslscan.Splitter
+	index    int // Number of statements executed
+	err      error
+}
+
+// Stream starts an incremental parse of a new script. The parser is reset as
+// for ParseDSL; it must not be used for anything else until Close returns.
+// If callback returns an error the stream stops and Write returns it.
+func (p *FunctionalDSLParser) Stream(ctx context.Context, callback StreamActionCallback) *DSLStream {
+	p.reset()
+	p.diags = nil
+
+	return &DSLStream{
+		parser:   p,
+		ctx:      ctx,
+		callback: callback,
+		splitter: dslscan.Splitter{Newlines: true},
+	}
+}
+
+// Write appends a chunk of DSL text and executes every statement it
+// completes. After an error, Write and Close return that error.
+func (s *DSLStream) Write(chunk string) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, statement := range s.splitter.Write(chunk) {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Close executes the last statement and returns every action of the script.
+func (s *DSLStream) Close() ([]map[string]any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, statement := range s.splitter.Close() {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return nil, err
+		}
+	}
+
+	p := s.parser
+	code := s.splitter.Source()
+	if len(p.actions) == 0 {
+		err := fmt.Errorf("no actions found in DSL code")
+		s.err = p.failParse(code, p.checker.Explain(code, 0, len(code), err), err)
+		return nil, s.err
+	}
+
+	log.Printf("✅ Functional DSL Parser: Streamed %d actions from %d statements", len(p.actions), s.index)
+	return p.actions, nil
+}
+
+// run checks and executes a single statement. Only the statement is checked,
+// with diagnostic spans pointing into the whole script.
+func (s *DSLStream) run(statement dslStatement) error {
+	p := s.parser
+	source := s.splitter.Source()[:statement.End]
+
+	diags := append(p.checker.CheckRange(source, statement.Start, statement.End),
+		p.trackNameDiagnostics(source, statement.Start, statement.End)...)
+	p.diags = append(p.diags, diags...)
+	if first, ok := diagnostics.First(diags); ok {
+		return diagnostics.NewError(source, p.diags, fmt.Errorf("failed to execute DSL: %s", first))
+	}
+	for _, diag := range diags {
+		log.Printf("⚠️  DSL %s", diag)
+	}
+
+	first := len(p.actions)
+	p.expansion = ""
+	if err := p.executeStatement(s.ctx, statement.Text); err != nil {
+		diag := p.checker.Explain(source, statement.Start, statement.End, err)
+		return p.failParse(source, diag, fmt.Errorf("failed to execute DSL: %w", err))
+	}
+	if p.sourceMap {
+		p.annotateActions(source, s.index, statement, first)
+	}
+	s.index++
+
+	if s.callback == nil {
+		return nil
+	}
+	for _, action := range p.actions[first:] {
+		if err := s.callback(action); err != nil {
+			return fmt.Errorf("stream callback: %w", err)
+		}
+	}
+	return nil
+}
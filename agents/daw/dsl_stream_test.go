@@ -0,0 +1,230 @@
+package daw
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkedProvider streams a fixed output in small chunks, recording how much
+// had been sent each time an action was emitted.
+type chunkedProvider struct {
+	output    string
+	chunkSize int
+	sent      int
+	streamErr error
+}
+
+func (c *chunkedProvider) Name() string { return "chunked" }
+
+func (c *chunkedProvider) Generate(ctx context.Context, request *llm.GenerationRequest) (*llm.GenerationResponse, error) {
+	return &llm.GenerationResponse{RawOutput: c.output}, nil
+}
+
+func (c *chunkedProvider) GenerateStream(ctx context.Context, request *llm.GenerationRequest, callback llm.StreamCallback) (*llm.GenerationResponse, error) {
+	for c.sent < len(c.output) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(c.sent+c.chunkSize, len(c.output))
+		delta := c.output[c.sent:end]
+		c.sent = end
+		_ = callback(llm.StreamEvent{Type: "text_delta", Message: delta})
+	}
+	if c.streamErr != nil {
+		return nil, c.streamErr
+	}
+	return &llm.GenerationResponse{RawOutput: c.output}, nil
+}
+
+func streamTestParser(t *testing.T) *FunctionalDSLParser {
+	t.Helper()
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Drums"},
+				map[string]any{"index": 1, "name": "Bass"},
+			},
+		},
+	})
+	return parser
+}
+
+func TestDSLStream_MatchesParseDSL(t *testing.T) {
+	scripts := []string{
+		`track(name="Keys").set_track(volume_db=-6); track(id=1).add_fx(fxname="ReaComp")`,
+		"track(name=\"Pad\")\n  .add_fx(fxname=\"ReaVerbate\")\n  .set_track(pan=0.3)\ntrack(id=2).set_track(mute=true)",
+		"let drums = filter(tracks, track.index < 1)\ndrums.set_track(solo=true)\n\n",
+		`track(name="a;b").new_clip(bar=1, length_bars=4)`,
+	}
+
+	for _, script := range scripts {
+		want, err := streamTestParser(t).ParseDSL(script)
+		require.NoError(t, err, script)
+
+		var emitted []map[string]any
+		stream := streamTestParser(t).Stream(context.Background(), func(action map[string]any) error {
+			emitted = append(emitted, action)
+			return nil
+		})
+		for i := 0; i < len(script); i++ {
+			require.NoError(t, stream.Write(script[i:i+1]), script)
+		}
+		got, err := stream.Close()
+		require.NoError(t, err, script)
+		assert.Equal(t, want, got, script)
+		assert.Equal(t, want, emitted, script)
+	}
+}
+
+func TestDSLStream_EmitsCompleteStatements(t *testing.T) {
+	var emitted []string
+	stream := streamTestParser(t).Stream(context.Background(), func(action map[string]any) error {
+		emitted = append(emitted, action["action"].(string))
+		return nil
+	})
+
+	require.NoError(t, stream.Write(`track(name="Lead"`))
+	assert.Empty(t, emitted)
+
+	require.NoError(t, stream.Write(`); track(id=1).set_track(mute=`))
+	assert.Equal(t, []string{"create_track"}, emitted)
+
+	// A newline only ends the statement once the next line is not a chain
+	require.NoError(t, stream.Write("true)\n"))
+	assert.Equal(t, []string{"create_track"}, emitted)
+	require.NoError(t, stream.Write("  .add_fx(fxname=\"ReaEQ\")\n"))
+	assert.Equal(t, []string{"create_track"}, emitted)
+	require.NoError(t, stream.Write("play()"))
+	assert.Equal(t, []string{"create_track", "set_track", "add_track_fx"}, emitted)
+
+	actions, err := stream.Close()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"create_track", "set_track", "add_track_fx", "play"}, emitted)
+	assert.Len(t, actions, 4)
+}
+
+func TestDSLStream_SourceMap(t *testing.T) {
+	parser := streamTestParser(t)
+	parser.SetSourceMap(true)
+	stream := parser.Stream(context.Background(), nil)
+	require.NoError(t, stream.Write("track(name=\"Lead\")\nplay()"))
+	actions, err := stream.Close()
+	require.NoError(t, err)
+
+	source := actions[1][ActionSourceKey].(map[string]any)
+	assert.Equal(t, 1, source["statement"])
+	assert.Equal(t, "play()", source["text"])
+	assert.Equal(t, 2, source["span"].(diagnostics.Span).Start.Line)
+}
+
+func TestDSLStream_Diagnostics(t *testing.T) {
+	code := "track(name=\"Lead\")\n  .set_track(mute=true, volume=-3)\nlet bass = filter(tracks, track.name == \"Bas\"); play()"
+
+	parser := streamTestParser(t)
+	_, err := parser.ParseDSL(code)
+	require.NoError(t, err)
+	want := parser.Diagnostics()
+	require.Len(t, want, 2)
+
+	// Each statement is checked on its own, with spans into the whole script
+	stream := parser.Stream(context.Background(), nil)
+	for i := 0; i < len(code); i += 7 {
+		require.NoError(t, stream.Write(code[i:min(i+7, len(code))]))
+	}
+	_, err = stream.Close()
+	require.NoError(t, err)
+	assert.Equal(t, want, parser.Diagnostics())
+}
+
+func TestDSLStream_Errors(t *testing.T) {
+	t.Run("failed statement stops the stream", func(t *testing.T) {
+		var emitted int
+		stream := streamTestParser(t).Stream(context.Background(), func(action map[string]any) error {
+			emitted++
+			return nil
+		})
+		require.NoError(t, stream.Write(`track(name="Lead"); `))
+		err := stream.Write(`track(id=1).set_volume(db=-3); play()`)
+		require.Error(t, err)
+		assert.Equal(t, 1, emitted)
+
+		diagErr, ok := diagnostics.AsError(err)
+		require.True(t, ok)
+		first, _ := diagnostics.First(diagErr.Diagnostics)
+		assert.Equal(t, diagnostics.KindUnknownMethod, first.Kind)
+		assert.Equal(t, 33, first.Span.Start.Column)
+
+		assert.Equal(t, err, stream.Write("stop()"))
+		_, closeErr := stream.Close()
+		assert.Equal(t, err, closeErr)
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		stream := streamTestParser(t).Stream(context.Background(), func(action map[string]any) error {
+			return errors.New("client gone")
+		})
+		err := stream.Write(`play(); stop()`)
+		assert.ErrorContains(t, err, "client gone")
+	})
+
+	t.Run("no actions", func(t *testing.T) {
+		stream := streamTestParser(t).Stream(context.Background(), nil)
+		require.NoError(t, stream.Write("  \n"))
+		_, err := stream.Close()
+		assert.ErrorContains(t, err, "no actions found in DSL code")
+	})
+}
+
+func TestDawAgent_GenerateActionsStream_Incremental(t *testing.T) {
+	state := map[string]any{"tracks": []any{map[string]any{"index": 0, "name": "Drums"}}}
+
+	t.Run("actions arrive while streaming", func(t *testing.T) {
+		provider := &chunkedProvider{
+			output:    "track(name=\"Bass\").set_track(volume_db=-3)\ntrack(id=1).add_fx(fxname=\"ReaComp\")\nplay()",
+			chunkSize: 5,
+		}
+		agent := NewDawAgentWithProvider(&config.Config{}, provider)
+
+		var sentAt []int
+		result, err := agent.GenerateActionsStream(context.Background(), "add a bass track", state, func(action map[string]any) error {
+			sentAt = append(sentAt, provider.sent)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Actions, 4)
+		require.Len(t, sentAt, 4)
+		assert.Less(t, sentAt[0], len(provider.output), "first statement should run before the output is complete")
+		assert.Equal(t, "create_track", result.Actions[0]["action"])
+		assert.Equal(t, "play", result.Actions[3]["action"])
+	})
+
+	t.Run("out of scope", func(t *testing.T) {
+		provider := &chunkedProvider{output: "// ERROR: cannot bake cakes", chunkSize: 1}
+		agent := NewDawAgentWithProvider(&config.Config{}, provider)
+		_, err := agent.GenerateActionsStream(context.Background(), "bake a cake", state, func(map[string]any) error { return nil })
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "out of scope: cannot bake cakes")
+	})
+
+	t.Run("parse error cancels the stream", func(t *testing.T) {
+		provider := &chunkedProvider{
+			output:    "track(id=1).set_volume(db=-3)\n" + "play()\nstop()\nplay()\nstop()\n",
+			chunkSize: 4,
+			streamErr: errors.New("should not be reached"),
+		}
+		agent := NewDawAgentWithProvider(&config.Config{}, provider)
+		_, err := agent.GenerateActionsStream(context.Background(), "turn it down", state, func(map[string]any) error { return nil })
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse DSL")
+		assert.Less(t, provider.sent, len(provider.output))
+	})
+}
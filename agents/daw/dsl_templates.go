@@ -0,0 +1,438 @@
+package daw
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+)
+
+// TrackTemplate is a named multi-track setup: tracks with instruments, FX
+// chains, sends, colours and folder layout. Scripts instantiate it with
+// template(name="rock_band"), optionally changing how many tracks of a role
+// it creates: template(name="rock_band", guitar=2, keys=0).
+//
+// A template expands to DSL statements (see DSL), so instantiating it
+// produces the same actions as writing the setup by hand.
+type TrackTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Tracks      []TemplateTrack `json:"tracks"`
+}
+
+// TemplateTrack is a track of a template. A track with Tracks is a folder
+// containing them.
+type TemplateTrack struct {
+	Name       string          `json:"name"`
+	Role       string          `json:"role,omitempty"`  // Key for count overrides; defaults to the name in snake_case
+	Count      int             `json:"count,omitempty"` // Number of copies, numbered "Name 1", "Name 2"; defaults to 1
+	Instrument string          `json:"instrument,omitempty"`
+	Color      string          `json:"color,omitempty"`
+	VolumeDB   *float64        `json:"volume_db,omitempty"`
+	Pan        *float64        `json:"pan,omitempty"`
+	FX         []string        `json:"fx,omitempty"`
+	Sends      []TemplateSend  `json:"sends,omitempty"`
+	Tracks     []TemplateTrack `json:"tracks,omitempty"`
+}
+
+// TemplateSend routes a template track to another track of the template.
+type TemplateSend struct {
+	To       string   `json:"to"` // Name of the destination track
+	VolumeDB *float64 `json:"volume_db,omitempty"`
+}
+
+// role returns the override key of the track.
+func (t TemplateTrack) role() string {
+	if t.Role != "" {
+		return t.Role
+	}
+	return templateKey(t.Name)
+}
+
+// count returns the number of copies of the track, applying overrides.
+func (t TemplateTrack) count(counts map[string]int) int {
+	if count, ok := counts[t.role()]; ok {
+		return count
+	}
+	if t.Count > 0 {
+		return t.Count
+	}
+	return 1
+}
+
+// Roles returns the override keys of all tracks in the template, in order.
+func (t TrackTemplate) Roles() []string {
+	var roles []string
+	var walk func(tracks []TemplateTrack)
+	walk = func(tracks []TemplateTrack) {
+		for _, track := range tracks {
+			roles = append(roles, track.role())
+			walk(track.Tracks)
+		}
+	}
+	walk(t.Tracks)
+	return roles
+}
+
+// templateTrackLine is a track of an expanded template in project order.
+type templateTrackLine struct {
+	TemplateTrack
+	Template    string // Name of the template track, before copies are numbered
+	FolderDepth int
+}
+
+// DSL expands the template to DSL statements. counts overrides how many
+// tracks of each role are created; 0 leaves a role (and a folder's contents)
+// out, sends to a role with several tracks go to each of them, and sends to
+// tracks that are left out are dropped.
+func (t TrackTemplate) DSL(counts map[string]int) (string, error) {
+	roles := t.Roles()
+	for role, count := range counts {
+		if !containsString(roles, role) {
+			return "", fmt.Errorf("template %s has no role %q (roles: %s)", t.Name, role, strings.Join(roles, ", "))
+		}
+		if count < 0 {
+			return "", fmt.Errorf("template %s: count for %s must not be negative", t.Name, role)
+		}
+	}
+
+	lines := flattenTemplateTracks(t.Tracks, counts)
+	if len(lines) == 0 {
+		return "", fmt.Errorf("template %s: overrides leave no tracks", t.Name)
+	}
+	created := make(map[string][]string, len(lines))
+	for _, line := range lines {
+		created[line.Template] = append(created[line.Template], line.Name)
+	}
+
+	var statements, sends []string
+	for i, line := range lines {
+		statement, err := templateTrackDSL(line)
+		if err != nil {
+			return "", fmt.Errorf("template %s: track %s: %w", t.Name, line.Name, err)
+		}
+
+		variable := fmt.Sprintf("t%d", i+1)
+		hasSends := false
+		for _, send := range line.Sends {
+			for _, to := range created[send.To] {
+				args := []dslArg{{"to", quoteDSLString(to)}}
+				if send.VolumeDB != nil {
+					args = append(args, dslArg{"volume_db", formatDSLNumber(*send.VolumeDB)})
+				}
+				sends = append(sends, variable+"."+formatDSLCall("add_send", args))
+				hasSends = true
+			}
+		}
+		if hasSends {
+			statement = "let " + variable + " = " + statement
+		}
+		statements = append(statements, statement)
+	}
+
+	// Sends come last so every destination exists
+	return strings.Join(append(statements, sends...), "\n"), nil
+}
+
+// flattenTemplateTracks lists the tracks in project order with REAPER folder
+// depths: 1 on a folder track, and -N on the last track of N closing folders.
+func flattenTemplateTracks(tracks []TemplateTrack, counts map[string]int) []templateTrackLine {
+	var lines []templateTrackLine
+	for _, track := range tracks {
+		count := track.count(counts)
+		for copyIndex := 1; copyIndex <= count; copyIndex++ {
+			line := templateTrackLine{TemplateTrack: track, Template: track.Name}
+			if count > 1 {
+				line.Name = fmt.Sprintf("%s %d", track.Name, copyIndex)
+			}
+
+			children := flattenTemplateTracks(track.Tracks, counts)
+			if len(children) > 0 {
+				line.FolderDepth = 1
+				children[len(children)-1].FolderDepth--
+			}
+			lines = append(lines, line)
+			lines = append(lines, children...)
+		}
+	}
+	return lines
+}
+
+// templateTrackDSL renders a template track as a track() call with its
+// properties and FX chained.
+func templateTrackDSL(line templateTrackLine) (string, error) {
+	trackArgs := []dslArg{{"name", quoteDSLString(line.Name)}}
+	if line.Instrument != "" {
+		trackArgs = append(trackArgs, dslArg{"instrument", quoteDSLString(line.Instrument)})
+	}
+	calls := []string{formatDSLCall("track", trackArgs)}
+
+	var props []dslArg
+	if line.VolumeDB != nil {
+		props = append(props, dslArg{"volume_db", formatDSLNumber(*line.VolumeDB)})
+	}
+	if line.Pan != nil {
+		if *line.Pan < -1 || *line.Pan > 1 {
+			return "", fmt.Errorf("pan %g is outside -1..1", *line.Pan)
+		}
+		props = append(props, dslArg{"pan", formatDSLNumber(*line.Pan)})
+	}
+	if line.Color != "" {
+		props = append(props, dslArg{"color", quoteDSLString(line.Color)})
+	}
+	if line.FolderDepth != 0 {
+		props = append(props, dslArg{"folder_depth", fmt.Sprintf("%d", line.FolderDepth)})
+	}
+	if len(props) > 0 {
+		calls = append(calls, formatDSLCall("set_track", props))
+	}
+
+	for _, fx := range line.FX {
+		calls = append(calls, formatDSLCall("add_fx", []dslArg{{"fxname", quoteDSLString(fx)}}))
+	}
+	return strings.Join(calls, "."), nil
+}
+
+// quoteDSLString renders s as a DSL string literal.
+func quoteDSLString(s string) string {
+	literal, _ := formatDSLValue(s)
+	return literal
+}
+
+// formatDSLNumber renders f as a DSL number literal.
+func formatDSLNumber(f float64) string {
+	literal, _ := formatDSLValue(f)
+	return literal
+}
+
+// templateKey normalises a template or role name: "Podcast 3-person" becomes
+// "podcast_3_person".
+func templateKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9')
+	}), "_")
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateRegistry holds the track templates available to DSL scripts.
+// It is safe for concurrent use.
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]TrackTemplate
+}
+
+// NewTemplateRegistry creates an empty template registry.
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: make(map[string]TrackTemplate),
+	}
+}
+
+// Register adds a template to the registry, replacing any template with the
+// same name. Names are normalised, so "Rock Band" registers rock_band.
+func (r *TemplateRegistry) Register(template TrackTemplate) error {
+	template.Name = templateKey(template.Name)
+	if template.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if len(template.Tracks) == 0 {
+		return fmt.Errorf("template %s has no tracks", template.Name)
+	}
+
+	names := make(map[string]bool)
+	var sends []TemplateSend
+	var walk func(tracks []TemplateTrack) error
+	walk = func(tracks []TemplateTrack) error {
+		for _, track := range tracks {
+			if strings.TrimSpace(track.Name) == "" {
+				return fmt.Errorf("template %s: every track needs a name", template.Name)
+			}
+			for _, text := range append([]string{track.Name, track.Instrument, track.Color}, track.FX...) {
+				if strings.Contains(text, `"`) {
+					return fmt.Errorf("template %s: track %s: %q contains a double quote", template.Name, track.Name, text)
+				}
+			}
+			if track.Count < 0 {
+				return fmt.Errorf("template %s: track %s: count must not be negative", template.Name, track.Name)
+			}
+			names[track.Name] = true
+			sends = append(sends, track.Sends...)
+			if err := walk(track.Tracks); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(template.Tracks); err != nil {
+		return err
+	}
+
+	roles := template.Roles()
+	seen := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		if !isDSLIdentifier(role) {
+			return fmt.Errorf("template %s: invalid role %q", template.Name, role)
+		}
+		if seen[role] {
+			return fmt.Errorf("template %s: duplicate role %q", template.Name, role)
+		}
+		seen[role] = true
+	}
+	for _, send := range sends {
+		if !names[send.To] {
+			return fmt.Errorf("template %s: send to unknown track %q", template.Name, send.To)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.templates[template.Name]; exists {
+		log.Printf("⚠️  Replacing track template %s", template.Name)
+	}
+	r.templates[template.Name] = template
+	return nil
+}
+
+// Load registers templates from a JSON array of TrackTemplate.
+func (r *TemplateRegistry) Load(data []byte) error {
+	var templates []TrackTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("failed to parse track templates: %w", err)
+	}
+	for _, template := range templates {
+		if err := r.Register(template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the template registered under name, which is normalised the
+// same way as in Register.
+func (r *TemplateRegistry) Get(name string) (TrackTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	template, ok := r.templates[templateKey(name)]
+	return template, ok
+}
+
+// Names returns the sorted names of all registered templates.
+func (r *TemplateRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe lists the registered templates for the model, one per line:
+// "name - description (roles: a, b, c)". Empty when there are no templates.
+func (r *TemplateRegistry) Describe() string {
+	var lines []string
+	for _, name := range r.Names() {
+		template, _ := r.Get(name)
+		line := name
+		if template.Description != "" {
+			line += " - " + template.Description
+		}
+		line += " (roles: " + strings.Join(template.Roles(), ", ") + ")"
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+//go:embed track_templates.json
+var builtinTrackTemplates []byte
+
+// defaultTemplateRegistry holds the built-in templates and is shared by all
+// parsers unless overridden with SetTemplateRegistry.
+var defaultTemplateRegistry = NewTemplateRegistry()
+
+func init() {
+	if err := defaultTemplateRegistry.Load(builtinTrackTemplates); err != nil {
+		panic(fmt.Sprintf("invalid built-in track templates: %v", err))
+	}
+}
+
+// DefaultTemplateRegistry returns the registry used by new parsers, which
+// starts with the built-in templates.
+func DefaultTemplateRegistry() *TemplateRegistry {
+	return defaultTemplateRegistry
+}
+
+// SetTemplateRegistry sets the registry used to resolve template() calls.
+func (p *FunctionalDSLParser) SetTemplateRegistry(registry *TemplateRegistry) {
+	if registry == nil {
+		registry = defaultTemplateRegistry
+	}
+	p.templates = registry
+}
+
+// Template handles template() statements, which create a multi-track setup:
+// template(name="rock_band", guitar=2). Arguments other than name set how
+// many tracks of a role are created.
+func (r *ReaperDSL) Template(args gs.Args) error {
+	p := r.parser
+
+	nameValue, ok := args["name"]
+	if !ok || nameValue.Kind != gs.ValueString {
+		return fmt.Errorf("template requires name (available: %s)", strings.Join(p.templates.Names(), ", "))
+	}
+	template, ok := p.templates.Get(nameValue.Str)
+	if !ok {
+		return fmt.Errorf("unknown template %q (available: %s)", nameValue.Str, strings.Join(p.templates.Names(), ", "))
+	}
+
+	counts := make(map[string]int)
+	for key, value := range args {
+		if key == "name" {
+			continue
+		}
+		if value.Kind != gs.ValueNumber || value.Num != float64(int(value.Num)) {
+			return fmt.Errorf("template %s: %s must be a whole number of tracks", template.Name, key)
+		}
+		counts[key] = int(value.Num)
+	}
+
+	dslCode, err := template.DSL(counts)
+	if err != nil {
+		return err
+	}
+	return p.expandTemplate(template.Name, dslCode)
+}
+
+// expandTemplate runs the statements of an expanded template in a scope of
+// their own, so its track variables don't leak into the script.
+func (p *FunctionalDSLParser) expandTemplate(name, dslCode string) error {
+	p.markExpansion("template:" + name)
+	callerScope := p.scope
+	p.scope = newDSLScope()
+	defer func() { p.scope = callerScope }()
+
+	log.Printf("🧱 Expanding track template %s", name)
+	for _, statement := range splitDSLStatements(dslCode) {
+		if err := p.executeStatement(context.Background(), statement.Text); err != nil {
+			return fmt.Errorf("template %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestTrackTemplate_DSL(t *testing.T) {
+	template := TrackTemplate{
+		Name: "studio",
+		Tracks: []TemplateTrack{
+			{
+				Name:  "Band",
+				Color: "red",
+				Tracks: []TemplateTrack{
+					{Name: "Guitar", Count: 2, FX: []string{"ReaEQ"}, Sends: []TemplateSend{{To: "Reverb", VolumeDB: floatPtr(-12)}}},
+					{Name: "Keys", Tracks: []TemplateTrack{{Name: "Piano", Instrument: "ReaSynth"}}},
+				},
+			},
+			{Name: "Reverb", VolumeDB: floatPtr(-6), Pan: floatPtr(0.25), FX: []string{"ReaVerbate"}},
+		},
+	}
+	assert.Equal(t, []string{"band", "guitar", "keys", "piano", "reverb"}, template.Roles())
+
+	dslCode, err := template.DSL(nil)
+	require.NoError(t, err)
+	assert.Equal(t, `track(name="Band").set_track(color="red", folder_depth=1)
+let t2 = track(name="Guitar 1").add_fx(fxname="ReaEQ")
+let t3 = track(name="Guitar 2").add_fx(fxname="ReaEQ")
+track(name="Keys").set_track(folder_depth=1)
+track(name="Piano", instrument="ReaSynth").set_track(folder_depth=-2)
+track(name="Reverb").set_track(volume_db=-6, pan=0.25).add_fx(fxname="ReaVerbate")
+t2.add_send(to="Reverb", volume_db=-12)
+t3.add_send(to="Reverb", volume_db=-12)`, dslCode)
+
+	t.Run("overrides", func(t *testing.T) {
+		dslCode, err := template.DSL(map[string]int{"guitar": 1, "keys": 0, "reverb": 0})
+		require.NoError(t, err)
+		assert.Equal(t, `track(name="Band").set_track(color="red", folder_depth=1)
+track(name="Guitar").set_track(folder_depth=-1).add_fx(fxname="ReaEQ")`, dslCode)
+	})
+
+	t.Run("sends to copies", func(t *testing.T) {
+		cue := TrackTemplate{
+			Name: "cue",
+			Tracks: []TemplateTrack{
+				{Name: "Talkback", Sends: []TemplateSend{{To: "Headphones"}}},
+				{Name: "Headphones", Count: 2},
+			},
+		}
+		dslCode, err := cue.DSL(nil)
+		require.NoError(t, err)
+		assert.Equal(t, `let t1 = track(name="Talkback")
+track(name="Headphones 1")
+track(name="Headphones 2")
+t1.add_send(to="Headphones 1")
+t1.add_send(to="Headphones 2")`, dslCode)
+
+		dslCode, err = cue.DSL(map[string]int{"headphones": 1})
+		require.NoError(t, err)
+		assert.Contains(t, dslCode, `t1.add_send(to="Headphones")`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := template.DSL(map[string]int{"drums": 1})
+		assert.ErrorContains(t, err, `template studio has no role "drums" (roles: band, guitar, keys, piano, reverb)`)
+		_, err = template.DSL(map[string]int{"guitar": -1})
+		assert.ErrorContains(t, err, "must not be negative")
+		_, err = template.DSL(map[string]int{"band": 0, "reverb": 0})
+		assert.ErrorContains(t, err, "overrides leave no tracks")
+	})
+}
+
+func TestTemplateRegistry(t *testing.T) {
+	assert.Equal(t, []string{"orchestral", "podcast_3_person", "rock_band"}, DefaultTemplateRegistry().Names())
+
+	registry := NewTemplateRegistry()
+	assert.Empty(t, registry.Describe())
+	require.NoError(t, registry.Load([]byte(`[{"name": "Duo Setup", "description": "Two voices", "tracks": [{"name": "Voice", "count": 2}]}]`)))
+
+	template, ok := registry.Get("duo-setup")
+	require.True(t, ok)
+	assert.Equal(t, "duo_setup", template.Name)
+	assert.Equal(t, "duo_setup - Two voices (roles: voice)", registry.Describe())
+
+	tests := []struct {
+		name     string
+		template TrackTemplate
+		want     string
+	}{
+		{"no name", TrackTemplate{Tracks: []TemplateTrack{{Name: "A"}}}, "name is required"},
+		{"no tracks", TrackTemplate{Name: "empty"}, "has no tracks"},
+		{"unnamed track", TrackTemplate{Name: "x", Tracks: []TemplateTrack{{}}}, "every track needs a name"},
+		{"duplicate role", TrackTemplate{Name: "x", Tracks: []TemplateTrack{{Name: "Gtr", Role: "guitar"}, {Name: "Guitar"}}}, `duplicate role "guitar"`},
+		{"unknown send", TrackTemplate{Name: "x", Tracks: []TemplateTrack{{Name: "A", Sends: []TemplateSend{{To: "Bus"}}}}}, `send to unknown track "Bus"`},
+		{"quote", TrackTemplate{Name: "x", Tracks: []TemplateTrack{{Name: `12" Kick`}}}, "double quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, registry.Register(tt.template), tt.want)
+		})
+	}
+}
+
+func TestFunctionalDSLParser_Template(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(map[string]any{
+		"state": map[string]any{
+			"tracks": []any{map[string]any{"index": 0, "name": "Click"}},
+		},
+	})
+
+	actions, err := parser.ParseDSL(`template(name="rock band", guitar=2, keys=0)`)
+	require.NoError(t, err)
+
+	created := map[string]int{}
+	var sends []map[string]any
+	for _, action := range actions {
+		switch action["action"] {
+		case "create_track":
+			created[action["name"].(string)] = action["index"].(int)
+		case "add_send":
+			sends = append(sends, action)
+		}
+	}
+	assert.Len(t, created, 13)
+	assert.Equal(t, 1, created["Drums"], "template tracks follow the existing tracks")
+	assert.Contains(t, created, "Guitar 1")
+	assert.Contains(t, created, "Guitar 2")
+	assert.NotContains(t, created, "Keys")
+
+	require.Len(t, sends, 4)
+	for _, send := range sends {
+		assert.Equal(t, created["Reverb"], send["target"])
+	}
+	assert.Equal(t, created["Guitar 1"], sends[0]["track"])
+	assert.Equal(t, -18.0, sends[0]["volume_db"])
+
+	// Template variables stay local to the expansion
+	_, leaked := parser.Variable("t8")
+	assert.False(t, leaked)
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			dslCode string
+			want    string
+		}{
+			{`template(name="jazz_trio")`, `unknown template "jazz_trio" (available: orchestral, podcast_3_person, rock_band)`},
+			{`template(name="rock_band", banjo=1)`, `template rock_band has no role "banjo"`},
+			{`template(name="rock_band", guitar=1.5)`, "guitar must be a whole number of tracks"},
+		}
+		for _, tt := range tests {
+			_, err := parser.ParseDSL(tt.dslCode)
+			require.Error(t, err, tt.dslCode)
+			assert.Contains(t, err.Error(), tt.want, tt.dslCode)
+		}
+	})
+}
+
+func TestFunctionalDSLParser_AddSend(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+	parser.SetState(map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Vocals"},
+				map[string]any{"index": 1, "name": "Reverb"},
+				map[string]any{"index": 2, "name": "Guitar"},
+			},
+		},
+	})
+
+	actions, err := parser.ParseDSL(`track(id=1).add_send(to="Reverb", volume_db=-12); track(name="Delay"); filter(tracks, track.index < 1).add_send(to="Delay"); track(id=3).add_send(to=2, pan=-0.5)`)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"action": "add_send", "track": 0, "target": 1, "volume_db": -12.0},
+		{"action": "create_track", "index": 3, "name": "Delay"},
+		{"action": "add_send", "track": 0, "target": 3},
+		{"action": "add_send", "track": 2, "target": 1, "pan": -0.5},
+	}, actions)
+
+	_, err = parser.ParseDSL(`track(id=2).add_send(to="Reverb")`)
+	assert.ErrorContains(t, err, "cannot send to itself")
+	_, err = parser.ParseDSL(`track(id=1).add_send(to="Bus")`)
+	assert.ErrorContains(t, err, `no track named "Bus"`)
+}
@@ -0,0 +1,347 @@
+package daw
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
+)
+
+// trackRef is a variable bound to a track, e.g. let bass = track(name="Bass").
+type trackRef struct {
+	Index int
+}
+
+// dslScope holds let-bound variables. Scopes don't nest: the script has one,
+// and macro and template bodies each run in a scope of their own, which they
+// can't see out of.
+type dslScope struct {
+	vars map[string]any
+}
+
+func newDSLScope() *dslScope {
+	return &dslScope{vars: make(map[string]any)}
+}
+
+func (s *dslScope) lookup(name string) (any, bool) {
+	value, ok := s.vars[name]
+	return value, ok
+}
+
+func (s *dslScope) set(name string, value any) {
+	s.vars[name] = value
+}
+
+// Variable returns the value bound to a name in the current script scope.
+// Tracks are returned as their index.
+func (p *FunctionalDSLParser) Variable(name string) (any, bool) {
+	value, ok := p.scope.lookup(name)
+	if ref, isTrack := value.(trackRef); isTrack {
+		return ref.Index, ok
+	}
+	return value, ok
+}
+
+// executeStatement executes a single top-level statement, handling let-bindings
+// and statements whose receiver is a bound variable (bass.add_fx(...)). A
+// collection left by the previous statement's filter() or map() is dropped,
+// so only calls chained on it apply to it.
+func (p *FunctionalDSLParser) executeStatement(ctx context.Context, statement string) error {
+	delete(p.data, "current_filtered")
+	if isMacroCall(statement) {
+		return p.executeMacroCall(ctx, statement)
+	}
+	if name, expr, ok := parseLet(statement); ok {
+		return p.executeLet(ctx, name, expr)
+	}
+	_, err := p.executeExpression(ctx, statement)
+	return err
+}
+
+// executeLet evaluates expr and binds the result to name.
+func (p *FunctionalDSLParser) executeLet(ctx context.Context, name, expr string) error {
+	if p.isReservedName(name) {
+		return fmt.Errorf("cannot bind reserved name '%s'", name)
+	}
+
+	value, err := p.evaluateBinding(ctx, expr)
+	if err != nil {
+		return fmt.Errorf("let %s: %w", name, err)
+	}
+
+	p.scope.set(name, value)
+	log.Printf("📌 let %s = %v", name, value)
+	return nil
+}
+
+// evaluateBinding evaluates the right-hand side of a let statement.
+// Literals and variables are bound directly; calls are executed and bound to
+// the collection or track they produced.
+func (p *FunctionalDSLParser) evaluateBinding(ctx context.Context, expr string) (any, error) {
+	if value, ok := p.literalOrVariable(expr); ok {
+		return value, nil
+	}
+
+	receiver, err := p.executeExpression(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// A let never applies the result to following statements by itself
+	defer delete(p.data, "current_filtered")
+
+	switch {
+	case p.lastCollection != nil:
+		return p.lastCollection, nil
+	case receiver != nil:
+		return receiver, nil
+	case p.currentTrackIndex >= 0:
+		return trackRef{Index: p.currentTrackIndex}, nil
+	default:
+		return nil, fmt.Errorf("expression does not produce a track, collection or value: %s", expr)
+	}
+}
+
+// literalOrVariable resolves literals, bound variables and collection names.
+func (p *FunctionalDSLParser) literalOrVariable(expr string) (any, bool) {
+	literal := parseDSLLiteral(expr)
+	if literal.Str != expr {
+		return valueToAny(literal), true
+	}
+	if value, ok := p.scope.lookup(expr); ok {
+		return value, true
+	}
+	if collection, ok := p.data[expr].([]any); ok {
+		return collection, true
+	}
+	return nil, false
+}
+
+// executeExpression runs DSL code through the engine after substituting
+// variables. If the code starts with a bound variable, the statement is parsed
+// into its call chain, the variable becomes the receiver and the calls after
+// it run against that receiver; the variable is returned.
+func (p *FunctionalDSLParser) executeExpression(ctx context.Context, code string) (any, error) {
+	p.lastCollection = nil
+	code = p.substituteVariables(code)
+
+	p.currentStatement = code
+	defer func() { p.currentStatement = "" }()
+
+	name, ok := chainReceiver(code)
+	var receiver any
+	if ok {
+		receiver, ok = p.scope.lookup(name)
+	}
+	if !ok {
+		return nil, p.engine.Execute(ctx, code)
+	}
+
+	chain, err := p.callParser.Parse(code)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	if len(chain.Calls) == 0 || chain.Calls[0].Name != capitalizeMethodName(name) || len(chain.Calls[0].Args) > 0 {
+		return nil, fmt.Errorf("variable '%s' must be followed by a method chain: %s", name, code)
+	}
+	if err := p.setReceiver(name, receiver); err != nil {
+		return nil, err
+	}
+	for _, call := range chain.Calls[1:] {
+		if err := p.callMethod(call); err != nil {
+			return nil, err
+		}
+	}
+	return receiver, nil
+}
+
+// callMethod runs one call of a parsed chain against the DSL methods, with
+// the same errors as the engine.
+func (p *FunctionalDSLParser) callMethod(call gs.Call) error {
+	method := reflect.ValueOf(p.reaperDSL).MethodByName(call.Name)
+	if !method.IsValid() {
+		return fmt.Errorf("unknown method: %s", call.Name)
+	}
+	handler, ok := method.Interface().(func(gs.Args) error)
+	if !ok {
+		return fmt.Errorf("unknown method: %s", call.Name)
+	}
+
+	args := make(gs.Args)
+	for _, arg := range call.Args {
+		args[arg.Name] = arg.Value
+	}
+	if err := handler(args); err != nil {
+		return fmt.Errorf("method %s error: %w", call.Name, err)
+	}
+	return nil
+}
+
+// parseLet splits a let_statement, "let" IDENTIFIER "=" expression, into the
+// name and the expression.
+func parseLet(statement string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(statement, "let")
+	if !ok || rest == "" || !isSpace(rest[0]) {
+		return "", "", false
+	}
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	name := leadingIdentifier(rest)
+	if name == "" {
+		return "", "", false
+	}
+	expr, ok := strings.CutPrefix(strings.TrimLeft(rest[len(name):], " \t\r\n"), "=")
+	if !ok || strings.HasPrefix(expr, "=") {
+		return "", "", false
+	}
+	expr = strings.TrimSpace(expr)
+	return name, expr, expr != ""
+}
+
+// chainReceiver returns the identifier a variable_call starts with: a bare
+// name followed by a method chain or nothing.
+func chainReceiver(code string) (string, bool) {
+	name := leadingIdentifier(code)
+	if name == "" {
+		return "", false
+	}
+	after := strings.TrimLeft(code[len(name):], " \t\r\n")
+	return name, after == "" || after[0] == '.'
+}
+
+// leadingIdentifier returns the IDENTIFIER at the start of s, or "".
+func leadingIdentifier(s string) string {
+	if s == "" || !(s[0] == '_' || (s[0] >= 'a' && s[0] <= 'z') || (s[0] >= 'A' && s[0] <= 'Z')) {
+		return ""
+	}
+	end := 1
+	for end < len(s) && isIdentifierChar(s[end]) {
+		end++
+	}
+	return s[:end]
+}
+
+func isSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\r' || char == '\n'
+}
+
+// setReceiver makes a bound variable the context for the rest of a chain.
+func (p *FunctionalDSLParser) setReceiver(name string, value any) error {
+	switch v := value.(type) {
+	case trackRef:
+		delete(p.data, "current_filtered")
+		p.currentTrackIndex = v.Index
+	case []any:
+		p.data["current_filtered"] = v
+		p.currentTrackIndex = -1
+		p.markExpansion(name)
+	default:
+		return fmt.Errorf("variable '%s' is a %T and has no methods", name, value)
+	}
+	return nil
+}
+
+// trackItem returns the state entry for a track index, or a minimal track map
+// for tracks created earlier in the script.
+func (p *FunctionalDSLParser) trackItem(index int) map[string]any {
+	if tracks, ok := p.data["tracks"].([]any); ok {
+		for _, track := range tracks {
+			if trackMap, ok := track.(map[string]any); ok {
+				if trackIndex, ok := itemTrackIndex(trackMap); ok && trackIndex == index {
+					return trackMap
+				}
+			}
+		}
+	}
+	return map[string]any{"index": index}
+}
+
+// substituteVariables replaces references to scalar variables in argument
+// positions with their literal values, e.g. set_track(volume_db=gain) becomes
+// set_track(volume_db=-3) after let gain = -3.
+func (p *FunctionalDSLParser) substituteVariables(code string) string {
+	var result strings.Builder
+	var scanner dslscan.Scanner
+
+	for i := 0; i < len(code); i++ {
+		char := code[i]
+		depth, isCode := scanner.Next(char)
+
+		isStart := isCode && (char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')) &&
+			(i == 0 || !isIdentifierChar(code[i-1]))
+		if depth == 0 || !isStart {
+			result.WriteByte(char)
+			continue
+		}
+
+		end := i
+		for end < len(code) && isIdentifierChar(code[end]) {
+			end++
+		}
+		name := code[i:end]
+		if literal, ok := p.scalarLiteral(name); ok && isValuePosition(code, i, end) {
+			result.WriteString(literal)
+		} else {
+			result.WriteString(name)
+		}
+		i = end - 1
+	}
+
+	return result.String()
+}
+
+// scalarLiteral returns the DSL literal for a variable bound to a number, string or bool.
+func (p *FunctionalDSLParser) scalarLiteral(name string) (string, bool) {
+	value, ok := p.scope.lookup(name)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`, true
+	default:
+		return "", false
+	}
+}
+
+// isValuePosition reports whether the identifier at code[start:end] is used as
+// a value: not a property (x.name), function reference (@name), call (name(...)),
+// receiver (name.method) or parameter name (name=..., name+=...).
+func isValuePosition(code string, start, end int) bool {
+	before := strings.TrimRight(code[:start], " \t")
+	if strings.HasSuffix(before, ".") || strings.HasSuffix(before, "@") {
+		return false
+	}
+
+	after := strings.TrimLeft(code[end:], " \t")
+	if strings.HasPrefix(after, "(") || strings.HasPrefix(after, ".") {
+		return false
+	}
+	if strings.HasPrefix(after, "=") && !strings.HasPrefix(after, "==") {
+		return false
+	}
+	if len(after) >= 2 && strings.ContainsRune("+-*/", rune(after[0])) && after[1] == '=' {
+		return false
+	}
+	return true
+}
+
+// isReservedName reports whether name can't be used as a variable because it
+// is a keyword or DSL method.
+func (p *FunctionalDSLParser) isReservedName(name string) bool {
+	switch name {
+	case "let", "true", "false":
+		return true
+	}
+	return reflect.ValueOf(p.reaperDSL).MethodByName(capitalizeMethodName(name)).IsValid()
+}
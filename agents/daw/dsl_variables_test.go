@@ -0,0 +1,144 @@
+package daw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionalDSLParser_LetBindings(t *testing.T) {
+	state := map[string]any{
+		"state": map[string]any{
+			"tracks": []any{
+				map[string]any{"index": 0, "name": "Drums"},
+				map[string]any{"index": 1, "name": "Drums 2"},
+				map[string]any{"index": 2, "name": "Keys"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		dslCode string
+		want    []map[string]any
+	}{
+		{
+			name:    "track binding used as receiver",
+			dslCode: `let bass = track(name="Bass"); track(name="Pad"); bass.add_fx(fxname="ReaEQ")`,
+			want: []map[string]any{
+				{"action": "create_track", "name": "Bass", "index": 3},
+				{"action": "create_track", "name": "Pad", "index": 4},
+				{"action": "add_track_fx", "track": 3, "fxname": "ReaEQ"},
+			},
+		},
+		{
+			name:    "receiver with a chain of calls",
+			dslCode: "let my_bass = track(name=\"Bass\")\nmy_bass .add_fx(fxname=\"ReaEQ\").set_track(mute=true)",
+			want: []map[string]any{
+				{"action": "create_track", "name": "Bass", "index": 3},
+				{"action": "add_track_fx", "track": 3, "fxname": "ReaEQ"},
+				{"action": "set_track", "track": 3, "mute": true},
+			},
+		},
+		{
+			name:    "existing track binding",
+			dslCode: "let keys = track(id=3)\nkeys.set_track(mute=true)",
+			want: []map[string]any{
+				{"action": "set_track", "track": 2, "mute": true},
+			},
+		},
+		{
+			name:    "collection binding used as receiver and collection",
+			dslCode: `let drums = filter(tracks, track.name == "Drums"); drums.set_track(solo=true); for_each(drums, @mute)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 0, "solo": true},
+				{"action": "set_track", "track": 0, "mute": true},
+			},
+		},
+		{
+			name:    "number and string bindings substituted in arguments",
+			dslCode: `let gain = -4.5; let label = "Lead"; track().set_track(name=label, volume_db=gain)`,
+			want: []map[string]any{
+				{"action": "create_track", "index": 3},
+				{"action": "set_track", "track": 3, "name": "Lead", "volume_db": -4.5},
+			},
+		},
+		{
+			name:    "stored value readable as variable",
+			dslCode: `store(name="level", value=-12); track(id=1).set_track(volume_db=level)`,
+			want: []map[string]any{
+				{"action": "set_track", "track": 0, "volume_db": -12.0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+			parser.SetState(state)
+
+			actions, err := parser.ParseDSL(tt.dslCode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, actions)
+		})
+	}
+}
+
+func TestFunctionalDSLParser_LetVariableLookup(t *testing.T) {
+	parser, err := NewFunctionalDSLParser()
+	require.NoError(t, err)
+
+	_, err = parser.ParseDSL(`let bars = 8; let bass = track(name="Bass")`)
+	require.NoError(t, err)
+
+	bars, ok := parser.Variable("bars")
+	require.True(t, ok)
+	assert.Equal(t, 8.0, bars)
+
+	bass, ok := parser.Variable("bass")
+	require.True(t, ok)
+	assert.Equal(t, 0, bass)
+
+	// Variables don't leak into the next script
+	_, err = parser.ParseDSL(`track()`)
+	require.NoError(t, err)
+	_, ok = parser.Variable("bars")
+	assert.False(t, ok)
+}
+
+func TestFunctionalDSLParser_LetErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dslCode string
+		wantErr string
+	}{
+		{
+			name:    "reserved name",
+			dslCode: `let track = track(name="Bass")`,
+			wantErr: "cannot bind reserved name 'track'",
+		},
+		{
+			name:    "method on number",
+			dslCode: `let gain = 3; gain.set_track(mute=true)`,
+			wantErr: "variable 'gain' is a float64 and has no methods",
+		},
+		{
+			name:    "unknown method on receiver",
+			dslCode: `let bass = track(name="Bass"); bass.add_sparkle(amount=1)`,
+			wantErr: "1:37: error: unknown method 'add_sparkle'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewFunctionalDSLParser()
+			require.NoError(t, err)
+
+			_, err = parser.ParseDSL(tt.dslCode)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
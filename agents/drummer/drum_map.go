@@ -0,0 +1,108 @@
+package drummer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DrumMap maps canonical drum names (kick, snare, hat, ...) to MIDI note numbers
+type DrumMap struct {
+	Name  string         `json:"name"`
+	Notes map[string]int `json:"notes"`
+}
+
+// DefaultDrumMap is the name of the drum map used when none is configured
+const DefaultDrumMap = "gm"
+
+// generalMIDINotes is the General MIDI percussion map (channel 10). GM has no
+// finger snap, so snap plays the hand clap.
+var generalMIDINotes = map[string]int{
+	"kick":         36, // Bass Drum 1
+	"snare_rim":    37, // Side Stick
+	"snare_xstick": 37, // Side Stick
+	"snare":        38, // Acoustic Snare
+	"clap":         39, // Hand Clap
+	"snap":         39, // Hand Clap
+	"tom_low":      43, // High Floor Tom
+	"hat":          42, // Closed Hi-Hat
+	"hat_pedal":    44, // Pedal Hi-Hat
+	"hat_open":     46, // Open Hi-Hat
+	"tom_mid":      47, // Low-Mid Tom
+	"crash":        49, // Crash Cymbal 1
+	"tom_high":     50, // High Tom
+	"ride":         51, // Ride Cymbal 1
+	"china":        52, // Chinese Cymbal
+	"ride_bell":    53, // Ride Bell
+	"tambourine":   54, // Tambourine
+	"splash":       55, // Splash Cymbal
+	"cowbell":      56, // Cowbell
+	"shaker":       70, // Maracas
+}
+
+// pads16Notes lays the most used drums out chromatically from C1 (36), one per
+// pad, for pad samplers loaded pad by pad (Sitala, ReaSamplOmatic5000 instances
+// on consecutive notes, a Drum Rack without a GM kit). The cross stick shares
+// the rim shot's pad, and the less used percussion continues on the next bank.
+var pads16Notes = map[string]int{
+	"kick":         36,
+	"snare":        37,
+	"clap":         38,
+	"snare_rim":    39,
+	"snare_xstick": 39,
+	"hat":          40,
+	"hat_pedal":    41,
+	"hat_open":     42,
+	"tom_low":      43,
+	"tom_mid":      44,
+	"tom_high":     45,
+	"crash":        46,
+	"ride":         47,
+	"ride_bell":    48,
+	"splash":       49,
+	"china":        50,
+	"tambourine":   51,
+	"cowbell":      52,
+	"shaker":       53,
+	"snap":         54,
+}
+
+// drumMapPresets are the built-in drum maps by name. Each maps every drum of
+// the drummer DSL.
+var drumMapPresets = map[string]*DrumMap{
+	"gm":     {Name: "gm", Notes: generalMIDINotes},
+	"pads16": {Name: "pads16", Notes: pads16Notes},
+}
+
+// DrumMapPreset returns a copy of the built-in drum map with the given name
+// ("" for the default)
+func DrumMapPreset(name string) (*DrumMap, error) {
+	if name == "" {
+		name = DefaultDrumMap
+	}
+	preset, ok := drumMapPresets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown drum map %q (available: %s)", name, strings.Join(DrumMapPresets(), ", "))
+	}
+	return preset.With(preset.Name, nil), nil
+}
+
+// DrumMapPresets returns the names of the built-in drum maps, sorted
+func DrumMapPresets() []string {
+	return slices.Sorted(maps.Keys(drumMapPresets))
+}
+
+// Note returns the MIDI note number of a drum
+func (m *DrumMap) Note(drum string) (int, bool) {
+	note, ok := m.Notes[drum]
+	return note, ok
+}
+
+// With returns a copy of the drum map with the given name and notes changed,
+// for kits mapped differently from a preset
+func (m *DrumMap) With(name string, notes map[string]int) *DrumMap {
+	out := &DrumMap{Name: name, Notes: maps.Clone(m.Notes)}
+	maps.Copy(out.Notes, notes)
+	return out
+}
@@ -0,0 +1,106 @@
+package drummer
+
+import (
+	"fmt"
+
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+)
+
+// DrumPatternOptions controls how drum_pattern grids become MIDI notes
+type DrumPatternOptions struct {
+	DrumMap        *DrumMap // Drum name to MIDI note; nil = General MIDI
+	StepBeats      float64  // Length of a grid step in beats; 0 = 16th notes
+	NoteBeats      float64  // Length of each note in beats; 0 = one step
+	StartBeats     float64  // Position of the first step
+	AccentVelocity int      // Velocity of "X" steps; 0 = 127
+	GhostVelocity  int      // Velocity of "o" steps; 0 = 60
+}
+
+// DefaultDrumPatternOptions returns the options the drummer grammar describes:
+// General MIDI, 16th-note steps, accents at 127 and ghost notes at 60
+func DefaultDrumPatternOptions() DrumPatternOptions {
+	drumMap, _ := DrumMapPreset(DefaultDrumMap)
+	return DrumPatternOptions{
+		DrumMap:        drumMap,
+		StepBeats:      0.25,
+		AccentVelocity: 127,
+		GhostVelocity:  60,
+	}
+}
+
+// ConvertDrumPatternToNoteEvents converts a drum_pattern action to NoteEvents.
+// Each grid character is one step: "x" is a hit at the pattern's velocity, "X"
+// an accent, "o" a ghost note, and "-" or "." a rest. "|" and spaces separate
+// bars and take no time.
+func ConvertDrumPatternToNoteEvents(action map[string]any, options DrumPatternOptions) ([]models.NoteEvent, error) {
+	if action["action"] != "drum_pattern" {
+		return nil, fmt.Errorf("not a drum_pattern action: %v", action["action"])
+	}
+	drum, _ := action["drum"].(string)
+	grid, _ := action["grid"].(string)
+	if drum == "" || grid == "" {
+		return nil, fmt.Errorf("drum_pattern needs a drum and a grid")
+	}
+
+	// Unset options take their defaults
+	defaults := DefaultDrumPatternOptions()
+	if options.DrumMap == nil {
+		options.DrumMap = defaults.DrumMap
+	}
+	if options.StepBeats <= 0 {
+		options.StepBeats = defaults.StepBeats
+	}
+	if options.NoteBeats <= 0 {
+		options.NoteBeats = options.StepBeats
+	}
+	if options.AccentVelocity <= 0 {
+		options.AccentVelocity = defaults.AccentVelocity
+	}
+	if options.GhostVelocity <= 0 {
+		options.GhostVelocity = defaults.GhostVelocity
+	}
+
+	pitch, ok := options.DrumMap.Note(drum)
+	if !ok {
+		return nil, fmt.Errorf("drum %q is not in the %s drum map", drum, options.DrumMap.Name)
+	}
+
+	velocity := 100
+	switch v := action["velocity"].(type) {
+	case int:
+		velocity = v
+	case float64:
+		velocity = int(v)
+	}
+
+	var notes []models.NoteEvent
+	step := 0
+	for _, c := range grid {
+		var noteVelocity int
+		hit := true
+		switch c {
+		case 'x':
+			noteVelocity = velocity
+		case 'X':
+			noteVelocity = options.AccentVelocity
+		case 'o':
+			noteVelocity = options.GhostVelocity
+		case '-', '.':
+			hit = false
+		case '|', ' ':
+			continue
+		default:
+			return nil, fmt.Errorf("drum_pattern grid %q: unexpected %q at step %d", grid, c, step+1)
+		}
+		if hit {
+			notes = append(notes, models.NoteEvent{
+				MidiNoteNumber: pitch,
+				Velocity:       min(max(noteVelocity, 1), 127),
+				StartBeats:     options.StartBeats + float64(step)*options.StepBeats,
+				DurationBeats:  options.NoteBeats,
+			})
+		}
+		step++
+	}
+	return notes, nil
+}
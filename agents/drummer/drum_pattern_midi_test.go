@@ -0,0 +1,93 @@
+package drummer
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertDrumPatternToNoteEvents(t *testing.T) {
+	pattern := func(drum, grid string) map[string]any {
+		return map[string]any{"action": "drum_pattern", "drum": drum, "grid": grid, "velocity": 90}
+	}
+
+	notes, err := ConvertDrumPatternToNoteEvents(pattern("snare", "X-o-|x---"), DefaultDrumPatternOptions())
+	require.NoError(t, err)
+	assert.Equal(t, []models.NoteEvent{
+		{MidiNoteNumber: 38, Velocity: 127, StartBeats: 0, DurationBeats: 0.25},
+		{MidiNoteNumber: 38, Velocity: 60, StartBeats: 0.5, DurationBeats: 0.25},
+		{MidiNoteNumber: 38, Velocity: 90, StartBeats: 1, DurationBeats: 0.25},
+	}, notes)
+
+	// 8th-note steps, shorter notes, an offset and softer accents and ghosts
+	pads, err := DrumMapPreset("pads16")
+	require.NoError(t, err)
+	notes, err = ConvertDrumPatternToNoteEvents(pattern("hat", "xXo."), DrumPatternOptions{
+		DrumMap:        pads,
+		StepBeats:      0.5,
+		NoteBeats:      0.1,
+		StartBeats:     4,
+		AccentVelocity: 110,
+		GhostVelocity:  40,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []models.NoteEvent{
+		{MidiNoteNumber: 40, Velocity: 90, StartBeats: 4, DurationBeats: 0.1},
+		{MidiNoteNumber: 40, Velocity: 110, StartBeats: 4.5, DurationBeats: 0.1},
+		{MidiNoteNumber: 40, Velocity: 40, StartBeats: 5, DurationBeats: 0.1},
+	}, notes)
+
+	// Zero options take the defaults
+	notes, err = ConvertDrumPatternToNoteEvents(pattern("kick", "---X"), DrumPatternOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.NoteEvent{{MidiNoteNumber: 36, Velocity: 127, StartBeats: 0.75, DurationBeats: 0.25}}, notes)
+
+	_, err = ConvertDrumPatternToNoteEvents(pattern("snap", "x---"), DrumPatternOptions{DrumMap: &DrumMap{Name: "kicks", Notes: map[string]int{"kick": 36}}})
+	assert.ErrorContains(t, err, `drum "snap" is not in the kicks drum map`)
+	_, err = ConvertDrumPatternToNoteEvents(pattern("kick", "x-?-"), DefaultDrumPatternOptions())
+	assert.ErrorContains(t, err, `unexpected '?' at step 3`)
+	_, err = ConvertDrumPatternToNoteEvents(map[string]any{"action": "add_midi"}, DefaultDrumPatternOptions())
+	assert.ErrorContains(t, err, "not a drum_pattern action")
+}
+
+func TestDrumMapPreset(t *testing.T) {
+	assert.Equal(t, []string{"gm", "pads16"}, DrumMapPresets())
+
+	// Every preset maps every drum of the grammar
+	drumNames := regexp.MustCompile(`(?s)DRUM_NAME:(.*?)\n\n`).FindStringSubmatch(llm.GetDrummerDSLGrammar())
+	require.Len(t, drumNames, 2)
+	drums := regexp.MustCompile(`"(\w+)"`).FindAllStringSubmatch(drumNames[1], -1)
+	require.Len(t, drums, 20)
+	for _, name := range DrumMapPresets() {
+		preset, err := DrumMapPreset(name)
+		require.NoError(t, err)
+		for _, drum := range drums {
+			_, ok := preset.Note(drum[1])
+			assert.True(t, ok, "%s has no %s", name, drum[1])
+		}
+	}
+
+	gm, err := DrumMapPreset("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDrumMap, gm.Name)
+
+	// Presets are copies, and With changes a copy
+	gm.Notes["kick"] = 35
+	custom := gm.With("my_kit", map[string]int{"snap": 31})
+	again, err := DrumMapPreset("GM")
+	require.NoError(t, err)
+	note, _ := again.Note("kick")
+	assert.Equal(t, 36, note)
+	note, ok := custom.Note("snap")
+	assert.True(t, ok)
+	assert.Equal(t, 31, note)
+	note, _ = gm.Note("snap")
+	assert.Equal(t, 39, note, "the preset keeps its snap")
+
+	_, err = DrumMapPreset("battery")
+	assert.ErrorContains(t, err, `unknown drum map "battery"`)
+}
@@ -2,6 +2,7 @@ package drummer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -17,14 +18,16 @@ type DrummerAgent struct {
 	provider     llm.Provider
 	systemPrompt string
 	metrics      *metrics.SentryMetrics
+	maxRepairs   int // Times rejected DSL is sent back to the model (0 = no repair)
 }
 
 // DrummerResult contains the DSL output
-// Note: Conversion to MIDI happens in the Reaper extension, NOT here
+// Note: ConvertDrumPatternToNoteEvents converts the actions to MIDI with a drum map
 type DrummerResult struct {
-	DSL     string           `json:"dsl"`     // Raw DSL code from LLM
-	Actions []map[string]any `json:"actions"` // Parsed actions from Grammar School
-	Usage   any              `json:"usage"`
+	DSL     string              `json:"dsl"`     // Raw DSL code from LLM
+	Actions []map[string]any    `json:"actions"` // Parsed actions from Grammar School
+	Usage   any                 `json:"usage"`
+	Repairs []llm.RepairAttempt `json:"repairs,omitempty"` // Rejected DSL sent back for repair, oldest first
 }
 
 // NewDrummerAgent creates a new drummer agent
@@ -45,6 +48,7 @@ func NewDrummerAgentWithProvider(cfg *config.Config, provider llm.Provider) *Dru
 		provider:     provider,
 		systemPrompt: systemPrompt,
 		metrics:      metrics.NewSentryMetrics(),
+		maxRepairs:   cfg.DSLRepairAttempts,
 	}
 
 	log.Printf("🥁 DRUMMER AGENT INITIALIZED:")
@@ -68,70 +72,162 @@ func (a *DrummerAgent) Generate(
 
 	transaction.SetTag("model", model)
 
-	// Build provider request with CFG grammar
-	request := &llm.GenerationRequest{
-		Model:        model,
-		InputArray:   inputArray,
-		SystemPrompt: a.systemPrompt,
-		CFGGrammar: &llm.CFGConfig{
-			ToolName:    "drummer_dsl",
-			Description: buildDrummerToolDescription(),
-			Grammar:     llm.GetDrummerDSLGrammar(),
-			Syntax:      "lark",
-		},
-	}
+	request := a.buildRequest(model, inputArray)
 
 	// Call provider
 	log.Printf("🚀 DRUMMER REQUEST: %s model=%s, input_messages=%d",
 		a.provider.Name(), model, len(inputArray))
 
-	resp, err := a.provider.Generate(ctx, request)
+	// Generate and parse, sending rejected DSL back to the model when repair is enabled
+	repair, err := llm.GenerateWithRepair(ctx, a.provider, request, a.maxRepairs, parseDrummerResponse)
+	if a.maxRepairs > 0 && repair != nil && len(repair.History) > 0 {
+		a.metrics.RecordDSLRepair(ctx, "drummer", repair.Repairs, err == nil)
+	}
 	if err != nil {
 		transaction.SetTag("success", "false")
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("provider request failed: %w", err)
+		if errors.Is(err, llm.ErrProviderFailed) {
+			sentry.CaptureException(err)
+		}
+		return nil, err
 	}
+	actions := repair.Actions
 
-	// Extract DSL from response
-	dslCode := resp.RawOutput
-	if dslCode == "" {
-		transaction.SetTag("success", "false")
-		return nil, fmt.Errorf("no DSL output in response")
+	result := &DrummerResult{
+		DSL:     repair.DSL,
+		Actions: actions,
+		Usage:   repair.Response.Usage,
+		Repairs: repair.History,
 	}
 
-	log.Printf("🥁 DSL Output: %s", dslCode)
+	// Record metrics
+	transaction.SetTag("success", "true")
+	transaction.SetTag("action_count", fmt.Sprintf("%d", len(actions)))
+
+	duration := time.Since(startTime)
+	a.metrics.RecordGenerationDuration(ctx, duration, true)
+
+	log.Printf("✅ DRUMMER COMPLETE: %d actions", len(actions))
+
+	return result, nil
+}
+
+// StreamActionCallback is called for each drum pattern action as soon as it
+// has been parsed from the stream
+type StreamActionCallback func(action map[string]any) error
+
+// GenerateStream creates drum pattern DSL like Generate, but passes each
+// pattern(...) action to callback as soon as the model has written it.
+// Providers without streaming fall back to Generate, with callback called for
+// each action afterwards. Streamed output is not repaired: a rejected
+// statement ends the stream, and actions already passed to callback stand.
+func (a *DrummerAgent) GenerateStream(
+	ctx context.Context,
+	model string,
+	inputArray []map[string]any,
+	callback StreamActionCallback,
+) (*DrummerResult, error) {
+	provider, ok := a.provider.(llm.StreamingProvider)
+	if !ok {
+		log.Printf("⚠️  Provider %s does not support streaming, using Generate", a.provider.Name())
+		result, err := a.Generate(ctx, model, inputArray)
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range result.Actions {
+			if err := callback(action); err != nil {
+				return nil, fmt.Errorf("stream callback: %w", err)
+			}
+		}
+		return result, nil
+	}
+
+	startTime := time.Now()
+	log.Printf("🥁 DRUMMER STREAMING REQUEST STARTED (Model: %s)", model)
+
+	// Start Sentry transaction
+	transaction := sentry.StartTransaction(ctx, "drummer.generate_stream")
+	defer transaction.Finish()
+
+	transaction.SetTag("model", model)
+	transaction.SetTag("streaming", "true")
 
-	// Parse DSL using Grammar School to get actions
 	parser, err := NewDrummerDSLParser()
 	if err != nil {
-		transaction.SetTag("success", "false")
 		return nil, fmt.Errorf("failed to create DSL parser: %w", err)
 	}
+	stream := parser.Stream(ctx, callback)
 
-	actions, err := parser.ParseDSL(dslCode)
+	resp, err := llm.StreamDSL(ctx, provider, a.buildRequest(model, inputArray), stream.Write)
+	var actions []map[string]any
+	if err == nil {
+		actions, err = stream.Close()
+	}
 	if err != nil {
 		transaction.SetTag("success", "false")
+		if errors.Is(err, llm.ErrProviderFailed) {
+			sentry.CaptureException(err)
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to parse DSL: %w", err)
 	}
 
 	result := &DrummerResult{
-		DSL:     dslCode,
+		DSL:     stream.splitter.Source(),
 		Actions: actions,
-		Usage:   resp.Usage,
+	}
+	if resp != nil {
+		result.Usage = resp.Usage
 	}
 
-	// Record metrics
 	transaction.SetTag("success", "true")
 	transaction.SetTag("action_count", fmt.Sprintf("%d", len(actions)))
 
 	duration := time.Since(startTime)
 	a.metrics.RecordGenerationDuration(ctx, duration, true)
 
-	log.Printf("✅ DRUMMER COMPLETE: %d actions", len(actions))
+	log.Printf("✅ DRUMMER STREAMING COMPLETE: %d actions", len(actions))
 
 	return result, nil
 }
 
+// buildRequest builds the provider request with the drummer CFG grammar
+func (a *DrummerAgent) buildRequest(model string, inputArray []map[string]any) *llm.GenerationRequest {
+	return &llm.GenerationRequest{
+		Model:        model,
+		InputArray:   inputArray,
+		SystemPrompt: a.systemPrompt,
+		CFGGrammar: &llm.CFGConfig{
+			ToolName:    "drummer_dsl",
+			Description: buildDrummerToolDescription(),
+			Grammar:     llm.GetDrummerDSLGrammar(),
+			Syntax:      "lark",
+		},
+	}
+}
+
+// parseDrummerResponse extracts drum pattern actions from the DSL in a response
+func parseDrummerResponse(resp *llm.GenerationResponse) ([]map[string]any, error) {
+	dslCode := resp.RawOutput
+	if dslCode == "" {
+		return nil, fmt.Errorf("no DSL output in response")
+	}
+
+	log.Printf("🥁 DSL Output: %s", dslCode)
+
+	// Parse DSL using Grammar School to get actions
+	parser, err := NewDrummerDSLParser()
+	if err != nil {
+		return nil, llm.Permanent(fmt.Errorf("failed to create DSL parser: %w", err))
+	}
+
+	actions, err := parser.ParseDSL(dslCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSL: %w", err)
+	}
+
+	return actions, nil
+}
+
 // buildDrummerSystemPrompt creates the system prompt for the drummer agent
 func buildDrummerSystemPrompt() string {
 	return `You are a professional drummer. Create drum patterns using pattern() calls.
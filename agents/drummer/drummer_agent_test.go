@@ -0,0 +1,57 @@
+package drummer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/config"
+	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkedProvider streams a fixed output in small chunks, recording how much
+// had been sent each time an action was emitted.
+type chunkedProvider struct {
+	output    string
+	chunkSize int
+	sent      int
+}
+
+func (c *chunkedProvider) Name() string { return "chunked" }
+
+func (c *chunkedProvider) Generate(ctx context.Context, request *llm.GenerationRequest) (*llm.GenerationResponse, error) {
+	return &llm.GenerationResponse{RawOutput: c.output}, nil
+}
+
+func (c *chunkedProvider) GenerateStream(ctx context.Context, request *llm.GenerationRequest, callback llm.StreamCallback) (*llm.GenerationResponse, error) {
+	for c.sent < len(c.output) {
+		end := min(c.sent+c.chunkSize, len(c.output))
+		delta := c.output[c.sent:end]
+		c.sent = end
+		_ = callback(llm.StreamEvent{Type: "text_delta", Message: delta})
+	}
+	return &llm.GenerationResponse{RawOutput: c.output}, nil
+}
+
+func TestDrummerAgent_GenerateStream(t *testing.T) {
+	provider := &chunkedProvider{
+		output:    `pattern(drum=kick, grid="x---x---x---x---"); pattern(drum=hat, grid="-x-x-x-x-x-x-x-x")`,
+		chunkSize: 8,
+	}
+	agent := NewDrummerAgentWithProvider(&config.Config{}, provider)
+
+	var sentAt []int
+	result, err := agent.GenerateStream(context.Background(), "gpt-5.1", []map[string]any{
+		{"role": "user", "content": "four on the floor"},
+	}, func(action map[string]any) error {
+		sentAt = append(sentAt, provider.sent)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Actions, 2)
+	require.Len(t, sentAt, 2)
+	assert.Less(t, sentAt[0], len(provider.output), "first pattern should be emitted before the output is complete")
+	assert.Equal(t, provider.output, result.DSL)
+	assert.Equal(t, "hat", result.Actions[1]["drum"])
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/Conceptual-Machines/grammar-school-go/gs"
 	"github.com/Conceptual-Machines/magda-agents-go/llm"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
 )
 
 // DrummerDSLParser parses Drummer DSL code using Grammar School
@@ -15,6 +17,8 @@ type DrummerDSLParser struct {
 	engine     *gs.Engine
 	drummerDSL *DrummerDSL
 	actions    []map[string]any
+	checker    *diagnostics.Checker
+	diags      []diagnostics.Diagnostic // Diagnostics of the last script
 }
 
 // DrummerDSL implements the DSL side-effect methods
@@ -40,9 +44,16 @@ func NewDrummerDSLParser() (*DrummerDSLParser, error) {
 	}
 
 	parser.engine = engine
+	parser.checker = diagnostics.NewChecker(grammar, parser.drummerDSL)
 	return parser, nil
 }
 
+// Diagnostics returns the warnings found in the last script passed to ParseDSL.
+// When ParseDSL fails, they are carried by the returned *diagnostics.Error.
+func (p *DrummerDSLParser) Diagnostics() []diagnostics.Diagnostic {
+	return p.diags
+}
+
 // ParseDSL parses DSL code and returns actions
 func (p *DrummerDSLParser) ParseDSL(dslCode string) ([]map[string]any, error) {
 	if dslCode == "" {
@@ -51,19 +62,125 @@ func (p *DrummerDSLParser) ParseDSL(dslCode string) ([]map[string]any, error) {
 
 	p.actions = make([]map[string]any, 0)
 
+	// Syntax errors and unknown methods stop the script before anything runs
+	p.diags = p.checker.Check(dslCode)
+	if first, ok := diagnostics.First(p.diags); ok {
+		return nil, diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %s", first))
+	}
+	for _, diag := range p.diags {
+		log.Printf("⚠️  Drummer DSL %s", diag)
+	}
+
+	// Execute each statement separately so a failure can be traced to its source
 	ctx := context.Background()
-	if err := p.engine.Execute(ctx, dslCode); err != nil {
-		return nil, fmt.Errorf("failed to execute DSL: %w", err)
+	for _, statement := range dslscan.Split(dslCode, false) {
+		if err := p.engine.Execute(ctx, dslCode[statement.Start:statement.End]); err != nil {
+			p.diags = append(p.diags, p.checker.Explain(dslCode, statement.Start, statement.End, err))
+			return nil, diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %w", err))
+		}
 	}
 
 	if len(p.actions) == 0 {
-		return nil, fmt.Errorf("no actions found in DSL code")
+		err := fmt.Errorf("no actions found in DSL code")
+		p.diags = append(p.diags, p.checker.Explain(dslCode, 0, len(dslCode), err))
+		return nil, diagnostics.NewError(dslCode, p.diags, err)
 	}
 
 	log.Printf("✅ Drummer DSL Parser: Translated %d actions from DSL", len(p.actions))
 	return p.actions, nil
 }
 
+// DrummerDSLStream executes Drummer DSL while its text is still arriving,
+// e.g. from a streaming LLM response. Each pattern(...) statement runs as
+// soon as its ";" arrives and its action is passed to the callback.
+type DrummerDSLStream struct {
+	parser   *DrummerDSLParser
+	ctx      context.Context
+	splitter dslscan.Splitter
+	callback func(action map[string]any) error
+	err      error
+}
+
+// Stream starts an incremental parse of a new script. If callback returns an
+// error the stream stops and Write returns it.
+func (p *DrummerDSLParser) Stream(ctx context.Context, callback func(action map[string]any) error) *DrummerDSLStream {
+	p.actions = make([]map[string]any, 0)
+	p.diags = nil
+	return &DrummerDSLStream{parser: p, ctx: ctx, callback: callback}
+}
+
+// Write appends a chunk of DSL text and executes every statement it
+// completes. After an error, Write and Close return that error.
+func (s *DrummerDSLStream) Write(chunk string) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, statement := range s.splitter.Write(chunk) {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Close executes the last statement and returns every action of the script.
+func (s *DrummerDSLStream) Close() ([]map[string]any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, statement := range s.splitter.Close() {
+		if err := s.run(statement); err != nil {
+			s.err = err
+			return nil, err
+		}
+	}
+
+	p := s.parser
+	dslCode := s.splitter.Source()
+	if len(p.actions) == 0 {
+		err := fmt.Errorf("no actions found in DSL code")
+		p.diags = append(p.diags, p.checker.Explain(dslCode, 0, len(dslCode), err))
+		s.err = diagnostics.NewError(dslCode, p.diags, err)
+		return nil, s.err
+	}
+
+	log.Printf("✅ Drummer DSL Parser: Streamed %d actions from DSL", len(p.actions))
+	return p.actions, nil
+}
+
+// run checks and executes one statement, then passes its actions to the
+// callback. Only the statement is checked, with diagnostic spans pointing
+// into the whole script.
+func (s *DrummerDSLStream) run(statement dslscan.Statement) error {
+	p := s.parser
+	dslCode := s.splitter.Source()[:statement.End]
+
+	for _, diag := range p.checker.CheckRange(dslCode, statement.Start, statement.End) {
+		p.diags = append(p.diags, diag)
+		if diag.Severity == diagnostics.SeverityError {
+			return diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %s", diag))
+		}
+		log.Printf("⚠️  Drummer DSL %s", diag)
+	}
+
+	first := len(p.actions)
+	if err := p.engine.Execute(s.ctx, dslCode[statement.Start:]); err != nil {
+		p.diags = append(p.diags, p.checker.Explain(dslCode, statement.Start, statement.End, err))
+		return diagnostics.NewError(dslCode, p.diags, fmt.Errorf("failed to execute DSL: %w", err))
+	}
+
+	if s.callback == nil {
+		return nil
+	}
+	for _, action := range p.actions[first:] {
+		if err := s.callback(action); err != nil {
+			return fmt.Errorf("stream callback: %w", err)
+		}
+	}
+	return nil
+}
+
 // Pattern handles pattern() calls - creates a drum_pattern action
 func (d *DrummerDSL) Pattern(args gs.Args) error {
 	p := d.parser
@@ -1,8 +1,10 @@
 package drummer
 
 import (
+	"context"
 	"testing"
 
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -161,3 +163,80 @@ func TestCountHits(t *testing.T) {
 		})
 	}
 }
+
+func TestDrummerDSLParser_Diagnostics(t *testing.T) {
+	parser, err := NewDrummerDSLParser()
+	require.NoError(t, err)
+
+	dsl := "pattern(drum=kick, grid=\"x---x---\");\npattern(grid=\"----x---\", velocty=90)"
+	_, err = parser.ParseDSL(dsl)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing drum name")
+
+	diagErr, ok := diagnostics.AsError(err)
+	require.True(t, ok)
+	require.Len(t, diagErr.Diagnostics, 2)
+
+	failure := diagErr.Diagnostics[0]
+	assert.Equal(t, diagnostics.KindExecution, failure.Kind)
+	assert.Equal(t, "pattern", failure.Method)
+	assert.Equal(t, diagnostics.Position{Offset: 37, Line: 2, Column: 1}, failure.Span.Start)
+
+	warning := diagErr.Diagnostics[1]
+	assert.Equal(t, diagnostics.KindUnknownParameter, warning.Kind)
+	assert.Equal(t, []string{"velocity"}, warning.Suggestions)
+	assert.Equal(t, 2, warning.Span.Start.Line)
+}
+
+func TestDrummerDSLParser_UnknownMethod(t *testing.T) {
+	parser, err := NewDrummerDSLParser()
+	require.NoError(t, err)
+
+	_, err = parser.ParseDSL(`patern(drum=kick, grid="x---")`)
+	require.Error(t, err)
+
+	diagErr, ok := diagnostics.AsError(err)
+	require.True(t, ok)
+	require.Len(t, diagErr.Diagnostics, 1)
+	assert.Equal(t, diagnostics.KindUnknownMethod, diagErr.Diagnostics[0].Kind)
+	assert.Equal(t, []string{"pattern"}, diagErr.Diagnostics[0].Suggestions)
+}
+
+func TestDrummerDSLParser_Stream(t *testing.T) {
+	parser, err := NewDrummerDSLParser()
+	require.NoError(t, err)
+
+	var drums []string
+	stream := parser.Stream(context.Background(), func(action map[string]any) error {
+		drums = append(drums, action["drum"].(string))
+		return nil
+	})
+
+	require.NoError(t, stream.Write(`pattern(drum=kick, grid="x---x---x---x---"`))
+	assert.Empty(t, drums)
+	require.NoError(t, stream.Write(`); pattern(drum=snare, grid="----x-`))
+	assert.Equal(t, []string{"kick"}, drums)
+	require.NoError(t, stream.Write(`------x---")`))
+	assert.Equal(t, []string{"kick"}, drums)
+
+	actions, err := stream.Close()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kick", "snare"}, drums)
+	require.Len(t, actions, 2)
+	assert.Equal(t, "----x-------x---", actions[1]["grid"])
+
+	t.Run("error stops the stream", func(t *testing.T) {
+		stream := parser.Stream(context.Background(), nil)
+		require.NoError(t, stream.Write(`pattern(drum=kick, grid="x---");`))
+		err := stream.Write(` patern(drum=snare, grid="--x-");`)
+		require.Error(t, err)
+
+		diagErr, ok := diagnostics.AsError(err)
+		require.True(t, ok)
+		assert.Equal(t, diagnostics.KindUnknownMethod, diagErr.Diagnostics[0].Kind)
+		assert.Equal(t, 33, diagErr.Diagnostics[0].Span.Start.Offset)
+
+		_, closeErr := stream.Close()
+		assert.Equal(t, err, closeErr)
+	})
+}
@@ -0,0 +1,65 @@
+package jsfx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EffectsFolder is the folder, inside REAPER's Effects folder, generated
+// effects are installed in
+const EffectsFolder = "magda"
+
+// nonSlugPattern matches what a file name leaves out
+var nonSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// EffectName returns the name on the desc: line of JSFX code
+func EffectName(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "desc:"); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// InstallActions returns the DAW actions that put a generated effect on a
+// track: install_jsfx writes the code to a file in EffectsFolder, named after
+// the effect, and add_track_fx adds the effect by its path. The add_track_fx
+// action has no track if track is negative.
+func InstallActions(result *JSFXResult, track int) ([]map[string]any, error) {
+	if result == nil || strings.TrimSpace(result.JSFXCode) == "" {
+		return nil, fmt.Errorf("no JSFX code to install")
+	}
+	if result.CompileError != "" {
+		return nil, fmt.Errorf("JSFX does not compile: %s", result.CompileError)
+	}
+
+	name := EffectName(result.JSFXCode)
+	slug := strings.Trim(nonSlugPattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		slug = "effect"
+	}
+	path := EffectsFolder + "/" + slug + ".jsfx"
+
+	install := map[string]any{
+		"action": "install_jsfx",
+		"path":   path,
+		"code":   result.JSFXCode,
+	}
+	if name != "" {
+		install["name"] = name
+	}
+	if result.Description != "" {
+		install["description"] = result.Description
+	}
+
+	addFX := map[string]any{
+		"action": "add_track_fx",
+		"fxname": "JS: " + path,
+	}
+	if track >= 0 {
+		addFX["track"] = track
+	}
+	return []map[string]any{install, addFX}, nil
+}
@@ -0,0 +1,34 @@
+package jsfx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallActions(t *testing.T) {
+	code := "desc:Tape Saturation (warm)\nslider1:drive=0.5<0,1,0.01>Drive\n@sample\nspl0 = tanh(spl0 * drive);"
+	actions, err := InstallActions(&JSFXResult{JSFXCode: code, Description: "Soft tape-style clipping"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{
+			"action":      "install_jsfx",
+			"path":        "magda/tape_saturation_warm.jsfx",
+			"name":        "Tape Saturation (warm)",
+			"description": "Soft tape-style clipping",
+			"code":        code,
+		},
+		{"action": "add_track_fx", "track": 2, "fxname": "JS: magda/tape_saturation_warm.jsfx"},
+	}, actions)
+
+	// No desc: line and no track
+	actions, err = InstallActions(&JSFXResult{JSFXCode: "@sample\nspl0 *= 0.5;"}, -1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"action": "add_track_fx", "fxname": "JS: magda/effect.jsfx"}, actions[1])
+
+	_, err = InstallActions(&JSFXResult{JSFXCode: code, CompileError: "line 4: unknown function tanh"}, 0)
+	assert.ErrorContains(t, err, "JSFX does not compile: line 4: unknown function tanh")
+	_, err = InstallActions(&JSFXResult{}, 0)
+	assert.ErrorContains(t, err, "no JSFX code to install")
+}
@@ -0,0 +1,73 @@
+package mix
+
+import "fmt"
+
+// ConvertRecommendationToDAWAction converts a recommendation's action to the
+// DAW agent's action format: "add_fx" becomes "add_track_fx" with "fxname",
+// "set_volume" and "set_pan" become "set_track" with "volume_db" and "pan",
+// and "modify_fx_param" keeps its FX, parameter and value. Recommendations
+// without a track go on track, unless it is negative. The recommendation's
+// description is kept as the action's "description", so the action can be
+// explained to the user.
+func ConvertRecommendationToDAWAction(recommendation Recommendation, track int) (map[string]any, error) {
+	action := map[string]any{}
+	switch recommendation.ActionType {
+	case "add_fx":
+		if recommendation.FXName == "" {
+			return nil, fmt.Errorf("recommendation %s adds an FX without a name", recommendation.ID)
+		}
+		action["action"] = "add_track_fx"
+		action["fxname"] = recommendation.FXName
+	case "modify_fx_param":
+		if recommendation.Parameter == "" {
+			return nil, fmt.Errorf("recommendation %s modifies an FX without a parameter", recommendation.ID)
+		}
+		action["action"] = "modify_fx_param"
+		if recommendation.FXIndex >= 0 {
+			action["fx_index"] = recommendation.FXIndex
+		}
+		if recommendation.FXName != "" {
+			action["fxname"] = recommendation.FXName
+		}
+		action["parameter"] = recommendation.Parameter
+		action["value"] = recommendation.Value
+		if recommendation.ValueString != "" {
+			action["value"] = recommendation.ValueString
+		}
+	case "set_volume":
+		action["action"] = "set_track"
+		action["volume_db"] = recommendation.Value
+	case "set_pan":
+		action["action"] = "set_track"
+		action["pan"] = recommendation.Value
+	case "":
+		return nil, fmt.Errorf("recommendation %s has no action", recommendation.ID)
+	default:
+		return nil, fmt.Errorf("recommendation %s has unknown action %q", recommendation.ID, recommendation.ActionType)
+	}
+
+	if recommendation.Track >= 0 {
+		action["track"] = recommendation.Track
+	} else if track >= 0 {
+		action["track"] = track
+	}
+	if recommendation.Description != "" {
+		action["description"] = recommendation.Description
+	}
+	return action, nil
+}
+
+// DAWActions returns the DAW actions of all recommendations, in order.
+// Recommendations whose action can't be converted are left out.
+// Recommendations without a track go on track, unless it is negative.
+func (r *AnalysisResult) DAWActions(track int) []map[string]any {
+	var actions []map[string]any
+	for _, recommendation := range r.Recommendations {
+		action, err := ConvertRecommendationToDAWAction(recommendation, track)
+		if err != nil {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
@@ -0,0 +1,52 @@
+package mix
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisResult_DAWActions(t *testing.T) {
+	recommendation := func(id, actionType, fxName string, fxIndex, track int, parameter string, value float64, valueString string) string {
+		data, err := json.Marshal(map[string]any{
+			"id": id, "priority": "medium", "description": "", "explanation": "",
+			"action_type": actionType, "fx_name": fxName, "fx_index": fxIndex, "track": track,
+			"parameter": parameter, "value": value, "value_string": valueString,
+		})
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	var result AnalysisResult
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"analysis": {"summary": "", "issues": [], "strengths": []},
+		"recommendations": [
+			`+recommendation("rec_1", "add_fx", "ReaEQ", -1, -1, "", 0, "")+`,
+			`+recommendation("rec_2", "set_volume", "", -1, 2, "", -1.5, "")+`,
+			`+recommendation("rec_3", "modify_fx_param", "", 0, -1, "ratio", 4, "")+`,
+			`+recommendation("rec_4", "set_pan", "", -1, 0, "", -0.3, "")+`,
+			`+recommendation("rec_5", "modify_fx_param", "ReaEQ", 1, 3, "Band 2 Type", 0, "Notch")+`,
+			`+recommendation("rec_6", "add_fx", "", -1, -1, "", 0, "")+`
+		],
+		"relationship_issues": [{"tracks": [0, 1], "issue": "Guitars overlap", "recommendation": "Pan the guitars apart"}]
+	}`), &result))
+	result.Recommendations[1].Description = "Lower the vocal"
+
+	assert.Equal(t, []map[string]any{
+		{"action": "add_track_fx", "track": 1, "fxname": "ReaEQ"},
+		{"action": "set_track", "track": 2, "volume_db": -1.5, "description": "Lower the vocal"},
+		{"action": "modify_fx_param", "track": 1, "fx_index": 0, "parameter": "ratio", "value": float64(4)},
+		{"action": "set_track", "track": 0, "pan": -0.3},
+		{"action": "modify_fx_param", "track": 3, "fx_index": 1, "fxname": "ReaEQ", "parameter": "Band 2 Type", "value": "Notch"},
+	}, result.DAWActions(1))
+
+	// A negative track leaves recommendations without one off any track
+	action, err := ConvertRecommendationToDAWAction(result.Recommendations[0], -1)
+	require.NoError(t, err)
+	assert.NotContains(t, action, "track")
+
+	_, err = ConvertRecommendationToDAWAction(result.Recommendations[5], 1)
+	assert.ErrorContains(t, err, "recommendation rec_6 adds an FX without a name")
+}
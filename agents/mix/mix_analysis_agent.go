@@ -323,21 +323,29 @@ type Issue struct {
 	FrequencyRange []int  `json:"frequency_range,omitempty"` // [low, high] Hz
 }
 
-// Recommendation provides a suggested action
+// Recommendation provides a suggested action. The action's fields are flat,
+// as in the output schema; fields the action doesn't use are empty, or -1
+// for indexes.
 type Recommendation struct {
-	ID          string         `json:"id"`
-	Priority    string         `json:"priority"` // "low", "medium", "high"
-	Description string         `json:"description"`
-	Explanation string         `json:"explanation"`
-	Action      map[string]any `json:"action"` // DAW agent compatible action
-}
-
-// RelationshipRecommendation addresses multi-track issues
+	ID          string  `json:"id"`
+	Priority    string  `json:"priority"` // "low", "medium", "high"
+	Description string  `json:"description"`
+	Explanation string  `json:"explanation"`
+	ActionType  string  `json:"action_type"` // "add_fx", "modify_fx_param", "set_volume", "set_pan"
+	FXName      string  `json:"fx_name"`
+	FXIndex     int     `json:"fx_index"` // Existing FX to modify
+	Track       int     `json:"track"`    // -1 for the analyzed track
+	Parameter   string  `json:"parameter"`
+	Value       float64 `json:"value"` // Parameter value, volume in dB, or pan from -1 to 1
+	ValueString string  `json:"value_string"`
+}
+
+// RelationshipRecommendation addresses multi-track issues. The changes it
+// calls for are among the recommendations.
 type RelationshipRecommendation struct {
-	Tracks         []int            `json:"tracks"`
-	Issue          string           `json:"issue"`
-	Recommendation string           `json:"recommendation"`
-	Actions        []map[string]any `json:"actions"` // Actions for each track
+	Tracks         []int  `json:"tracks"`
+	Issue          string `json:"issue"`
+	Recommendation string `json:"recommendation"`
 }
 
 // MixAnalysisAgent analyzes audio and provides mixing recommendations
@@ -348,7 +356,15 @@ type MixAnalysisAgent struct {
 
 // NewMixAnalysisAgent creates a new mix analysis agent
 func NewMixAnalysisAgent(cfg *config.Config) *MixAnalysisAgent {
-	provider := llm.NewOpenAIProvider(cfg.OpenAIAPIKey)
+	return NewMixAnalysisAgentWithProvider(cfg, nil)
+}
+
+// NewMixAnalysisAgentWithProvider creates a mix analysis agent with a specific LLM provider
+func NewMixAnalysisAgentWithProvider(cfg *config.Config, provider llm.Provider) *MixAnalysisAgent {
+	// Use provided provider or create OpenAI provider (default)
+	if provider == nil {
+		provider = llm.NewOpenAIProvider(cfg.OpenAIAPIKey)
+	}
 
 	return &MixAnalysisAgent{
 		provider:     provider,
@@ -433,10 +449,13 @@ Each recommendation should include:
 - Reference existing FX by index when modifying
 
 ## Action Types You Can Recommend
-- "add_fx": Add an effect plugin (specify fx_name and parameters)
-- "modify_fx_param": Change a parameter on existing FX
-- "set_volume": Adjust track volume (volume_db)
-- "set_pan": Adjust track panning (-1 to 1)
+Set action_type and the fields it uses; set the others to "" (or -1 for fx_index and track).
+- "add_fx": Add an effect plugin (fx_name)
+- "modify_fx_param": Change a parameter on existing FX (fx_index, parameter, and value or value_string)
+- "set_volume": Adjust track volume (value in dB)
+- "set_pan": Adjust track panning (value from -1 to 1)
+Set track to the track's index, or -1 for the track being analyzed.
+Issues between tracks go in relationship_issues; put the changes that fix them in recommendations.
 
 ## REAPER Stock Plugins (USE ONLY THESE)
 Only recommend these REAPER built-in plugins:
@@ -62,7 +62,7 @@ func TestMixAnalysisAgent_AnalyzeTrack_Bass(t *testing.T) {
 	for _, rec := range result.Recommendations {
 		t.Logf("   [%s] %s", rec.Priority, rec.Description)
 		t.Logf("      Why: %s", rec.Explanation)
-		t.Logf("      Action Type: %s, FX: %s", rec.Action["action_type"], rec.Action["fx_name"])
+		t.Logf("      Action Type: %s, FX: %s", rec.ActionType, rec.FXName)
 	}
 
 	// Should recommend ReaEQ or ReaComp
@@ -125,7 +125,7 @@ func TestMixAnalysisAgent_AnalyzeMaster(t *testing.T) {
 
 	for _, rec := range result.Recommendations {
 		t.Logf("   [%s] %s", rec.Priority, rec.Description)
-		t.Logf("      Action: %v", rec.Action)
+		t.Logf("      Action: %s %s %s=%v", rec.ActionType, rec.FXName, rec.Parameter, rec.Value)
 	}
 
 	// Should recommend loudness increase for streaming
@@ -2,7 +2,10 @@ package config
 
 // Config contains configuration for MAGDA agents
 type Config struct {
-	OpenAIAPIKey string // OpenAI API key for LLM provider
-	GeminiAPIKey string // Google Gemini API key (optional)
-	MCPServerURL string // MCP server URL (optional)
+	OpenAIAPIKey      string // OpenAI API key for LLM provider
+	GeminiAPIKey      string // Google Gemini API key (optional)
+	MCPServerURL      string // MCP server URL (optional)
+	DSLRepairAttempts int    // Times rejected DSL is sent back to the model for repair (0 = disabled)
+	DSLSourceMap      bool   // Attach the producing DSL statement and span to each DAW action
+	DrumMap           string // Drum map preset drum patterns are converted to MIDI with ("" = General MIDI)
 }
@@ -1026,6 +1026,8 @@ func truncate(s string, maxLen int) string {
 
 // GenerateStream implements streaming generation using OpenAI's Responses API
 // It streams text chunks as they arrive from the LLM and calls the callback for each chunk
+// With a CFG grammar, text_delta events carry only the DSL tool input; output
+// text the model writes alongside it is sent as output_text_delta
 func (p *OpenAIProvider) GenerateStream(
 	ctx context.Context,
 	request *GenerationRequest,
@@ -1057,8 +1059,9 @@ func (p *OpenAIProvider) GenerateStream(
 	stream := p.client.Responses.NewStreaming(ctx, params)
 	defer stream.Close()
 
-	// Accumulate text and track usage
-	var accumulatedText string
+	// Accumulate text and track usage. With a CFG grammar the DSL arrives as
+	// tool input, and any output text alongside it is prose
+	var outputText, toolInput string
 	var finalResponse *responses.Response
 	eventCount := 0
 
@@ -1080,13 +1083,17 @@ func (p *OpenAIProvider) GenerateStream(
 			textDelta := event.AsResponseOutputTextDelta()
 			delta := textDelta.Delta
 			if delta != "" {
-				accumulatedText += delta
+				outputText += delta
+				eventType := "text_delta"
+				if request.CFGGrammar != nil {
+					eventType = "output_text_delta"
+				}
 				if callback != nil {
 					_ = callback(StreamEvent{
-						Type:    "text_delta",
+						Type:    eventType,
 						Message: delta,
 						Data: map[string]interface{}{
-							"accumulated_length": len(accumulatedText),
+							"accumulated_length": len(outputText),
 						},
 					})
 				}
@@ -1094,7 +1101,7 @@ func (p *OpenAIProvider) GenerateStream(
 
 		case "response.output_text.done":
 			// Text output complete
-			log.Printf("✅ Text output complete: %d chars accumulated", len(accumulatedText))
+			log.Printf("✅ Text output complete: %d chars accumulated", len(outputText))
 
 		case "response.completed":
 			// Response complete - extract final response
@@ -1122,13 +1129,30 @@ func (p *OpenAIProvider) GenerateStream(
 			// CFG tool call arguments streaming (for DSL output)
 			delta := event.Arguments
 			if delta != "" {
-				accumulatedText += delta
+				toolInput += delta
+				if callback != nil {
+					_ = callback(StreamEvent{
+						Type:    "text_delta",
+						Message: delta,
+						Data: map[string]interface{}{
+							"accumulated_length": len(toolInput),
+							"is_tool_call":       true,
+						},
+					})
+				}
+			}
+
+		case "response.custom_tool_call_input.delta":
+			// CFG custom tool input streaming (grammar-constrained DSL output)
+			delta := event.Delta.OfString
+			if delta != "" {
+				toolInput += delta
 				if callback != nil {
 					_ = callback(StreamEvent{
 						Type:    "text_delta",
 						Message: delta,
 						Data: map[string]interface{}{
-							"accumulated_length": len(accumulatedText),
+							"accumulated_length": len(toolInput),
 							"is_tool_call":       true,
 						},
 					})
@@ -1137,7 +1161,7 @@ func (p *OpenAIProvider) GenerateStream(
 
 		case "response.function_call_arguments.done":
 			// Tool call arguments complete
-			log.Printf("✅ Tool call arguments complete: %d chars", len(accumulatedText))
+			log.Printf("✅ Tool call arguments complete: %d chars", len(toolInput))
 
 		default:
 			// Log other event types for debugging
@@ -1172,10 +1196,16 @@ func (p *OpenAIProvider) GenerateStream(
 		return nil, fmt.Errorf("stream error: %w", err)
 	}
 
+	// As in processResponseWithCFG, the tool input is the output when there is one
+	rawOutput := outputText
+	if toolInput != "" {
+		rawOutput = toolInput
+	}
+
 	// Log completion
 	duration := time.Since(startTime)
 	log.Printf("✅ OPENAI STREAMING COMPLETE: %d events, %d chars, %v duration",
-		eventCount, len(accumulatedText), duration)
+		eventCount, len(rawOutput), duration)
 
 	// Send completion event
 	if callback != nil {
@@ -1183,7 +1213,7 @@ func (p *OpenAIProvider) GenerateStream(
 			Type:    "completed",
 			Message: "Generation complete",
 			Data: map[string]interface{}{
-				"total_length": len(accumulatedText),
+				"total_length": len(rawOutput),
 				"event_count":  eventCount,
 			},
 		})
@@ -1191,7 +1221,7 @@ func (p *OpenAIProvider) GenerateStream(
 
 	// Build response
 	response := &GenerationResponse{
-		RawOutput: accumulatedText,
+		RawOutput: rawOutput,
 	}
 
 	// Extract usage from final response if available
@@ -1,8 +1,15 @@
 package llm
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -142,3 +149,37 @@ func TestOpenAIProvider_ReasoningModeMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIProvider_GenerateStreamWithCFG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type": "response.output_text.delta", "delta": "Adding a bass track.", "item_id": "msg", "output_index": 0, "content_index": 0, "sequence_number": 1}`,
+			`{"type": "response.custom_tool_call_input.delta", "delta": "track(name=\"Bass\")", "item_id": "call", "output_index": 1, "sequence_number": 2}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL))
+	provider := &OpenAIProvider{client: &client, apiKey: "test-key"}
+
+	var textDeltas, outputText []string
+	resp, err := provider.GenerateStream(context.Background(), &GenerationRequest{
+		Model:      "gpt-5-mini",
+		CFGGrammar: &CFGConfig{ToolName: "magda_dsl", Grammar: "start: \"track\""},
+	}, func(event StreamEvent) error {
+		switch event.Type {
+		case "text_delta":
+			textDeltas = append(textDeltas, event.Message)
+		case "output_text_delta":
+			outputText = append(outputText, event.Message)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`track(name="Bass")`}, textDeltas, "only the DSL is sent as text deltas")
+	assert.Equal(t, []string{"Adding a bass track."}, outputText)
+	assert.Equal(t, `track(name="Bass")`, resp.RawOutput)
+}
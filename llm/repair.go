@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+)
+
+// ErrProviderFailed wraps provider errors returned by GenerateWithRepair so
+// callers can tell them apart from DSL that was still rejected after repair.
+var ErrProviderFailed = errors.New("provider request failed")
+
+// DSLValidator parses the DSL in a response and returns the actions it produces.
+type DSLValidator func(resp *GenerationResponse) ([]map[string]any, error)
+
+// RepairAttempt records a DSL output that was rejected and sent back to the model.
+type RepairAttempt struct {
+	Attempt     int                      `json:"attempt"` // 1 for the first output
+	DSL         string                   `json:"dsl"`
+	Error       string                   `json:"error"`
+	Diagnostics []diagnostics.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// RepairResult is the outcome of GenerateWithRepair.
+type RepairResult struct {
+	Response *GenerationResponse // Response that produced the final DSL
+	DSL      string
+	Actions  []map[string]any
+	History  []RepairAttempt // Rejected outputs, oldest first; empty if the first output was accepted
+	Repairs  int             // Rejected outputs sent back to the model
+}
+
+// RepairError is returned by GenerateWithRepair when the DSL is still
+// rejected after the last repair. It wraps the last validation error and
+// keeps the rejected outputs, so the history isn't lost when a caller only
+// returns the error.
+type RepairError struct {
+	History []RepairAttempt // Rejected outputs, oldest first
+	Err     error           // Validation error of the last output
+}
+
+func (e *RepairError) Error() string { return e.Err.Error() }
+func (e *RepairError) Unwrap() error { return e.Err }
+
+// AsRepairError returns the RepairError in err's chain, if any.
+func AsRepairError(err error) (*RepairError, bool) {
+	var repairErr *RepairError
+	if errors.As(err, &repairErr) {
+		return repairErr, true
+	}
+	return nil, false
+}
+
+// permanentError marks a validation error that a repair cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a validation error as not repairable, e.g. a request the
+// model has declared out of scope. GenerateWithRepair returns it immediately.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// GenerateWithRepair calls the provider and validates the DSL it returns. When
+// validation fails and maxRepairs > 0, the rejected DSL and its diagnostics are
+// appended to the conversation and the model is asked for a corrected version,
+// up to maxRepairs more times. With maxRepairs == 0 this is a single
+// generate-and-validate call.
+//
+// Provider errors are wrapped with ErrProviderFailed. When the output is still
+// rejected after the last repair, the validation error is returned wrapped in
+// a RepairError, along with a result holding the full history (and no
+// actions).
+func GenerateWithRepair(
+	ctx context.Context,
+	provider Provider,
+	request *GenerationRequest,
+	maxRepairs int,
+	validate DSLValidator,
+) (*RepairResult, error) {
+	current := *request
+	current.InputArray = append([]map[string]any(nil), request.InputArray...)
+
+	var history []RepairAttempt
+	for attempt := 1; ; attempt++ {
+		resp, err := provider.Generate(ctx, &current)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrProviderFailed, err)
+		}
+
+		actions, err := validate(resp)
+		if err == nil {
+			if len(history) > 0 {
+				log.Printf("🔧 DSL repaired after %d attempt(s)", len(history))
+			}
+			return &RepairResult{
+				Response: resp,
+				DSL:      resp.RawOutput,
+				Actions:  actions,
+				History:  history,
+				Repairs:  len(history),
+			}, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return nil, err
+		}
+
+		record := RepairAttempt{Attempt: attempt, DSL: resp.RawOutput, Error: err.Error()}
+		if diagErr, ok := diagnostics.AsError(err); ok {
+			record.Diagnostics = diagErr.Diagnostics
+		}
+		history = append(history, record)
+		log.Printf("🔧 DSL rejected (attempt %d/%d): %v", attempt, maxRepairs+1, err)
+
+		if attempt > maxRepairs {
+			return &RepairResult{Response: resp, DSL: resp.RawOutput, History: history, Repairs: max(0, maxRepairs)},
+				&RepairError{History: history, Err: err}
+		}
+
+		current.InputArray = append(current.InputArray, map[string]any{
+			"role":    "user",
+			"content": BuildRepairMessage(resp.RawOutput, err),
+		})
+	}
+}
+
+// BuildRepairMessage describes a rejected DSL output to the model, with
+// source-annotated diagnostics when the parser provided them.
+func BuildRepairMessage(dsl string, err error) string {
+	var b strings.Builder
+	b.WriteString("Your previous DSL output was rejected by the parser.\n\n")
+	b.WriteString("DSL:\n")
+	b.WriteString(dsl)
+	b.WriteString("\n\nProblems:\n")
+	if diagErr, ok := diagnostics.AsError(err); ok && len(diagErr.Diagnostics) > 0 {
+		b.WriteString(diagErr.Format())
+	} else {
+		b.WriteString(err.Error())
+		b.WriteString("\n")
+	}
+	b.WriteString("\nReturn the complete corrected DSL. Keep the parts that were correct and only fix the reported problems.")
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/diagnostics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider returns one output per call and records the requests it received.
+func scriptedProvider(outputs ...string) (*MockProvider, *[]*GenerationRequest) {
+	var requests []*GenerationRequest
+	return &MockProvider{
+		name: "mock",
+		generateFunc: func(ctx context.Context, request *GenerationRequest) (*GenerationResponse, error) {
+			requests = append(requests, request)
+			if len(requests) > len(outputs) {
+				return nil, errors.New("no more outputs")
+			}
+			return &GenerationResponse{RawOutput: outputs[len(requests)-1]}, nil
+		},
+	}, &requests
+}
+
+// acceptValid accepts the DSL "valid" and rejects everything else with a diagnostic.
+func acceptValid(resp *GenerationResponse) ([]map[string]any, error) {
+	if resp.RawOutput == "valid" {
+		return []map[string]any{{"action": "ok"}}, nil
+	}
+	diag := diagnostics.Diagnostic{
+		Severity: diagnostics.SeverityError,
+		Kind:     diagnostics.KindUnknownMethod,
+		Message:  fmt.Sprintf("unknown method '%s'", resp.RawOutput),
+		Span:     diagnostics.NewSpan(resp.RawOutput, 0, len(resp.RawOutput)),
+	}
+	return nil, diagnostics.NewError(resp.RawOutput, []diagnostics.Diagnostic{diag}, errors.New("failed to execute DSL"))
+}
+
+func TestGenerateWithRepair(t *testing.T) {
+	request := &GenerationRequest{InputArray: []map[string]any{{"role": "user", "content": "make a beat"}}}
+
+	t.Run("accepted first time", func(t *testing.T) {
+		provider, requests := scriptedProvider("valid")
+		result, err := GenerateWithRepair(context.Background(), provider, request, 2, acceptValid)
+		require.NoError(t, err)
+		assert.Equal(t, "valid", result.DSL)
+		assert.Empty(t, result.History)
+		assert.Equal(t, 0, result.Repairs)
+		assert.Len(t, *requests, 1)
+	})
+
+	t.Run("repaired on second attempt", func(t *testing.T) {
+		provider, requests := scriptedProvider("patern", "valid")
+		result, err := GenerateWithRepair(context.Background(), provider, request, 2, acceptValid)
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]any{{"action": "ok"}}, result.Actions)
+		assert.Equal(t, 1, result.Repairs)
+		require.Len(t, result.History, 1)
+		assert.Equal(t, 1, result.History[0].Attempt)
+		assert.Equal(t, "patern", result.History[0].DSL)
+		require.Len(t, result.History[0].Diagnostics, 1)
+
+		// The repair request carries the rejected DSL and its diagnostics,
+		// without modifying the caller's request
+		require.Len(t, *requests, 2)
+		repairInput := (*requests)[1].InputArray
+		require.Len(t, repairInput, 2)
+		assert.Contains(t, repairInput[1]["content"], "unknown method 'patern'")
+		assert.Contains(t, repairInput[1]["content"], "  patern\n  ^^^^^^")
+		assert.Len(t, request.InputArray, 1)
+	})
+
+	t.Run("gives up after max repairs", func(t *testing.T) {
+		provider, requests := scriptedProvider("a", "b", "c", "valid")
+		result, err := GenerateWithRepair(context.Background(), provider, request, 2, acceptValid)
+		require.Error(t, err)
+		assert.Len(t, *requests, 3)
+		require.NotNil(t, result)
+		assert.Equal(t, 2, result.Repairs)
+		assert.Len(t, result.History, 3)
+		assert.Nil(t, result.Actions)
+
+		// The error keeps the history for callers that drop the result
+		repairErr, ok := AsRepairError(fmt.Errorf("failed to parse actions: %w", err))
+		require.True(t, ok)
+		assert.Equal(t, result.History, repairErr.History)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		provider, requests := scriptedProvider("a", "valid")
+		_, err := GenerateWithRepair(context.Background(), provider, request, 0, acceptValid)
+		require.Error(t, err)
+		assert.Len(t, *requests, 1)
+		repairErr, ok := AsRepairError(err)
+		require.True(t, ok)
+		assert.Len(t, repairErr.History, 1)
+	})
+
+	t.Run("permanent errors are not repaired", func(t *testing.T) {
+		provider, requests := scriptedProvider("// ERROR: out of scope", "valid")
+		outOfScope := errors.New("request is out of scope")
+		_, err := GenerateWithRepair(context.Background(), provider, request, 2, func(*GenerationResponse) ([]map[string]any, error) {
+			return nil, Permanent(outOfScope)
+		})
+		assert.ErrorIs(t, err, outOfScope)
+		assert.Len(t, *requests, 1)
+	})
+
+	t.Run("provider errors", func(t *testing.T) {
+		provider, _ := scriptedProvider()
+		_, err := GenerateWithRepair(context.Background(), provider, request, 2, acceptValid)
+		assert.ErrorIs(t, err, ErrProviderFailed)
+		assert.Equal(t, "provider request failed: no more outputs", err.Error())
+	})
+}
+
+func TestBuildRepairMessage(t *testing.T) {
+	message := BuildRepairMessage("track(", errors.New("unbalanced"))
+	assert.Contains(t, message, "DSL:\ntrack(\n")
+	assert.Contains(t, message, "Problems:\nunbalanced\n")
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// StreamDSL runs a streaming generation and passes each piece of output text
+// to write as it arrives, so DSL statements can be executed before the model
+// has finished. Providers that stream no text deltas have their final
+// RawOutput written in one piece.
+//
+// An error from write cancels the generation and is returned as-is. Provider
+// errors are wrapped with ErrProviderFailed, as in GenerateWithRepair.
+func StreamDSL(
+	ctx context.Context,
+	provider StreamingProvider,
+	request *GenerationRequest,
+	write func(text string) error,
+) (*GenerationResponse, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeErr error
+	received := 0
+	resp, err := provider.GenerateStream(streamCtx, request, func(event StreamEvent) error {
+		if writeErr != nil || (event.Type != "text_delta" && event.Type != "chunk") {
+			return nil
+		}
+		received += len(event.Message)
+		if writeErr = write(event.Message); writeErr != nil {
+			cancel()
+		}
+		return writeErr
+	})
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderFailed, err)
+	}
+
+	if received == 0 && resp != nil && resp.RawOutput != "" {
+		if err := write(resp.RawOutput); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDSL(t *testing.T) {
+	deltas := func(parts ...string) *MockProvider {
+		return &MockProvider{
+			name: "mock",
+			generateStreamFunc: func(ctx context.Context, request *GenerationRequest, callback StreamCallback) (*GenerationResponse, error) {
+				_ = callback(StreamEvent{Type: "started"})
+				for _, part := range parts {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+					_ = callback(StreamEvent{Type: "text_delta", Message: part})
+					_ = callback(StreamEvent{Type: "heartbeat", Message: "Processing..."})
+				}
+				return &GenerationResponse{RawOutput: "final"}, nil
+			},
+		}
+	}
+
+	t.Run("writes text deltas", func(t *testing.T) {
+		var written []string
+		resp, err := StreamDSL(context.Background(), deltas("pattern(", "drum=kick);"), &GenerationRequest{}, func(text string) error {
+			written = append(written, text)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pattern(", "drum=kick);"}, written)
+		assert.Equal(t, "final", resp.RawOutput)
+	})
+
+	t.Run("falls back to raw output", func(t *testing.T) {
+		var written []string
+		_, err := StreamDSL(context.Background(), deltas(), &GenerationRequest{}, func(text string) error {
+			written = append(written, text)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"final"}, written)
+	})
+
+	t.Run("write error cancels", func(t *testing.T) {
+		writeErr := errors.New("bad statement")
+		calls := 0
+		_, err := StreamDSL(context.Background(), deltas("a", "b", "c"), &GenerationRequest{}, func(text string) error {
+			calls++
+			return writeErr
+		})
+		assert.Equal(t, writeErr, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		provider := &MockProvider{
+			name: "mock",
+			generateStreamFunc: func(ctx context.Context, request *GenerationRequest, callback StreamCallback) (*GenerationResponse, error) {
+				return nil, errors.New("rate limited")
+			},
+		}
+		_, err := StreamDSL(context.Background(), provider, &GenerationRequest{}, func(string) error { return nil })
+		assert.ErrorIs(t, err, ErrProviderFailed)
+		assert.ErrorContains(t, err, "rate limited")
+	})
+}
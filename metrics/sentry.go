@@ -108,3 +108,30 @@ func (m *SentryMetrics) RecordGenerationDuration(ctx context.Context, duration t
 
 	span.Description = fmt.Sprintf("Generation Request: %t", success)
 }
+
+// RecordDSLRepair records a DSL repair loop run: how many rejected outputs were
+// sent back to the model and whether a valid DSL was eventually produced
+func (m *SentryMetrics) RecordDSLRepair(ctx context.Context, agent string, repairs int, success bool) {
+	if !m.enabled {
+		return
+	}
+
+	span := sentry.StartSpan(ctx, "dsl.repair")
+	defer span.Finish()
+
+	// Set span tags
+	span.SetTag("agent", agent)
+	span.SetTag("success", fmt.Sprintf("%t", success))
+
+	// Set span data
+	span.SetData("repairs", repairs)
+	span.SetData("success", success)
+
+	if success {
+		span.Status = sentry.SpanStatusOK
+	} else {
+		span.Status = sentry.SpanStatusInternalError
+	}
+
+	span.Description = fmt.Sprintf("DSL Repair: %s (%d repairs, success=%t)", agent, repairs, success)
+}
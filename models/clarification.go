@@ -0,0 +1,28 @@
+package models
+
+// Clarification is returned instead of actions when a request is too
+// ambiguous to act on, e.g. "make it louder" with no track selected. The host
+// asks the user the question, and resumes the request with the chosen option.
+type Clarification struct {
+	Request  string                `json:"request"`  // The original request
+	Question string                `json:"question"` // What to ask the user
+	Options  []ClarificationOption `json:"options"`
+	Agent    string                `json:"agent,omitempty"` // Agent that asked
+}
+
+// ClarificationOption is an answer the user can choose
+type ClarificationOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Track *int   `json:"track,omitempty"` // Track the option picks, selected when the request resumes
+}
+
+// Option returns the option with the given ID
+func (c *Clarification) Option(id string) (*ClarificationOption, bool) {
+	for i := range c.Options {
+		if c.Options[i].ID == id {
+			return &c.Options[i], true
+		}
+	}
+	return nil, false
+}
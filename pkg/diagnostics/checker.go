@@ -0,0 +1,358 @@
+package diagnostics
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/Conceptual-Machines/magda-agents-go/pkg/dslscan"
+)
+
+var (
+	unknownMethodPattern = regexp.MustCompile(`unknown method: (\w+)`)
+	methodErrorPattern   = regexp.MustCompile(`(?s)method (\w+) error: (.*)`)
+	namedArgPattern      = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*(?:[-+*/]?=)(?:[^=]|$)`)
+)
+
+// Checker finds problems in DSL source before and after it is executed by a
+// Grammar School engine. Method names come from the DSL struct the engine
+// dispatches to; parameter names come from the Lark grammar.
+type Checker struct {
+	signatures map[string][]string
+	handlers   map[string]bool // Go method names reachable from the DSL
+	methods    []string        // DSL method names, for suggestions
+}
+
+// NewChecker creates a checker for a grammar and the DSL struct passed to gs.NewEngine.
+func NewChecker(grammar string, dsl any) *Checker {
+	c := &Checker{
+		signatures: Signatures(grammar),
+		handlers:   make(map[string]bool),
+	}
+
+	argsType := reflect.TypeOf(gs.Args{})
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	dslType := reflect.TypeOf(dsl)
+	for i := 0; i < dslType.NumMethod(); i++ {
+		method := dslType.Method(i)
+		if method.Type.NumIn() != 2 || method.Type.In(1) != argsType || method.Type.NumOut() != 1 || method.Type.Out(0) != errorType {
+			continue
+		}
+		c.handlers[method.Name] = true
+		// Only names the engine can reach from snake_case are worth suggesting
+		if name := SnakeCase(method.Name); engineMethodName(name) == method.Name {
+			c.methods = append(c.methods, name)
+		}
+	}
+	sort.Strings(c.methods)
+
+	return c
+}
+
+// Signature returns the named parameters the grammar allows for a method.
+func (c *Checker) Signature(method string) ([]string, bool) {
+	params, ok := c.signatures[method]
+	return params, ok
+}
+
+// call is a method call found in DSL source.
+type call struct {
+	name      string
+	nameStart int
+	open      int
+	close     int // -1 when the parenthesis is never closed
+}
+
+func (c call) end() int {
+	if c.close < 0 {
+		return c.open + 1
+	}
+	return c.close + 1
+}
+
+// Check reports syntax errors, unknown methods (errors) and unknown
+// parameters (warnings) in source without executing it.
+func (c *Checker) Check(source string) []Diagnostic {
+	calls, diags := scan(source)
+
+	for _, cl := range calls {
+		if !c.handlers[engineMethodName(cl.name)] {
+			diags = append(diags, Diagnostic{
+				Severity:    SeverityError,
+				Kind:        KindUnknownMethod,
+				Message:     fmt.Sprintf("unknown method '%s'", cl.name),
+				Span:        NewSpan(source, cl.nameStart, cl.nameStart+len(cl.name)),
+				Method:      cl.name,
+				Suggestions: Suggest(cl.name, c.methods),
+			})
+			continue
+		}
+		diags = append(diags, c.checkParams(source, cl)...)
+	}
+
+	sortDiagnostics(diags)
+	return diags
+}
+
+// CheckRange checks the statement at source[start:end] as Check does, with
+// spans pointing into the whole source. Streaming parsers use it to check
+// each statement as it completes without checking the source before it again.
+func (c *Checker) CheckRange(source string, start, end int) []Diagnostic {
+	diags := c.Check(source[start:end])
+	base := PositionAt(source, start)
+	for i := range diags {
+		diags[i].Span = Span{Start: diags[i].Span.Start.shift(base), End: diags[i].Span.End.shift(base)}
+	}
+	return diags
+}
+
+// checkParams reports named arguments the grammar does not define for the call.
+func (c *Checker) checkParams(source string, cl call) []Diagnostic {
+	method := SnakeCase(cl.name)
+	params, ok := c.signatures[method]
+	if !ok || cl.close < 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(params))
+	for _, param := range params {
+		allowed[param] = true
+	}
+
+	var diags []Diagnostic
+	for _, arg := range splitArgs(source, cl.open+1, cl.close) {
+		text := source[arg:cl.close]
+		match := namedArgPattern.FindStringSubmatch(text)
+		if match == nil || allowed[match[1]] {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Severity:    SeverityWarning,
+			Kind:        KindUnknownParameter,
+			Message:     fmt.Sprintf("unknown parameter '%s' for %s", match[1], method),
+			Span:        NewSpan(source, arg, arg+len(match[1])),
+			Method:      method,
+			Parameter:   match[1],
+			Expected:    params,
+			Suggestions: Suggest(match[1], params),
+		})
+	}
+	return diags
+}
+
+// Explain turns an error from executing source[start:end] into a diagnostic
+// pointing at the failing call when the error names one.
+func (c *Checker) Explain(source string, start, end int, err error) Diagnostic {
+	message := err.Error()
+	region := source[start:end]
+	diag := Diagnostic{
+		Severity: SeverityError,
+		Kind:     KindExecution,
+		Message:  message,
+		Span:     NewSpan(source, start, end),
+	}
+
+	// locate narrows the span to the first call the engine dispatches to
+	// engineName and returns the name as written in the source
+	locate := func(engineName string) string {
+		calls, _ := scan(region)
+		for _, cl := range calls {
+			if engineMethodName(cl.name) == engineName {
+				diag.Span = NewSpan(source, start+cl.nameStart, start+cl.end())
+				return cl.name
+			}
+		}
+		return SnakeCase(engineName)
+	}
+
+	switch {
+	case strings.Contains(message, "parse error: "):
+		diag.Kind = KindSyntax
+	case unknownMethodPattern.MatchString(message):
+		match := unknownMethodPattern.FindStringSubmatch(message)
+		diag.Kind = KindUnknownMethod
+		diag.Method = locate(match[1])
+		diag.Message = fmt.Sprintf("unknown method '%s'", diag.Method)
+		diag.Suggestions = Suggest(diag.Method, c.methods)
+	case methodErrorPattern.MatchString(message):
+		match := methodErrorPattern.FindStringSubmatch(message)
+		diag.Method = SnakeCase(locate(match[1]))
+		diag.Message = match[2]
+	}
+
+	return diag
+}
+
+// sortDiagnostics orders diagnostics by position, errors before warnings.
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Span.Start.Offset != diags[j].Span.Start.Offset {
+			return diags[i].Span.Start.Offset < diags[j].Span.Start.Offset
+		}
+		return diags[i].Severity == SeverityError && diags[j].Severity != SeverityError
+	})
+}
+
+// engineMethodName mirrors how the Grammar School parser maps a DSL name to a
+// Go method: each "_" part is capitalized and the rest lowercased.
+func engineMethodName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + strings.ToLower(part[1:]))
+		}
+	}
+	return b.String()
+}
+
+// splitArgs returns the start offsets of the top-level arguments in
+// source[start:end], skipping leading whitespace.
+func splitArgs(source string, start, end int) []int {
+	var args []int
+	region := source[start:end]
+	add := func(from int) {
+		text := region[from:]
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		if trimmed != "" && trimmed[0] != ',' {
+			args = append(args, start+from+len(text)-len(trimmed))
+		}
+	}
+
+	add(0)
+	dslscan.Walk(region, func(i, depth int) {
+		if depth == 0 && region[i] == ',' {
+			add(i + 1)
+		}
+	})
+
+	return args
+}
+
+// scan finds top-level calls and reports bracket, string and chaining errors.
+func scan(source string) ([]call, []Diagnostic) {
+	var calls []call
+	var diags []Diagnostic
+	type opener struct {
+		char   byte
+		offset int
+	}
+	var stack []opener
+	closers := map[byte]byte{'(': ')', '[': ']', '{': '}'}
+
+	inString := false
+	escapeNext := false
+	stringStart := 0
+	for i := 0; i < len(source); i++ {
+		char := source[i]
+		if escapeNext {
+			escapeNext = false
+			continue
+		}
+		if inString {
+			switch char {
+			case '\\':
+				escapeNext = true
+			case '"':
+				inString = false
+			case '\n':
+				inString = false
+				diags = append(diags, unterminatedString(source, stringStart, i))
+			}
+			continue
+		}
+
+		switch {
+		case char == '"':
+			inString = true
+			stringStart = i
+		case char == '(' || char == '[' || char == '{':
+			stack = append(stack, opener{char, i})
+		case char == ')' || char == ']' || char == '}':
+			if len(stack) == 0 {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Kind:     KindSyntax,
+					Message:  fmt.Sprintf("unexpected '%c'", char),
+					Span:     NewSpan(source, i, i+1),
+				})
+				continue
+			}
+			top := stack[len(stack)-1]
+			if closers[top.char] != char {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Kind:     KindSyntax,
+					Message:  fmt.Sprintf("unexpected '%c'", char),
+					Span:     NewSpan(source, i, i+1),
+					Expected: []string{string(closers[top.char])},
+				})
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 && char == ')' {
+				for j := len(calls) - 1; j >= 0; j-- {
+					if calls[j].open == top.offset {
+						calls[j].close = i
+						break
+					}
+				}
+			}
+		case char == '.' && len(stack) == 0:
+			if i > 0 && source[i-1] >= '0' && source[i-1] <= '9' {
+				continue
+			}
+			rest := strings.TrimLeft(source[i+1:], " \t")
+			if rest == "" || !isIdentifierChar(rest[0]) || (rest[0] >= '0' && rest[0] <= '9') {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Kind:     KindSyntax,
+					Message:  "expected a method name after '.'",
+					Span:     NewSpan(source, i, i+1),
+					Expected: []string{"method call"},
+				})
+			}
+		case isIdentifierChar(char) && len(stack) == 0 && (i == 0 || !isIdentifierChar(source[i-1])):
+			end := i
+			for end < len(source) && isIdentifierChar(source[end]) {
+				end++
+			}
+			name := source[i:end]
+			next := end
+			for next < len(source) && (source[next] == ' ' || source[next] == '\t') {
+				next++
+			}
+			prev := strings.TrimRight(source[:i], " \t")
+			if next < len(source) && source[next] == '(' && isIdentifier(name) && !strings.HasSuffix(prev, "@") {
+				calls = append(calls, call{name: name, nameStart: i, open: next, close: -1})
+			}
+			i = end - 1
+		}
+	}
+
+	if inString {
+		diags = append(diags, unterminatedString(source, stringStart, len(source)))
+	}
+	for _, open := range stack {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Kind:     KindSyntax,
+			Message:  fmt.Sprintf("unclosed '%c'", open.char),
+			Span:     NewSpan(source, open.offset, open.offset+1),
+			Expected: []string{string(closers[open.char])},
+		})
+	}
+
+	return calls, diags
+}
+
+func unterminatedString(source string, start, end int) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Kind:     KindSyntax,
+		Message:  "unterminated string",
+		Span:     NewSpan(source, start, end),
+		Expected: []string{`"`},
+	}
+}
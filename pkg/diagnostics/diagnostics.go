@@ -0,0 +1,179 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity tells whether a diagnostic stopped the script or is advisory.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Kind classifies a diagnostic so callers can react to it without parsing messages.
+type Kind string
+
+const (
+	KindSyntax           Kind = "syntax"
+	KindUnknownMethod    Kind = "unknown_method"
+	KindUnknownParameter Kind = "unknown_parameter"
+	KindUnknownTrack     Kind = "unknown_track"
+	KindExecution        Kind = "execution"
+)
+
+// Position is a location in DSL source. Line and Column are 1-based;
+// Column counts bytes, which matches the ASCII-only DSL grammars.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is a half-open range of DSL source.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// PositionAt returns the position of a byte offset in source.
+func PositionAt(source string, offset int) Position {
+	offset = max(0, min(offset, len(source)))
+	line := 1 + strings.Count(source[:offset], "\n")
+	lineStart := strings.LastIndex(source[:offset], "\n") + 1
+	return Position{Offset: offset, Line: line, Column: offset - lineStart + 1}
+}
+
+// shift moves a position in a region of source that starts at base to the
+// same place in the whole source.
+func (p Position) shift(base Position) Position {
+	shifted := Position{Offset: base.Offset + p.Offset, Line: base.Line + p.Line - 1, Column: p.Column}
+	if p.Line == 1 {
+		shifted.Column = base.Column + p.Column - 1
+	}
+	return shifted
+}
+
+// NewSpan returns the span covering source[start:end].
+func NewSpan(source string, start, end int) Span {
+	if end < start {
+		end = start
+	}
+	return Span{Start: PositionAt(source, start), End: PositionAt(source, end)}
+}
+
+// Diagnostic is a single problem found in DSL source.
+type Diagnostic struct {
+	Severity    Severity `json:"severity"`
+	Kind        Kind     `json:"kind"`
+	Message     string   `json:"message"`
+	Span        Span     `json:"span"`
+	Method      string   `json:"method,omitempty"`
+	Parameter   string   `json:"parameter,omitempty"`
+	Expected    []string `json:"expected,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// String renders the diagnostic on one line, e.g.
+// `1:22: warning: unknown parameter 'volume' for set_track (did you mean 'volume_db'?)`.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d: %s: %s", d.Span.Start.Line, d.Span.Start.Column, d.Severity, d.Message)
+	if len(d.Suggestions) > 0 {
+		fmt.Fprintf(&b, " (did you mean %s?)", quoteList(d.Suggestions, " or "))
+	} else if len(d.Expected) > 0 {
+		fmt.Fprintf(&b, " (expected %s)", quoteList(d.Expected, ", "))
+	}
+	return b.String()
+}
+
+// Error is returned by the DSL parsers when a script fails. Error() keeps the
+// parser's original message; Diagnostics carries the structured detail.
+type Error struct {
+	Source      string       `json:"source"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Err         error        `json:"-"`
+}
+
+// NewError returns the error for a failed script, with diags sorted by position.
+func NewError(source string, diags []Diagnostic, err error) *Error {
+	sorted := append([]Diagnostic(nil), diags...)
+	sortDiagnostics(sorted)
+	return &Error{Source: source, Diagnostics: sorted, Err: err}
+}
+
+// First returns the first error-severity diagnostic.
+func First(diags []Diagnostic) (Diagnostic, bool) {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Format renders every diagnostic with the offending source line and a caret
+// marker under the span. The output is meant for logs, UIs without rich
+// rendering, and LLM repair prompts.
+func (e *Error) Format() string {
+	return Format(e.Source, e.Diagnostics)
+}
+
+// AsError extracts the diagnostics error from an error chain.
+func AsError(err error) (*Error, bool) {
+	var diagErr *Error
+	if errors.As(err, &diagErr) {
+		return diagErr, true
+	}
+	return nil, false
+}
+
+// Format renders diagnostics against their source, one block per diagnostic:
+//
+//	1:22: warning: unknown parameter 'volume' for set_track (did you mean 'volume_db'?)
+//	  track(id=1).set_track(volume=-3)
+//	                        ^^^^^^
+func Format(source string, diags []Diagnostic) string {
+	lines := strings.Split(source, "\n")
+	var b strings.Builder
+	for i, d := range diags {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(d.String())
+		b.WriteString("\n")
+
+		lineIndex := d.Span.Start.Line - 1
+		if lineIndex < 0 || lineIndex >= len(lines) {
+			continue
+		}
+		line := lines[lineIndex]
+		width := 1
+		if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > d.Span.Start.Column {
+			width = d.Span.End.Column - d.Span.Start.Column
+		} else if d.Span.End.Line > d.Span.Start.Line {
+			width = max(1, len(line)-d.Span.Start.Column+1)
+		}
+		fmt.Fprintf(&b, "  %s\n  %s%s\n", line, strings.Repeat(" ", d.Span.Start.Column-1), strings.Repeat("^", width))
+	}
+	return b.String()
+}
+
+func quoteList(items []string, sep string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "'" + item + "'"
+	}
+	return strings.Join(quoted, sep)
+}
@@ -0,0 +1,211 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Conceptual-Machines/grammar-school-go/gs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGrammar = `
+start: statement (";" statement)*
+statement: track_call chain*
+track_call: "track" "(" track_params? ")"
+track_params: track_param ("," SP track_param)*
+           | NUMBER
+track_param: "name" "=" STRING  // comment with "quotes"
+           | "id" "=" NUMBER
+chain: ".set_track" "(" set_params? ")"
+     | ".add_automation" "(" automation_params ")"
+set_params: set_param ("," SP set_param)*
+set_param: "volume_db" assign_op NUMBER
+         | "mute" "=" BOOLEAN
+automation_params: automation_param ("," SP automation_param)*
+automation_param: "curve" "=" STRING
+                | "direction" "=" ("up" | "down")
+                | "points" "=" points
+points: "[" "{" "time" "=" NUMBER "}" "]"
+assign_op: "=" | "+=" | "-="
+SP: " "
+STRING: /"[^"]*"/
+NUMBER: /-?\d+(\.\d+)?/
+BOOLEAN: "true" | "false"
+`
+
+type testDSL struct{}
+
+func (d *testDSL) Track(args gs.Args) error         { return nil }
+func (d *testDSL) SetTrack(args gs.Args) error      { return nil }
+func (d *testDSL) AddAutomation(args gs.Args) error { return nil }
+func (d *testDSL) Helper() string                   { return "" }
+
+func TestPositionAt(t *testing.T) {
+	source := "track(id=1)\n  .set_track(mute=true)"
+	assert.Equal(t, Position{Offset: 0, Line: 1, Column: 1}, PositionAt(source, 0))
+	assert.Equal(t, Position{Offset: 14, Line: 2, Column: 3}, PositionAt(source, 14))
+	assert.Equal(t, Position{Offset: len(source), Line: 2, Column: 24}, PositionAt(source, 100))
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		want       []string
+	}{
+		{"volume", []string{"volume_db", "pan", "mute"}, []string{"volume_db"}},
+		{"addAutomation", []string{"add_automation", "add_fx"}, []string{"add_automation"}},
+		{"set_trak", []string{"set_track", "set_clip", "delete"}, []string{"set_track"}},
+		{"Bas", []string{"Bass", "Drums", "Bass Synth"}, []string{"Bass", "Bass Synth"}},
+		{"tempo", []string{"volume_db", "pan"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Suggest(tt.name, tt.candidates))
+		})
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	signatures := Signatures(testGrammar)
+	assert.Equal(t, map[string][]string{
+		"track":          {"id", "name"},
+		"set_track":      {"mute", "volume_db"},
+		"add_automation": {"curve", "direction", "points"},
+	}, signatures)
+}
+
+func TestChecker_Check(t *testing.T) {
+	checker := NewChecker(testGrammar, &testDSL{})
+
+	tests := []struct {
+		name   string
+		source string
+		want   []Diagnostic
+	}{
+		{
+			name:   "valid script",
+			source: `track(id=1).set_track(volume_db+=-3, mute=true); track(name="Pad")`,
+		},
+		{
+			name:   "unknown parameter",
+			source: `track(id=1).set_track(volume=-3)`,
+			want: []Diagnostic{{
+				Severity: SeverityWarning, Kind: KindUnknownParameter,
+				Message:     "unknown parameter 'volume' for set_track",
+				Span:        Span{Position{22, 1, 23}, Position{28, 1, 29}},
+				Method:      "set_track",
+				Parameter:   "volume",
+				Expected:    []string{"mute", "volume_db"},
+				Suggestions: []string{"volume_db"},
+			}},
+		},
+		{
+			name:   "camelCase method",
+			source: "track(id=1)\n  .addAutomation(curve=\"sine\")",
+			want: []Diagnostic{{
+				Severity: SeverityError, Kind: KindUnknownMethod,
+				Message:     "unknown method 'addAutomation'",
+				Span:        Span{Position{15, 2, 4}, Position{28, 2, 17}},
+				Method:      "addAutomation",
+				Suggestions: []string{"add_automation"},
+			}},
+		},
+		{
+			name:   "unclosed call",
+			source: `track(id=1).set_track(mute=true`,
+			want: []Diagnostic{{
+				Severity: SeverityError, Kind: KindSyntax,
+				Message:  "unclosed '('",
+				Span:     Span{Position{21, 1, 22}, Position{22, 1, 23}},
+				Expected: []string{")"},
+			}},
+		},
+		{
+			name:   "unterminated string",
+			source: `track(name="Bass)`,
+			want: []Diagnostic{
+				{
+					Severity: SeverityError, Kind: KindSyntax,
+					Message:  "unclosed '('",
+					Span:     Span{Position{5, 1, 6}, Position{6, 1, 7}},
+					Expected: []string{")"},
+				},
+				{
+					Severity: SeverityError, Kind: KindSyntax,
+					Message:  "unterminated string",
+					Span:     Span{Position{11, 1, 12}, Position{17, 1, 18}},
+					Expected: []string{`"`},
+				},
+			},
+		},
+		{
+			name:   "dangling chain",
+			source: `track(id=1).`,
+			want: []Diagnostic{{
+				Severity: SeverityError, Kind: KindSyntax,
+				Message:  "expected a method name after '.'",
+				Span:     Span{Position{11, 1, 12}, Position{12, 1, 13}},
+				Expected: []string{"method call"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checker.Check(tt.source))
+		})
+	}
+}
+
+func TestChecker_Explain(t *testing.T) {
+	checker := NewChecker(testGrammar, &testDSL{})
+	source := `track(id=1); track(id=2).set_track(mute=true)`
+
+	methodErr := fmt.Errorf("failed to execute DSL: %w", fmt.Errorf("method SetTrack error: %w", errors.New("no track context")))
+	diag := checker.Explain(source, 13, len(source), methodErr)
+	assert.Equal(t, KindExecution, diag.Kind)
+	assert.Equal(t, "set_track", diag.Method)
+	assert.Equal(t, "no track context", diag.Message)
+	assert.Equal(t, 25, diag.Span.Start.Offset)
+	assert.Equal(t, len(source), diag.Span.End.Offset)
+
+	diag = checker.Explain(source, 0, 11, errors.New("something else"))
+	assert.Equal(t, KindExecution, diag.Kind)
+	assert.Equal(t, Span{Position{0, 1, 1}, Position{11, 1, 12}}, diag.Span)
+}
+
+func TestChecker_CheckRange(t *testing.T) {
+	checker := NewChecker(testGrammar, &testDSL{})
+	source := "track(id=1);\ntrack(id=2)\n  .addAutomation(curve=\"sine\")"
+	start := strings.Index(source, "track(id=2)")
+
+	diags := checker.CheckRange(source, start, len(source))
+	require.Len(t, diags, 1)
+	assert.Equal(t, Span{Position{28, 3, 4}, Position{41, 3, 17}}, diags[0].Span)
+	assert.Equal(t, checker.Check(source), diags)
+
+	diags = checker.CheckRange("track(id=1); track(id=2).set_track(volume=-3)", 13, 45)
+	require.Len(t, diags, 1)
+	assert.Equal(t, Span{Position{35, 1, 36}, Position{41, 1, 42}}, diags[0].Span)
+}
+
+func TestError(t *testing.T) {
+	checker := NewChecker(testGrammar, &testDSL{})
+	source := "track(id=1).set_track(volume=-3)"
+	cause := errors.New("failed to execute DSL: boom")
+	err := NewError(source, checker.Check(source), cause)
+
+	assert.Equal(t, "failed to execute DSL: boom", err.Error())
+	assert.ErrorIs(t, fmt.Errorf("wrapped: %w", err), cause)
+
+	found, ok := AsError(fmt.Errorf("wrapped: %w", err))
+	require.True(t, ok)
+	assert.Equal(t, "1:23: warning: unknown parameter 'volume' for set_track (did you mean 'volume_db'?)\n"+
+		"  track(id=1).set_track(volume=-3)\n"+
+		"                        ^^^^^^\n", found.Format())
+}
@@ -0,0 +1,200 @@
+package diagnostics
+
+import (
+	"sort"
+	"strings"
+)
+
+// grammarToken is a token of a Lark grammar: a quoted literal, a rule or
+// terminal name, a regex, or a single punctuation character.
+type grammarToken struct {
+	text    string
+	literal bool
+}
+
+// Signatures extracts method signatures from a Lark grammar: every call rule
+// like `"track" "(" track_params? ")"` or `".set_track" "(" ... ")"` maps the
+// method name to the named parameters reachable from its argument rules
+// (`"name" "=" STRING`, `"volume_db" assign_op numeric_expr`).
+//
+// Values are not searched, so `"points" "=" automation_points` contributes
+// "points" but not the fields of each point. Methods whose arguments are all
+// positional map to an empty list.
+func Signatures(grammar string) map[string][]string {
+	rules := parseGrammarRules(grammar)
+	signatures := make(map[string][]string)
+
+	for _, tokens := range rules {
+		for i := 0; i+1 < len(tokens); i++ {
+			if !tokens[i].literal || tokens[i+1].text != "(" || !tokens[i+1].literal {
+				continue
+			}
+			name := strings.TrimPrefix(tokens[i].text, ".")
+			if !isIdentifier(name) {
+				continue
+			}
+
+			params := make(map[string]bool)
+			for _, existing := range signatures[name] {
+				params[existing] = true
+			}
+			for j := i + 2; j < len(tokens); j++ {
+				if tokens[j].literal && tokens[j].text == ")" {
+					break
+				}
+				if isRuleName(tokens[j].text) {
+					collectParams(rules, tokens[j].text, params, make(map[string]bool))
+				}
+			}
+
+			names := make([]string, 0, len(params))
+			for param := range params {
+				names = append(names, param)
+			}
+			sort.Strings(names)
+			signatures[name] = names
+		}
+	}
+
+	return signatures
+}
+
+// collectParams adds the named parameters of rule and the rules it refers to.
+func collectParams(rules map[string][]grammarToken, rule string, params, visited map[string]bool) {
+	if visited[rule] {
+		return
+	}
+	visited[rule] = true
+
+	tokens := rules[rule]
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.literal && i+1 < len(tokens) && isAssignment(tokens[i+1]) {
+			if isIdentifier(token.text) {
+				params[token.text] = true
+			}
+			i = skipValue(tokens, i+2)
+			continue
+		}
+		if !token.literal && isRuleName(token.text) {
+			collectParams(rules, token.text, params, visited)
+		}
+	}
+}
+
+// skipValue returns the index of the last token of the value starting at i,
+// which is a single token or a parenthesised group.
+func skipValue(tokens []grammarToken, i int) int {
+	if i >= len(tokens) {
+		return i
+	}
+	if tokens[i].literal || tokens[i].text != "(" {
+		return i
+	}
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].literal {
+			continue
+		}
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+func isAssignment(token grammarToken) bool {
+	if token.literal {
+		return token.text == "="
+	}
+	return token.text == "assign_op"
+}
+
+// isRuleName reports whether name is a rule (lowercase) rather than a terminal.
+func isRuleName(name string) bool {
+	return isIdentifier(name) && strings.ToLower(name) == name
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseGrammarRules tokenizes a Lark grammar into rule bodies keyed by rule name.
+func parseGrammarRules(grammar string) map[string][]grammarToken {
+	var tokens []grammarToken
+	for i := 0; i < len(grammar); {
+		c := grammar[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case strings.HasPrefix(grammar[i:], "//"):
+			end := strings.IndexByte(grammar[i:], '\n')
+			if end < 0 {
+				end = len(grammar) - i
+			}
+			i += end
+		case c == '"':
+			end := i + 1
+			for end < len(grammar) && grammar[end] != '"' {
+				if grammar[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			tokens = append(tokens, grammarToken{text: grammar[i+1 : min(end, len(grammar))], literal: true})
+			i = end + 1
+		case c == '/':
+			end := i + 1
+			for end < len(grammar) && grammar[end] != '/' {
+				if grammar[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			tokens = append(tokens, grammarToken{text: "/regex/"})
+			i = end + 1
+		case isIdentifierChar(c):
+			end := i
+			for end < len(grammar) && isIdentifierChar(grammar[end]) {
+				end++
+			}
+			tokens = append(tokens, grammarToken{text: grammar[i:end]})
+			i = end
+		default:
+			tokens = append(tokens, grammarToken{text: string(c)})
+			i++
+		}
+	}
+
+	rules := make(map[string][]grammarToken)
+	current := ""
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].literal && isIdentifier(tokens[i].text) && i+1 < len(tokens) && tokens[i+1].text == ":" && !tokens[i+1].literal {
+			current = tokens[i].text
+			i++
+			continue
+		}
+		if current != "" {
+			rules[current] = append(rules[current], tokens[i])
+		}
+	}
+	return rules
+}
@@ -0,0 +1,97 @@
+package diagnostics
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxSuggestions is the number of "did you mean" candidates reported per diagnostic.
+const MaxSuggestions = 3
+
+// Suggest returns the candidates closest to name, best first. Comparison is
+// case-insensitive and also treats camelCase as snake_case, so "addAutomation"
+// suggests "add_automation". Candidates that share a prefix or contain name
+// also qualify, which catches truncated names like "volume" for "volume_db".
+func Suggest(name string, candidates []string) []string {
+	target := normalizeName(name)
+	plain := strings.ToLower(strings.TrimSpace(name))
+	if target == "" {
+		return nil
+	}
+
+	type scored struct {
+		name     string
+		distance int
+	}
+	var matches []scored
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+
+		normalized := normalizeName(candidate)
+		distance := min(levenshtein(target, normalized), levenshtein(plain, strings.ToLower(candidate)))
+		limit := max(1, len(target)/3)
+		related := len(target) >= 3 && len(normalized) >= 3 && (strings.HasPrefix(normalized, target) || strings.HasPrefix(target, normalized) || strings.Contains(normalized, target))
+		if distance <= limit || related {
+			matches = append(matches, scored{candidate, distance})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var result []string
+	for i := 0; i < len(matches) && i < MaxSuggestions; i++ {
+		result = append(result, matches[i].name)
+	}
+	return result
+}
+
+// SnakeCase converts PascalCase or camelCase to snake_case (SetTrack -> set_track).
+func SnakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && name[i-1] != '_' {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(SnakeCase(strings.TrimSpace(name)))
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
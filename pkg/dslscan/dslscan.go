@@ -0,0 +1,190 @@
+// Package dslscan finds the structure of DSL source that doesn't depend on a
+// grammar: string literals, bracket depth, top-level statements and
+// arguments. The DSL parsers, their streaming variants and the diagnostics
+// checker share it, so they agree on where a statement ends.
+package dslscan
+
+import "strings"
+
+// Scanner tracks string literals and bracket depth over DSL source, one byte
+// at a time. The zero value is ready to use at the start of the source.
+type Scanner struct {
+	depth      int
+	inString   bool
+	escapeNext bool
+}
+
+// Next advances over the next byte of source. It reports whether the byte is
+// code, i.e. not part of a string literal (quotes included), and the bracket
+// depth it is at. Brackets are at the depth of the code around them.
+func (s *Scanner) Next(char byte) (int, bool) {
+	if s.escapeNext {
+		s.escapeNext = false
+		return s.depth, false
+	}
+	if s.inString {
+		switch char {
+		case '\\':
+			s.escapeNext = true
+		case '"':
+			s.inString = false
+		}
+		return s.depth, false
+	}
+
+	switch char {
+	case '"':
+		s.inString = true
+		return s.depth, false
+	case '(', '[', '{':
+		s.depth++
+		return s.depth - 1, true
+	case ')', ']', '}':
+		if s.depth > 0 {
+			s.depth--
+		}
+	}
+	return s.depth, true
+}
+
+// Walk calls visit for every byte of source outside string literals with the
+// bracket depth at that byte.
+func Walk(source string, visit func(i, depth int)) {
+	var scanner Scanner
+	for i := 0; i < len(source); i++ {
+		if depth, code := scanner.Next(source[i]); code {
+			visit(i, depth)
+		}
+	}
+}
+
+// Closing returns the index of the bracket closing the one at open, or -1 if
+// it is never closed.
+func Closing(source string, open int) int {
+	var scanner Scanner
+	for i := open; i < len(source); i++ {
+		depth, code := scanner.Next(source[i])
+		if code && depth == 0 && i > open && strings.IndexByte(")]}", source[i]) >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// SplitTopLevel splits s on sep, ignoring separators inside strings,
+// parentheses, brackets and braces. Parts are trimmed; empty parts are dropped.
+func SplitTopLevel(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	add := func(end int) {
+		if part := strings.TrimSpace(s[start:end]); part != "" {
+			parts = append(parts, part)
+		}
+		start = end + 1
+	}
+
+	Walk(s, func(i, depth int) {
+		if depth == 0 && s[i] == sep {
+			add(i)
+		}
+	})
+	add(len(s))
+
+	return parts
+}
+
+// Statement is a top-level statement of DSL source with its [Start, End)
+// offsets in the source. Surrounding whitespace is trimmed.
+type Statement struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Split splits source into top-level statements, as a Splitter does.
+func Split(source string, newlines bool) []Statement {
+	splitter := Splitter{Newlines: newlines}
+	statements := splitter.Write(source)
+	return append(statements, splitter.Close()...)
+}
+
+// Splitter splits DSL source that arrives in pieces, e.g. from a streaming
+// LLM response, into top-level statements. Statements end at a ";" outside
+// strings and brackets. With Newlines set they also end at such a newline,
+// unless the next line continues a method chain (starts with "."); a
+// statement ended by a newline is therefore only reported once the first
+// character of the next line has arrived, or on Close.
+type Splitter struct {
+	Newlines bool
+
+	source  string
+	start   int // Offset of the statement being received
+	pos     int // Next offset to scan
+	newline int // Offset of a newline awaiting the next line, if pending
+	pending bool
+	scanner Scanner
+}
+
+// Write appends a chunk of source and returns the statements it completes.
+func (s *Splitter) Write(chunk string) []Statement {
+	s.source += chunk
+
+	var statements []Statement
+	for ; s.pos < len(s.source); s.pos++ {
+		char := s.source[s.pos]
+
+		if s.pending {
+			switch char {
+			case ' ', '\t', '\r', '\n':
+				continue
+			case '.':
+				s.pending = false
+			default:
+				s.pending = false
+				statements = append(statements, s.flush(s.newline)...)
+			}
+		}
+
+		depth, code := s.scanner.Next(char)
+		if !code || depth > 0 {
+			continue
+		}
+		switch {
+		case char == ';':
+			statements = append(statements, s.flush(s.pos)...)
+		case char == '\n' && s.Newlines:
+			s.newline = s.pos
+			s.pending = true
+		}
+	}
+	return statements
+}
+
+// Close returns the statements left at the end of the source.
+func (s *Splitter) Close() []Statement {
+	var statements []Statement
+	if s.pending {
+		s.pending = false
+		statements = s.flush(s.newline)
+	}
+	return append(statements, s.flush(len(s.source))...)
+}
+
+// Source returns the source received so far.
+func (s *Splitter) Source() string {
+	return s.source
+}
+
+// flush returns the statement ending at end unless it is blank.
+func (s *Splitter) flush(end int) []Statement {
+	text := s.source[s.start:end]
+	start := s.start
+	s.start = min(end+1, len(s.source))
+
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil
+	}
+	offset := start + strings.Index(text, trimmed)
+	return []Statement{{Text: trimmed, Start: offset, End: offset + len(trimmed)}}
+}
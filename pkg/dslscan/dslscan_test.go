@@ -0,0 +1,68 @@
+package dslscan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner(t *testing.T) {
+	source := `a(b="x)\"", [1])`
+	var depths []int
+	Walk(source, func(i, depth int) { depths = append(depths, depth) })
+	// a ( b = " x ) \ " " , _ [ 1 ] )
+	assert.Equal(t, []int{0, 0, 1, 1, 1, 1, 1, 2, 1, 0}, depths)
+}
+
+func TestClosing(t *testing.T) {
+	source := `map(tracks, @gain(db=")")) .x()`
+	assert.Equal(t, 25, Closing(source, 3))
+	assert.Equal(t, 24, Closing(source, 17))
+	assert.Equal(t, -1, Closing("f(a, (b)", 1))
+}
+
+func TestSplitTopLevel(t *testing.T) {
+	assert.Equal(t, []string{"tracks", `@gain(db=-3, label="a,b")`, "[1, 2]"},
+		SplitTopLevel(` tracks, @gain(db=-3, label="a,b"), [1, 2], `, ','))
+}
+
+func TestSplit(t *testing.T) {
+	source := ` pattern(drum=kick, grid="x;--"); pattern(drum=snare) ;`
+	assert.Equal(t, []Statement{
+		{Text: `pattern(drum=kick, grid="x;--")`, Start: 1, End: 32},
+		{Text: "pattern(drum=snare)", Start: 34, End: 53},
+	}, Split(source, false))
+
+	source = "track(id=1)\n  .add_fx(fxname=\"ReaEQ\")\ntrack(\n  id=2\n)\n\n"
+	assert.Equal(t, []Statement{
+		{Text: "track(id=1)\n  .add_fx(fxname=\"ReaEQ\")", Start: 0, End: 37},
+		{Text: "track(\n  id=2\n)", Start: 38, End: 53},
+	}, Split(source, true))
+	assert.Len(t, Split(source, false), 1, "newlines only split with Newlines set")
+}
+
+func TestSplitter(t *testing.T) {
+	source := ` pattern(drum=kick, grid="x;--"); pattern(drum=snare) ; chord(symbol=C)`
+
+	var splitter Splitter
+	var statements []Statement
+	for i := 0; i < len(source); i++ {
+		completed := splitter.Write(source[i : i+1])
+		if source[i] == ';' && i < 20 {
+			assert.Empty(t, completed, "separator inside a string")
+		}
+		statements = append(statements, completed...)
+	}
+	assert.Len(t, statements, 2)
+	assert.Equal(t, []Statement{{Text: "chord(symbol=C)", Start: 56, End: 71}}, splitter.Close())
+	assert.Equal(t, Split(source, false)[:2], statements)
+	assert.Equal(t, source, splitter.Source())
+
+	// A statement ended by a newline waits for the next line
+	splitter = Splitter{Newlines: true}
+	assert.Empty(t, splitter.Write("track(id=1)\n  "))
+	assert.Empty(t, splitter.Write(".add_fx(fxname=\"ReaEQ\")\n"))
+	assert.Equal(t, []Statement{{Text: "track(id=1)\n  .add_fx(fxname=\"ReaEQ\")", Start: 0, End: 37}}, splitter.Write("t"))
+	assert.Empty(t, splitter.Write("rack(id=2)\n"))
+	assert.Equal(t, []Statement{{Text: "track(id=2)", Start: 38, End: 49}}, splitter.Close())
+}
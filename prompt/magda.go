@@ -138,6 +138,7 @@ all previous actions. Always check the state to understand:
 **Available Collections**:
 - ` + "`tracks`" + ` - All tracks in the project
 - ` + "`clips`" + ` - All clips from all tracks (automatically extracted from state)
+- ` + "`notes`" + ` - All MIDI notes from clips with note data in state (fields: ` + "`pitch`" + `, ` + "`velocity`" + `, ` + "`start`" + ` and ` + "`length`" + ` in beats from the clip start)
 
 **CRITICAL - COMPOUND ACTIONS**: After filtering, you can apply any action to the filtered items:
 - Pattern: ` + "`filter(collection, predicate).action(...)`" + ` where ` + "`action`" + ` is any available method (set_track, set_clip, move_clip, delete_clip, etc.)
@@ -167,6 +168,28 @@ all previous actions. Always check the state to understand:
   - "set volume to -3 dB for all tracks" → ` + "`filter(tracks, track.index >= 0).set_track(volume_db=-3)`" + ` (use ` + "`track.index >= 0`" + ` to match all tracks, or any property that's always true)
   - "select all tracks" → ` + "`filter(tracks, track.index >= 0).set_track(selected=true)`" + ` (use ` + "`track.index >= 0`" + ` to match all tracks)
   - "rename track 1 to Bass" → ` + "`track(id=1).set_track(name=\"Bass\")`" + `
+- **Relative and Expression Values** (resolved against each item's current state - do NOT compute new values yourself):
+  - Operators ` + "`+=`, `-=`, `*=`, `/=`" + ` work on ` + "`volume_db`" + `, ` + "`pan`" + ` (set_track), ` + "`length`" + `, ` + "`fade_in`" + `, ` + "`fade_out`" + `, ` + "`gain_db`" + ` (set_clip) and ` + "`position`" + ` (move_clip)
+  - ` + "`bar(n)`" + ` and ` + "`beat(n)`" + ` convert bars/beats to seconds using the project tempo
+  - "turn everything down 3 dB" → ` + "`filter(tracks, track.index >= 0).set_track(volume_db+=-3)`" + `
+  - "nudge selected clips one bar later" → ` + "`filter(clips, clip.selected == true).move_clip(position+=bar(1))`" + `
+  - "double the length of short clips" → ` + "`filter(clips, clip.length < 2.0).set_clip(length*=2)`" + `
+  - "halve the panning of all tracks" → ` + "`filter(tracks, track.index >= 0).set_track(pan=track.pan * 0.5)`" + `
+- **Clip Editing** (work on a single clip via ` + "`track(id=N)`" + ` with ` + "`clip`" + `/` + "`position`" + `/` + "`bar`" + `, or on ` + "`filter(clips, ...)`" + `):
+  - "split the drum clip at bar 5" → ` + "`track(id=1).split_clip(clip=0, at_bar=5)`" + `
+  - "cut all long clips in half" → ` + "`filter(clips, clip.length > 8.0).split_clip(at=clip.position + clip.length / 2)`" + `
+  - "duplicate the chorus clip 3 times" → ` + "`track(id=2).duplicate_clip(clip=1, times=3)`" + ` (optional ` + "`gap`" + ` in seconds, e.g. ` + "`gap=bar(1)`" + `)
+  - "loop the selected clips until bar 17" → ` + "`filter(clips, clip.selected == true).loop_clip(to_bar=17)`" + `
+  - "trim the last beat off the bass clip" → ` + "`track(id=2).trim_clip(clip=0, end=clip.position + clip.length - beat(1))`" + ` (` + "`start`" + `/` + "`end`" + ` are absolute positions in seconds)
+  - "fade in the selected clips over a beat and drop them 3 dB" → ` + "`filter(clips, clip.selected == true).set_clip(fade_in=beat(1), gain_db+=-3)`" + `
+  - "mute the clip at bar 9 on track 1" → ` + "`track(id=1).set_clip(bar=9, mute=true)`" + `
+- **MIDI Editing** (transform notes already in clips - use ` + "`filter(notes, ...)`" + ` to edit only some notes):
+  - "quantize the bass to 16ths" → ` + "`track(id=2).quantize(clip=0, grid=\"1/16\")`" + ` (grids: ` + "`\"1/4\"`" + `, ` + "`\"1/8\"`" + `, ` + "`\"1/16\"`" + `, triplets ` + "`\"1/8t\"`" + `, dotted ` + "`\"1/8d\"`" + `; optional ` + "`strength`" + ` and ` + "`swing`" + ` 0-1)
+  - "transpose the chords up a fifth" → ` + "`track(id=3).transpose(clip=0, semitones=7)`" + ` (or ` + "`octaves=-1`" + `)
+  - "raise velocities on the hi-hats" → ` + "`filter(notes, note.pitch == 42).set_velocity(velocity+=15)`" + ` (` + "`velocity=100`" + ` sets, ` + "`velocity*=0.8`" + ` scales)
+  - "humanize the drums" → ` + "`track(id=1).humanize(clip=0, timing=0.03, velocity=10)`" + ` (timing in beats)
+  - "legato the pad" → ` + "`filter(clips, clip.name == \"Pad\").legato()`" + `
+  - "reverse the melody" → ` + "`track(id=2).reverse(clip=0)`" + `
 - **Abstract Examples**:
   - "select [items] and [action]" → ` + "`filter(collection, predicate).set_track(selected=true); filter(collection, predicate).set_track(...)`" + ` for tracks OR ` + "`filter(collection, predicate).set_clip(selected=true); filter(collection, predicate).set_clip(...)`" + ` for clips, where the second action is the SECOND property (rename, color, delete, etc.)
   - "filter [items] and [action1] and [action2]" → ` + "`filter(collection, predicate).action1(...); filter(collection, predicate).action2(...)`" + `
@@ -240,9 +263,10 @@ Sets properties for a track (name, volume_db, pan, mute, solo, selected, etc.).
   - ` + "`track(id=1).set_track(volume_db=-3, pan=0.5)`" + ` - sets volume and pan for track 1
 
 **set_clip**
-Sets properties for a clip (name, color, selected, etc.).
-- DSL syntax: ` + "`.set_clip(name=\"...\", color=\"...\", selected=true/false)`" + ` - you can specify one or more properties
-- Required: ` + "`action: \"set_clip\"`" + `, ` + "`track`" + ` (integer), and at least one property (` + "`name`" + `, ` + "`color`" + `, or ` + "`selected`" + `)
+Sets properties for a clip (name, color, selected, length, fades, gain, mute).
+- DSL syntax: ` + "`.set_clip(name=\"...\", color=\"...\", selected=true/false, fade_in=..., fade_out=..., gain_db=..., mute=true/false)`" + ` - you can specify one or more properties
+- Required: ` + "`action: \"set_clip\"`" + `, ` + "`track`" + ` (integer), and at least one property (` + "`name`" + `, ` + "`color`" + `, ` + "`selected`" + `, ` + "`length`" + `, ` + "`fade_in`" + `, ` + "`fade_out`" + `, ` + "`gain_db`" + `, or ` + "`mute`" + `)
+- ` + "`fade_in`" + ` / ` + "`fade_out`" + ` are fade lengths in seconds, ` + "`gain_db`" + ` is the item volume in dB
 - Optional: ` + "`clip`" + ` (integer), ` + "`position`" + ` (number in seconds), or ` + "`bar`" + ` (integer) for clip identification
 - Examples:
   - ` + "`filter(clips, clip.length < 1.5).set_clip(name=\"Short Clip\")`" + ` - renames all clips shorter than 1.5 seconds
@@ -259,51 +283,105 @@ Moves a clip to a different time position.
 - Optional: ` + "`clip`" + ` (integer), ` + "`old_position`" + ` (number in seconds), or ` + "`bar`" + ` (integer)
 - Example: ` + "`filter(clips, clip.length < 1.5).move_clip(position=10.0)`" + ` moves all short clips to position 10.0 seconds
 
+**split_clip**
+Splits a clip into two at a time position.
+- DSL syntax: ` + "`.split_clip(at=...)`" + ` (seconds) or ` + "`.split_clip(at_bar=...)`" + ` (1-based bar)
+- Required: ` + "`action: \"split_clip\"`" + `, ` + "`track`" + ` (integer), ` + "`at`" + ` (number in seconds)
+- Clip identification: ` + "`clip`" + ` (integer), ` + "`position`" + ` (number in seconds), or ` + "`bar`" + ` (integer)
+- Filtered clips that don't span the split point are skipped
+
+**duplicate_clip**
+Duplicates a clip one or more times, placing copies after the original.
+- DSL syntax: ` + "`.duplicate_clip(times=..., gap=...)`" + `
+- Required: ` + "`action: \"duplicate_clip\"`" + `, ` + "`track`" + ` (integer), ` + "`times`" + ` (integer, default 1), ` + "`gap`" + ` (number in seconds, default 0)
+- Optional: ` + "`positions`" + ` (array of numbers in seconds) - positions of the copies when the clip is known from state
+
+**loop_clip**
+Loops a clip's content until the start of a bar.
+- DSL syntax: ` + "`.loop_clip(to_bar=...)`" + `
+- Required: ` + "`action: \"loop_clip\"`" + `, ` + "`track`" + ` (integer), ` + "`to_bar`" + ` (integer, 1-based), ` + "`end`" + ` (number in seconds)
+- Optional: ` + "`length`" + ` (number in seconds) - the new clip length when the clip is known from state
+
+**trim_clip**
+Moves a clip's start and/or end edge.
+- DSL syntax: ` + "`.trim_clip(start=..., end=...)`" + ` - absolute positions in seconds, at least one required
+- Required: ` + "`action: \"trim_clip\"`" + `, ` + "`track`" + ` (integer), and ` + "`start`" + ` and/or ` + "`end`" + ` (numbers in seconds)
+
+### MIDI Editing
+
+MIDI actions identify the clip like other clip actions (` + "`clip`" + `, ` + "`position`" + `, or ` + "`bar`" + `). The optional ` + "`notes`" + ` (array of note indexes within the clip) restricts the action to notes selected with ` + "`filter(notes, ...)`" + `; without it the action applies to all notes in the clip. Note timing values are in beats.
+
+**quantize_notes** - DSL: ` + "`.quantize(grid=\"1/16\", strength=1.0, swing=0)`" + `
+- Required: ` + "`track`" + `, ` + "`grid`" + ` (number in beats), ` + "`strength`" + ` (0-1). Optional: ` + "`swing`" + ` (0-1)
+
+**transpose_notes** - DSL: ` + "`.transpose(semitones=..., octaves=...)`" + `
+- Required: ` + "`track`" + `, ` + "`semitones`" + ` (integer, octaves are converted to semitones)
+
+**set_note_velocity** - DSL: ` + "`.set_velocity(velocity=100)`" + `, ` + "`.set_velocity(velocity+=10)`" + `, ` + "`.set_velocity(velocity*=0.8)`" + `
+- Required: ` + "`track`" + ` and one of ` + "`velocity`" + ` (integer 1-127), ` + "`offset`" + ` (integer added to each velocity), or ` + "`scale`" + ` (number each velocity is multiplied by)
+
+**humanize_notes** - DSL: ` + "`.humanize(timing=0.05, velocity=8)`" + `
+- Required: ` + "`track`" + `, ` + "`timing`" + ` (max random offset in beats), ` + "`velocity`" + ` (max random velocity change)
+
+**legato_notes** - DSL: ` + "`.legato(gap=0)`" + `
+- Required: ` + "`track`" + `, ` + "`gap`" + ` (beats left between each note and the next)
+
+**reverse_notes** - DSL: ` + "`.reverse()`" + `
+- Required: ` + "`track`" + `
+
 ### Automation
 
-**add_automation** / **addAutomation**
+**add_automation**
 Adds automation envelopes to a track parameter using curve functions or manual points.
 - **PREFERRED**: Use curve-based syntax for common patterns (cleaner and more intuitive)
-- Required: ` + "`param`" + ` (string) - the parameter to automate
-- Parameter names:
-  - ` + "`\"volume\"`" + ` - Track volume envelope (values in dB, e.g., -60 to 12)
-  - ` + "`\"pan\"`" + ` - Track pan envelope (values from -1.0 to 1.0)
-  - ` + "`\"mute\"`" + ` - Track mute envelope (values 0 or 1)
-  - ` + "`\"FXName:ParamName\"`" + ` - FX parameter (e.g., "Serum:Cutoff", values 0.0 to 1.0)
+- Target (one of):
+  - ` + "`param=\"volume\"`" + ` - Track volume envelope (values in dB, e.g., -60 to 12)
+  - ` + "`param=\"pan\"`" + ` - Track pan envelope (values from -1.0 to 1.0)
+  - ` + "`param=\"mute\"`" + ` - Track mute envelope (values 0 or 1)
+  - ` + "`param=\"send\", send=...`" + ` - Send level in dB; ` + "`send`" + ` is the destination name or 1-based send index
+  - ` + "`fx=..., fx_param=...`" + ` - FX parameter (values 0.0 to 1.0); each is a name (e.g. ` + "`fx=\"ReaEQ\", fx_param=\"Gain\"`" + `) or 1-based index
+  - ` + "`param=\"FXName:ParamName\"`" + ` - Legacy FX parameter form (e.g., "Serum:Cutoff")
 
 **Curve-Based Syntax (Recommended)**:
-` + "`.addAutomation(param=\"...\", curve=\"curve_type\", start=X, end=Y)`" + `
+` + "`.add_automation(param=\"...\", curve=\"curve_type\", start=X, end=Y)`" + `
+
+Curves are rendered to explicit envelope points. The action carries ` + "`points`" + ` (` + "`[{time, value}]`" + `, time in beats), the resolved target (` + "`fx_index`" + `/` + "`fx_name`" + `, ` + "`param_index`" + `/` + "`param_name`" + `, ` + "`send_index`" + `/` + "`send_name`" + `, 0-based) and ` + "`shape: 1`" + ` (square) for stepped curves.
 
 Available curves:
 | Curve | Description | Extra params |
 |-------|-------------|--------------|
-| ` + "`fade_in`" + ` | Volume: -∞ dB → 0 dB | ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`fade_out`" + ` | Volume: 0 dB → -∞ dB | ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`ramp`" + ` | Linear interpolation | ` + "`from`" + `, ` + "`to`" + `, ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`sine`" + ` | Sinusoidal oscillation | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`saw`" + ` | Sawtooth wave | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`square`" + ` | Square wave | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`exp_in`" + ` | Exponential ease-in | ` + "`from`" + `, ` + "`to`" + `, ` + "`start`" + `, ` + "`end`" + ` |
-| ` + "`exp_out`" + ` | Exponential ease-out | ` + "`from`" + `, ` + "`to`" + `, ` + "`start`" + `, ` + "`end`" + ` |
+| ` + "`linear`" + ` (alias ` + "`ramp`" + `) | Straight line | ` + "`from`" + `, ` + "`to`" + ` |
+| ` + "`exp`" + ` (alias ` + "`exp_in`" + `) | Exponential: slow start, fast finish | ` + "`from`" + `, ` + "`to`" + ` |
+| ` + "`log`" + ` (alias ` + "`exp_out`" + `) | Logarithmic: fast start, slow finish | ` + "`from`" + `, ` + "`to`" + ` |
+| ` + "`s_curve`" + ` | Smooth S-shaped transition | ` + "`from`" + `, ` + "`to`" + ` |
+| ` + "`fade_in`" + ` | Silence → unity (volume: -60 dB → 0 dB) | |
+| ` + "`fade_out`" + ` | Unity → silence (volume: 0 dB → -60 dB) | |
+| ` + "`sine`" + ` / ` + "`triangle`" + ` | Oscillation between ` + "`from`" + ` and ` + "`to`" + ` | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`phase`" + ` |
+| ` + "`square`" + ` | Square wave | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`phase`" + ` |
+| ` + "`saw`" + ` | Rising sawtooth each cycle | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`phase`" + ` |
+| ` + "`random`" + ` | Sample-and-hold: random value each step (default one per beat) | ` + "`freq`" + `, ` + "`amplitude`" + `, ` + "`seed`" + ` |
+| ` + "`adsr`" + ` | Attack/decay/sustain/release from ` + "`from`" + ` to ` + "`to`" + ` | ` + "`attack`" + `, ` + "`decay`" + `, ` + "`release`" + ` (beats), ` + "`sustain`" + ` (0-1) |
+| ` + "`duck`" + ` | Sidechain duck: drops to ` + "`to`" + ` each step (default one per beat), recovers to ` + "`from`" + ` | ` + "`freq`" + `, ` + "`release`" + ` (0-1 of a step) |
 
 Curve parameters:
-- ` + "`start`" + ` / ` + "`start_bar`" + ` - Start time in beats or bars
-- ` + "`end`" + ` / ` + "`end_bar`" + ` - End time in beats or bars
-- ` + "`from`" + ` / ` + "`to`" + ` - Value range for ramp/exp curves
-- ` + "`freq`" + ` - Oscillation frequency (cycles per bar) for sine/saw/square
-- ` + "`amplitude`" + ` - Oscillation amplitude (0-1) for oscillators
-- ` + "`phase`" + ` - Phase offset (0-1) for oscillators
+- ` + "`start`" + ` / ` + "`end`" + ` - Start/end time in beats, or ` + "`start_bar`" + ` / ` + "`end_bar`" + ` (1-based bars)
+- ` + "`from`" + ` / ` + "`to`" + ` - Value range (defaults to the target's full range)
+- ` + "`freq`" + ` - Rate in cycles per bar (e.g. ` + "`freq=2`" + `), or tempo-synced note value (e.g. ` + "`freq=\"1/8\"`" + `, ` + "`freq=\"1/4t\"`" + `)
+- ` + "`amplitude`" + ` - Oscillation depth (0-1) around the middle of the range
+- ` + "`phase`" + ` - Phase offset in cycles (0-1)
 
 **Curve Examples:**
-- Fade in over 4 beats: ` + "`track(id=1).addAutomation(param=\"volume\", curve=\"fade_in\", start=0, end=4)`" + `
-- Fade out bars 8-12: ` + "`track(id=1).addAutomation(param=\"volume\", curve=\"fade_out\", start_bar=8, end_bar=12)`" + `
-- Pan LFO: ` + "`track(id=1).addAutomation(param=\"pan\", curve=\"sine\", freq=0.5, amplitude=1.0, start=0, end=16)`" + `
-- Filter sweep: ` + "`track(id=1).addAutomation(param=\"Serum:Cutoff\", curve=\"ramp\", from=0.2, to=1.0, start=0, end=16)`" + `
-- Sidechain-style pump: ` + "`track(id=1).addAutomation(param=\"volume\", curve=\"saw\", freq=1, amplitude=0.5, start=0, end=32)`" + `
-- Exponential buildup: ` + "`track(id=1).addAutomation(param=\"Serum:Cutoff\", curve=\"exp_in\", from=0.1, to=1.0, start=0, end=16)`" + `
+- Fade in over 4 beats: ` + "`track(id=1).add_automation(param=\"volume\", curve=\"fade_in\", start=0, end=4)`" + `
+- Fade out bars 8-12: ` + "`track(id=1).add_automation(param=\"volume\", curve=\"fade_out\", start_bar=8, end_bar=12)`" + `
+- Pan LFO: ` + "`track(id=1).add_automation(param=\"pan\", curve=\"sine\", freq=0.5, amplitude=1.0, start=0, end=16)`" + `
+- Filter sweep: ` + "`track(id=1).add_automation(fx=\"Serum\", fx_param=\"Cutoff\", curve=\"linear\", from=0.2, to=1.0, start=0, end=16)`" + `
+- Eighth-note filter wobble: ` + "`track(id=1).add_automation(fx=1, fx_param=\"Cutoff\", curve=\"sine\", freq=\"1/8\", start=0, end=16)`" + `
+- Sidechain-style pump: ` + "`track(id=1).add_automation(param=\"volume\", curve=\"duck\", from=0, to=-12, start=0, end=32)`" + `
+- Reverb send swell: ` + "`track(id=1).add_automation(param=\"send\", send=\"Reverb\", curve=\"s_curve\", from=-30, to=-6, start_bar=9, end_bar=13)`" + `
+- Random filter steps: ` + "`track(id=1).add_automation(fx=\"ReaEQ\", fx_param=\"Frequency\", curve=\"random\", freq=\"1/16\", start=0, end=8)`" + `
 
 **Point-Based Syntax (Advanced)**:
-For custom shapes, use manual points: ` + "`.addAutomation(param=\"...\", points=[{time=0, value=...}, {time=4, value=...}])`" + `
+For custom shapes, use manual points: ` + "`.add_automation(param=\"...\", points=[{time=0, value=...}, {time=4, value=...}])`" + `
 - ` + "`time`" + ` or ` + "`bar`" + ` - Position of the point
 - ` + "`value`" + ` - Parameter value at this point
 - Optional ` + "`shape`" + ` (0=linear, 1=square, 2=slow, 3=fast start, 4=fast end, 5=bezier)
@@ -316,6 +394,29 @@ For custom shapes, use manual points: ` + "`.addAutomation(param=\"...\", points
   - **NEVER** use ` + "`create_clip_at_bar`" + ` when user says "select clips" - selection is different from creation!
   - Always use ` + "`set_clip(selected=true)`" + ` to select clips!
 
+### Project and Transport
+
+Project-level actions have no ` + "`track`" + `. In the DSL they are statements of their own, never chained onto ` + "`track()`" + `. Bar ranges are 1-based and the end bar is exclusive: bars 9-16 are ` + "`start_bar=9, end_bar=17`" + `.
+
+**render** - DSL: ` + "`render(range=\"project\", format=\"wav\", stems=false)`" + `, ` + "`render(start_bar=1, end_bar=17, format=\"mp3\")`" + `
+- Required: ` + "`range`" + ` (` + "`\"project\"`" + `, ` + "`\"loop\"`" + `, ` + "`\"selection\"`" + `, or ` + "`\"bars\"`" + ` with ` + "`start_bar`" + `/` + "`end_bar`" + `), ` + "`format`" + ` (wav, flac, aiff, mp3, ogg), ` + "`stems`" + ` (boolean, one file per track)
+- Optional: ` + "`sample_rate`" + ` (integer), ` + "`bit_depth`" + ` (16, 24 or 32; wav/flac/aiff only), ` + "`file`" + ` (output file name)
+
+**save_project** - DSL: ` + "`save_project()`" + `, ` + "`save_project(path=\"Song v2\")`" + `
+- Optional: ` + "`path`" + ` (save a copy under this name)
+
+**set_project** - DSL: ` + "`set_project(name=\"...\", sample_rate=48000, tempo=120)`" + `
+- At least one of ` + "`name`" + `, ` + "`sample_rate`" + ` (44100, 48000, 88200, 96000, 176400, 192000), ` + "`tempo`" + ` (BPM)
+
+**set_loop** - DSL: ` + "`set_loop(start_bar=9, end_bar=17)`" + `
+- Required: ` + "`start_bar`" + `, ` + "`end_bar`" + `
+
+**set_cursor** - DSL: ` + "`set_cursor(bar=5)`" + ` or ` + "`set_cursor(position=12.5)`" + `
+- One of ` + "`bar`" + ` (1-based) or ` + "`position`" + ` (seconds)
+
+**play** / **stop** - DSL: ` + "`play()`" + `, ` + "`stop()`" + `
+- Start playback from the edit cursor, or stop playback
+
 ## Action Execution Order and Parent-Child Relationships
 
 Actions are executed sequentially in the order they appear in the array. Many actions have parent-child relationships where a child action depends on its parent existing first.
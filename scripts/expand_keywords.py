@@ -1,7 +1,7 @@
 #!/usr/bin/env python3
 """
 Simple keyword expansion script using OpenAI SDK directly.
-Expands DAW and Arranger keywords with synonyms, translations, and slang.
+Expands DAW, Arranger, Drummer, JSFX and Mix keywords with synonyms, translations, and slang.
 """
 
 import json
@@ -134,27 +134,64 @@ def main():
         "roman", "scale", "harmony", "sequence", "pattern",
         "major", "minor", "diminished", "augmented",
         "triad", "seventh", "ninth",
-        "arpeggio", "bassline", "riff", "hook", "groove", "lick",
+        "arpeggio", "bassline", "bass line", "walking bass", "riff", "hook", "groove", "lick",
         "phrase", "motif", "ostinato", "fill", "break",
         "C", "D", "E", "F", "G", "A", "B",
         "sharp", "flat", "natural",
         "pentatonic", "dorian", "mixolydian",
         "sus2", "sus4", "add9",
     ]
+
+    drummer_keywords = [
+        "drum", "drums", "beat", "kick", "snare", "hi-hat", "hihat", "hat",
+        "tom", "cymbal", "crash", "ride", "clap", "percussion",
+        "pattern", "groove", "fill", "break", "breakbeat", "backbeat",
+        "four on the floor", "shuffle", "ghost note", "rimshot",
+        "shaker", "tambourine", "cowbell", "808", "rhythm",
+    ]
+
+    jsfx_keywords = [
+        "jsfx", "js effect", "js plugin", "eel2", "eel",
+        "custom effect", "custom plugin", "custom fx", "own effect", "own plugin",
+        "dsp code", "effect code", "plugin code",
+    ]
+
+    mix_keywords = [
+        "analyze", "analyse", "analysis", "mix analysis", "analyze my mix", "analyse my mix",
+        "mix feedback", "mixing advice", "mix check", "muddy", "muddiness", "mud", "boomy", "boxy",
+        "harsh", "harshness", "sibilance", "sibilant", "masking", "frequency masking",
+        "resonance", "resonant", "ringing", "sit better", "sits better", "fix the mix",
+        "balance the mix", "loudness", "lufs", "phase issue", "phase problem", "thin sounding",
+    ]
     
     print(f"🔍 Expanding DAW keywords ({len(daw_keywords)} base keywords)...")
     expanded_daw = expand_keywords(client, daw_keywords, "DAW operations and REAPER-specific terms")
     
     print(f"🔍 Expanding Arranger keywords ({len(arranger_keywords)} base keywords)...")
     expanded_arranger = expand_keywords(client, arranger_keywords, "musical content and music theory terms")
+
+    print(f"🔍 Expanding Drummer keywords ({len(drummer_keywords)} base keywords)...")
+    expanded_drummer = expand_keywords(client, drummer_keywords, "drums, percussion and rhythm terms")
+
+    print(f"🔍 Expanding JSFX keywords ({len(jsfx_keywords)} base keywords)...")
+    expanded_jsfx = expand_keywords(client, jsfx_keywords, "writing custom audio effects and plugin code")
+
+    print(f"🔍 Expanding Mix keywords ({len(mix_keywords)} base keywords)...")
+    expanded_mix = expand_keywords(client, mix_keywords, "mix analysis and sound problems")
     
     # Combine base + expanded (deduplicate)
     all_daw = deduplicate(daw_keywords + expanded_daw)
     all_arranger = deduplicate(arranger_keywords + expanded_arranger)
+    all_drummer = deduplicate(drummer_keywords + expanded_drummer)
+    all_jsfx = deduplicate(jsfx_keywords + expanded_jsfx)
+    all_mix = deduplicate(mix_keywords + expanded_mix)
     
     result = {
         "daw": all_daw,
-        "arranger": all_arranger
+        "arranger": all_arranger,
+        "drummer": all_drummer,
+        "jsfx": all_jsfx,
+        "mix": all_mix
     }
     
     # Output as JSON
@@ -162,13 +199,16 @@ def main():
     print("\n" + output)
     
     # Also write to file
-    output_file = "expanded_keywords.json"
+    output_file = "agents/coordination/expanded_keywords.json"
     with open(output_file, "w", encoding="utf-8") as f:
         f.write(output)
     print(f"\n💾 Also saved to {output_file}")
     
     print(f"\n✅ Expanded to {len(all_daw)} DAW keywords (from {len(daw_keywords)}) "
-          f"and {len(all_arranger)} Arranger keywords (from {len(arranger_keywords)})")
+          f"{len(all_arranger)} Arranger keywords (from {len(arranger_keywords)}) "
+          f"{len(all_drummer)} Drummer keywords (from {len(drummer_keywords)}) "
+          f"{len(all_jsfx)} JSFX keywords (from {len(jsfx_keywords)}) "
+          f"and {len(all_mix)} Mix keywords (from {len(mix_keywords)})")
 
 
 if __name__ == "__main__":